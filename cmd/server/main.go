@@ -1,19 +1,286 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"repello/internal/analytics"
 	"repello/internal/api"
+	"repello/internal/clearing"
+	"repello/internal/enrich"
+	"repello/internal/gateway"
+	"repello/internal/liquidity"
+	"repello/internal/marketmaker"
 	"repello/internal/matching"
 	"repello/internal/metrics"
+	"repello/internal/risk"
+	"repello/internal/sandbox"
+	"repello/internal/server"
+	"repello/internal/surveillance"
+	"repello/internal/tape"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
+	restEnabled := flag.Bool("rest", true, "serve the REST API over TCP")
+	addr := flag.String("addr", ":8080", "TCP address for the REST API")
+	unixSocket := flag.String("unix-socket", "", "also serve the REST API on this Unix domain socket path")
+	reusePort := flag.Bool("reuseport", false, "bind TCP with SO_REUSEPORT so a new process can take over the port")
+	pidfile := flag.String("pidfile", "", "PID file used to hand over from the previous process on a rolling restart")
+	sandboxEnabled := flag.Bool("sandbox", false, "serve a paper-trading copy of the API under /sandbox")
+	sandboxLedger := flag.String("sandbox-ledger", "", "append the sandbox's balance ledger and snapshots to this file, as JSON lines")
+	botSymbols := flag.String("liquidity-bot", "", "quote synthetic liquidity on these symbols, as SYMBOL:PRICE:TICK[,...]")
+	botTarget := flag.String("liquidity-bot-target", "live", "engine the liquidity bot quotes on: live or sandbox")
+	creditReplenish := flag.Bool("credit-replenish-on-fill", false, "return firm credit as orders fill, so credit limits cap open orders only")
+	autoReduceOnly := flag.Bool("auto-reduce-only", false, "switch an account to reduce-only when its trade breaches a position limit")
+	makerFeeBps := flag.Int64("maker-fee-bps", 0, "maker fee in basis points of notional; negative pays a rebate")
+	takerFeeBps := flag.Int64("taker-fee-bps", 0, "taker fee in basis points of notional")
+	largeTrade := flag.Int64("large-trade-notional", 0, "flag trades with at least this notional as LARGE_TRADE; 0 disables")
+	clockSkew := flag.Duration("clock-skew-tolerance", 0, "reject orders whose transact_time is further than this from the server clock; 0 disables")
+	clockRestamp := flag.Bool("clock-skew-restamp", false, "replace out-of-tolerance transact times with the server time instead of rejecting")
+	orderRate := flag.Int("order-rate-limit", 0, "max order entry requests per second per account, across all protocols; 0 is unlimited")
+	maxInFlight := flag.Int("max-inflight-per-client", 0, "max order entry requests one client IP may have in progress on the REST API; 0 is unlimited")
+	symbolAliases := flag.String("symbol-aliases", "", "accept other spellings of symbols, as ALIAS=SYMBOL[,...], e.g. BTC-USD=BTCUSD")
+	speedBumps := flag.String("speed-bump", "", "delay aggressive orders on these symbols, as SYMBOL:DELAY[,...], e.g. BTCUSD:350us")
+	idStrategy := flag.String("order-id-strategy", "uuid", "how to generate IDs for orders submitted without one: uuid, sequential or snowflake")
+	idNode := flag.Int64("order-id-node", 0, "this server's node number for snowflake order IDs, 0-1023")
+	sessionClose := flag.String("session-close", "00:00", "time of day the trading session closes and DAY orders are cancelled, as HH:MM")
+	sessionZone := flag.String("session-timezone", "UTC", "IANA time zone of -session-close, e.g. America/New_York")
+	shadowChecks := flag.String("shadow-checks", "", "run these pre-trade checks in shadow mode, counting and logging what they would reject without enforcing it: clock-skew, positions, credit")
+	symbolConfig := flag.String("symbol-config", "", "JSON file of per-symbol matching overrides, reloaded on SIGHUP")
+	warmupSymbols := flag.String("warmup-symbols", "", "create books for these symbols at startup, as SYMBOL[,...]; configured symbols always are")
+	bookCapacity := flag.Int("warmup-book-capacity", 0, "pre-size each book's order index for this many resting orders; 0 lets it grow")
+	warmupOrders := flag.Int("warmup-orders", 0, "match this many synthetic orders on a scratch engine before serving; 0 skips")
+	lpSample := flag.Duration("lp-sample-interval", time.Second, "how often books are sampled for liquidity-provider time at the touch; 0 disables")
+	tapeRetain := flag.Int("tape-retain", tape.DefaultRetain, "fills each symbol's trade tape keeps; 0 disables the tape")
+	averageWindows := flag.String("average-windows", "1m,5m,15m", "windows to compute each symbol's VWAP and TWAP over, as DURATION[,...]; empty disables")
+	clearingURL := flag.String("clearing-url", "", "submit every trade to the clearing system at this URL, as a JSON POST; empty disables")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "how long listeners may take to drain on shutdown")
+	flag.Parse()
+
 	m := metrics.NewMetrics()
+	lifecycle := server.NewLifecycle(m)
 	engine := matching.NewEngine(m)
-	server := api.NewAPIServer(":8080", engine, m)
+	engine.AddTradeStage(enrich.Fees{MakerBps: *makerFeeBps, TakerBps: *takerFeeBps})
+	if *largeTrade > 0 {
+		engine.AddTradeStage(enrich.LargeTrade{Threshold: *largeTrade})
+	}
+	session, err := matching.ParseSessionClose(*sessionClose, *sessionZone)
+	if err != nil {
+		log.Fatalf("invalid -session-close: %s", err)
+	}
+	engine.SetSessionClose(session)
+	bumps, err := matching.ParseSpeedBumps(*speedBumps)
+	if err != nil {
+		log.Fatalf("invalid -speed-bump: %s", err)
+	}
+	for symbol, delay := range bumps {
+		engine.SetSpeedBump(symbol, delay)
+	}
+	if *symbolConfig != "" {
+		configs, err := matching.ReadSymbolConfigs(*symbolConfig)
+		if err == nil {
+			err = engine.ConfigureSymbols(configs)
+		}
+		if err != nil {
+			log.Fatalf("invalid -symbol-config: %s", err)
+		}
+		go reloadSymbolConfigs(engine, *symbolConfig)
+	}
+	alerts := surveillance.NewAlertStore()
+	engine.AddEventListener(surveillance.NewAnalyzer(surveillance.DefaultConfig(), alerts))
+	credit := risk.NewCreditLimits(risk.CreditConfig{ReplenishOnFill: *creditReplenish})
+	positions := risk.NewPositionMonitor(risk.PositionConfig{AutoReduceOnly: *autoReduceOnly}, credit)
+	shadowed := make(map[string]bool)
+	for _, name := range splitList(*shadowChecks) {
+		switch name {
+		case "clock-skew", "positions", "credit":
+			shadowed[name] = true
+		default:
+			log.Fatalf("invalid -shadow-checks: unknown check %q", name)
+		}
+	}
+	addCheck := func(name string, c matching.PreTradeCheck) {
+		if shadowed[name] {
+			engine.AddShadowCheck(name, c)
+		} else {
+			engine.AddPreTradeCheck(c)
+		}
+	}
+	if *clockSkew > 0 {
+		addCheck("clock-skew", matching.NewClockGuard(*clockSkew, *clockRestamp))
+	}
+	// Positions first: reduce-only may shrink an order before credit is reserved
+	addCheck("positions", positions)
+	addCheck("credit", credit)
+	engine.AddEventListener(credit)
+	engine.SetFirmDirectory(credit)
+	engine.AddEventListener(positions)
+	analyticsConfig := analytics.DefaultConfig()
+	analyticsConfig.AverageWindows, err = analytics.ParseWindows(*averageWindows)
+	if err != nil {
+		log.Fatalf("invalid -average-windows: %s", err)
+	}
+	dashboards := analytics.NewCollector(analyticsConfig, credit)
+	engine.AddEventListener(dashboards)
+	var trades *tape.Tape
+	if *tapeRetain > 0 {
+		trades = tape.New(*tapeRetain)
+		engine.AddEventListener(trades)
+	}
+	var outbox *clearing.Outbox
+	if *clearingURL != "" {
+		outbox = clearing.NewOutbox(clearing.DefaultConfig(), clearing.NewHTTPClearer(*clearingURL))
+		engine.AddTradeStage(outbox)
+		engine.AddEventListener(outbox)
+	}
+	var lp *liquidity.Tracker
+	var obligations *liquidity.ObligationMonitor
+	if *lpSample > 0 {
+		lp = liquidity.NewTracker(engine, *lpSample)
+		obligations = liquidity.NewObligationMonitor(engine, lp, alerts)
+	}
+	aliases, err := gateway.ParseAliases(*symbolAliases)
+	if err != nil {
+		log.Fatalf("invalid -symbol-aliases: %s", err)
+	}
+	ids, err := gateway.NewIDGenerator(*idStrategy, *idNode)
+	if err != nil {
+		log.Fatalf("invalid -order-id-strategy: %s", err)
+	}
+	gw := gateway.New(engine, gateway.Config{
+		Normalizer:         gateway.Normalizer{SymbolAliases: aliases},
+		IDs:                ids,
+		Metrics:            m,
+		MaxOrdersPerSecond: *orderRate,
+	})
+	restAPI := api.NewAPIServer(gw, m, alerts, credit, positions)
+	var sb *sandbox.Sandbox
+	if *sandboxEnabled {
+		cfg := sandbox.DefaultConfig()
+		if *sandboxLedger != "" {
+			journal, err := os.OpenFile(*sandboxLedger, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+			if err != nil {
+				log.Fatalf("invalid -sandbox-ledger: %s", err)
+			}
+			defer journal.Close()
+			cfg.Journal = journal
+		}
+		sb = sandbox.New(cfg)
+		sb.Engine().SetSessionClose(session)
+		restAPI.EnableSandbox(sb)
+	}
+
+	var bot *marketmaker.Bot
+	if *botSymbols != "" {
+		cfg := marketmaker.DefaultConfig()
+		symbols, err := marketmaker.ParseSymbols(*botSymbols)
+		if err != nil {
+			log.Fatalf("invalid -liquidity-bot: %s", err)
+		}
+		cfg.Symbols = symbols
+		switch {
+		case *botTarget == "live":
+			bot = marketmaker.New(engine, cfg)
+		case *botTarget == "sandbox" && sb != nil:
+			bot = marketmaker.New(sb.Engine(), cfg)
+		default:
+			log.Fatalf("invalid -liquidity-bot-target %q: want live, or sandbox with -sandbox", *botTarget)
+		}
+	}
 
-	log.Println("Server starting on port 8080...")
-	if err := server.Run(); err != nil {
+	restAPI.SetLifecycle(lifecycle)
+	restAPI.SetMaxInFlight(*maxInFlight)
+	restAPI.SetAnalytics(dashboards)
+	if trades != nil {
+		restAPI.SetTape(trades)
+	}
+	if lp != nil {
+		restAPI.SetLiquidityTracker(lp)
+		restAPI.SetObligationMonitor(obligations)
+	}
+	if outbox != nil {
+		restAPI.SetClearing(outbox)
+	}
+	manager := server.NewManager(*shutdownTimeout)
+	manager.SetLifecycle(lifecycle)
+	if *restEnabled {
+		rest := api.NewRESTListener("tcp", *addr, restAPI)
+		rest.SetReusePort(*reusePort)
+		manager.Add(rest)
+	}
+	if *unixSocket != "" {
+		manager.Add(api.NewRESTListener("unix", *unixSocket, restAPI))
+	}
+
+	if *pidfile != "" {
+		manager.OnReady(func() {
+			if err := server.TakeOver(*pidfile); err != nil {
+				log.Printf("rolling restart handoff failed: %s", err)
+			}
+		})
+		defer server.ReleasePIDFile(*pidfile)
+	}
+
+	took := engine.WarmUp(matching.WarmUpConfig{
+		Symbols:         splitList(*warmupSymbols),
+		OrdersPerBook:   *bookCapacity,
+		SyntheticOrders: *warmupOrders,
+	})
+	log.Printf("Engine warmed up in %s", took)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go engine.RunExpiry(ctx, time.Second)
+	if sb != nil {
+		go sb.Engine().RunExpiry(ctx, time.Second)
+	}
+	if bot != nil {
+		go bot.Run(ctx)
+	}
+	if lp != nil {
+		go lp.Run(ctx)
+	}
+	if outbox != nil {
+		go outbox.Run(ctx)
+	}
+
+	if err := manager.Run(ctx); err != nil {
 		log.Fatalf("could not start server: %s\n", err)
 	}
+	log.Println("Server stopped")
+}
+
+// reloadSymbolConfigs reapplies the symbol config file on every SIGHUP. A file
+// that fails to load leaves the running configuration in place.
+func reloadSymbolConfigs(engine *matching.Engine, path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		configs, err := matching.ReadSymbolConfigs(path)
+		if err == nil {
+			err = engine.ConfigureSymbols(configs)
+		}
+		if err != nil {
+			log.Printf("symbol config reload failed, keeping the current one: %s", err)
+			continue
+		}
+		log.Printf("Reloaded symbol config for %d symbols from %s", len(configs), path)
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
 }
@@ -0,0 +1,103 @@
+package analytics
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tradeSample is a trade as the averages see it.
+type tradeSample struct {
+	at       int64 // UnixNano
+	price    int64
+	quantity int64
+}
+
+// Averages are a symbol's volume- and time-weighted average prices over one
+// window ending now. The prices are omitted when the window had no trades or,
+// for the TWAP, no known price.
+type Averages struct {
+	Window string `json:"window"`
+	Trades int64  `json:"trades"`
+	Volume int64  `json:"volume"`
+	VWAP   int64  `json:"vwap,omitempty"`
+	// TWAP weights each price by how long it was the last trade price, from
+	// the start of the window or the first trade, whichever is later.
+	TWAP int64 `json:"twap,omitempty"`
+}
+
+// ParseWindows reads averaging windows written as DURATION[,...], e.g.
+// "1m,5m,15m".
+func ParseWindows(s string) ([]time.Duration, error) {
+	var windows []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		window, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid averaging window %q: want a positive duration", part)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// recordTrade adds a trade to symbol's samples, dropping those too old for
+// the longest window. The last one before it is kept, as the price the
+// window opens at. It must be called with c.mu held.
+func (c *Collector) recordTrade(symbol string, price, quantity int64, now time.Time) {
+	if len(c.cfg.AverageWindows) == 0 {
+		return
+	}
+	samples := append(c.trades[symbol], tradeSample{at: now.UnixNano(), price: price, quantity: quantity})
+	cutoff := now.Add(-slices.Max(c.cfg.AverageWindows)).UnixNano()
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].at > cutoff })
+	if i > 1 {
+		samples = append(samples[:0], samples[i-1:]...)
+	}
+	c.trades[symbol] = samples
+}
+
+// Averages returns symbol's VWAP and TWAP over each configured window.
+func (c *Collector) Averages(symbol string) []Averages {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now().UnixNano()
+	samples := c.trades[symbol]
+
+	out := make([]Averages, 0, len(c.cfg.AverageWindows))
+	for _, window := range c.cfg.AverageWindows {
+		start := now - window.Nanoseconds()
+		a := Averages{Window: window.String()}
+
+		var turnover int64
+		var weighted float64
+		var covered int64
+		for i, s := range samples {
+			end := now
+			if i+1 < len(samples) {
+				end = samples[i+1].at
+			}
+			if from := max(s.at, start); end > from {
+				weighted += float64(s.price) * float64(end-from)
+				covered += end - from
+			}
+			if s.at > start {
+				a.Trades++
+				a.Volume += s.quantity
+				turnover += s.price * s.quantity
+			}
+		}
+		if a.Volume > 0 {
+			a.VWAP = turnover / a.Volume
+		}
+		if covered > 0 {
+			a.TWAP = int64(weighted/float64(covered) + 0.5)
+		}
+		out = append(out, a)
+	}
+	return out
+}
@@ -0,0 +1,288 @@
+// Package analytics aggregates engine activity for internal business
+// dashboards: daily traded volume per symbol and per firm, active accounts,
+// top-of-book spread over time, and rolling average prices.
+package analytics
+
+import (
+	"repello/internal/matching"
+	"repello/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// dateLayout keys daily statistics by UTC date.
+const dateLayout = "2006-01-02"
+
+// Config controls how much history a Collector keeps.
+type Config struct {
+	// Days is how many UTC days of daily statistics are kept, today included.
+	Days int
+	// SpreadInterval is the resolution of the spread series: each symbol
+	// keeps the last top of book seen in each interval.
+	SpreadInterval time.Duration
+	// SpreadSamples is how many intervals of each symbol's spread are kept.
+	SpreadSamples int
+	// AverageWindows are the periods, ending now, each symbol's VWAP and
+	// TWAP are computed over. Trades are kept for the longest.
+	AverageWindows []time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Days:           30,
+		SpreadInterval: time.Second,
+		SpreadSamples:  3600,
+		AverageWindows: []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute},
+	}
+}
+
+// Volume is trading activity over a day.
+type Volume struct {
+	Trades   int64 `json:"trades"`
+	Volume   int64 `json:"volume"`   // quantity traded
+	Turnover int64 `json:"turnover"` // notional traded, price times quantity
+}
+
+func (v *Volume) add(trade *models.Trade) {
+	v.Trades++
+	v.Volume += trade.Quantity
+	v.Turnover += trade.Price * trade.Quantity
+}
+
+// AccountActivity is an account's trading over a day. Fees is what it was
+// charged, net of rebates; an account on both sides of a trade counts it
+// twice.
+type AccountActivity struct {
+	Volume
+	Fees int64 `json:"fees"`
+}
+
+// DailyStats is one UTC day of activity. A firm's volume counts each side it
+// traded on, so a firm on both sides of a trade counts it twice.
+type DailyStats struct {
+	Date           string             `json:"date"`
+	Symbols        map[string]*Volume `json:"symbols"`
+	Firms          map[string]*Volume `json:"firms"`
+	ActiveAccounts int                `json:"active_accounts"`
+}
+
+// SpreadSample is a symbol's top of book at the end of an interval. Spread is
+// omitted when either side of the book was empty.
+type SpreadSample struct {
+	Timestamp int64 `json:"timestamp"` // UnixNano, start of the interval
+	BestBid   int64 `json:"best_bid"`
+	BestAsk   int64 `json:"best_ask"`
+	Spread    int64 `json:"spread,omitempty"`
+}
+
+type day struct {
+	stats    DailyStats
+	accounts map[string]struct{}
+	activity map[string]*AccountActivity
+}
+
+// Collector is a matching.EventListener that aggregates activity for
+// dashboards. Only what it has seen since it was registered is counted; the
+// engine keeps no history to rebuild it from.
+type Collector struct {
+	cfg     Config
+	firms   matching.FirmDirectory // nil without firms
+	mu      sync.Mutex
+	days    map[string]*day
+	spreads map[string][]SpreadSample
+	trades  map[string][]tradeSample // by symbol, oldest first
+	now     func() time.Time
+}
+
+var (
+	_ matching.EventListener = (*Collector)(nil)
+	_ matching.TopListener   = (*Collector)(nil)
+)
+
+// NewCollector creates a collector. firms attributes volume to firms and may
+// be nil.
+func NewCollector(cfg Config, firms matching.FirmDirectory) *Collector {
+	return &Collector{
+		cfg:     cfg,
+		firms:   firms,
+		days:    make(map[string]*day),
+		spreads: make(map[string][]SpreadSample),
+		trades:  make(map[string][]tradeSample),
+		now:     time.Now,
+	}
+}
+
+// day returns the statistics for t's UTC date, dropping days that fall out of
+// the retention window when a new one starts. It must be called with c.mu
+// held.
+func (c *Collector) day(t time.Time) *day {
+	date := t.UTC().Format(dateLayout)
+	d, ok := c.days[date]
+	if !ok {
+		d = &day{
+			stats: DailyStats{
+				Date:    date,
+				Symbols: make(map[string]*Volume),
+				Firms:   make(map[string]*Volume),
+			},
+			accounts: make(map[string]struct{}),
+			activity: make(map[string]*AccountActivity),
+		}
+		c.days[date] = d
+		cutoff := t.UTC().AddDate(0, 0, -c.cfg.Days).Format(dateLayout)
+		for date := range c.days {
+			if date <= cutoff {
+				delete(c.days, date)
+			}
+		}
+	}
+	return d
+}
+
+func (c *Collector) OrderAccepted(order *models.Order, top matching.BookTop) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if order.Account != "" {
+		c.day(now).accounts[order.Account] = struct{}{}
+	}
+	c.sampleSpread(order.Symbol, top, now)
+}
+
+func (c *Collector) OrderCancelled(order *models.Order, top matching.BookTop) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sampleSpread(order.Symbol, top, c.now())
+}
+
+// TopChanged samples the spread once a book has settled, since the top an
+// order is accepted with is the book's before it matches.
+func (c *Collector) TopChanged(symbol string, top matching.BookTop) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sampleSpread(symbol, top, c.now())
+}
+
+func (c *Collector) TradeExecuted(trade *models.Trade, taker, maker *models.Order) {
+	now := c.now()
+	var firms []string
+	if c.firms != nil {
+		for _, order := range []*models.Order{taker, maker} {
+			if firm, ok := c.firms.FirmOf(order.Account); ok {
+				firms = append(firms, firm)
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d := c.day(now)
+	volume, ok := d.stats.Symbols[trade.Symbol]
+	if !ok {
+		volume = &Volume{}
+		d.stats.Symbols[trade.Symbol] = volume
+	}
+	volume.add(trade)
+	for _, firm := range firms {
+		volume, ok := d.stats.Firms[firm]
+		if !ok {
+			volume = &Volume{}
+			d.stats.Firms[firm] = volume
+		}
+		volume.add(trade)
+	}
+	d.addActivity(taker.Account, trade, trade.TakerFee)
+	d.addActivity(maker.Account, trade, trade.MakerFee)
+	c.recordTrade(trade.Symbol, trade.Price, trade.Quantity, now)
+}
+
+func (d *day) addActivity(account string, trade *models.Trade, fee int64) {
+	if account == "" {
+		return
+	}
+	activity, ok := d.activity[account]
+	if !ok {
+		activity = &AccountActivity{}
+		d.activity[account] = activity
+	}
+	activity.add(trade)
+	activity.Fees += fee
+}
+
+// sampleSpread records top as symbol's latest top of book in the interval
+// containing now. It must be called with c.mu held.
+func (c *Collector) sampleSpread(symbol string, top matching.BookTop, now time.Time) {
+	sample := SpreadSample{
+		Timestamp: now.Truncate(c.cfg.SpreadInterval).UnixNano(),
+		BestBid:   top.BestBid,
+		BestAsk:   top.BestAsk,
+	}
+	if top.BestBid > 0 && top.BestAsk > 0 {
+		sample.Spread = top.BestAsk - top.BestBid
+	}
+
+	series := c.spreads[symbol]
+	if n := len(series); n > 0 && series[n-1].Timestamp == sample.Timestamp {
+		series[n-1] = sample
+		return
+	}
+	if len(series) == c.cfg.SpreadSamples {
+		series = append(series[:0], series[1:]...)
+	}
+	c.spreads[symbol] = append(series, sample)
+}
+
+// Daily returns the statistics of the last days UTC days with activity,
+// oldest first. days <= 0 returns every day kept.
+func (c *Collector) Daily(days int) []DailyStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]DailyStats, 0, len(c.days))
+	for _, d := range c.days {
+		stats := d.stats
+		stats.ActiveAccounts = len(d.accounts)
+		stats.Symbols = make(map[string]*Volume, len(d.stats.Symbols))
+		for symbol, v := range d.stats.Symbols {
+			volume := *v
+			stats.Symbols[symbol] = &volume
+		}
+		stats.Firms = make(map[string]*Volume, len(d.stats.Firms))
+		for firm, v := range d.stats.Firms {
+			volume := *v
+			stats.Firms[firm] = &volume
+		}
+		out = append(out, stats)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Date < out[j].Date
+	})
+	if days > 0 && len(out) > days {
+		out = out[len(out)-days:]
+	}
+	return out
+}
+
+// AccountToday returns account's trading so far today, UTC.
+func (c *Collector) AccountToday(account string) AccountActivity {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d, ok := c.days[c.now().UTC().Format(dateLayout)]; ok {
+		if activity, ok := d.activity[account]; ok {
+			return *activity
+		}
+	}
+	return AccountActivity{}
+}
+
+// Spreads returns symbol's spread series since since (UnixNano), oldest
+// first.
+func (c *Collector) Spreads(symbol string, since int64) []SpreadSample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	series := c.spreads[symbol]
+	i := sort.Search(len(series), func(i int) bool {
+		return series[i].Timestamp >= since
+	})
+	return append([]SpreadSample{}, series[i:]...)
+}
@@ -0,0 +1,131 @@
+package analytics
+
+import (
+	"repello/internal/enrich"
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"repello/internal/risk"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newOrder(id, account string, side models.Side, price, quantity int64) *models.Order {
+	order := models.NewOrder(id, "BTCUSD", side, models.Limit, price, quantity)
+	order.Account = account
+	return order
+}
+
+func TestCollector(t *testing.T) {
+	firms := risk.NewCreditLimits(risk.CreditConfig{})
+	firms.SetLimit("firm1", 1_000_000)
+	firms.AssignAccount("alice", "firm1")
+
+	engine := matching.NewEngine(metrics.NewMetrics())
+	collector := NewCollector(DefaultConfig(), firms)
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	collector.now = func() time.Time { return now }
+	engine.AddEventListener(collector)
+
+	engine.ProcessOrder(newOrder("s1", "mm", models.Sell, 101, 10))
+	engine.ProcessOrder(newOrder("b1", "mm", models.Buy, 99, 10))
+	now = now.Add(2 * time.Second)
+	engine.ProcessOrder(newOrder("a1", "alice", models.Buy, 101, 4))
+
+	days := collector.Daily(0)
+	if assert.Len(t, days, 1) {
+		assert.Equal(t, "2026-03-02", days[0].Date)
+		assert.Equal(t, 2, days[0].ActiveAccounts)
+		assert.Equal(t, &Volume{Trades: 1, Volume: 4, Turnover: 404}, days[0].Symbols["BTCUSD"])
+		assert.Equal(t, &Volume{Trades: 1, Volume: 4, Turnover: 404}, days[0].Firms["firm1"])
+	}
+
+	spreads := collector.Spreads("BTCUSD", 0)
+	if assert.Len(t, spreads, 2) {
+		assert.Equal(t, int64(2), spreads[0].Spread)
+		assert.Equal(t, int64(2), spreads[1].Spread)
+		assert.Equal(t, now.UnixNano(), spreads[1].Timestamp)
+	}
+	assert.Len(t, collector.Spreads("BTCUSD", now.UnixNano()), 1)
+
+	// Orders in the same interval replace its sample
+	engine.CancelOrder("b1")
+	spreads = collector.Spreads("BTCUSD", 0)
+	assert.Len(t, spreads, 2)
+	assert.Equal(t, int64(0), spreads[1].BestBid)
+	assert.Zero(t, spreads[1].Spread)
+
+	// Days out of the retention window are dropped when a new day starts
+	now = now.AddDate(0, 0, 30)
+	engine.ProcessOrder(newOrder("b2", "bob", models.Buy, 99, 1))
+	days = collector.Daily(0)
+	if assert.Len(t, days, 1) {
+		assert.Equal(t, "2026-04-01", days[0].Date)
+		assert.Equal(t, 1, days[0].ActiveAccounts)
+	}
+}
+
+func TestCollector_AccountToday(t *testing.T) {
+	engine := matching.NewEngine(metrics.NewMetrics())
+	engine.AddTradeStage(enrich.Fees{MakerBps: -10, TakerBps: 20})
+	collector := NewCollector(DefaultConfig(), nil)
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	collector.now = func() time.Time { return now }
+	engine.AddEventListener(collector)
+
+	engine.ProcessOrder(newOrder("s1", "mm", models.Sell, 1000, 10))
+	engine.ProcessOrder(newOrder("b1", "alice", models.Buy, 1000, 5))
+	engine.ProcessOrder(newOrder("b2", "alice", models.Buy, 1000, 5))
+
+	assert.Equal(t, AccountActivity{Volume: Volume{Trades: 2, Volume: 10, Turnover: 10000}, Fees: 20}, collector.AccountToday("alice"))
+	assert.Equal(t, AccountActivity{Volume: Volume{Trades: 2, Volume: 10, Turnover: 10000}, Fees: -10}, collector.AccountToday("mm"))
+	assert.Zero(t, collector.AccountToday("bob"))
+
+	// Yesterday's trading doesn't count
+	now = now.AddDate(0, 0, 1)
+	assert.Zero(t, collector.AccountToday("alice"))
+}
+
+func TestCollector_Averages(t *testing.T) {
+	engine := matching.NewEngine(metrics.NewMetrics())
+	cfg := DefaultConfig()
+	cfg.AverageWindows = []time.Duration{time.Minute, 10 * time.Minute}
+	collector := NewCollector(cfg, nil)
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	now := start
+	collector.now = func() time.Time { return now }
+	engine.AddEventListener(collector)
+
+	trade := func(price, quantity int64) {
+		engine.ProcessOrder(newOrder("s-"+now.String(), "mm", models.Sell, price, quantity))
+		engine.ProcessOrder(newOrder("b-"+now.String(), "alice", models.Buy, price, quantity))
+	}
+	trade(100, 1)
+	now = start.Add(5 * time.Minute)
+	trade(110, 3)
+	now = start.Add(5*time.Minute + 30*time.Second)
+	trade(120, 1)
+	now = start.Add(6 * time.Minute)
+
+	averages := collector.Averages("BTCUSD")
+	if assert.Len(t, averages, 2) {
+		// The 110 trade is on the last minute's edge, so only its price counts:
+		// 110 for 30s, then 120 for 30s
+		assert.Equal(t, Averages{Window: "1m0s", Trades: 1, Volume: 1, VWAP: 120, TWAP: 115}, averages[0])
+		// 100 for 5m, 110 for 30s and 120 for 30s, from the first trade
+		assert.Equal(t, Averages{Window: "10m0s", Trades: 3, Volume: 5, VWAP: 110, TWAP: 103}, averages[1])
+	}
+	assert.Empty(t, collector.Averages("ETHUSD")[0].VWAP)
+
+	// Trades older than the longest window are dropped, bar the price it opens at
+	now = start.Add(30 * time.Minute)
+	trade(130, 1)
+	collector.mu.Lock()
+	assert.Len(t, collector.trades["BTCUSD"], 2)
+	collector.mu.Unlock()
+
+	_, err := ParseWindows("1m,soon")
+	assert.Error(t, err)
+}
@@ -0,0 +1,349 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"repello/internal/gateway"
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/risk"
+	"repello/internal/server"
+	"repello/internal/surveillance"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// AlertResponse is a surveillance alert plus links to the implicated orders.
+type AlertResponse struct {
+	surveillance.Alert
+	Links AlertLinks `json:"links"`
+}
+
+type AlertLinks struct {
+	Orders []string `json:"orders"`
+}
+
+func newAlertResponse(alert surveillance.Alert) AlertResponse {
+	links := AlertLinks{Orders: make([]string, len(alert.OrderIDs))}
+	for i, id := range alert.OrderIDs {
+		links.Orders[i] = "/api/v1/orders/" + id
+	}
+	return AlertResponse{Alert: alert, Links: links}
+}
+
+// handleListAlerts lists alerts, optionally filtered by ?status= and ?type=.
+func (s *APIServer) handleListAlerts(ctx *fasthttp.RequestCtx) {
+	statusParam := string(ctx.QueryArgs().Peek("status"))
+	typeParam := string(ctx.QueryArgs().Peek("type"))
+
+	response := make([]AlertResponse, 0)
+	for _, alert := range s.alerts.List() {
+		if statusParam != "" && alert.Status.String() != statusParam {
+			continue
+		}
+		if typeParam != "" && alert.Type.String() != typeParam {
+			continue
+		}
+		response = append(response, newAlertResponse(alert))
+	}
+	writeJSON(ctx, fasthttp.StatusOK, response)
+}
+
+func (s *APIServer) handleGetAlert(ctx *fasthttp.RequestCtx, alertID string) {
+	alert, err := s.alerts.Get(alertID)
+	if err != nil {
+		writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Alert not found"})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, newAlertResponse(alert))
+}
+
+func (s *APIServer) handleUpdateAlert(ctx *fasthttp.RequestCtx, alertID string) {
+	var update surveillance.AlertUpdate
+	if err := json.Unmarshal(ctx.PostBody(), &update); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	alert, err := s.alerts.Update(alertID, update)
+	if err != nil {
+		if err.Error() == "alert not found" {
+			writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Alert not found"})
+		} else {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, newAlertResponse(alert))
+}
+
+// UpdateFirmRequest sets a clearing firm's credit limit and options and adds
+// accounts to it. Any field may be omitted; a new firm needs a credit limit.
+type UpdateFirmRequest struct {
+	CreditLimit *int64   `json:"credit_limit,omitempty"`
+	Accounts    []string `json:"accounts,omitempty"`
+	NoCross     *bool    `json:"no_cross,omitempty"`
+}
+
+func (s *APIServer) handleGetFirm(ctx *fasthttp.RequestCtx, firmID string) {
+	firm, err := s.credit.Firm(firmID)
+	if err != nil {
+		writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Firm not found"})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, firm)
+}
+
+func (s *APIServer) handleUpdateFirm(ctx *fasthttp.RequestCtx, firmID string) {
+	var req UpdateFirmRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	if req.CreditLimit != nil {
+		if err := s.credit.SetLimit(firmID, *req.CreditLimit); err != nil {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if req.NoCross != nil {
+		if err := s.credit.SetNoCross(firmID, *req.NoCross); err != nil {
+			writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Firm not found"})
+			return
+		}
+	}
+	for _, account := range req.Accounts {
+		if err := s.credit.AssignAccount(account, firmID); err != nil {
+			if err.Error() == "firm not found" {
+				writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Firm not found"})
+			} else {
+				writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			return
+		}
+	}
+
+	s.handleGetFirm(ctx, firmID)
+}
+
+// SetPositionLimitRequest caps an account's or firm's net position. Omit the
+// symbol to cover every symbol; a limit of 0 removes the limit.
+type SetPositionLimitRequest struct {
+	Scope  risk.LimitScope `json:"scope"`
+	ID     string          `json:"id"`
+	Symbol string          `json:"symbol,omitempty"`
+	Limit  int64           `json:"limit"`
+}
+
+type SetReduceOnlyRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+func (s *APIServer) handleSetPositionLimit(ctx *fasthttp.RequestCtx) {
+	var req SetPositionLimitRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := s.positions.SetLimit(req.Scope, req.ID, req.Symbol, req.Limit); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, req)
+}
+
+func (s *APIServer) handleSetReduceOnly(ctx *fasthttp.RequestCtx, account string) {
+	var req SetReduceOnlyRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if account == "" {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "account id is required"})
+		return
+	}
+	s.positions.SetReduceOnly(account, req.Enabled)
+	writeJSON(ctx, fasthttp.StatusOK, s.positions.Positions(account))
+}
+
+// PurgeStaleRequest cancels a symbol's resting orders older than OlderThan, a
+// Go duration such as "24h".
+type PurgeStaleRequest struct {
+	Symbol    string `json:"symbol"`
+	OlderThan string `json:"older_than"`
+}
+
+type PurgedOrder struct {
+	OrderID    string `json:"order_id"`
+	Account    string `json:"account_id,omitempty"`
+	Price      int64  `json:"price"`
+	Remaining  int64  `json:"remaining_quantity"`
+	AcceptedAt int64  `json:"accepted_at"`
+}
+
+type PurgeStaleResponse struct {
+	Symbol string        `json:"symbol"`
+	Purged []PurgedOrder `json:"purged"`
+}
+
+// handlePurgeStale removes old resting orders from a book. Every purged order
+// is logged, as there is no other record of who removed it.
+func (s *APIServer) handlePurgeStale(ctx *fasthttp.RequestCtx) {
+	var req PurgeStaleRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	maxAge, err := time.ParseDuration(req.OlderThan)
+	if err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid older_than: " + err.Error()})
+		return
+	}
+
+	orders, err := s.engine.PurgeStaleOrders(req.Symbol, maxAge, time.Now())
+	if err != nil {
+		if err.Error() == "order book not found" {
+			writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Order book not found"})
+		} else {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+
+	response := PurgeStaleResponse{Symbol: req.Symbol, Purged: make([]PurgedOrder, len(orders))}
+	for i, order := range orders {
+		response.Purged[i] = PurgedOrder{
+			OrderID:    order.ID,
+			Account:    order.Account,
+			Price:      order.Price,
+			Remaining:  order.RemainingQuantity,
+			AcceptedAt: order.AcceptedAt,
+		}
+		log.Printf("admin purge: cancelled stale order %s (account %q, %s %d @ %d, accepted %s) from %s, older than %s, requested by %s",
+			order.ID, order.Account, order.Side, order.RemainingQuantity, order.Price,
+			time.Unix(0, order.AcceptedAt).UTC().Format(time.RFC3339), req.Symbol, maxAge, ctx.RemoteAddr())
+	}
+	writeJSON(ctx, fasthttp.StatusOK, response)
+}
+
+// CancelAllRequest is the emergency kill switch: every active order in
+// Symbols, or in every symbol if there are none, is cancelled with Reason.
+type CancelAllRequest struct {
+	Symbols []string `json:"symbols,omitempty"`
+	Reason  string   `json:"reason"`
+}
+
+type CancelAllResponse struct {
+	Reason    string         `json:"reason"`
+	Cancelled int            `json:"cancelled"`
+	Symbols   map[string]int `json:"symbols"` // orders cancelled per symbol
+}
+
+// handleCancelAll cancels every active order for incident response and
+// notifies every feed. It is logged, as there is no other record of who
+// pulled the market.
+func (s *APIServer) handleCancelAll(ctx *fasthttp.RequestCtx) {
+	var req CancelAllRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	orders, err := s.gateway.CancelAll(req.Symbols, req.Reason)
+	if err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	response := CancelAllResponse{Reason: req.Reason, Cancelled: len(orders), Symbols: make(map[string]int)}
+	for _, order := range orders {
+		response.Symbols[order.Symbol]++
+	}
+	scope := "all symbols"
+	if len(req.Symbols) > 0 {
+		scope = strings.Join(req.Symbols, ",")
+	}
+	log.Printf("admin cancel-all: cancelled %d orders in %s, reason %q, requested by %s",
+		len(orders), scope, req.Reason, ctx.RemoteAddr())
+	writeJSON(ctx, fasthttp.StatusOK, response)
+}
+
+// handleGetLifecycle reports the lifecycle state and its transitions so far.
+func (s *APIServer) handleGetLifecycle(ctx *fasthttp.RequestCtx) {
+	if s.lifecycle == nil {
+		writeJSON(ctx, fasthttp.StatusOK, server.LifecycleStatus{State: server.Ready, Transitions: []server.Transition{}})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, s.lifecycle.Status())
+}
+
+// handleSetSymbolConfigs replaces every symbol's overrides with the body, a
+// JSON object keyed by symbol, as a reload of the -symbol-config file would.
+func (s *APIServer) handleSetSymbolConfigs(ctx *fasthttp.RequestCtx) {
+	var configs map[string]matching.SymbolConfig
+	if err := json.Unmarshal(ctx.PostBody(), &configs); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := s.engine.ConfigureSymbols(configs); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("Symbol configuration replaced for %d symbols by %s", len(configs), ctx.RemoteAddr())
+	writeJSON(ctx, fasthttp.StatusOK, s.engine.SymbolConfigs())
+}
+
+// PublishFixingRequest publishes Symbol's fixing price, e.g. its closing
+// price, so orders waiting for it can trade.
+type PublishFixingRequest struct {
+	Symbol string `json:"symbol"`
+	Price  int64  `json:"price"`
+}
+
+// handlePublishFixing crosses the symbol's fixing orders at the published
+// price.
+func (s *APIServer) handlePublishFixing(ctx *fasthttp.RequestCtx) {
+	var req PublishFixingRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	result, err := s.engine.PublishFixing(req.Symbol, req.Price)
+	if err != nil {
+		if err.Error() == "order book not found" {
+			writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Order book not found"})
+		} else {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	log.Printf("Fixing price %d published for %s by %s: %d trades, %d orders cancelled",
+		req.Price, req.Symbol, ctx.RemoteAddr(), len(result.Trades), len(result.Cancelled))
+	writeJSON(ctx, fasthttp.StatusOK, result)
+}
+
+// RejectsResponse answers "why are my orders failing": rejection counts by
+// reason code, and the recent rejections themselves.
+type RejectsResponse struct {
+	Counts metrics.RejectionReport `json:"counts"`
+	Recent []gateway.Rejection     `json:"recent"`
+	// Shadow counts, by check, the orders shadow-mode checks would have
+	// rejected.
+	Shadow metrics.RejectionReport `json:"shadow"`
+}
+
+// handleGetRejects lists recent rejections, filtered by ?account_id= and
+// ?symbol=.
+func (s *APIServer) handleGetRejects(ctx *fasthttp.RequestCtx) {
+	account := string(ctx.QueryArgs().Peek("account_id"))
+	symbol := string(ctx.QueryArgs().Peek("symbol"))
+	writeJSON(ctx, fasthttp.StatusOK, RejectsResponse{
+		Counts: s.metrics.Rejections(),
+		Recent: s.gateway.RecentRejects(account, symbol),
+		Shadow: s.metrics.ShadowRejections(),
+	})
+}
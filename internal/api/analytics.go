@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"repello/internal/analytics"
+	"repello/internal/liquidity"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SetAnalytics enables the dashboard analytics endpoints.
+func (s *APIServer) SetAnalytics(c *analytics.Collector) {
+	s.analytics = c
+}
+
+// SetLiquidityTracker enables the liquidity-provider report.
+func (s *APIServer) SetLiquidityTracker(t *liquidity.Tracker) {
+	s.liquidity = t
+}
+
+// SetObligationMonitor enables the quote obligation endpoints.
+func (s *APIServer) SetObligationMonitor(m *liquidity.ObligationMonitor) {
+	s.dmm = m
+}
+
+// handleGetDailyAnalytics returns per-day volume and active accounts. ?days=N
+// limits it to the last N days with activity.
+func (s *APIServer) handleGetDailyAnalytics(ctx *fasthttp.RequestCtx) {
+	days := 0
+	if param := string(ctx.QueryArgs().Peek("days")); param != "" {
+		var err error
+		if days, err = strconv.Atoi(param); err != nil || days < 0 {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid days"})
+			return
+		}
+	}
+	writeJSON(ctx, fasthttp.StatusOK, s.analytics.Daily(days))
+}
+
+// handleGetSpreads returns a symbol's top-of-book spread series, from ?since=
+// (UnixNano) if given.
+func (s *APIServer) handleGetSpreads(ctx *fasthttp.RequestCtx, symbol string) {
+	symbol = s.gateway.Normalizer().Symbol(symbol)
+	var since int64
+	if param := string(ctx.QueryArgs().Peek("since")); param != "" {
+		var err error
+		if since, err = strconv.ParseInt(param, 10, 64); err != nil {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid since"})
+			return
+		}
+	}
+	writeJSON(ctx, fasthttp.StatusOK, s.analytics.Spreads(symbol, since))
+}
+
+// handleGetAverages returns a symbol's VWAP and TWAP over each configured
+// window.
+func (s *APIServer) handleGetAverages(ctx *fasthttp.RequestCtx, symbol string) {
+	writeJSON(ctx, fasthttp.StatusOK, s.analytics.Averages(s.gateway.Normalizer().Symbol(symbol)))
+}
+
+func (s *APIServer) handleGetOpenInterest(ctx *fasthttp.RequestCtx) {
+	writeJSON(ctx, fasthttp.StatusOK, s.positions.OpenInterest())
+}
+
+// handleGetLiquidityProviders reports each account's maker volume and
+// presence at the touch per symbol, filtered by ?account_id= and ?symbol=.
+func (s *APIServer) handleGetLiquidityProviders(ctx *fasthttp.RequestCtx) {
+	account := string(ctx.QueryArgs().Peek("account_id"))
+	symbol := string(ctx.QueryArgs().Peek("symbol"))
+	writeJSON(ctx, fasthttp.StatusOK, s.liquidity.Report(account, symbol))
+}
+
+func (s *APIServer) handleGetObligations(ctx *fasthttp.RequestCtx) {
+	writeJSON(ctx, fasthttp.StatusOK, s.dmm.Status())
+}
+
+// handleSetObligation adds, replaces or, with min_presence_pct 0, removes a
+// market maker's quoting obligation.
+func (s *APIServer) handleSetObligation(ctx *fasthttp.RequestCtx) {
+	var o liquidity.Obligation
+	if err := json.Unmarshal(ctx.PostBody(), &o); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := s.dmm.SetObligation(o); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, s.dmm.Status())
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"log"
+	"repello/internal/clearing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SetClearing enables the settlement endpoints.
+func (s *APIServer) SetClearing(o *clearing.Outbox) {
+	s.clearing = o
+}
+
+func (s *APIServer) handleGetSettlement(ctx *fasthttp.RequestCtx, tradeID string) {
+	settlement, ok := s.clearing.Get(tradeID)
+	if !ok {
+		writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Settlement not found"})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, settlement)
+}
+
+// handleListSettlements lists settlements, oldest trade first, optionally
+// filtered by ?status=.
+func (s *APIServer) handleListSettlements(ctx *fasthttp.RequestCtx) {
+	statusParam := string(ctx.QueryArgs().Peek("status"))
+
+	response := make([]clearing.Settlement, 0)
+	for _, settlement := range s.clearing.List() {
+		if statusParam != "" && settlement.Status.String() != statusParam {
+			continue
+		}
+		response = append(response, settlement)
+	}
+	writeJSON(ctx, fasthttp.StatusOK, response)
+}
+
+func (s *APIServer) handleRetrySettlement(ctx *fasthttp.RequestCtx, tradeID string) {
+	settlement, err := s.clearing.Retry(tradeID)
+	if err != nil {
+		if err.Error() == "settlement not found" {
+			writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Settlement not found"})
+		} else {
+			writeJSON(ctx, fasthttp.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	log.Printf("admin %s: retrying settlement %s of trade %s", ctx.RemoteAddr(), settlement.Reference, tradeID)
+	writeJSON(ctx, fasthttp.StatusOK, settlement)
+}
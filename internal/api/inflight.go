@@ -0,0 +1,99 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// inFlightLimit caps how many order entry requests each client may have in
+// progress at once. Clients are told apart by IP address: HTTP/1.1 serves one
+// request at a time per connection, so a client only gets parallelism by
+// opening more connections, and there are no API keys to go by. Cancels have
+// a lane of their own and may overflow into the other one, but not the other
+// way round, so a client stuck with slow submits can still pull its orders.
+type inFlightLimit struct {
+	max    int
+	mu     sync.Mutex
+	counts map[inFlightLane]int
+}
+
+// inFlightLane is a client's cancels, or the rest of its order entry requests.
+type inFlightLane struct {
+	client string // IP address
+	cancel bool
+}
+
+func newInFlightLimit(max int) *inFlightLimit {
+	return &inFlightLimit{max: max, counts: make(map[inFlightLane]int)}
+}
+
+// acquire counts a request in lane, unless it already has max; a cancel then
+// goes in the client's other lane if that has room. It returns the lane the
+// request was counted in.
+func (l *inFlightLimit) acquire(lane inFlightLane) (inFlightLane, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts[lane] >= l.max {
+		if !lane.cancel {
+			return lane, false
+		}
+		lane.cancel = false
+		if l.counts[lane] >= l.max {
+			return lane, false
+		}
+	}
+	l.counts[lane]++
+	return lane, true
+}
+
+func (l *inFlightLimit) release(lane inFlightLane) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts[lane]--; l.counts[lane] <= 0 {
+		delete(l.counts, lane)
+	}
+}
+
+// SetMaxInFlight limits each client to max order entry requests (submits,
+// cancels and amends, live or sandbox) in progress at once, plus max more
+// cancels; more are answered with 429 straight away. Zero removes the limit.
+func (s *APIServer) SetMaxInFlight(max int) {
+	s.inflight = nil
+	if max > 0 {
+		s.inflight = newInFlightLimit(max)
+	}
+}
+
+// isOrderEntry reports whether a request changes orders.
+func isOrderEntry(method, path string) bool {
+	path = strings.TrimPrefix(path, "/sandbox")
+	if !strings.HasPrefix(path, "/api/v1/orders") {
+		return false
+	}
+	switch path {
+	case "/api/v1/orders", "/api/v1/orders/oco", "/api/v1/orders/amend/batch", "/api/v1/orders/shift":
+		return method == "POST"
+	}
+	return strings.HasPrefix(path, "/api/v1/orders/") && (method == "DELETE" || method == "PATCH")
+}
+
+// admitInFlight applies the in-flight limit to order entry requests. If it
+// returns true the request may go ahead, and done must be called once it has
+// been handled.
+func (s *APIServer) admitInFlight(ctx *fasthttp.RequestCtx, method, path string) (done func(), ok bool) {
+	if s.inflight == nil || !isOrderEntry(method, path) {
+		return func() {}, true
+	}
+	// DELETE is only used to cancel
+	lane, ok := s.inflight.acquire(inFlightLane{client: ctx.RemoteIP().String(), cancel: method == "DELETE"})
+	if !ok {
+		writeJSON(ctx, fasthttp.StatusTooManyRequests, map[string]string{
+			"error": "too many requests in flight: at most " + strconv.Itoa(s.inflight.max) + " order entry requests per client",
+		})
+		return nil, false
+	}
+	return func() { s.inflight.release(lane) }, true
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/valyala/fasthttp"
+)
+
+// RESTListener serves an APIServer on one TCP or Unix socket address.
+type RESTListener struct {
+	network   string
+	addr      string
+	reusePort bool
+	ln        net.Listener
+	server    *fasthttp.Server
+}
+
+// NewRESTListener binds api to addr. network is "tcp" or "unix".
+func NewRESTListener(network, addr string, api *APIServer) *RESTListener {
+	return &RESTListener{
+		network: network,
+		addr:    addr,
+		server:  &fasthttp.Server{Handler: api.HandleRequest},
+	}
+}
+
+// SetReusePort binds TCP addresses with SO_REUSEPORT so a replacement process
+// can listen on the same port while this one drains.
+func (l *RESTListener) SetReusePort(enabled bool) {
+	l.reusePort = enabled
+}
+
+func (l *RESTListener) Name() string {
+	return fmt.Sprintf("rest/%s %s", l.network, l.addr)
+}
+
+// Listen binds the address.
+func (l *RESTListener) Listen() error {
+	var err error
+	switch {
+	case l.network == "unix":
+		// Clear a socket left behind by a previous run
+		if err = os.Remove(l.addr); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if l.ln, err = net.Listen("unix", l.addr); err != nil {
+			return err
+		}
+		if err = os.Chmod(l.addr, 0660); err != nil {
+			l.ln.Close()
+			return err
+		}
+	case l.reusePort:
+		l.ln, err = listenReusePort(l.addr)
+	default:
+		l.ln, err = net.Listen(l.network, l.addr)
+	}
+	return err
+}
+
+// Serve blocks until the listener fails or Shutdown is called.
+func (l *RESTListener) Serve() error {
+	return l.server.Serve(l.ln)
+}
+
+// Shutdown stops accepting connections and waits for open ones to finish.
+func (l *RESTListener) Shutdown(ctx context.Context) error {
+	err := l.server.ShutdownWithContext(ctx)
+	if l.ln != nil {
+		l.ln.Close() // in case Serve never ran; already closed otherwise
+	}
+	return err
+}
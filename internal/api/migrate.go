@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"repello/internal/matching"
+	"repello/internal/models"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+// MigrationOrder is one resting order in an import or export file. Quantity is
+// what remains to be filled; Timestamp (UnixNano) sets priority on import.
+type MigrationOrder struct {
+	OrderID     string             `json:"order_id"`
+	Account     string             `json:"account_id,omitempty"`
+	Symbol      string             `json:"symbol"`
+	Side        models.Side        `json:"side"`
+	Price       int64              `json:"price"`
+	Quantity    int64              `json:"quantity"`
+	TimeInForce models.TimeInForce `json:"time_in_force,omitempty"`
+	ExpireAt    int64              `json:"expire_at,omitempty"`
+	Timestamp   int64              `json:"timestamp"`
+}
+
+// migrationColumns is the CSV header, in column order.
+var migrationColumns = []string{"order_id", "account_id", "symbol", "side", "price", "quantity", "time_in_force", "expire_at", "timestamp"}
+
+type ImportOrdersResponse struct {
+	DryRun   bool                   `json:"dry_run"`
+	Imported int                    `json:"imported"`
+	Errors   []matching.ImportError `json:"errors,omitempty"`
+}
+
+func (m MigrationOrder) order() *models.Order {
+	order := models.NewOrder(m.OrderID, m.Symbol, m.Side, models.Limit, m.Price, m.Quantity)
+	order.Account = m.Account
+	order.TimeInForce = m.TimeInForce
+	order.ExpireAt = m.ExpireAt
+	order.Timestamp = m.Timestamp
+	return order
+}
+
+func newMigrationOrder(order *models.Order) MigrationOrder {
+	return MigrationOrder{
+		OrderID:     order.ID,
+		Account:     order.Account,
+		Symbol:      order.Symbol,
+		Side:        order.Side,
+		Price:       order.Price,
+		Quantity:    order.RemainingQuantity,
+		TimeInForce: order.TimeInForce,
+		ExpireAt:    order.ExpireAt,
+		Timestamp:   order.Timestamp,
+	}
+}
+
+// handleImportOrders bulk-loads resting orders, as a JSON array or, with
+// ?format=csv, CSV with a header row. ?dry_run=true only validates.
+func (s *APIServer) handleImportOrders(ctx *fasthttp.RequestCtx) {
+	dryRun := string(ctx.QueryArgs().Peek("dry_run")) == "true"
+
+	var records []MigrationOrder
+	var err error
+	switch format := string(ctx.QueryArgs().Peek("format")); format {
+	case "", "json":
+		err = json.Unmarshal(ctx.PostBody(), &records)
+	case "csv":
+		records, err = readMigrationCSV(bytes.NewReader(ctx.PostBody()))
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid import file: " + err.Error()})
+		return
+	}
+
+	orders := make([]*models.Order, len(records))
+	for i, record := range records {
+		orders[i] = record.order()
+	}
+	response := ImportOrdersResponse{DryRun: dryRun, Errors: s.engine.ImportOrders(orders, dryRun)}
+	if len(response.Errors) > 0 {
+		writeJSON(ctx, fasthttp.StatusBadRequest, response)
+		return
+	}
+	if !dryRun {
+		response.Imported = len(orders)
+	}
+	writeJSON(ctx, fasthttp.StatusOK, response)
+}
+
+// handleExportOrders writes the resting orders of ?symbol=, or of every
+// symbol, in the import format, in priority order.
+func (s *APIServer) handleExportOrders(ctx *fasthttp.RequestCtx) {
+	orders := s.engine.ExportOrders(string(ctx.QueryArgs().Peek("symbol")))
+	records := make([]MigrationOrder, len(orders))
+	for i := range orders {
+		records[i] = newMigrationOrder(&orders[i])
+	}
+
+	switch format := string(ctx.QueryArgs().Peek("format")); format {
+	case "", "json":
+		writeJSON(ctx, fasthttp.StatusOK, records)
+	case "csv":
+		ctx.SetContentType("text/csv")
+		ctx.SetStatusCode(fasthttp.StatusOK)
+		writeMigrationCSV(ctx, records)
+	default:
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown format %q", format)})
+	}
+}
+
+func writeMigrationCSV(w io.Writer, records []MigrationOrder) error {
+	cw := csv.NewWriter(w)
+	cw.Write(migrationColumns)
+	for _, r := range records {
+		cw.Write([]string{
+			r.OrderID,
+			r.Account,
+			r.Symbol,
+			r.Side.String(),
+			strconv.FormatInt(r.Price, 10),
+			strconv.FormatInt(r.Quantity, 10),
+			r.TimeInForce.String(),
+			strconv.FormatInt(r.ExpireAt, 10),
+			strconv.FormatInt(r.Timestamp, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// readMigrationCSV reads records whose columns are named by a header row, so
+// columns may come in any order and optional ones may be left out.
+func readMigrationCSV(r io.Reader) ([]MigrationOrder, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("missing header row")
+	}
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[name] = i
+	}
+	for _, name := range []string{"order_id", "symbol", "side", "price", "quantity", "timestamp"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %s", name)
+		}
+	}
+
+	records := make([]MigrationOrder, 0, len(rows)-1)
+	for line, row := range rows[1:] {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return row[i]
+			}
+			return ""
+		}
+		number := func(name string) int64 {
+			v, parseErr := strconv.ParseInt(field(name), 10, 64)
+			if parseErr != nil && field(name) != "" && err == nil {
+				err = fmt.Errorf("line %d: invalid %s", line+2, name)
+			}
+			return v
+		}
+
+		record := MigrationOrder{
+			OrderID:   field("order_id"),
+			Account:   field("account_id"),
+			Symbol:    field("symbol"),
+			Price:     number("price"),
+			Quantity:  number("quantity"),
+			ExpireAt:  number("expire_at"),
+			Timestamp: number("timestamp"),
+		}
+		if sideErr := record.Side.UnmarshalJSON([]byte(field("side"))); sideErr != nil && err == nil {
+			err = fmt.Errorf("line %d: %w", line+2, sideErr)
+		}
+		if tifErr := record.TimeInForce.UnmarshalJSON([]byte(field("time_in_force"))); tifErr != nil && err == nil {
+			err = fmt.Errorf("line %d: %w", line+2, tifErr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
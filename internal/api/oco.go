@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"repello/internal/models"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// OCORequest submits two orders, such as a take-profit limit and a stop-loss,
+// as a one-cancels-other pair: once either fills completely the other is
+// cancelled.
+type OCORequest struct {
+	Orders []CreateOrderRequest `json:"orders"` // exactly two
+}
+
+// OCOResponse reports both orders of a pair, in request order. An order
+// cancelled because the other filled has Message set to why.
+type OCOResponse struct {
+	Orders []CreateOrderResponse `json:"orders"`
+}
+
+func (s *APIServer) handleCreateOCO(ctx *fasthttp.RequestCtx) {
+	var req OCORequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if len(req.Orders) != 2 {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "orders must list exactly 2 orders"})
+		return
+	}
+
+	first, second := req.Orders[0].order(), req.Orders[1].order()
+	matches, err := s.orders.SubmitOCO(first, second)
+	if err != nil {
+		status := fasthttp.StatusBadRequest
+		if isRateLimited(err) {
+			status = fasthttp.StatusTooManyRequests
+		} else if strings.HasPrefix(err.Error(), "duplicate order id") {
+			status = fasthttp.StatusConflict
+		}
+		writeJSON(ctx, status, map[string]string{"error": err.Error(), "order_id": first.ID, "linked_order_id": second.ID})
+		return
+	}
+
+	response := OCOResponse{Orders: make([]CreateOrderResponse, 0, 2)}
+	for i, order := range []*models.Order{first, second} {
+		r := CreateOrderResponse{
+			OrderID:           order.ID,
+			Status:            order.Status.String(),
+			TimeInForce:       order.TimeInForce,
+			Sequence:          order.Priority.Sequence,
+			Tag:               order.Tag,
+			Metadata:          order.Metadata,
+			Message:           order.CancelReason,
+			FilledQuantity:    order.FilledQuantity,
+			RemainingQuantity: order.RemainingQuantity,
+		}
+		if match := matches[i]; match != nil {
+			if len(match.Trades) > 0 {
+				r.Trades = tradeResponses(match.Trades, order.ID)
+			}
+			match.Release()
+		}
+		response.Orders = append(response.Orders, r)
+	}
+	writeJSON(ctx, fasthttp.StatusCreated, response)
+}
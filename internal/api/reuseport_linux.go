@@ -0,0 +1,4 @@
+package api
+
+// The syscall package doesn't export SO_REUSEPORT for every Linux arch.
+const soReusePort = 0xf
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"repello/internal/gateway"
+	"repello/internal/sandbox"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ResetSandboxRequest optionally seeds the sandbox after it is cleared.
+type ResetSandboxRequest struct {
+	Seed []sandbox.SeedSpec `json:"seed,omitempty"`
+}
+
+// EnableSandbox serves sb's engine under /sandbox, mirroring the live API, and
+// enables the sandbox admin endpoint.
+func (s *APIServer) EnableSandbox(sb *sandbox.Sandbox) {
+	// Same symbol names as live, so clients can switch with just the prefix
+	gw := gateway.New(sb.Engine(), gateway.Config{Normalizer: s.gateway.Normalizer()})
+	s.sandbox = &APIServer{
+		orders:    gw,
+		gateway:   gw,
+		engine:    sb.Engine(),
+		metrics:   sb.Metrics(),
+		paper:     sb,
+		lifecycle: s.lifecycle,
+		startTime: s.startTime,
+	}
+}
+
+// handleResetSandbox clears the sandbox and seeds it with the requested
+// synthetic liquidity.
+func (s *APIServer) handleResetSandbox(ctx *fasthttp.RequestCtx) {
+	var req ResetSandboxRequest
+	if body := ctx.PostBody(); len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+	}
+
+	sb := s.sandbox.paper
+	sb.Reset()
+	for _, spec := range req.Seed {
+		if err := sb.Seed(spec); err != nil {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	writeJSON(ctx, fasthttp.StatusOK, map[string]string{"status": "reset"})
+}
+
+// handleGetSandboxLedger lists sandbox balance movements, optionally only
+// ?account_id='s and those after ?after=SEQUENCE.
+func (s *APIServer) handleGetSandboxLedger(ctx *fasthttp.RequestCtx) {
+	var after int64
+	if param := string(ctx.QueryArgs().Peek("after")); param != "" {
+		var err error
+		if after, err = strconv.ParseInt(param, 10, 64); err != nil || after < 0 {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid after"})
+			return
+		}
+	}
+	account := string(ctx.QueryArgs().Peek("account_id"))
+	writeJSON(ctx, fasthttp.StatusOK, s.sandbox.paper.Ledger(account, after))
+}
+
+// handleReconcileSandbox checks the sandbox balances against the latest
+// ledger snapshot plus the entries since, answering 409 if they differ.
+func (s *APIServer) handleReconcileSandbox(ctx *fasthttp.RequestCtx) {
+	r := s.sandbox.paper.Reconcile()
+	if !r.OK {
+		log.Printf("admin %s: sandbox ledger does not reconcile: %d mismatches", ctx.RemoteAddr(), len(r.Mismatches))
+		writeJSON(ctx, fasthttp.StatusConflict, r)
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, r)
+}
+
+func (s *APIServer) handleGetBalance(ctx *fasthttp.RequestCtx, account string) {
+	if account == "" {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "account id is required"})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, s.paper.Balance(account))
+}
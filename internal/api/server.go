@@ -2,139 +2,745 @@ package api
 
 import (
 	"encoding/json"
+	"repello/internal/analytics"
+	"repello/internal/clearing"
+	"repello/internal/gateway"
+	"repello/internal/liquidity"
 	"repello/internal/matching"
 	"repello/internal/metrics"
 	"repello/internal/models"
+	"repello/internal/risk"
+	"repello/internal/sandbox"
+	"repello/internal/server"
+	"repello/internal/surveillance"
+	"repello/internal/tape"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/valyala/fasthttp"
 )
 
 // --- Request/Response Structs ---
 
 type CreateOrderRequest struct {
-	Symbol   string           `json:"symbol"`
-	Side     models.Side      `json:"side"`
-	Type     models.OrderType `json:"type"`
-	Price    int64            `json:"price,omitempty"` // Required for LIMIT, omit for MARKET
-	Quantity int64            `json:"quantity"`
+	// OrderID lets the client choose the order's ID, e.g. one assigned by
+	// another venue. It must be unique; omit it to have one generated.
+	OrderID     string             `json:"order_id,omitempty"`
+	Account     string             `json:"account_id,omitempty"`
+	Symbol      string             `json:"symbol"`
+	Side        models.Side        `json:"side"`
+	Type        models.OrderType   `json:"type"`
+	Price       int64              `json:"price,omitempty"` // Required for LIMIT, omit for MARKET, MARKET_TO_LIMIT, FIXING and STOP_MARKET
+	Quantity    int64              `json:"quantity"`
+	StopPrice   int64              `json:"stop_price,omitempty"`    // STOP_MARKET only: last trade price that triggers it
+	MinQuantity int64              `json:"min_quantity,omitempty"`  // least to trade on arrival, or nothing
+	TimeInForce models.TimeInForce `json:"time_in_force,omitempty"` // defaults per symbol
+	ExpireAt    int64              `json:"expire_at,omitempty"`     // UnixNano, GTD only
+	ReduceOnly  bool               `json:"reduce_only,omitempty"`
+	Hidden      bool               `json:"hidden,omitempty"`   // LIMIT only: rests without showing in depth or market data
+	Tag         string             `json:"tag,omitempty"`      // strategy tag, echoed in responses
+	Metadata    map[string]string  `json:"metadata,omitempty"` // free-form, echoed in responses
+	// TransactTime is when the client created the order (UnixNano). It must
+	// be within the server's clock skew tolerance.
+	TransactTime int64 `json:"transact_time,omitempty"`
+	// DisplayQuantity makes a LIMIT order an iceberg: the book shows no more
+	// than this much of it at a time.
+	DisplayQuantity int64 `json:"display_quantity,omitempty"`
+	// PostOnly makes a LIMIT order that never takes liquidity. If it would
+	// cross it is rejected or, with PostOnlyReprice, moved one tick behind the
+	// opposite side's best price.
+	PostOnly        bool `json:"post_only,omitempty"`
+	PostOnlyReprice bool `json:"post_only_reprice,omitempty"`
+	// PegType pegs a LIMIT order to the MIDPOINT or its own side's best
+	// (PRIMARY) price, repriced as the book moves; PegOffset ticks make it
+	// less aggressive. Its price is then the furthest the peg may take it.
+	PegType   models.PegType `json:"peg_type,omitempty"`
+	PegOffset int64          `json:"peg_offset,omitempty"`
 }
 
 type TradeResponse struct {
-	TradeID   string `json:"trade_id"`
-	Price     int64  `json:"price"`
-	Quantity  int64  `json:"quantity"`
-	Timestamp int64  `json:"timestamp"`
+	TradeID       string           `json:"trade_id"`
+	Symbol        string           `json:"symbol"`
+	Price         int64            `json:"price"`
+	Quantity      int64            `json:"quantity"`
+	AggressorSide models.Side      `json:"aggressor_side"`
+	MakerOrderID  string           `json:"maker_order_id"`
+	TakerOrderID  string           `json:"taker_order_id"`
+	Liquidity     models.Liquidity `json:"liquidity"` // from the requesting order's point of view
+	Fee           int64            `json:"fee"`       // charged to the requesting order; negative is a rebate
+	Flags         []string         `json:"flags,omitempty"`
+	SettlementRef string           `json:"settlement_ref,omitempty"`
+	Timestamp     int64            `json:"timestamp"`
 }
 
 type CreateOrderResponse struct {
+	OrderID           string             `json:"order_id"`
+	Status            string             `json:"status"`
+	TimeInForce       models.TimeInForce `json:"time_in_force"`
+	Sequence          uint64             `json:"sequence,omitempty"` // time priority within the symbol; unset if rejected
+	Tag               string             `json:"tag,omitempty"`
+	Metadata          map[string]string  `json:"metadata,omitempty"`
+	Message           string             `json:"message,omitempty"`
+	FilledQuantity    int64              `json:"filled_quantity,omitempty"`
+	RemainingQuantity int64              `json:"remaining_quantity,omitempty"`
+	// SkippedQuantity is resting quantity of the order's own firm passed over
+	// under the firm's no-cross option.
+	SkippedQuantity int64           `json:"skipped_quantity,omitempty"`
+	Trades          []TradeResponse `json:"trades,omitempty"`
+	// RepricedTo is the price a post-only order was moved to so as not to
+	// cross, or a pegged order's price on entry.
+	RepricedTo int64 `json:"repriced_to,omitempty"`
+}
+
+// ReduceOrderRequest shrinks a resting order. Set exactly one field.
+type ReduceOrderRequest struct {
+	ReduceBy    int64 `json:"reduce_by,omitempty"`
+	NewQuantity int64 `json:"new_quantity,omitempty"` // new total quantity, including filled
+}
+
+type ReduceOrderResponse struct {
+	OrderID           string             `json:"order_id"`
+	Status            string             `json:"status"`
+	TimeInForce       models.TimeInForce `json:"time_in_force"`
+	Quantity          int64              `json:"quantity"`
+	FilledQuantity    int64              `json:"filled_quantity"`
+	RemainingQuantity int64              `json:"remaining_quantity"`
+}
+
+// MaxBatchAmendments caps how many orders one batch amend may change.
+const MaxBatchAmendments = 1000
+
+// BatchAmendRequest amends many resting orders at once. Each symbol's
+// amendments are applied together or, if any is invalid, not at all.
+type BatchAmendRequest struct {
+	Amendments []AmendOrderRequest `json:"amendments"`
+}
+
+// AmendOrderRequest is one order's change. A new price replaces the order
+// with a new one at that price; quantity can only go down.
+type AmendOrderRequest struct {
+	OrderID     string `json:"order_id"`
+	Price       int64  `json:"price,omitempty"`
+	ReduceBy    int64  `json:"reduce_by,omitempty"`
+	NewQuantity int64  `json:"new_quantity,omitempty"` // new total quantity, including filled
+}
+
+// ShiftOrdersRequest moves all of an account's resting orders in a symbol by
+// a number of ticks, negative to move them down.
+type ShiftOrdersRequest struct {
+	Account string `json:"account_id"`
+	Symbol  string `json:"symbol"`
+	Ticks   int64  `json:"ticks"`
+}
+
+type BatchAmendResponse struct {
+	Results []AmendOrderResponse `json:"results"` // in request order
+}
+
+type AmendOrderResponse struct {
 	OrderID           string          `json:"order_id"`
-	Status            string          `json:"status"`
-	Message           string          `json:"message,omitempty"`
+	NewOrderID        string          `json:"new_order_id,omitempty"` // the replacement, if repriced
+	Status            string          `json:"status,omitempty"`       // of the order, or its replacement
+	Price             int64           `json:"price,omitempty"`
+	Quantity          int64           `json:"quantity,omitempty"`
 	FilledQuantity    int64           `json:"filled_quantity,omitempty"`
 	RemainingQuantity int64           `json:"remaining_quantity,omitempty"`
-	Trades            []TradeResponse `json:"trades,omitempty"`
+	Trades            []TradeResponse `json:"trades,omitempty"` // the replacement's, if it crossed
+	Error             string          `json:"error,omitempty"`
 }
 
 type CancelOrderResponse struct {
-	OrderID string `json:"order_id"`
-	Status  string `json:"status"`
+	OrderID     string             `json:"order_id"`
+	Status      string             `json:"status"`
+	TimeInForce models.TimeInForce `json:"time_in_force"`
 }
 
 type GetOrderResponse struct {
-	OrderID        string           `json:"order_id"`
-	Symbol         string           `json:"symbol"`
-	Side           models.Side      `json:"side"`
-	Type           models.OrderType `json:"type"`
-	Price          int64            `json:"price"`
-	Quantity       int64            `json:"quantity"`
-	FilledQuantity int64            `json:"filled_quantity"`
-	Status         string           `json:"status"`
-	Timestamp      int64            `json:"timestamp"`
+	OrderID        string             `json:"order_id"`
+	Account        string             `json:"account_id,omitempty"`
+	Symbol         string             `json:"symbol"`
+	Side           models.Side        `json:"side"`
+	Type           models.OrderType   `json:"type"`
+	Price          int64              `json:"price"`
+	Quantity       int64              `json:"quantity"`
+	FilledQuantity int64              `json:"filled_quantity"`
+	Status         string             `json:"status"`
+	CancelReason   string             `json:"cancel_reason,omitempty"`
+	TimeInForce    models.TimeInForce `json:"time_in_force"`
+	ExpireAt       int64              `json:"expire_at,omitempty"`
+	ReduceOnly     bool               `json:"reduce_only,omitempty"`
+	Hidden         bool               `json:"hidden,omitempty"`
+	MinQuantity    int64              `json:"min_quantity,omitempty"`
+	StopPrice      int64              `json:"stop_price,omitempty"`
+	Sequence       uint64             `json:"sequence,omitempty"`
+	Tag            string             `json:"tag,omitempty"`
+	Metadata       map[string]string  `json:"metadata,omitempty"`
+	TransactTime   int64              `json:"transact_time,omitempty"`
+	ClockSkew      int64              `json:"clock_skew,omitempty"`
+	Timestamp      int64              `json:"timestamp"`
+	AcceptedAt     int64              `json:"accepted_at,omitempty"`
+	FirstFillAt    int64              `json:"first_fill_at,omitempty"`
+	TriggeredAt    int64              `json:"triggered_at,omitempty"`
+	CompletedAt    int64              `json:"completed_at,omitempty"`
+	// Icebergs only: the most the book shows at a time, and what is left of
+	// the slice it shows now.
+	DisplayQuantity int64 `json:"display_quantity,omitempty"`
+	VisibleQuantity int64 `json:"visible_quantity,omitempty"`
+	PostOnly        bool  `json:"post_only,omitempty"`
+	PostOnlyReprice bool  `json:"post_only_reprice,omitempty"`
+	// Pegged orders only: price is where the peg has them now.
+	PegType   models.PegType `json:"peg_type,omitempty"`
+	PegOffset int64          `json:"peg_offset,omitempty"`
+	PegLimit  int64          `json:"peg_limit,omitempty"`
+}
+
+type OrderHistoryResponse struct {
+	OrderID string               `json:"order_id"`
+	Events  []gateway.OrderEvent `json:"events"` // oldest first
+}
+
+// MaxQueryOrders caps how many orders one bulk query may ask for.
+const MaxQueryOrders = 1000
+
+type QueryOrdersRequest struct {
+	OrderIDs []string `json:"order_ids"`
+}
+
+type QueryOrdersResponse struct {
+	Orders   []GetOrderResponse `json:"orders"`              // in request order
+	NotFound []string           `json:"not_found,omitempty"` // requested IDs the engine doesn't know
 }
 
 type HealthResponse struct {
-	Status          string `json:"status"`
-	UptimeSeconds   int64  `json:"uptime_seconds"`
-	OrdersProcessed int64  `json:"orders_processed"`
+	Status          string       `json:"status"`
+	State           server.State `json:"state"` // lifecycle state
+	UptimeSeconds   int64        `json:"uptime_seconds"`
+	OrdersProcessed int64        `json:"orders_processed"`
 }
 
-// APIServer is the HTTP server for the matching engine.
+// ProbeTimeout bounds the deep readiness check.
+const ProbeTimeout = time.Second
+
+// TimeResponse is the server clock, for clients estimating their offset from
+// it. ReceiveTime is taken as the request is handled and TransmitTime just
+// before the response is encoded; with the client's own send and receive
+// times, offset = ((ReceiveTime - sent) + (TransmitTime - received)) / 2.
+type TimeResponse struct {
+	ReceiveTime  int64  `json:"receive_time"`          // UnixNano
+	TransmitTime int64  `json:"transmit_time"`         // UnixNano
+	ISO          string `json:"iso"`                   // TransmitTime, RFC 3339
+	ClientTime   int64  `json:"client_time,omitempty"` // ?client_time echoed back
+}
+
+type ReadyResponse struct {
+	Status string       `json:"status"` // "ready" or "not_ready"
+	State  server.State `json:"state"`
+	// Deep is the result of the end-to-end order probe, if one was requested.
+	Deep *gateway.ProbeResult `json:"deep,omitempty"`
+}
+
+// APIServer serves the REST API for the matching engine. It is protocol
+// plumbing only; RESTListener binds it to addresses.
 type APIServer struct {
-	listenAddr string
-	engine     *matching.Engine
-	metrics    *metrics.Metrics
-	startTime  time.Time
+	orders    gateway.OrderEntry // order entry; queries go to engine directly
+	gateway   *gateway.Gateway
+	engine    *matching.Engine
+	metrics   *metrics.Metrics
+	alerts    *surveillance.AlertStore
+	credit    *risk.CreditLimits
+	positions *risk.PositionMonitor
+	sandbox   *APIServer       // serves /sandbox, nil unless enabled
+	paper     *sandbox.Sandbox // set on the sandbox's own APIServer
+	lifecycle *server.Lifecycle
+	analytics *analytics.Collector
+	liquidity *liquidity.Tracker
+	dmm       *liquidity.ObligationMonitor // designated market maker quote obligations
+	inflight  *inFlightLimit               // nil unless SetMaxInFlight
+	clearing  *clearing.Outbox
+	tape      *tape.Tape // serves /api/v1/tape, nil unless SetTape
+	startTime time.Time
 }
 
 // NewAPIServer creates a new APIServer.
-func NewAPIServer(listenAddr string, engine *matching.Engine, metrics *metrics.Metrics) *APIServer {
+func NewAPIServer(gw *gateway.Gateway, metrics *metrics.Metrics, alerts *surveillance.AlertStore, credit *risk.CreditLimits, positions *risk.PositionMonitor) *APIServer {
 	return &APIServer{
-		listenAddr: listenAddr,
-		engine:     engine,
-		metrics:    metrics,
-		startTime:  time.Now(),
+		orders:    gw,
+		gateway:   gw,
+		engine:    gw.Engine(),
+		metrics:   metrics,
+		alerts:    alerts,
+		credit:    credit,
+		positions: positions,
+		startTime: time.Now(),
 	}
 }
 
-// Run starts the HTTP server.
-func (s *APIServer) Run() error {
-	// fasthttp RequestHandler
-	handler := func(ctx *fasthttp.RequestCtx) {
-		path := string(ctx.Path())
-		method := string(ctx.Method())
+// SetLifecycle makes health checks report l's state. Without one the server
+// counts as Ready whenever it is answering.
+func (s *APIServer) SetLifecycle(l *server.Lifecycle) {
+	s.lifecycle = l
+	if s.sandbox != nil {
+		s.sandbox.lifecycle = l
+	}
+}
 
-		switch path {
-		case "/api/v1/orders":
+func (s *APIServer) state() server.State {
+	if s.lifecycle == nil {
+		return server.Ready
+	}
+	return s.lifecycle.State()
+}
+
+// HandleRequest is the fasthttp RequestHandler routing REST requests.
+func (s *APIServer) HandleRequest(ctx *fasthttp.RequestCtx) {
+	path := string(ctx.Path())
+	done, ok := s.admitInFlight(ctx, string(ctx.Method()), path)
+	if !ok {
+		return
+	}
+	defer done()
+	s.route(ctx, path)
+}
+
+func (s *APIServer) route(ctx *fasthttp.RequestCtx, path string) {
+	method := string(ctx.Method())
+
+	if s.sandbox != nil && strings.HasPrefix(path, "/sandbox/") {
+		s.sandbox.route(ctx, strings.TrimPrefix(path, "/sandbox"))
+		return
+	}
+
+	switch path {
+	case "/api/v1/orders":
+		if method == "POST" {
+			s.handleCreateOrder(ctx)
+		} else {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		}
+	case "/api/v1/symbols":
+		if method == "GET" {
+			s.handleListSymbols(ctx)
+		} else {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		}
+	case "/api/v1/orders/oco":
+		if method == "POST" {
+			s.handleCreateOCO(ctx)
+		} else {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		}
+	case "/api/v1/orders/amend/batch":
+		if method == "POST" {
+			s.handleBatchAmend(ctx)
+		} else {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		}
+	case "/api/v1/orders/shift":
+		if method == "POST" {
+			s.handleShiftOrders(ctx)
+		} else {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		}
+	case "/api/v1/orders/query":
+		if method == "POST" {
+			s.handleQueryOrders(ctx)
+		} else {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		}
+	case "/api/v1/orders/simulate":
+		if method == "POST" {
+			s.handleSimulateOrder(ctx)
+		} else {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		}
+	case "/api/v1/snapshot":
+		if method == "GET" {
+			s.handleGetSnapshot(ctx)
+		} else {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		}
+	case "/api/v1/time":
+		if method == "GET" {
+			s.handleGetTime(ctx)
+		} else {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		}
+	case "/health":
+		if method == "GET" {
+			s.handleHealthCheck(ctx)
+		} else {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		}
+	case "/health/ready":
+		if method == "GET" {
+			s.handleReadyCheck(ctx)
+		} else {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		}
+	case "/metrics":
+		if method == "GET" {
+			s.handleGetMetrics(ctx)
+		} else {
+			ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+		}
+	default:
+		// Handle paths with parameters (e.g., /api/v1/orders/{id})
+		if strings.HasPrefix(path, "/api/v1/orders/") && strings.HasSuffix(path, "/history") {
+			if method == "GET" {
+				id := strings.TrimSuffix(strings.TrimPrefix(path, "/api/v1/orders/"), "/history")
+				s.handleGetOrderHistory(ctx, id)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if strings.HasPrefix(path, "/api/v1/orders/") && strings.HasSuffix(path, "/queue") {
+			if method == "GET" {
+				id := strings.TrimSuffix(strings.TrimPrefix(path, "/api/v1/orders/"), "/queue")
+				s.handleGetQueuePosition(ctx, id)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if strings.HasPrefix(path, "/api/v1/orders/") {
+			if method == "DELETE" {
+				// Extract ID: /api/v1/orders/{id}
+				id := strings.TrimPrefix(path, "/api/v1/orders/")
+				s.handleCancelOrder(ctx, id)
+			} else if method == "GET" {
+				id := strings.TrimPrefix(path, "/api/v1/orders/")
+				s.handleGetOrder(ctx, id)
+			} else if method == "PATCH" {
+				id := strings.TrimPrefix(path, "/api/v1/orders/")
+				s.handleReduceOrder(ctx, id)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.tape != nil && strings.HasPrefix(path, "/api/v1/tape/") {
+			if method == "GET" {
+				s.handleGetTape(ctx, strings.TrimPrefix(path, "/api/v1/tape/"))
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if strings.HasPrefix(path, "/api/v1/accounts/") && strings.HasSuffix(path, "/exposure") {
+			if method == "GET" {
+				account := strings.TrimSuffix(strings.TrimPrefix(path, "/api/v1/accounts/"), "/exposure")
+				s.handleGetAccountExposure(ctx, account)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if strings.HasPrefix(path, "/api/v1/accounts/") && strings.HasSuffix(path, "/summary") {
+			if method == "GET" {
+				account := strings.TrimSuffix(strings.TrimPrefix(path, "/api/v1/accounts/"), "/summary")
+				s.handleGetAccountSummary(ctx, account)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if strings.HasPrefix(path, "/api/v1/accounts/") && strings.HasSuffix(path, "/notifications") {
+			account := strings.TrimSuffix(strings.TrimPrefix(path, "/api/v1/accounts/"), "/notifications")
+			if method == "GET" {
+				s.handleGetNotifications(ctx, account)
+			} else if method == "PUT" {
+				s.handleSetNotifications(ctx, account)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.positions != nil && strings.HasPrefix(path, "/api/v1/accounts/") && strings.HasSuffix(path, "/positions") {
+			if method == "GET" {
+				account := strings.TrimSuffix(strings.TrimPrefix(path, "/api/v1/accounts/"), "/positions")
+				s.handleGetPositions(ctx, account)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.positions != nil && strings.HasPrefix(path, "/api/v1/admin/accounts/") && strings.HasSuffix(path, "/reduce-only") {
+			if method == "PUT" {
+				account := strings.TrimSuffix(strings.TrimPrefix(path, "/api/v1/admin/accounts/"), "/reduce-only")
+				s.handleSetReduceOnly(ctx, account)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.positions != nil && path == "/api/v1/admin/position-limits" {
+			if method == "PUT" {
+				s.handleSetPositionLimit(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.positions != nil && path == "/api/v1/admin/breaches" {
+			if method == "GET" {
+				writeJSON(ctx, fasthttp.StatusOK, s.positions.Breaches())
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.paper != nil && strings.HasPrefix(path, "/api/v1/accounts/") && strings.HasSuffix(path, "/balance") {
+			if method == "GET" {
+				account := strings.TrimSuffix(strings.TrimPrefix(path, "/api/v1/accounts/"), "/balance")
+				s.handleGetBalance(ctx, account)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.sandbox != nil && path == "/api/v1/admin/sandbox/reset" {
+			if method == "POST" {
+				s.handleResetSandbox(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.sandbox != nil && path == "/api/v1/admin/sandbox/ledger" {
+			if method == "GET" {
+				s.handleGetSandboxLedger(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.sandbox != nil && path == "/api/v1/admin/sandbox/reconcile" {
+			if method == "GET" {
+				s.handleReconcileSandbox(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if path == "/api/v1/admin/orders/import" {
 			if method == "POST" {
-				s.handleCreateOrder(ctx)
+				s.handleImportOrders(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if path == "/api/v1/admin/orders/export" {
+			if method == "GET" {
+				s.handleExportOrders(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if path == "/api/v1/admin/symbol-config" {
+			if method == "GET" {
+				writeJSON(ctx, fasthttp.StatusOK, s.engine.SymbolConfigs())
+			} else if method == "PUT" {
+				s.handleSetSymbolConfigs(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if path == "/api/v1/admin/lifecycle" {
+			if method == "GET" {
+				s.handleGetLifecycle(ctx)
 			} else {
 				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
 			}
-		case "/health":
+			return
+		}
+		if s.analytics != nil && path == "/api/v1/admin/analytics/daily" {
+			if method == "GET" {
+				s.handleGetDailyAnalytics(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.analytics != nil && strings.HasPrefix(path, "/api/v1/admin/analytics/spreads/") {
+			if method == "GET" {
+				s.handleGetSpreads(ctx, strings.TrimPrefix(path, "/api/v1/admin/analytics/spreads/"))
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.analytics != nil && strings.HasPrefix(path, "/api/v1/admin/analytics/averages/") {
+			if method == "GET" {
+				s.handleGetAverages(ctx, strings.TrimPrefix(path, "/api/v1/admin/analytics/averages/"))
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.liquidity != nil && path == "/api/v1/admin/liquidity-providers" {
+			if method == "GET" {
+				s.handleGetLiquidityProviders(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.dmm != nil && path == "/api/v1/admin/quote-obligations" {
+			if method == "GET" {
+				s.handleGetObligations(ctx)
+			} else if method == "PUT" {
+				s.handleSetObligation(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.positions != nil && path == "/api/v1/admin/analytics/open-interest" {
 			if method == "GET" {
-				s.handleHealthCheck(ctx)
+				s.handleGetOpenInterest(ctx)
 			} else {
 				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
 			}
-		case "/metrics":
+			return
+		}
+		if path == "/api/v1/admin/rejects" {
 			if method == "GET" {
-				s.handleGetMetrics(ctx)
+				s.handleGetRejects(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if path == "/api/v1/admin/fixing" {
+			if method == "POST" {
+				s.handlePublishFixing(ctx)
 			} else {
 				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
 			}
-		default:
-			// Handle paths with parameters (e.g., /api/v1/orders/{id})
-			if strings.HasPrefix(path, "/api/v1/orders/") {
-				if method == "DELETE" {
-					// Extract ID: /api/v1/orders/{id}
-					id := strings.TrimPrefix(path, "/api/v1/orders/")
-					s.handleCancelOrder(ctx, id)
-				} else if method == "GET" {
-					id := strings.TrimPrefix(path, "/api/v1/orders/")
-					s.handleGetOrder(ctx, id)
-				} else {
-					ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
-				}
-				return
+			return
+		}
+		if path == "/api/v1/admin/cancel-all" {
+			if method == "POST" {
+				s.handleCancelAll(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
 			}
-			if strings.HasPrefix(path, "/api/v1/orderbook/") {
-				if method == "GET" {
-					symbol := strings.TrimPrefix(path, "/api/v1/orderbook/")
-					s.handleGetOrderBook(ctx, symbol)
-				} else {
-					ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
-				}
-				return
+			return
+		}
+		if path == "/api/v1/admin/purge-stale" {
+			if method == "POST" {
+				s.handlePurgeStale(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
 			}
-			ctx.Error("Not Found", fasthttp.StatusNotFound)
+			return
 		}
+		if s.credit != nil && strings.HasPrefix(path, "/api/v1/admin/firms/") {
+			id := strings.TrimPrefix(path, "/api/v1/admin/firms/")
+			if method == "GET" {
+				s.handleGetFirm(ctx, id)
+			} else if method == "PUT" {
+				s.handleUpdateFirm(ctx, id)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.clearing != nil && path == "/api/v1/admin/settlements" {
+			if method == "GET" {
+				s.handleListSettlements(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.clearing != nil && strings.HasPrefix(path, "/api/v1/admin/settlements/") && strings.HasSuffix(path, "/retry") {
+			if method == "POST" {
+				id := strings.TrimSuffix(strings.TrimPrefix(path, "/api/v1/admin/settlements/"), "/retry")
+				s.handleRetrySettlement(ctx, id)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.clearing != nil && strings.HasPrefix(path, "/api/v1/trades/") && strings.HasSuffix(path, "/settlement") {
+			if method == "GET" {
+				id := strings.TrimSuffix(strings.TrimPrefix(path, "/api/v1/trades/"), "/settlement")
+				s.handleGetSettlement(ctx, id)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.alerts != nil && path == "/api/v1/admin/alerts" {
+			if method == "GET" {
+				s.handleListAlerts(ctx)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if s.alerts != nil && strings.HasPrefix(path, "/api/v1/admin/alerts/") {
+			id := strings.TrimPrefix(path, "/api/v1/admin/alerts/")
+			if method == "GET" {
+				s.handleGetAlert(ctx, id)
+			} else if method == "PATCH" {
+				s.handleUpdateAlert(ctx, id)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if strings.HasPrefix(path, "/api/v1/symbols/") {
+			if method == "GET" {
+				s.handleGetSymbol(ctx, strings.TrimPrefix(path, "/api/v1/symbols/"))
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		if strings.HasPrefix(path, "/api/v1/orderbook/") {
+			if method == "GET" {
+				symbol := strings.TrimPrefix(path, "/api/v1/orderbook/")
+				s.handleGetOrderBook(ctx, symbol)
+			} else {
+				ctx.Error("Method not allowed", fasthttp.StatusMethodNotAllowed)
+			}
+			return
+		}
+		ctx.Error("Not Found", fasthttp.StatusNotFound)
 	}
+}
 
-	return fasthttp.ListenAndServe(s.listenAddr, handler)
+// order builds the order req asks for.
+func (req CreateOrderRequest) order() *models.Order {
+	order := models.NewOrder(
+		req.OrderID,
+		req.Symbol,
+		req.Side,
+		req.Type,
+		req.Price,
+		req.Quantity,
+	)
+	order.Account = req.Account
+	order.TimeInForce = req.TimeInForce
+	order.ExpireAt = req.ExpireAt
+	order.ReduceOnly = req.ReduceOnly
+	order.MinQuantity = req.MinQuantity
+	order.StopPrice = req.StopPrice
+	order.DisplayQuantity = req.DisplayQuantity
+	order.PostOnly, order.PostOnlyReprice = req.PostOnly, req.PostOnlyReprice
+	order.PegType, order.PegOffset = req.PegType, req.PegOffset
+	order.Hidden = req.Hidden
+	order.Tag = req.Tag
+	order.Metadata = req.Metadata
+	order.TransactTime = req.TransactTime
+	return order
 }
 
 func (s *APIServer) handleCreateOrder(ctx *fasthttp.RequestCtx) {
@@ -145,41 +751,43 @@ func (s *APIServer) handleCreateOrder(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	order := models.NewOrder(
-		uuid.New().String(),
-		req.Symbol,
-		req.Side,
-		req.Type,
-		req.Price,
-		req.Quantity,
-	)
-
-	result, err := s.engine.ProcessOrder(order)
+	order := req.order()
+	result, err := s.orders.Submit(order)
 	if err != nil {
-		if strings.Contains(err.Error(), "insufficient liquidity") {
-			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		// Rejections name the order, so a fill-or-kill that was killed can be
+		// told from a malformed request
+		rejection := map[string]string{"error": err.Error(), "order_id": order.ID, "status": order.Status.String()}
+		if isRateLimited(err) {
+			writeJSON(ctx, fasthttp.StatusTooManyRequests, rejection)
 			return
 		}
-		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		if strings.HasPrefix(err.Error(), "duplicate order id") {
+			writeJSON(ctx, fasthttp.StatusConflict, rejection)
+			return
+		}
+		writeJSON(ctx, fasthttp.StatusBadRequest, rejection)
 		return
 	}
 
 	response := CreateOrderResponse{
-		OrderID: order.ID,
-		Status:  order.Status.String(),
+		OrderID:     order.ID,
+		Status:      order.Status.String(),
+		TimeInForce: order.TimeInForce,
+		Sequence:    order.Priority.Sequence,
+		Tag:         order.Tag,
+		Metadata:    order.Metadata,
+	}
+	if (order.PostOnly || order.PegType != models.PegNone) && order.Price != req.Price {
+		response.RepricedTo = order.Price
 	}
 
 	if result != nil && len(result.Trades) > 0 {
-		response.Trades = make([]TradeResponse, len(result.Trades))
-		for i, trade := range result.Trades {
-			response.Trades[i] = TradeResponse{
-				TradeID:   trade.ID,
-				Price:     trade.Price,
-				Quantity:  trade.Quantity,
-				Timestamp: trade.Timestamp,
-			}
-		}
+		response.Trades = tradeResponses(result.Trades, order.ID)
 	}
+	sweepCapped := result.SweepCapped
+	response.SkippedQuantity = result.SkippedQuantity
+	// The response holds copies of everything it needs from the result.
+	result.Release()
 
 	switch order.Status {
 	case models.Accepted:
@@ -193,14 +801,39 @@ func (s *APIServer) handleCreateOrder(ctx *fasthttp.RequestCtx) {
 		response.FilledQuantity = order.FilledQuantity
 		writeJSON(ctx, fasthttp.StatusOK, response)
 	case models.Cancelled:
+		response.FilledQuantity = order.FilledQuantity
+		if sweepCapped {
+			response.Message = "Sweep limit reached, remaining quantity cancelled"
+		} else {
+			response.Message = "Immediate or cancel, remaining quantity cancelled"
+		}
 		writeJSON(ctx, fasthttp.StatusOK, response)
 	}
 }
 
+// handleSimulateOrder previews an order against the current book without
+// submitting it.
+func (s *APIServer) handleSimulateOrder(ctx *fasthttp.RequestCtx) {
+	var req CreateOrderRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	sim, err := s.engine.SimulateOrder(req.order())
+	if err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, sim)
+}
+
 func (s *APIServer) handleCancelOrder(ctx *fasthttp.RequestCtx, orderID string) {
-	order, err := s.engine.CancelOrder(orderID)
+	order, err := s.orders.Cancel(orderID)
 	if err != nil {
-		if err.Error() == "cannot cancel: order already filled" {
+		if isRateLimited(err) {
+			writeJSON(ctx, fasthttp.StatusTooManyRequests, map[string]string{"error": err.Error()})
+		} else if err.Error() == "cannot cancel: order already filled" {
 			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
 		} else if err.Error() == "order not found" {
 			writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Order not found"})
@@ -211,12 +844,165 @@ func (s *APIServer) handleCancelOrder(ctx *fasthttp.RequestCtx, orderID string)
 	}
 
 	response := CancelOrderResponse{
-		OrderID: order.ID,
-		Status:  order.Status.String(),
+		OrderID:     order.ID,
+		Status:      order.Status.String(),
+		TimeInForce: order.TimeInForce,
+	}
+	writeJSON(ctx, fasthttp.StatusOK, response)
+}
+
+func (s *APIServer) handleReduceOrder(ctx *fasthttp.RequestCtx, orderID string) {
+	var req ReduceOrderRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	order, err := s.orders.Amend(orderID, gateway.Amendment{ReduceBy: req.ReduceBy, NewQuantity: req.NewQuantity})
+	if err != nil {
+		if isRateLimited(err) {
+			writeJSON(ctx, fasthttp.StatusTooManyRequests, map[string]string{"error": err.Error()})
+		} else if err.Error() == "order not found" {
+			writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Order not found"})
+		} else {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+
+	response := ReduceOrderResponse{
+		OrderID:           order.ID,
+		Status:            order.Status.String(),
+		TimeInForce:       order.TimeInForce,
+		Quantity:          order.OriginalQuantity,
+		FilledQuantity:    order.FilledQuantity,
+		RemainingQuantity: order.RemainingQuantity,
 	}
 	writeJSON(ctx, fasthttp.StatusOK, response)
 }
 
+// tradeResponses describes trades from the point of view of orderID.
+func tradeResponses(trades []*models.Trade, orderID string) []TradeResponse {
+	out := make([]TradeResponse, len(trades))
+	for i, trade := range trades {
+		out[i] = TradeResponse{
+			TradeID:       trade.ID,
+			Symbol:        trade.Symbol,
+			Price:         trade.Price,
+			Quantity:      trade.Quantity,
+			AggressorSide: trade.AggressorSide,
+			MakerOrderID:  trade.MakerOrderID,
+			TakerOrderID:  trade.TakerOrderID,
+			Liquidity:     trade.LiquidityFor(orderID),
+			Fee:           trade.FeeFor(orderID),
+			Flags:         append([]string(nil), trade.Flags...),
+			SettlementRef: trade.SettlementRef,
+			Timestamp:     trade.Timestamp,
+		}
+	}
+	return out
+}
+
+// handleBatchAmend reprices or reduces many resting orders, e.g. a quote
+// ladder, reporting each amendment's outcome.
+func (s *APIServer) handleBatchAmend(ctx *fasthttp.RequestCtx) {
+	var req BatchAmendRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if len(req.Amendments) == 0 || len(req.Amendments) > MaxBatchAmendments {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "amendments must list 1 to " + strconv.Itoa(MaxBatchAmendments) + " orders"})
+		return
+	}
+
+	amendments := make([]gateway.OrderAmendment, len(req.Amendments))
+	for i, a := range req.Amendments {
+		amendments[i] = gateway.OrderAmendment{
+			OrderID:   a.OrderID,
+			Price:     a.Price,
+			Amendment: gateway.Amendment{ReduceBy: a.ReduceBy, NewQuantity: a.NewQuantity},
+		}
+	}
+	results, err := s.orders.AmendBatch(amendments)
+	if err != nil {
+		if isRateLimited(err) {
+			writeJSON(ctx, fasthttp.StatusTooManyRequests, map[string]string{"error": err.Error()})
+		} else {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+
+	writeJSON(ctx, fasthttp.StatusOK, BatchAmendResponse{Results: amendResponses(results)})
+}
+
+// handleShiftOrders moves an account's resting orders in a symbol by a number
+// of ticks, e.g. to follow a fast market.
+func (s *APIServer) handleShiftOrders(ctx *fasthttp.RequestCtx) {
+	var req ShiftOrdersRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	results, err := s.orders.Shift(req.Account, req.Symbol, req.Ticks)
+	if err != nil {
+		if isRateLimited(err) {
+			writeJSON(ctx, fasthttp.StatusTooManyRequests, map[string]string{"error": err.Error()})
+		} else if err.Error() == "order book not found" {
+			writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": err.Error()})
+		} else {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, BatchAmendResponse{Results: amendResponses(results)})
+}
+
+// amendResponses describes the outcome of each amendment of a batch, releasing
+// the replacements' match results.
+func amendResponses(results []matching.AmendResult) []AmendOrderResponse {
+	out := make([]AmendOrderResponse, len(results))
+	for i, result := range results {
+		r := AmendOrderResponse{OrderID: result.OrderID}
+		if result.Err != nil {
+			r.Error = result.Err.Error()
+		}
+		if order := result.Order; order != nil {
+			if order.ID != result.OrderID {
+				r.NewOrderID = order.ID
+			}
+			r.Status = order.Status.String()
+			r.Price = order.Price
+			r.Quantity = order.OriginalQuantity
+			r.FilledQuantity = order.FilledQuantity
+			r.RemainingQuantity = order.RemainingQuantity
+		}
+		if result.Match != nil {
+			if len(result.Match.Trades) > 0 {
+				r.Trades = tradeResponses(result.Match.Trades, result.Order.ID)
+			}
+			result.Match.Release()
+		}
+		out[i] = r
+	}
+	return out
+}
+
+// handleGetOrderHistory lists every state transition the gateway saw for an
+// order, including rejection.
+func (s *APIServer) handleGetOrderHistory(ctx *fasthttp.RequestCtx, orderID string) {
+	events, ok := s.gateway.History(orderID)
+	if !ok {
+		writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Order not found"})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, OrderHistoryResponse{OrderID: orderID, Events: events})
+}
+
+func isRateLimited(err error) bool {
+	return strings.HasPrefix(err.Error(), "rate limit exceeded")
+}
+
 func (s *APIServer) handleGetOrderBook(ctx *fasthttp.RequestCtx, symbol string) {
 	depthParam := string(ctx.QueryArgs().Peek("depth"))
 	depthVal := 0
@@ -228,7 +1014,7 @@ func (s *APIServer) handleGetOrderBook(ctx *fasthttp.RequestCtx, symbol string)
 		}
 	}
 
-	depth, err := s.engine.GetOrderBookDepth(symbol, depthVal)
+	depth, err := s.engine.GetOrderBookDepth(s.gateway.Normalizer().Symbol(symbol), depthVal)
 	if err != nil {
 		writeJSON(ctx, fasthttp.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -244,8 +1030,38 @@ func (s *APIServer) handleGetOrder(ctx *fasthttp.RequestCtx, orderID string) {
 		return
 	}
 
-	response := GetOrderResponse{
+	writeJSON(ctx, fasthttp.StatusOK, newGetOrderResponse(order))
+}
+
+// handleQueryOrders returns the current state of many orders at once, for
+// reconcilers. Unknown IDs are listed rather than failing the request.
+func (s *APIServer) handleQueryOrders(ctx *fasthttp.RequestCtx) {
+	var req QueryOrdersRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if len(req.OrderIDs) == 0 || len(req.OrderIDs) > MaxQueryOrders {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "order_ids must list 1 to " + strconv.Itoa(MaxQueryOrders) + " orders"})
+		return
+	}
+
+	response := QueryOrdersResponse{Orders: make([]GetOrderResponse, 0, len(req.OrderIDs))}
+	for _, id := range req.OrderIDs {
+		order, err := s.engine.GetOrder(id)
+		if err != nil {
+			response.NotFound = append(response.NotFound, id)
+			continue
+		}
+		response.Orders = append(response.Orders, newGetOrderResponse(order))
+	}
+	writeJSON(ctx, fasthttp.StatusOK, response)
+}
+
+func newGetOrderResponse(order *models.Order) GetOrderResponse {
+	return GetOrderResponse{
 		OrderID:        order.ID,
+		Account:        order.Account,
 		Symbol:         order.Symbol,
 		Side:           order.Side,
 		Type:           order.Type,
@@ -253,10 +1069,162 @@ func (s *APIServer) handleGetOrder(ctx *fasthttp.RequestCtx, orderID string) {
 		Quantity:       order.OriginalQuantity,
 		FilledQuantity: order.FilledQuantity,
 		Status:         order.Status.String(),
+		CancelReason:   order.CancelReason,
+		TimeInForce:    order.TimeInForce,
+		ExpireAt:       order.ExpireAt,
+		ReduceOnly:     order.ReduceOnly,
+		Hidden:         order.Hidden,
+		MinQuantity:    order.MinQuantity,
+		StopPrice:      order.StopPrice,
+		Sequence:       order.Priority.Sequence,
+		Tag:            order.Tag,
+		Metadata:       order.Metadata,
+		TransactTime:   order.TransactTime,
+		ClockSkew:      order.ClockSkew,
 		Timestamp:      order.Timestamp,
+		AcceptedAt:     order.AcceptedAt,
+		FirstFillAt:    order.FirstFillAt,
+		TriggeredAt:    order.TriggeredAt,
+		CompletedAt:    order.CompletedAt,
+
+		DisplayQuantity: order.DisplayQuantity,
+		VisibleQuantity: order.VisibleQuantity,
+		PostOnly:        order.PostOnly,
+		PostOnlyReprice: order.PostOnlyReprice,
+		PegType:         order.PegType,
+		PegOffset:       order.PegOffset,
+		PegLimit:        order.PegLimit,
 	}
+}
 
-	writeJSON(ctx, fasthttp.StatusOK, response)
+func (s *APIServer) handleGetQueuePosition(ctx *fasthttp.RequestCtx, orderID string) {
+	pos, err := s.engine.GetQueuePosition(orderID)
+	if err != nil {
+		if err.Error() == "order not found" {
+			writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "Order not found"})
+		} else {
+			writeJSON(ctx, fasthttp.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, pos)
+}
+
+func (s *APIServer) handleGetAccountExposure(ctx *fasthttp.RequestCtx, account string) {
+	if account == "" {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "account id is required"})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, s.engine.GetAccountExposure(account))
+}
+
+func (s *APIServer) handleGetPositions(ctx *fasthttp.RequestCtx, account string) {
+	if account == "" {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "account id is required"})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, s.positions.Positions(account))
+}
+
+// AccountSummaryResponse is everything a trading UI shows for an account, in
+// one response. Holds are the notional of its resting orders. Sections whose
+// subsystem isn't running are omitted: Balance outside the sandbox, Positions
+// without the position monitor and Today without analytics.
+type AccountSummaryResponse struct {
+	Account    string                     `json:"account_id"`
+	Balance    *sandbox.Balance           `json:"balance,omitempty"`
+	Positions  map[string]int64           `json:"positions,omitempty"`
+	ReduceOnly bool                       `json:"reduce_only"`
+	OpenOrders int                        `json:"open_orders"`
+	BuyHolds   int64                      `json:"buy_holds"`
+	SellHolds  int64                      `json:"sell_holds"`
+	Symbols    []matching.SymbolExposure  `json:"symbols"`
+	Today      *analytics.AccountActivity `json:"today,omitempty"`
+	Timestamp  int64                      `json:"timestamp"`
+}
+
+// handleGetAccountSummary assembles the account's balance, holds, positions,
+// open orders and today's trading. Each part is read separately, so they are
+// not one atomic snapshot.
+func (s *APIServer) handleGetAccountSummary(ctx *fasthttp.RequestCtx, account string) {
+	if account == "" {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "account id is required"})
+		return
+	}
+	exposure := s.engine.GetAccountExposure(account)
+	resp := AccountSummaryResponse{
+		Account:    account,
+		OpenOrders: exposure.OpenOrders,
+		BuyHolds:   exposure.BuyNotional,
+		SellHolds:  exposure.SellNotional,
+		Symbols:    exposure.Symbols,
+		Timestamp:  time.Now().UnixNano(),
+	}
+	if s.paper != nil {
+		balance := s.paper.Balance(account)
+		resp.Balance = &balance
+	}
+	if s.positions != nil {
+		positions := s.positions.Positions(account)
+		resp.Positions, resp.ReduceOnly = positions.Positions, positions.ReduceOnly
+	}
+	if s.analytics != nil {
+		today := s.analytics.AccountToday(account)
+		resp.Today = &today
+	}
+	writeJSON(ctx, fasthttp.StatusOK, resp)
+}
+
+// NotificationsRequest sets which execution reports an account receives.
+// Empty ExecTypes restores all of them.
+type NotificationsRequest struct {
+	ExecTypes []gateway.ExecType `json:"exec_types"`
+}
+
+type NotificationsResponse struct {
+	Account   string             `json:"account_id"`
+	ExecTypes []gateway.ExecType `json:"exec_types"`
+}
+
+func (s *APIServer) handleGetNotifications(ctx *fasthttp.RequestCtx, account string) {
+	if account == "" {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "account id is required"})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, NotificationsResponse{Account: account, ExecTypes: s.gateway.Notifications(account)})
+}
+
+func (s *APIServer) handleSetNotifications(ctx *fasthttp.RequestCtx, account string) {
+	var req NotificationsRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if account == "" {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "account id is required"})
+		return
+	}
+	if err := s.gateway.SetNotifications(account, req.ExecTypes); err != nil {
+		writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, NotificationsResponse{Account: account, ExecTypes: s.gateway.Notifications(account)})
+}
+
+func (s *APIServer) handleGetTime(ctx *fasthttp.RequestCtx) {
+	resp := TimeResponse{ReceiveTime: time.Now().UnixNano()}
+	if v := ctx.QueryArgs().Peek("client_time"); len(v) > 0 {
+		clientTime, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid client_time: must be UnixNano"})
+			return
+		}
+		resp.ClientTime = clientTime
+	}
+	now := time.Now()
+	resp.TransmitTime = now.UnixNano()
+	resp.ISO = now.UTC().Format(time.RFC3339Nano)
+	writeJSON(ctx, fasthttp.StatusOK, resp)
 }
 
 func (s *APIServer) handleHealthCheck(ctx *fasthttp.RequestCtx) {
@@ -265,12 +1233,35 @@ func (s *APIServer) handleHealthCheck(ctx *fasthttp.RequestCtx) {
 
 	resp := HealthResponse{
 		Status:          "healthy",
+		State:           s.state(),
 		UptimeSeconds:   uptime,
 		OrdersProcessed: processed,
 	}
 	writeJSON(ctx, fasthttp.StatusOK, resp)
 }
 
+// handleReadyCheck reports whether the server can take orders: it must be in
+// the Ready state. With ?deep=true it also proves it by sending a probe order
+// through the gateway and cancelling it.
+func (s *APIServer) handleReadyCheck(ctx *fasthttp.RequestCtx) {
+	resp := ReadyResponse{Status: "ready", State: s.state()}
+	if resp.State != server.Ready {
+		resp.Status = "not_ready"
+		writeJSON(ctx, fasthttp.StatusServiceUnavailable, resp)
+		return
+	}
+	if string(ctx.QueryArgs().Peek("deep")) == "true" {
+		probe := s.gateway.Probe(ProbeTimeout)
+		resp.Deep = &probe
+		if !probe.OK {
+			resp.Status = "not_ready"
+			writeJSON(ctx, fasthttp.StatusServiceUnavailable, resp)
+			return
+		}
+	}
+	writeJSON(ctx, fasthttp.StatusOK, resp)
+}
+
 func (s *APIServer) handleGetMetrics(ctx *fasthttp.RequestCtx) {
 	writeJSON(ctx, fasthttp.StatusOK, s.metrics)
 }
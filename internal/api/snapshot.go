@@ -0,0 +1,62 @@
+package api
+
+import (
+	"repello/internal/gateway"
+	"repello/internal/matching"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// DefaultSnapshotDepth is how many price levels a side a market snapshot
+// includes unless ?depth= says otherwise.
+const DefaultSnapshotDepth = 10
+
+type SnapshotResponse struct {
+	Timestamp int64                     `json:"timestamp"` // UnixNano
+	Symbols   []matching.MarketSnapshot `json:"symbols"`
+}
+
+// handleGetSnapshot returns depth, last trade, activity and sequence for
+// ?symbols= (comma separated; every symbol if omitted) in one payload, so a
+// client can initialize its local state in one call. ?depth=N sets the levels
+// a side, 0 for the whole book.
+func (s *APIServer) handleGetSnapshot(ctx *fasthttp.RequestCtx) {
+	depth := DefaultSnapshotDepth
+	if param := string(ctx.QueryArgs().Peek("depth")); param != "" {
+		var err error
+		if depth, err = strconv.Atoi(param); err != nil || depth < 0 {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid depth"})
+			return
+		}
+	}
+
+	var symbols []string
+	if param := string(ctx.QueryArgs().Peek("symbols")); param != "" {
+		for _, symbol := range strings.Split(param, ",") {
+			symbols = append(symbols, s.gateway.Normalizer().Symbol(symbol))
+		}
+	} else {
+		for _, symbol := range s.engine.Symbols() {
+			if symbol != gateway.ProbeSymbol {
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+
+	response := SnapshotResponse{
+		Timestamp: time.Now().UnixNano(),
+		Symbols:   make([]matching.MarketSnapshot, 0, len(symbols)),
+	}
+	for _, symbol := range symbols {
+		snapshot, ok := s.engine.MarketSnapshot(symbol, depth)
+		if !ok || symbol == gateway.ProbeSymbol {
+			writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "symbol not found: " + symbol})
+			return
+		}
+		response.Symbols = append(response.Symbols, snapshot)
+	}
+	writeJSON(ctx, fasthttp.StatusOK, response)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"repello/internal/gateway"
+	"repello/internal/matching"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SymbolResponse is a symbol's reference data plus the decimal scales of
+// prices and quantities: with PriceDecimals 2, a price of 10125 is 101.25.
+// Aliases are the other names the symbol is accepted under.
+type SymbolResponse struct {
+	matching.SymbolInfo
+	Aliases          []string `json:"aliases,omitempty"`
+	PriceDecimals    int      `json:"price_decimals"`
+	QuantityDecimals int      `json:"quantity_decimals"`
+}
+
+func (s *APIServer) newSymbolResponse(info matching.SymbolInfo) SymbolResponse {
+	n := s.gateway.Normalizer()
+	return SymbolResponse{
+		SymbolInfo:       info,
+		Aliases:          n.AliasesOf(info.Symbol),
+		PriceDecimals:    n.PriceDecimals,
+		QuantityDecimals: n.QuantityDecimals,
+	}
+}
+
+func (s *APIServer) handleListSymbols(ctx *fasthttp.RequestCtx) {
+	symbols := make([]SymbolResponse, 0)
+	for _, symbol := range s.engine.Symbols() {
+		if symbol == gateway.ProbeSymbol {
+			continue
+		}
+		if info, ok := s.engine.SymbolInfo(symbol); ok {
+			symbols = append(symbols, s.newSymbolResponse(info))
+		}
+	}
+	writeJSON(ctx, fasthttp.StatusOK, symbols)
+}
+
+func (s *APIServer) handleGetSymbol(ctx *fasthttp.RequestCtx, symbol string) {
+	symbol = s.gateway.Normalizer().Symbol(symbol)
+	info, ok := s.engine.SymbolInfo(symbol)
+	if !ok || symbol == gateway.ProbeSymbol {
+		writeJSON(ctx, fasthttp.StatusNotFound, map[string]string{"error": "symbol not found"})
+		return
+	}
+	writeJSON(ctx, fasthttp.StatusOK, s.newSymbolResponse(info))
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"repello/internal/tape"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SetTape enables the trade tape endpoint.
+func (s *APIServer) SetTape(t *tape.Tape) {
+	s.tape = t
+}
+
+// handleGetTape returns a symbol's recent trades, oldest first. With
+// ?aggregated=true, consecutive fills by the same aggressor order are
+// combined into one entry, so a sweep is one line rather than one per level.
+// ?limit=N returns the last N entries.
+func (s *APIServer) handleGetTape(ctx *fasthttp.RequestCtx, symbol string) {
+	limit := 0
+	if param := string(ctx.QueryArgs().Peek("limit")); param != "" {
+		var err error
+		if limit, err = strconv.Atoi(param); err != nil || limit < 0 {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+	}
+	aggregated := false
+	if param := string(ctx.QueryArgs().Peek("aggregated")); param != "" {
+		var err error
+		if aggregated, err = strconv.ParseBool(param); err != nil {
+			writeJSON(ctx, fasthttp.StatusBadRequest, map[string]string{"error": "invalid aggregated"})
+			return
+		}
+	}
+	writeJSON(ctx, fasthttp.StatusOK, s.tape.Recent(s.gateway.Normalizer().Symbol(symbol), limit, aggregated))
+}
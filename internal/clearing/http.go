@@ -0,0 +1,52 @@
+package clearing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// HTTPClearer submits each trade as a JSON Settlement POSTed to URL. Any 2xx
+// answer counts as accepted.
+type HTTPClearer struct {
+	URL    string
+	client fasthttp.Client
+}
+
+var _ Clearer = (*HTTPClearer)(nil)
+
+func NewHTTPClearer(url string) *HTTPClearer {
+	return &HTTPClearer{URL: url}
+}
+
+func (c *HTTPClearer) Submit(ctx context.Context, s Settlement) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI(c.URL)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.Header.Set("Idempotency-Key", s.Reference)
+	req.SetBody(body)
+
+	timeout := time.Minute
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if err := c.client.DoTimeout(req, resp, timeout); err != nil {
+		return err
+	}
+	if code := resp.StatusCode(); code < 200 || code > 299 {
+		return fmt.Errorf("clearing system answered %d: %s", code, resp.Body())
+	}
+	return nil
+}
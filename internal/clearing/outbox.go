@@ -0,0 +1,315 @@
+// Package clearing hands executed trades to an external clearing system and
+// tracks each trade's settlement until the clearing system accepts it.
+package clearing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"repello/internal/matching"
+	"repello/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status is a trade's progress through clearing.
+type Status int
+
+const (
+	Pending Status = iota // waiting to be accepted by the clearing system
+	Cleared
+	Failed // gave up after the last retry
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pending:
+		return "PENDING"
+	case Cleared:
+		return "CLEARED"
+	case Failed:
+		return "FAILED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (s Status) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// Settlement is a trade as submitted to the clearing system, and how far its
+// submission has got.
+type Settlement struct {
+	Reference     string `json:"settlement_ref"`
+	TradeID       string `json:"trade_id"`
+	Symbol        string `json:"symbol"`
+	Price         int64  `json:"price"`
+	Quantity      int64  `json:"quantity"`
+	BuyerOrderID  string `json:"buyer_order_id"`
+	SellerOrderID string `json:"seller_order_id"`
+	BuyerAccount  string `json:"buyer_account_id,omitempty"`
+	SellerAccount string `json:"seller_account_id,omitempty"`
+	TradeTime     int64  `json:"trade_time"` // UnixNano
+
+	Status      Status `json:"status"`
+	Attempts    int    `json:"attempts"`
+	LastError   string `json:"last_error,omitempty"`
+	NextAttempt int64  `json:"next_attempt,omitempty"` // UnixNano, while pending
+	ClearedAt   int64  `json:"cleared_at,omitempty"`   // UnixNano
+}
+
+// Clearer submits trades to an external clearing system. A submission that
+// timed out may be repeated, so Submit must treat a reference it has already
+// accepted as a success.
+type Clearer interface {
+	Submit(ctx context.Context, s Settlement) error
+}
+
+type Config struct {
+	// ReferencePrefix starts every settlement reference.
+	ReferencePrefix string
+	// MaxAttempts is how many times a trade is submitted before it is marked
+	// Failed. Failed trades can be retried by hand.
+	MaxAttempts int
+	// RetryBackoff is the wait after the first failed attempt; it doubles
+	// with each further one.
+	RetryBackoff time.Duration
+	// Timeout bounds each submission.
+	Timeout time.Duration
+	// Retain is how many finished settlements are kept for queries.
+	Retain int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ReferencePrefix: "STL",
+		MaxAttempts:     5,
+		RetryBackoff:    time.Second,
+		Timeout:         5 * time.Second,
+		Retain:          100000,
+	}
+}
+
+// Outbox assigns every trade a settlement reference as it executes and
+// delivers the trade to a Clearer in the background, retrying failed
+// submissions. It is both a trade enrichment stage, so the reference is on
+// the trade callers and listeners see, and an event listener, so trades are
+// queued as they are published. The outbox is in memory, so trades still
+// pending when the server stops are not submitted.
+type Outbox struct {
+	cfg     Config
+	clearer Clearer
+	prefix  string // ReferencePrefix plus the start time, unique across restarts
+	wake    chan struct{}
+
+	mu       sync.Mutex
+	sequence int64
+	trades   map[string]*Settlement // by trade ID
+	pending  []*Settlement          // in trade order
+	finished []string               // trade IDs, oldest first
+}
+
+var (
+	_ matching.TradeStage    = (*Outbox)(nil)
+	_ matching.EventListener = (*Outbox)(nil)
+)
+
+// NewOutbox creates an outbox delivering to clearer once Run is called.
+func NewOutbox(cfg Config, clearer Clearer) *Outbox {
+	return &Outbox{
+		cfg:     cfg,
+		clearer: clearer,
+		prefix:  fmt.Sprintf("%s-%s-", cfg.ReferencePrefix, time.Now().UTC().Format("20060102150405")),
+		wake:    make(chan struct{}, 1),
+		trades:  make(map[string]*Settlement),
+	}
+}
+
+func (o *Outbox) Name() string { return "settlement" }
+
+// Enrich assigns the trade its settlement reference.
+func (o *Outbox) Enrich(trade *models.Trade, taker, maker *models.Order) {
+	o.mu.Lock()
+	o.sequence++
+	trade.SettlementRef = fmt.Sprintf("%s%08d", o.prefix, o.sequence)
+	o.mu.Unlock()
+}
+
+func (o *Outbox) OrderAccepted(order *models.Order, top matching.BookTop)  {}
+func (o *Outbox) OrderCancelled(order *models.Order, top matching.BookTop) {}
+
+// TradeExecuted queues the trade for submission. Trades are copied, since
+// the engine reuses them.
+func (o *Outbox) TradeExecuted(trade *models.Trade, taker, maker *models.Order) {
+	s := &Settlement{
+		Reference:     trade.SettlementRef,
+		TradeID:       trade.ID,
+		Symbol:        trade.Symbol,
+		Price:         trade.Price,
+		Quantity:      trade.Quantity,
+		BuyerOrderID:  trade.BuyerOrderID,
+		SellerOrderID: trade.SellerOrderID,
+		TradeTime:     trade.Timestamp,
+		Status:        Pending,
+	}
+	s.BuyerAccount, s.SellerAccount = taker.Account, maker.Account
+	if taker.Side == models.Sell {
+		s.BuyerAccount, s.SellerAccount = maker.Account, taker.Account
+	}
+
+	o.mu.Lock()
+	o.trades[s.TradeID] = s
+	o.pending = append(o.pending, s)
+	o.mu.Unlock()
+	o.signal()
+}
+
+func (o *Outbox) signal() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Get returns the settlement of tradeID, if it is pending or among the last
+// Retain finished.
+func (o *Outbox) Get(tradeID string) (Settlement, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s, ok := o.trades[tradeID]
+	if !ok {
+		return Settlement{}, false
+	}
+	return *s, true
+}
+
+// List returns the known settlements, oldest trade first.
+func (o *Outbox) List() []Settlement {
+	o.mu.Lock()
+	list := make([]Settlement, 0, len(o.trades))
+	for _, s := range o.trades {
+		list = append(list, *s)
+	}
+	o.mu.Unlock()
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].TradeTime < list[j].TradeTime
+	})
+	return list
+}
+
+// Retry puts a Failed settlement back in the outbox for another round of
+// attempts.
+func (o *Outbox) Retry(tradeID string) (Settlement, error) {
+	o.mu.Lock()
+	s, ok := o.trades[tradeID]
+	if !ok {
+		o.mu.Unlock()
+		return Settlement{}, fmt.Errorf("settlement not found")
+	}
+	if s.Status != Failed {
+		o.mu.Unlock()
+		return Settlement{}, fmt.Errorf("cannot retry: settlement is %s", s.Status)
+	}
+	s.Status = Pending
+	s.Attempts = 0
+	s.NextAttempt = 0
+	o.pending = append(o.pending, s)
+	retried := *s
+	o.mu.Unlock()
+	o.signal()
+	return retried, nil
+}
+
+// Run delivers queued trades until ctx is done.
+func (o *Outbox) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		case <-timer.C:
+		}
+		next := o.Flush(ctx)
+		timer.Stop()
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// Flush submits every pending trade that is due, in trade order, and returns
+// when the next retry is due, or the zero time if none is waiting.
+func (o *Outbox) Flush(ctx context.Context) time.Time {
+	now := time.Now()
+	o.mu.Lock()
+	due := make([]*Settlement, 0, len(o.pending))
+	for _, s := range o.pending {
+		if s.NextAttempt <= now.UnixNano() {
+			due = append(due, s)
+		}
+	}
+	o.mu.Unlock()
+
+	for _, s := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		o.mu.Lock()
+		submission := *s
+		o.mu.Unlock()
+		submitCtx, cancel := context.WithTimeout(ctx, o.cfg.Timeout)
+		err := o.clearer.Submit(submitCtx, submission)
+		cancel()
+		o.record(s, err)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var next time.Time
+	for _, s := range o.pending {
+		if at := time.Unix(0, s.NextAttempt); next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+	return next
+}
+
+// record applies the outcome of one submission of s.
+func (o *Outbox) record(s *Settlement, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s.Attempts++
+	if err == nil {
+		s.Status = Cleared
+		s.LastError = ""
+		s.NextAttempt = 0
+		s.ClearedAt = time.Now().UnixNano()
+	} else {
+		s.LastError = err.Error()
+		if s.Attempts < o.cfg.MaxAttempts {
+			s.NextAttempt = time.Now().Add(o.cfg.RetryBackoff << (s.Attempts - 1)).UnixNano()
+			return
+		}
+		s.Status = Failed
+		s.NextAttempt = 0
+		log.Printf("clearing: trade %s (%s) failed after %d attempts: %s", s.TradeID, s.Reference, s.Attempts, err)
+	}
+
+	for i, p := range o.pending {
+		if p == s {
+			o.pending = append(o.pending[:i], o.pending[i+1:]...)
+			break
+		}
+	}
+	o.finished = append(o.finished, s.TradeID)
+	for len(o.finished) > o.cfg.Retain {
+		if old, ok := o.trades[o.finished[0]]; ok && old.Status != Pending {
+			delete(o.trades, o.finished[0])
+		}
+		o.finished = o.finished[1:]
+	}
+}
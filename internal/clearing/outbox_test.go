@@ -0,0 +1,108 @@
+package clearing
+
+import (
+	"context"
+	"fmt"
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyClearer fails the first failures submissions, then accepts.
+type flakyClearer struct {
+	failures  int
+	submitted []Settlement
+}
+
+func (c *flakyClearer) Submit(ctx context.Context, s Settlement) error {
+	c.submitted = append(c.submitted, s)
+	if len(c.submitted) <= c.failures {
+		return fmt.Errorf("clearing system unavailable")
+	}
+	return nil
+}
+
+func newOrder(id, account string, side models.Side, price, quantity int64) *models.Order {
+	order := models.NewOrder(id, "BTCUSD", side, models.Limit, price, quantity)
+	order.Account = account
+	return order
+}
+
+func newTestOutbox(clearer Clearer) (*matching.Engine, *Outbox) {
+	cfg := DefaultConfig()
+	cfg.MaxAttempts = 2
+	cfg.RetryBackoff = 0
+	outbox := NewOutbox(cfg, clearer)
+	engine := matching.NewEngine(metrics.NewMetrics())
+	engine.AddTradeStage(outbox)
+	engine.AddEventListener(outbox)
+	return engine, outbox
+}
+
+func TestOutbox(t *testing.T) {
+	clearer := &flakyClearer{failures: 1}
+	engine, outbox := newTestOutbox(clearer)
+
+	engine.ProcessOrder(newOrder("s1", "seller", models.Sell, 100, 5))
+	result, err := engine.ProcessOrder(newOrder("b1", "buyer", models.Buy, 100, 5))
+	assert.NoError(t, err)
+	trade := result.Trades[0]
+	assert.True(t, strings.HasPrefix(trade.SettlementRef, "STL-"))
+
+	s, ok := outbox.Get(trade.ID)
+	assert.True(t, ok)
+	assert.Equal(t, Pending, s.Status)
+	assert.Equal(t, trade.SettlementRef, s.Reference)
+	assert.Equal(t, "buyer", s.BuyerAccount)
+	assert.Equal(t, "seller", s.SellerAccount)
+
+	// The first attempt fails and is retried
+	outbox.Flush(context.Background())
+	s, _ = outbox.Get(trade.ID)
+	assert.Equal(t, Pending, s.Status)
+	assert.Equal(t, 1, s.Attempts)
+	assert.Equal(t, "clearing system unavailable", s.LastError)
+
+	outbox.Flush(context.Background())
+	s, _ = outbox.Get(trade.ID)
+	assert.Equal(t, Cleared, s.Status)
+	assert.Equal(t, 2, s.Attempts)
+	assert.Empty(t, s.LastError)
+	assert.NotZero(t, s.ClearedAt)
+	assert.Len(t, clearer.submitted, 2)
+	assert.Equal(t, clearer.submitted[0].Reference, clearer.submitted[1].Reference)
+
+	_, err = outbox.Retry(trade.ID)
+	assert.EqualError(t, err, "cannot retry: settlement is CLEARED")
+	_, err = outbox.Retry("unknown")
+	assert.EqualError(t, err, "settlement not found")
+}
+
+func TestOutbox_FailsAfterMaxAttempts(t *testing.T) {
+	clearer := &flakyClearer{failures: 2}
+	engine, outbox := newTestOutbox(clearer)
+
+	engine.ProcessOrder(newOrder("s1", "seller", models.Sell, 100, 5))
+	result, _ := engine.ProcessOrder(newOrder("b1", "buyer", models.Buy, 100, 5))
+	tradeID := result.Trades[0].ID
+
+	outbox.Flush(context.Background())
+	next := outbox.Flush(context.Background())
+	assert.True(t, next.IsZero())
+	s, _ := outbox.Get(tradeID)
+	assert.Equal(t, Failed, s.Status)
+	assert.Equal(t, 2, s.Attempts)
+
+	// Retried by hand, it clears
+	s, err := outbox.Retry(tradeID)
+	assert.NoError(t, err)
+	assert.Equal(t, Pending, s.Status)
+	outbox.Flush(context.Background())
+	s, _ = outbox.Get(tradeID)
+	assert.Equal(t, Cleared, s.Status)
+	assert.Equal(t, []Settlement{s}, outbox.List())
+}
@@ -0,0 +1,44 @@
+// Package enrich provides trade enrichment stages for the matching engine's
+// post-match pipeline.
+package enrich
+
+import (
+	"repello/internal/matching"
+	"repello/internal/models"
+)
+
+// FlagLargeTrade marks trades at or above a LargeTrade stage's threshold.
+const FlagLargeTrade = "LARGE_TRADE"
+
+// Fees charges maker and taker fees in basis points of the trade's notional.
+// A negative rate pays a rebate.
+type Fees struct {
+	MakerBps int64
+	TakerBps int64
+}
+
+var _ matching.TradeStage = Fees{}
+
+func (f Fees) Name() string { return "fees" }
+
+func (f Fees) Enrich(trade *models.Trade, taker, maker *models.Order) {
+	notional := trade.Price * trade.Quantity
+	trade.MakerFee = notional * f.MakerBps / 10000
+	trade.TakerFee = notional * f.TakerBps / 10000
+}
+
+// LargeTrade flags trades whose notional reaches Threshold, for regulatory
+// large-trade reporting.
+type LargeTrade struct {
+	Threshold int64
+}
+
+var _ matching.TradeStage = LargeTrade{}
+
+func (l LargeTrade) Name() string { return "large_trade" }
+
+func (l LargeTrade) Enrich(trade *models.Trade, taker, maker *models.Order) {
+	if trade.Price*trade.Quantity >= l.Threshold {
+		trade.Flags = append(trade.Flags, FlagLargeTrade)
+	}
+}
@@ -0,0 +1,49 @@
+package enrich
+
+import (
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFees(t *testing.T) {
+	trade := models.NewTrade("t1", "BTCUSD", models.Buy, "taker", "maker", 1000, 50)
+	Fees{MakerBps: -2, TakerBps: 5}.Enrich(trade, nil, nil)
+	assert.Equal(t, int64(-10), trade.MakerFee)
+	assert.Equal(t, int64(25), trade.TakerFee)
+	assert.Equal(t, int64(25), trade.FeeFor("taker"))
+}
+
+func TestLargeTrade(t *testing.T) {
+	stage := LargeTrade{Threshold: 10000}
+
+	small := models.NewTrade("t1", "BTCUSD", models.Buy, "taker", "maker", 100, 99)
+	stage.Enrich(small, nil, nil)
+	assert.Empty(t, small.Flags)
+
+	large := models.NewTrade("t2", "BTCUSD", models.Buy, "taker", "maker", 100, 100)
+	stage.Enrich(large, nil, nil)
+	assert.Equal(t, []string{FlagLargeTrade}, large.Flags)
+}
+
+// The pipeline runs before results are returned and listeners are called, and
+// pooled trades don't carry flags over between orders.
+func TestPipelineInEngine(t *testing.T) {
+	engine := matching.NewEngine(metrics.NewMetrics())
+	engine.AddTradeStage(Fees{MakerBps: 1, TakerBps: 3})
+	engine.AddTradeStage(LargeTrade{Threshold: 100000})
+
+	engine.ProcessOrder(models.NewOrder("s1", "BTCUSD", models.Sell, models.Limit, 10000, 20))
+	result, err := engine.ProcessOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 10000, 10))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(30), result.Trades[0].TakerFee)
+	assert.Equal(t, int64(10), result.Trades[0].MakerFee)
+	assert.Equal(t, []string{FlagLargeTrade}, result.Trades[0].Flags)
+	result.Release()
+
+	result, _ = engine.ProcessOrder(models.NewOrder("b2", "BTCUSD", models.Buy, models.Limit, 10000, 1))
+	assert.Empty(t, result.Trades[0].Flags)
+}
@@ -0,0 +1,418 @@
+// Package gateway is the order entry layer every protocol front end (REST,
+// FIX, OUCH, gRPC, ...) submits through, so normalization and admission rules
+// such as rate limits are implemented once rather than per protocol.
+package gateway
+
+import (
+	"fmt"
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"sync"
+	"time"
+)
+
+// OrderEntry is what a protocol front end needs from the exchange. Front ends
+// translate their wire format to these calls and relay execution reports back
+// to their clients.
+type OrderEntry interface {
+	Submit(order *models.Order) (*matching.MatchResult, error)
+	SubmitOCO(first, second *models.Order) ([]*matching.MatchResult, error)
+	Cancel(orderID string) (*models.Order, error)
+	Amend(orderID string, amendment Amendment) (*models.Order, error)
+	AmendBatch(amendments []OrderAmendment) ([]matching.AmendResult, error)
+	Shift(account, symbol string, ticks int64) ([]matching.AmendResult, error)
+	// OnExecution registers h for every execution report. Handlers must be
+	// registered before orders are submitted.
+	OnExecution(h ExecutionHandler)
+}
+
+// Amendment changes a resting order's quantity. Set exactly one field;
+// quantity may only go down, and the order keeps its priority.
+type Amendment struct {
+	ReduceBy    int64
+	NewQuantity int64 // new total quantity, including filled
+}
+
+// OrderAmendment is one order's change in a batch amend. Price, if set and
+// different, reprices the order by replacing it with a new one at that price,
+// behind the orders already there; the Amendment may then be left empty.
+type OrderAmendment struct {
+	OrderID string
+	Price   int64
+	Amendment
+}
+
+// Config controls normalization and admission.
+type Config struct {
+	Normalizer Normalizer
+
+	// IDs assigns IDs to orders submitted without one; orders that bring
+	// their own keep it, and the engine rejects IDs already in use. Nil
+	// means UUIDs.
+	IDs IDGenerator
+
+	// Metrics, if set, counts rejected orders by reason code.
+	Metrics *metrics.Metrics
+
+	// MaxOrdersPerSecond limits how many submits, cancels and amends each
+	// account may send per second, with bursts up to the same number. Cancels
+	// have their own allowance and may also use the rest's once theirs is
+	// spent, but not the other way round, so an account that has used up its
+	// limit adding orders can still pull them. Zero means unlimited.
+	MaxOrdersPerSecond int
+}
+
+// Gateway implements OrderEntry on top of an engine.
+type Gateway struct {
+	engine   *matching.Engine
+	cfg      Config
+	handlers []ExecutionHandler
+
+	buckets map[lane]*bucket
+	mu      sync.Mutex
+	now     func() time.Time
+
+	notifications map[string][]ExecType // by account; absent means all
+	notifyMu      sync.RWMutex
+
+	rejects  []Rejection // oldest first, at most MaxRecentRejects
+	rejectMu sync.Mutex
+
+	histories  map[string][]OrderEvent // by order ID
+	historyIDs []string                // oldest first, at most MaxOrderHistories
+	historySeq uint64
+	historyMu  sync.Mutex
+}
+
+var (
+	_ OrderEntry             = (*Gateway)(nil)
+	_ matching.EventListener = (*Gateway)(nil)
+	_ matching.AmendListener = (*Gateway)(nil)
+)
+
+// New creates a gateway in front of engine and registers it as one of the
+// engine's listeners to produce execution reports.
+func New(engine *matching.Engine, cfg Config) *Gateway {
+	g := &Gateway{
+		engine:  engine,
+		cfg:     cfg,
+		buckets: make(map[lane]*bucket),
+		now:     time.Now,
+
+		notifications: make(map[string][]ExecType),
+		histories:     make(map[string][]OrderEvent),
+	}
+	if g.cfg.IDs == nil {
+		g.cfg.IDs = UUIDGenerator{}
+	}
+	engine.AddEventListener(g)
+	return g
+}
+
+// Engine returns the engine behind the gateway, for queries.
+func (g *Gateway) Engine() *matching.Engine {
+	return g.engine
+}
+
+// Normalizer returns the gateway's normalizer, whose decimal scales clients
+// need to convert prices and quantities.
+func (g *Gateway) Normalizer() Normalizer {
+	return g.cfg.Normalizer
+}
+
+func (g *Gateway) OnExecution(h ExecutionHandler) {
+	g.handlers = append(g.handlers, h)
+}
+
+// Submit assigns order an ID if it has none, normalizes and admits it, and
+// sends it to the engine. A rejected order gets a REJECTED execution report.
+func (g *Gateway) Submit(order *models.Order) (*matching.MatchResult, error) {
+	result, err := g.submit(order)
+	if err == nil {
+		return result, nil
+	}
+	g.reject(order, err)
+	return nil, err
+}
+
+// reject records and reports order's rejection with err.
+func (g *Gateway) reject(order *models.Order, err error) {
+	order.SetStatus(models.Rejected) // whether the gateway or the engine refused it
+	now := g.now().UnixNano()
+	g.recordReject(Rejection{
+		OrderID:   order.ID,
+		Account:   order.Account,
+		Symbol:    order.Symbol,
+		Code:      ClassifyReject(err),
+		Reason:    err.Error(),
+		Timestamp: now,
+	})
+	g.report(ExecutionReport{
+		Type:      ExecRejected,
+		OrderID:   order.ID,
+		Account:   order.Account,
+		Symbol:    order.Symbol,
+		Side:      order.Side,
+		Price:     order.Price,
+		Status:    order.Status,
+		Reason:    err.Error(),
+		Timestamp: now,
+	})
+}
+
+func (g *Gateway) submit(order *models.Order) (*matching.MatchResult, error) {
+	if order.ID == "" {
+		order.ID = g.cfg.IDs.NewID()
+	}
+	if err := g.cfg.Normalizer.Normalize(order); err != nil {
+		return nil, err
+	}
+	if err := g.admit(order.Account); err != nil {
+		return nil, err
+	}
+	result, err := g.engine.Dispatch(matching.NewOrderCommand{Order: order})
+	if err != nil {
+		return nil, err
+	}
+	return result.Match, nil
+}
+
+// SubmitOCO submits a one-cancels-other pair as a matching.OCOCommand. Both
+// orders count against the account's rate limit, and a pair over it is
+// refused without using any of it. If the pair is refused, both orders get
+// REJECTED execution reports, except a first order the engine cancelled
+// after it had been accepted.
+func (g *Gateway) SubmitOCO(first, second *models.Order) ([]*matching.MatchResult, error) {
+	matches, err := g.submitOCO(first, second)
+	if err == nil {
+		return matches, nil
+	}
+	for _, order := range []*models.Order{first, second} {
+		if order.Status != models.Cancelled {
+			g.reject(order, err)
+		}
+	}
+	return nil, err
+}
+
+func (g *Gateway) submitOCO(first, second *models.Order) ([]*matching.MatchResult, error) {
+	need := make(map[lane]int)
+	for _, order := range []*models.Order{first, second} {
+		if order.ID == "" {
+			order.ID = g.cfg.IDs.NewID()
+		}
+		if err := g.cfg.Normalizer.Normalize(order); err != nil {
+			return nil, err
+		}
+		need[lane{account: order.Account}]++
+	}
+	// Both legs or neither count against the rate limit
+	if err := g.takeAll(need); err != nil {
+		return nil, err
+	}
+	result, err := g.engine.Dispatch(matching.OCOCommand{First: first, Second: second})
+	if err != nil {
+		return nil, err
+	}
+	return result.Matches, nil
+}
+
+func (g *Gateway) Cancel(orderID string) (*models.Order, error) {
+	if err := g.admitCancel(orderID); err != nil {
+		return nil, err
+	}
+	result, err := g.engine.Dispatch(matching.CancelCommand{OrderID: orderID})
+	if err != nil {
+		return nil, err
+	}
+	return result.Orders[0], nil
+}
+
+func (g *Gateway) Amend(orderID string, amendment Amendment) (*models.Order, error) {
+	cmd := matching.AmendCommand{
+		OrderID:     orderID,
+		ReduceBy:    amendment.ReduceBy,
+		NewQuantity: amendment.NewQuantity,
+	}
+	if err := cmd.Validate(); err != nil {
+		return nil, err
+	}
+	if err := g.admitFor(orderID); err != nil {
+		return nil, err
+	}
+	result, err := g.engine.Dispatch(cmd)
+	if err != nil {
+		return nil, err
+	}
+	g.report(g.orderReport(ExecAmended, result.Orders[0]))
+	return result.Orders[0], nil
+}
+
+// AmendBatch applies amendments as a matching.BatchAmendCommand, giving each
+// repriced order's replacement a new ID. Every amendment counts against its
+// account's rate limit. The batch is admitted as a whole: if any account is
+// over its limit, none is applied and no account is charged.
+func (g *Gateway) AmendBatch(amendments []OrderAmendment) ([]matching.AmendResult, error) {
+	cmd := matching.BatchAmendCommand{Amendments: make([]matching.BatchAmendment, len(amendments))}
+	need := make(map[lane]int)
+	for i, a := range amendments {
+		// Unknown orders are left for the engine to report
+		if order, err := g.engine.GetOrder(a.OrderID); err == nil {
+			need[lane{account: order.Account}]++
+		}
+		cmd.Amendments[i] = matching.BatchAmendment{
+			OrderID:     a.OrderID,
+			Price:       a.Price,
+			ReduceBy:    a.ReduceBy,
+			NewQuantity: a.NewQuantity,
+		}
+		if a.Price != 0 {
+			cmd.Amendments[i].ReplacementID = g.cfg.IDs.NewID()
+		}
+	}
+	if err := g.takeAll(need); err != nil {
+		return nil, err
+	}
+	result, err := g.engine.Dispatch(cmd)
+	if err != nil {
+		return nil, err
+	}
+	g.reportAmends(result.Amends)
+	return result.Amends, nil
+}
+
+// reportAmends reports the orders of a batch that were reduced in place;
+// repriced ones are reported by the engine's cancel and accept events.
+func (g *Gateway) reportAmends(amends []matching.AmendResult) {
+	for _, a := range amends {
+		if a.Err == nil && a.Order.ID == a.OrderID {
+			g.report(g.orderReport(ExecAmended, a.Order))
+		}
+	}
+}
+
+// Shift moves account's resting orders in symbol by ticks ticks, as a
+// matching.ShiftCommand. It counts as one request against the rate limit.
+func (g *Gateway) Shift(account, symbol string, ticks int64) ([]matching.AmendResult, error) {
+	cmd := matching.ShiftCommand{Symbol: g.cfg.Normalizer.Symbol(symbol), Account: account, Ticks: ticks, NewID: g.cfg.IDs.NewID}
+	if err := cmd.Validate(); err != nil {
+		return nil, err
+	}
+	if err := g.admit(account); err != nil {
+		return nil, err
+	}
+	result, err := g.engine.Dispatch(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return result.Amends, nil
+}
+
+// lane is one of an account's rate limits: cancels have a lane of their own,
+// so they are never held up behind new orders.
+type lane struct {
+	account string
+	cancel  bool
+}
+
+// CancelAll is the exchange's emergency kill switch. It cancels every active
+// order in symbols, or in all symbols if there are none, giving each reason as
+// its CancelReason, then sends every execution handler a NOTICE report for
+// each symbol, or one without a symbol for the whole market, whatever reports
+// accounts have chosen. It is not rate limited.
+func (g *Gateway) CancelAll(symbols []string, reason string) ([]*models.Order, error) {
+	if reason == "" {
+		return nil, fmt.Errorf("invalid reason: required")
+	}
+	canonical := []string{""}
+	if len(symbols) > 0 {
+		canonical = make([]string, len(symbols))
+		for i, symbol := range symbols {
+			canonical[i] = g.cfg.Normalizer.Symbol(symbol)
+		}
+	}
+	cancelled := make([]*models.Order, 0)
+	for _, symbol := range canonical {
+		result, err := g.engine.Dispatch(matching.MassCancelCommand{Symbol: symbol, Reason: reason})
+		if err != nil {
+			return cancelled, err
+		}
+		cancelled = append(cancelled, result.Orders...)
+	}
+	for _, symbol := range canonical {
+		g.publish(ExecutionReport{Type: ExecNotice, Symbol: symbol, Reason: reason, Timestamp: g.now().UnixNano()})
+	}
+	return cancelled, nil
+}
+
+// admitFor applies the rate limit of the account that owns orderID. Unknown
+// orders are left for the engine to report.
+func (g *Gateway) admitFor(orderID string) error {
+	order, err := g.engine.GetOrder(orderID)
+	if err != nil {
+		return nil
+	}
+	return g.take(lane{account: order.Account})
+}
+
+// admitCancel is admitFor for cancels, which use their own lane first and
+// the account's other one once it is empty.
+func (g *Gateway) admitCancel(orderID string) error {
+	order, err := g.engine.GetOrder(orderID)
+	if err != nil {
+		return nil
+	}
+	if g.take(lane{account: order.Account, cancel: true}) == nil {
+		return nil
+	}
+	return g.take(lane{account: order.Account})
+}
+
+// admit takes a token from account's bucket for anything but a cancel.
+func (g *Gateway) admit(account string) error {
+	return g.take(lane{account: account})
+}
+
+// take takes a token from l's bucket.
+func (g *Gateway) take(l lane) error {
+	return g.takeAll(map[lane]int{l: 1})
+}
+
+// takeAll takes need[l] tokens from each lane l's bucket, or none at all if
+// any of them has too few.
+func (g *Gateway) takeAll(need map[lane]int) error {
+	if g.cfg.MaxOrdersPerSecond <= 0 {
+		return nil
+	}
+	rate := float64(g.cfg.MaxOrdersPerSecond)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	for l, n := range need {
+		b, ok := g.buckets[l]
+		if !ok {
+			b = &bucket{tokens: rate, last: now}
+			g.buckets[l] = b
+		}
+		b.refill(now, rate)
+		if b.tokens < float64(n) {
+			return fmt.Errorf("rate limit exceeded: at most %d requests per second", g.cfg.MaxOrdersPerSecond)
+		}
+	}
+	for l, n := range need {
+		g.buckets[l].tokens -= float64(n)
+	}
+	return nil
+}
+
+// bucket is a token bucket refilling at rate tokens per second up to rate.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) refill(now time.Time, rate float64) {
+	b.tokens = min(rate, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+}
@@ -0,0 +1,334 @@
+package gateway
+
+import (
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newOrder(id, account string, side models.Side, price, quantity int64) *models.Order {
+	order := models.NewOrder(id, "BTCUSD", side, models.Limit, price, quantity)
+	order.Account = account
+	return order
+}
+
+func TestGateway_RateLimit(t *testing.T) {
+	g := New(matching.NewEngine(metrics.NewMetrics()), Config{MaxOrdersPerSecond: 2})
+	now := time.Unix(1_700_000_000, 0)
+	g.now = func() time.Time { return now }
+
+	_, err := g.Submit(newOrder("a1", "alice", models.Buy, 90, 1))
+	assert.NoError(t, err)
+	_, err = g.Submit(newOrder("a2", "alice", models.Buy, 90, 2))
+	assert.NoError(t, err)
+	_, err = g.Amend("a2", Amendment{ReduceBy: 1})
+	assert.ErrorContains(t, err, "rate limit exceeded")
+
+	// Cancels have their own lane, so they get through when new orders can't
+	_, err = g.Cancel("a1")
+	assert.NoError(t, err)
+	_, err = g.Submit(newOrder("a3", "alice", models.Buy, 90, 1))
+	assert.ErrorContains(t, err, "rate limit exceeded")
+
+	// Limits are per account
+	_, err = g.Submit(newOrder("b1", "bob", models.Buy, 90, 1))
+	assert.NoError(t, err)
+
+	now = now.Add(500 * time.Millisecond)
+	_, err = g.Amend("a2", Amendment{ReduceBy: 1})
+	assert.NoError(t, err)
+	_, err = g.Submit(newOrder("a3", "alice", models.Buy, 90, 1))
+	assert.ErrorContains(t, err, "rate limit exceeded")
+
+	// Cancels that have used up their lane take from the other one
+	g.Submit(newOrder("b2", "bob", models.Buy, 90, 1))
+	g.Submit(newOrder("b3", "bob", models.Buy, 90, 1))
+	now = now.Add(time.Second)
+	_, err = g.Cancel("b1")
+	assert.NoError(t, err)
+	_, err = g.Cancel("b2")
+	assert.NoError(t, err)
+	_, err = g.Cancel("b3")
+	assert.NoError(t, err)
+	_, err = g.Submit(newOrder("b4", "bob", models.Buy, 90, 1))
+	assert.NoError(t, err)
+	_, err = g.Submit(newOrder("b5", "bob", models.Buy, 90, 1))
+	assert.ErrorContains(t, err, "rate limit exceeded")
+
+	// A one-cancels-other pair takes both its requests or none
+	g.Submit(newOrder("c1", "carol", models.Buy, 80, 1))
+	_, err = g.SubmitOCO(newOrder("c2", "carol", models.Sell, 120, 1), newOrder("c3", "carol", models.Sell, 130, 1))
+	assert.ErrorContains(t, err, "rate limit exceeded")
+	_, err = g.Submit(newOrder("c4", "carol", models.Buy, 80, 1))
+	assert.NoError(t, err)
+}
+
+func TestGateway_OCOReports(t *testing.T) {
+	g := New(matching.NewEngine(metrics.NewMetrics()), Config{})
+	var reports []string
+	g.OnExecution(func(r ExecutionReport) { reports = append(reports, r.OrderID+" "+r.Type.String()) })
+	g.Submit(newOrder("ask", "bob", models.Sell, 100, 1))
+
+	// The first leg fills on entry, so the second is cancelled unentered,
+	// but still announced first
+	reports = nil
+	matches, err := g.SubmitOCO(newOrder("tp", "alice", models.Buy, 100, 1), newOrder("sl", "alice", models.Buy, 90, 1))
+	assert.NoError(t, err)
+	assert.Nil(t, matches[1])
+	assert.Equal(t, []string{"tp NEW", "ask TRADE", "tp TRADE", "sl NEW", "sl CANCELLED"}, reports)
+}
+
+func TestGateway_AmendBatchRateLimit(t *testing.T) {
+	g := New(matching.NewEngine(metrics.NewMetrics()), Config{MaxOrdersPerSecond: 3})
+	now := time.Unix(1_700_000_000, 0)
+	g.now = func() time.Time { return now }
+	g.Submit(newOrder("a1", "alice", models.Buy, 90, 5))
+	g.Submit(newOrder("a2", "alice", models.Buy, 90, 5))
+	g.Submit(newOrder("b1", "bob", models.Buy, 90, 5))
+
+	// Alice has one request left, so the batch is refused as a whole
+	_, err := g.AmendBatch([]OrderAmendment{
+		{OrderID: "b1", Amendment: Amendment{ReduceBy: 1}},
+		{OrderID: "a1", Amendment: Amendment{ReduceBy: 1}},
+		{OrderID: "a2", Amendment: Amendment{ReduceBy: 1}},
+	})
+	assert.ErrorContains(t, err, "rate limit exceeded")
+	for _, id := range []string{"a1", "a2", "b1"} {
+		order, _ := g.Engine().GetOrder(id)
+		assert.Equal(t, int64(5), order.RemainingQuantity)
+	}
+
+	// and nobody was charged for it
+	_, err = g.AmendBatch([]OrderAmendment{
+		{OrderID: "b1", Amendment: Amendment{ReduceBy: 1}},
+		{OrderID: "b1", Amendment: Amendment{ReduceBy: 1}},
+		{OrderID: "a1", Amendment: Amendment{ReduceBy: 1}},
+	})
+	assert.NoError(t, err)
+	_, err = g.Amend("b1", Amendment{ReduceBy: 1})
+	assert.ErrorContains(t, err, "rate limit exceeded")
+}
+
+func TestGateway_Amend(t *testing.T) {
+	g := New(matching.NewEngine(metrics.NewMetrics()), Config{})
+	g.Submit(newOrder("a1", "alice", models.Sell, 100, 10))
+
+	_, err := g.Amend("a1", Amendment{})
+	assert.ErrorContains(t, err, "invalid amendment")
+	_, err = g.Amend("a1", Amendment{ReduceBy: 1, NewQuantity: 5})
+	assert.ErrorContains(t, err, "invalid amendment")
+
+	order, err := g.Amend("a1", Amendment{ReduceBy: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), order.RemainingQuantity)
+	order, err = g.Amend("a1", Amendment{NewQuantity: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), order.RemainingQuantity)
+	_, err = g.Amend("missing", Amendment{ReduceBy: 1})
+	assert.EqualError(t, err, "order not found")
+}
+
+func TestGateway_History(t *testing.T) {
+	g := New(matching.NewEngine(metrics.NewMetrics()), Config{})
+	g.Submit(newOrder("s1", "mm", models.Sell, 100, 5))
+	g.Amend("s1", Amendment{ReduceBy: 1})
+	g.Submit(newOrder("b1", "alice", models.Buy, 100, 3))
+	g.Cancel("s1")
+	g.Submit(newOrder("bad", "alice", models.Buy, 0, 3))
+
+	events, ok := g.History("s1")
+	assert.True(t, ok)
+	types := make([]ExecType, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+		if i > 0 {
+			assert.Greater(t, e.Sequence, events[i-1].Sequence)
+		}
+	}
+	assert.Equal(t, []ExecType{ExecNew, ExecAmended, ExecTrade, ExecCancelled}, types)
+	assert.Equal(t, int64(4), events[1].RemainingQuantity)
+	assert.Equal(t, int64(1), events[3].RemainingQuantity)
+
+	events, ok = g.History("bad")
+	assert.True(t, ok)
+	assert.Equal(t, ExecRejected, events[0].Type)
+	assert.Equal(t, models.Rejected, events[0].Status)
+	_, ok = g.History("missing")
+	assert.False(t, ok)
+}
+
+func TestGateway_ExecutionReports(t *testing.T) {
+	g := New(matching.NewEngine(metrics.NewMetrics()), Config{})
+	var reports []ExecutionReport
+	g.OnExecution(func(r ExecutionReport) { reports = append(reports, r) })
+
+	g.Submit(newOrder("s1", "mm", models.Sell, 100, 5))
+	g.Submit(newOrder("b1", "alice", models.Buy, 100, 3))
+	g.Cancel("s1")
+	g.Submit(newOrder("bad", "alice", models.Buy, 0, 3))
+
+	types := make([]ExecType, len(reports))
+	for i, r := range reports {
+		types[i] = r.Type
+	}
+	assert.Equal(t, []ExecType{ExecNew, ExecNew, ExecTrade, ExecTrade, ExecCancelled, ExecRejected}, types)
+
+	assert.Equal(t, "b1", reports[1].OrderID)
+	assert.Equal(t, models.Accepted, reports[1].Status)
+	maker := reports[2]
+	assert.Equal(t, "s1", maker.OrderID)
+	assert.Equal(t, int64(3), maker.LastQuantity)
+	assert.Equal(t, int64(2), maker.RemainingQuantity)
+	assert.Equal(t, models.Maker, *maker.Liquidity)
+	assert.Equal(t, "b1", reports[3].OrderID)
+	assert.Equal(t, models.Filled, reports[3].Status)
+	assert.Contains(t, reports[5].Reason, "invalid price")
+}
+
+func TestGateway_ReportOrder(t *testing.T) {
+	g := New(matching.NewEngine(metrics.NewMetrics()), Config{})
+	var reports []ExecutionReport
+	g.OnExecution(func(r ExecutionReport) {
+		if r.OrderID == "b1" {
+			reports = append(reports, r)
+		}
+	})
+
+	g.Submit(newOrder("s1", "mm", models.Sell, 100, 2))
+	ioc := newOrder("b1", "alice", models.Buy, 100, 5)
+	ioc.TimeInForce = models.IOC
+	_, err := g.Submit(ioc)
+	assert.NoError(t, err)
+
+	// An IOC's partial fill is reported as accepted, traded, then cancelled
+	types := make([]ExecType, len(reports))
+	for i, r := range reports {
+		types[i] = r.Type
+	}
+	assert.Equal(t, []ExecType{ExecNew, ExecTrade, ExecCancelled}, types)
+	assert.Equal(t, int64(2), reports[2].FilledQuantity)
+	assert.Equal(t, models.Cancelled, reports[2].Status)
+
+	events, _ := g.History("b1")
+	assert.Len(t, events, 3)
+	assert.Equal(t, ExecCancelled, events[2].Type)
+}
+
+func TestGateway_Notifications(t *testing.T) {
+	g := New(matching.NewEngine(metrics.NewMetrics()), Config{})
+	var reports []ExecutionReport
+	g.OnExecution(func(r ExecutionReport) { reports = append(reports, r) })
+	assert.NoError(t, g.SetNotifications("mm", []ExecType{ExecTrade}))
+	assert.Error(t, g.SetNotifications("mm", []ExecType{ExecType(42)}))
+	assert.Equal(t, []ExecType{ExecTrade}, g.Notifications("mm"))
+	assert.Equal(t, AllExecTypes, g.Notifications("alice"))
+
+	g.Submit(newOrder("s1", "mm", models.Sell, 100, 5))
+	g.Submit(newOrder("b1", "alice", models.Buy, 100, 3))
+	g.Cancel("s1")
+	g.Submit(newOrder("bad", "mm", models.Buy, 0, 3))
+
+	var mm []ExecType
+	for _, r := range reports {
+		if r.Account == "mm" {
+			mm = append(mm, r.Type)
+		}
+	}
+	assert.Equal(t, []ExecType{ExecTrade}, mm)
+	assert.Len(t, reports, 3)
+
+	// Clearing the preference restores every report
+	assert.NoError(t, g.SetNotifications("mm", nil))
+	g.Cancel("b1")
+	g.Submit(newOrder("bad2", "mm", models.Buy, 0, 3))
+	assert.Equal(t, ExecRejected, reports[len(reports)-1].Type)
+}
+
+func TestGateway_CancelAll(t *testing.T) {
+	g := New(matching.NewEngine(metrics.NewMetrics()), Config{MaxOrdersPerSecond: 2})
+	var reports []ExecutionReport
+	g.OnExecution(func(r ExecutionReport) { reports = append(reports, r) })
+	g.Submit(newOrder("a1", "alice", models.Buy, 90, 1))
+	g.Submit(newOrder("b1", "bob", models.Sell, 110, 1))
+	eth := models.NewOrder("b2", "ETHUSD", models.Buy, models.Limit, 50, 1)
+	eth.Account = "bob"
+	g.Submit(eth)
+	assert.NoError(t, g.SetNotifications("alice", []ExecType{ExecTrade}))
+
+	_, err := g.CancelAll(nil, "")
+	assert.ErrorContains(t, err, "invalid reason")
+
+	// Only the listed symbols, and not held back by the rate limit
+	reports = nil
+	cancelled, err := g.CancelAll([]string{"BTCUSD"}, "EXCHANGE_HALT")
+	assert.NoError(t, err)
+	assert.Len(t, cancelled, 2)
+	assert.Equal(t, "EXCHANGE_HALT", cancelled[0].CancelReason)
+	order, _ := g.Engine().GetOrder("b2")
+	assert.Equal(t, models.Accepted, order.Status)
+	if assert.Len(t, reports, 2) {
+		assert.Equal(t, ExecutionReport{Type: ExecCancelled, OrderID: "b1", Account: "bob", Symbol: "BTCUSD", Side: models.Sell, Price: 110,
+			Status: models.Cancelled, RemainingQuantity: 1, Reason: "EXCHANGE_HALT", Timestamp: reports[0].Timestamp}, reports[0])
+		// Notices go out whatever accounts asked for
+		assert.Equal(t, ExecNotice, reports[1].Type)
+		assert.Equal(t, "BTCUSD", reports[1].Symbol)
+		assert.Equal(t, "EXCHANGE_HALT", reports[1].Reason)
+	}
+
+	reports = nil
+	cancelled, err = g.CancelAll(nil, "EXCHANGE_HALT")
+	assert.NoError(t, err)
+	assert.Len(t, cancelled, 1)
+	if assert.Len(t, reports, 2) {
+		assert.Equal(t, ExecNotice, reports[1].Type)
+		assert.Empty(t, reports[1].Symbol)
+	}
+}
+
+func TestGateway_Probe(t *testing.T) {
+	g := New(matching.NewEngine(metrics.NewMetrics()), Config{})
+	var reports []ExecType
+	g.OnExecution(func(r ExecutionReport) { reports = append(reports, r.Type) })
+
+	result := g.Probe(time.Second)
+	assert.True(t, result.OK, result.Error)
+	assert.Equal(t, []ExecType{ExecNew, ExecCancelled}, reports)
+	book := g.Engine().OrderBooks[ProbeSymbol]
+	assert.Nil(t, book.GetBestBid())
+
+	// A stuck book fails the probe instead of hanging it
+	book.Lock()
+	result = g.Probe(10 * time.Millisecond)
+	book.Unlock()
+	assert.False(t, result.OK)
+	assert.Contains(t, result.Error, "timed out")
+}
+
+func TestGateway_Rejects(t *testing.T) {
+	m := metrics.NewMetrics()
+	g := New(matching.NewEngine(m), Config{Metrics: m, MaxOrdersPerSecond: 3})
+
+	g.Submit(newOrder("a1", "alice", models.Buy, 0, 1))
+	g.Submit(models.NewOrder("m1", "ETHUSD", models.Buy, models.Market, 0, 1))
+	g.Submit(newOrder("a2", "alice", models.Buy, 90, 1))
+	g.Submit(newOrder("a3", "alice", models.Buy, 90, 1))
+	g.Submit(newOrder("a4", "alice", models.Buy, 90, 1))
+
+	recent := g.RecentRejects("alice", "")
+	if assert.Len(t, recent, 2) {
+		assert.Equal(t, "a4", recent[0].OrderID)
+		assert.Equal(t, RejectRateLimit, recent[0].Code)
+		assert.Equal(t, RejectValidation, recent[1].Code)
+		assert.Equal(t, "invalid price: must be positive for limit orders", recent[1].Reason)
+	}
+	assert.Equal(t, RejectLiquidity, g.RecentRejects("", "ETHUSD")[0].Code)
+
+	counts := m.Rejections()
+	assert.Equal(t, map[string]int64{"VALIDATION": 1, "LIQUIDITY": 1, "RATE_LIMIT": 1}, counts.Total)
+	assert.Equal(t, map[string]int64{"VALIDATION": 1, "RATE_LIMIT": 1}, counts.ByAccount["alice"])
+	assert.Equal(t, map[string]int64{"LIQUIDITY": 1}, counts.BySymbol["ETHUSD"])
+}
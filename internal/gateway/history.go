@@ -0,0 +1,39 @@
+package gateway
+
+// MaxOrderHistories is how many orders' histories the gateway keeps. The
+// history of the order first seen longest ago is dropped first.
+const MaxOrderHistories = 100000
+
+// OrderEvent is one state transition of an order: the execution report the
+// gateway produced for it, whether or not the owner was sent it.
+type OrderEvent struct {
+	// Sequence orders events across all orders, so events with the same
+	// timestamp can still be told apart.
+	Sequence uint64 `json:"sequence"`
+	ExecutionReport
+}
+
+// recordHistory appends r to its order's history.
+func (g *Gateway) recordHistory(r ExecutionReport) {
+	g.historyMu.Lock()
+	defer g.historyMu.Unlock()
+	events, ok := g.histories[r.OrderID]
+	if !ok {
+		if len(g.historyIDs) == MaxOrderHistories {
+			delete(g.histories, g.historyIDs[0])
+			g.historyIDs = g.historyIDs[1:]
+		}
+		g.historyIDs = append(g.historyIDs, r.OrderID)
+	}
+	g.historySeq++
+	g.histories[r.OrderID] = append(events, OrderEvent{Sequence: g.historySeq, ExecutionReport: r})
+}
+
+// History returns every event of orderID, oldest first, and false if the
+// gateway has none: the order is unknown or its history was dropped.
+func (g *Gateway) History(orderID string) ([]OrderEvent, bool) {
+	g.historyMu.Lock()
+	defer g.historyMu.Unlock()
+	events, ok := g.histories[orderID]
+	return append([]OrderEvent(nil), events...), ok
+}
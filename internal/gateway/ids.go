@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator assigns IDs to orders submitted without one. Generators must be
+// safe for concurrent use.
+type IDGenerator interface {
+	NewID() string
+}
+
+// NewIDGenerator returns the generator called name: "uuid" (the default),
+// "sequential", or "snowflake". node tells snowflake generators on different
+// servers apart and is ignored by the others.
+func NewIDGenerator(name string, node int64) (IDGenerator, error) {
+	switch name {
+	case "", "uuid":
+		return UUIDGenerator{}, nil
+	case "sequential":
+		return &SequentialGenerator{}, nil
+	case "snowflake":
+		return NewSnowflakeGenerator(node)
+	default:
+		return nil, fmt.Errorf("unknown ID strategy %q: want uuid, sequential or snowflake", name)
+	}
+}
+
+// UUIDGenerator issues random version 4 UUIDs.
+type UUIDGenerator struct{}
+
+func (UUIDGenerator) NewID() string {
+	return uuid.New().String()
+}
+
+// SequentialGenerator issues 1, 2, 3, ... with an optional prefix. Numbering
+// restarts with the process, so it suits tests and single-instance setups.
+type SequentialGenerator struct {
+	Prefix string
+	next   atomic.Uint64
+}
+
+func (g *SequentialGenerator) NewID() string {
+	return g.Prefix + strconv.FormatUint(g.next.Add(1), 10)
+}
+
+// Snowflake ID layout, after the sign bit: milliseconds since snowflakeEpoch,
+// the node, and a sequence within the millisecond.
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	MaxSnowflakeNode      = 1<<snowflakeNodeBits - 1
+)
+
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SnowflakeGenerator issues time-ordered 64-bit IDs, unique across up to 1024
+// nodes and 4096 IDs per node per millisecond, as decimal strings.
+type SnowflakeGenerator struct {
+	node     int64
+	lastMs   int64
+	sequence int64
+	mu       sync.Mutex
+	now      func() time.Time
+}
+
+func NewSnowflakeGenerator(node int64) (*SnowflakeGenerator, error) {
+	if node < 0 || node > MaxSnowflakeNode {
+		return nil, fmt.Errorf("invalid snowflake node: must be between 0 and %d", MaxSnowflakeNode)
+	}
+	return &SnowflakeGenerator{node: node, now: time.Now}, nil
+}
+
+func (g *SnowflakeGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.now().Sub(snowflakeEpoch).Milliseconds()
+	if ms < g.lastMs {
+		// The clock went backwards; keep issuing from the last millisecond
+		ms = g.lastMs
+	}
+	if ms == g.lastMs {
+		g.sequence = (g.sequence + 1) & (1<<snowflakeSequenceBits - 1)
+		if g.sequence == 0 {
+			// Sequence exhausted: borrow the next millisecond
+			ms++
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastMs = ms
+
+	id := ms<<(snowflakeNodeBits+snowflakeSequenceBits) | g.node<<snowflakeSequenceBits | g.sequence
+	return strconv.FormatInt(id, 10)
+}
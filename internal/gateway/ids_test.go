@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGateway_OrderIDs(t *testing.T) {
+	g := New(matching.NewEngine(metrics.NewMetrics()), Config{IDs: &SequentialGenerator{Prefix: "ord-"}})
+
+	generated := newOrder("", "alice", models.Buy, 90, 1)
+	_, err := g.Submit(generated)
+	assert.NoError(t, err)
+	assert.Equal(t, "ord-1", generated.ID)
+
+	// Client IDs are kept, as long as they are unique
+	_, err = g.Submit(newOrder("venue-42", "alice", models.Buy, 90, 1))
+	assert.NoError(t, err)
+	_, err = g.Submit(newOrder("venue-42", "bob", models.Sell, 100, 1))
+	assert.EqualError(t, err, "duplicate order id: venue-42")
+	order, _ := g.Engine().GetOrder("venue-42")
+	assert.Equal(t, "alice", order.Account)
+
+	_, err = g.Submit(newOrder("ord-1", "bob", models.Sell, 100, 1))
+	assert.ErrorContains(t, err, "duplicate order id")
+}
+
+func TestSnowflakeGenerator(t *testing.T) {
+	_, err := NewSnowflakeGenerator(MaxSnowflakeNode + 1)
+	assert.Error(t, err)
+
+	g, err := NewSnowflakeGenerator(7)
+	assert.NoError(t, err)
+	now := snowflakeEpoch.Add(time.Second)
+	g.now = func() time.Time { return now }
+
+	// IDs keep increasing through a full millisecond, a clock step back and
+	// the next millisecond
+	var last int64
+	for i := 0; i < 5000; i++ {
+		if i == 4500 {
+			now = now.Add(-time.Millisecond)
+		}
+		id, err := strconv.ParseInt(g.NewID(), 10, 64)
+		assert.NoError(t, err)
+		if !assert.Greater(t, id, last) {
+			return
+		}
+		last = id
+	}
+	assert.Equal(t, int64(7), last>>snowflakeSequenceBits&MaxSnowflakeNode)
+}
+
+func TestNewIDGenerator(t *testing.T) {
+	for _, name := range []string{"", "uuid", "sequential", "snowflake"} {
+		g, err := NewIDGenerator(name, 1)
+		if assert.NoError(t, err, name) {
+			assert.NotEqual(t, g.NewID(), g.NewID(), name)
+		}
+	}
+	_, err := NewIDGenerator("random", 0)
+	assert.Error(t, err)
+}
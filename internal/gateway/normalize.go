@@ -0,0 +1,175 @@
+package gateway
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Normalizer puts inbound orders into the engine's canonical form, whatever
+// protocol they arrived on. Front ends map their wire enums with ParseSide,
+// ParseOrderType and ParseTimeInForce and their decimal prices and quantities
+// with Price and Quantity; Normalize then runs on every submitted order.
+type Normalizer struct {
+	// SymbolAliases maps other spellings of a symbol, upper-cased, to the
+	// engine's symbol, e.g. "BTC-USD" to "BTCUSD".
+	SymbolAliases map[string]string
+	// PriceDecimals and QuantityDecimals are how many decimal places one
+	// engine unit has on decimal wire formats: with PriceDecimals 2, "101.25"
+	// is 10125.
+	PriceDecimals    int
+	QuantityDecimals int
+}
+
+// Normalize canonicalizes order's symbol and account.
+func (n Normalizer) Normalize(order *models.Order) error {
+	symbol := n.Symbol(order.Symbol)
+	if symbol == "" {
+		return fmt.Errorf("invalid symbol: must not be empty")
+	}
+	order.Symbol = symbol
+	order.Account = strings.TrimSpace(order.Account)
+	return nil
+}
+
+// Symbol returns the engine's symbol for symbol, trimmed, upper-cased and with
+// aliases resolved. Queries use it too, so they find the book orders entered
+// under any alias went to.
+func (n Normalizer) Symbol(symbol string) string {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if canonical, ok := n.SymbolAliases[symbol]; ok {
+		return canonical
+	}
+	return symbol
+}
+
+// AliasesOf returns the aliases of the engine's symbol, sorted.
+func (n Normalizer) AliasesOf(symbol string) []string {
+	var aliases []string
+	for alias, canonical := range n.SymbolAliases {
+		if canonical == symbol {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// Price converts a decimal wire price to engine units.
+func (n Normalizer) Price(s string) (int64, error) {
+	v, err := DecimalToUnits(s, n.PriceDecimals)
+	if err != nil {
+		return 0, fmt.Errorf("invalid price: %w", err)
+	}
+	return v, nil
+}
+
+// Quantity converts a decimal wire quantity to engine units.
+func (n Normalizer) Quantity(s string) (int64, error) {
+	v, err := DecimalToUnits(s, n.QuantityDecimals)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity: %w", err)
+	}
+	return v, nil
+}
+
+// DecimalToUnits converts a decimal string to an integer count of
+// 10^-decimals units, without floating point. Values finer than one unit are
+// rejected rather than rounded.
+func DecimalToUnits(s string, decimals int) (int64, error) {
+	s = strings.TrimSpace(s)
+	whole, frac, _ := strings.Cut(s, ".")
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > decimals {
+		return 0, fmt.Errorf("%q has more than %d decimal places", s, decimals)
+	}
+	digits := whole + frac + strings.Repeat("0", decimals-len(frac))
+	v, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || whole == "" || whole == "-" || whole == "+" {
+		return 0, fmt.Errorf("%q is not a decimal number", s)
+	}
+	return v, nil
+}
+
+// ParseSide accepts side names in any case and FIX Side (54) codes.
+func ParseSide(s string) (models.Side, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "BUY", "B", "1":
+		return models.Buy, nil
+	case "SELL", "S", "2":
+		return models.Sell, nil
+	}
+	return 0, fmt.Errorf("unknown side: %s", s)
+}
+
+// ParseOrderType accepts order type names in any case and FIX OrdType (40)
+// codes.
+func ParseOrderType(s string) (models.OrderType, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "LIMIT", "LMT", "2":
+		return models.Limit, nil
+	case "MARKET", "MKT", "1":
+		return models.Market, nil
+	case "FIXING":
+		return models.AtFixing, nil
+	case "STOP_MARKET", "STOP", "3":
+		return models.StopMarket, nil
+	case "MARKET_TO_LIMIT", "K":
+		return models.MarketToLimit, nil
+	}
+	return 0, fmt.Errorf("unknown order type: %s", s)
+}
+
+// ParseTimeInForce accepts time in force names in any case and FIX
+// TimeInForce (59) codes. An empty string leaves the symbol's default.
+func ParseTimeInForce(s string) (models.TimeInForce, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "":
+		return models.TIFDefault, nil
+	case "DAY", "0":
+		return models.DAY, nil
+	case "GTC", "1":
+		return models.GTC, nil
+	case "IOC", "3":
+		return models.IOC, nil
+	case "FOK", "4":
+		return models.FOK, nil
+	case "GTD", "6":
+		return models.GTD, nil
+	}
+	return 0, fmt.Errorf("unknown time in force: %s", s)
+}
+
+// ParseAliases reads symbol aliases written as ALIAS=SYMBOL[,...]. A symbol
+// may have several aliases, e.g. BTC-USD=BTCUSD,XBTUSD=BTCUSD, but an alias
+// can't name two symbols or be aliased itself. Aliasing a symbol to itself is
+// ignored.
+func ParseAliases(s string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return aliases, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		alias, symbol, ok := strings.Cut(pair, "=")
+		alias = strings.ToUpper(strings.TrimSpace(alias))
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if !ok || alias == "" || symbol == "" {
+			return nil, fmt.Errorf("invalid symbol alias %q: want ALIAS=SYMBOL", pair)
+		}
+		if alias == symbol {
+			continue
+		}
+		if previous, ok := aliases[alias]; ok && previous != symbol {
+			return nil, fmt.Errorf("invalid symbol alias %q: %s is already an alias of %s", pair, alias, previous)
+		}
+		aliases[alias] = symbol
+	}
+	for alias, symbol := range aliases {
+		if _, ok := aliases[symbol]; ok {
+			return nil, fmt.Errorf("invalid symbol alias %s=%s: %s is itself an alias", alias, symbol, symbol)
+		}
+	}
+	return aliases, nil
+}
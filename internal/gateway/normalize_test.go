@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	n := Normalizer{SymbolAliases: map[string]string{"BTC-USD": "BTCUSD"}}
+
+	order := models.NewOrder("o1", " btc-usd ", models.Buy, models.Limit, 100, 1)
+	order.Account = " alice"
+	assert.NoError(t, n.Normalize(order))
+	assert.Equal(t, "BTCUSD", order.Symbol)
+	assert.Equal(t, "alice", order.Account)
+
+	order = models.NewOrder("o2", "ethusd", models.Buy, models.Limit, 100, 1)
+	assert.NoError(t, n.Normalize(order))
+	assert.Equal(t, "ETHUSD", order.Symbol)
+
+	assert.Error(t, n.Normalize(models.NewOrder("o3", "  ", models.Buy, models.Limit, 100, 1)))
+}
+
+func TestDecimalToUnits(t *testing.T) {
+	cases := []struct {
+		in       string
+		decimals int
+		want     int64
+	}{
+		{"101.25", 2, 10125},
+		{"101.2", 2, 10120},
+		{"101", 2, 10100},
+		{"101.250", 2, 10125},
+		{"-0.5", 1, -5},
+		{"7", 0, 7},
+	}
+	for _, c := range cases {
+		got, err := DecimalToUnits(c.in, c.decimals)
+		assert.NoError(t, err, c.in)
+		assert.Equal(t, c.want, got, c.in)
+	}
+
+	for _, bad := range []string{"101.255", "", ".5", "1e5", "1.2.3", "abc", "99999999999999999999"} {
+		_, err := DecimalToUnits(bad, 2)
+		assert.Error(t, err, bad)
+	}
+
+	n := Normalizer{PriceDecimals: 2}
+	_, err := n.Price("1.001")
+	assert.ErrorContains(t, err, "invalid price")
+}
+
+func TestParseEnums(t *testing.T) {
+	side, err := ParseSide("buy")
+	assert.NoError(t, err)
+	assert.Equal(t, models.Buy, side)
+	side, _ = ParseSide("2")
+	assert.Equal(t, models.Sell, side)
+	_, err = ParseSide("short")
+	assert.Error(t, err)
+
+	typ, _ := ParseOrderType("mkt")
+	assert.Equal(t, models.Market, typ)
+	typ, _ = ParseOrderType("2")
+	assert.Equal(t, models.Limit, typ)
+
+	tif, _ := ParseTimeInForce("3")
+	assert.Equal(t, models.IOC, tif)
+	tif, _ = ParseTimeInForce("")
+	assert.Equal(t, models.TIFDefault, tif)
+	_, err = ParseTimeInForce("GTX")
+	assert.Error(t, err)
+}
+
+func TestParseAliases(t *testing.T) {
+	aliases, err := ParseAliases("btc-usd=BTCUSD, ETH/USD=ethusd")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"BTC-USD": "BTCUSD", "ETH/USD": "ETHUSD"}, aliases)
+	_, err = ParseAliases("BTCUSD")
+	assert.Error(t, err)
+
+	// Several aliases per symbol, repeats and self-aliases are fine
+	aliases, err = ParseAliases("BTC-USD=BTCUSD,XBTUSD=BTCUSD,xbtusd=btcusd,BTCUSD=BTCUSD")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"BTC-USD": "BTCUSD", "XBTUSD": "BTCUSD"}, aliases)
+	n := Normalizer{SymbolAliases: aliases}
+	assert.Equal(t, []string{"BTC-USD", "XBTUSD"}, n.AliasesOf("BTCUSD"))
+	assert.Equal(t, "BTCUSD", n.Symbol(" xbtusd"))
+	assert.Equal(t, "ETHUSD", n.Symbol("ethusd"))
+
+	_, err = ParseAliases("XBT=BTCUSD,XBT=ETHUSD")
+	assert.ErrorContains(t, err, "already an alias of BTCUSD")
+	_, err = ParseAliases("XBTUSD=BTC-USD,BTC-USD=BTCUSD")
+	assert.ErrorContains(t, err, "BTC-USD is itself an alias")
+}
+
+func TestGateway_NormalizesBeforeEngine(t *testing.T) {
+	engine := matching.NewEngine(metrics.NewMetrics())
+	g := New(engine, Config{Normalizer: Normalizer{SymbolAliases: map[string]string{"BTC-USD": "BTCUSD"}}})
+
+	g.Submit(models.NewOrder("s1", "btc-usd", models.Sell, models.Limit, 100, 5))
+	result, err := g.Submit(models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 100, 5))
+	assert.NoError(t, err)
+	assert.Len(t, result.Trades, 1)
+}
@@ -0,0 +1,49 @@
+package gateway
+
+import (
+	"fmt"
+	"slices"
+)
+
+// AllExecTypes are the reports an account gets unless it chooses otherwise.
+var AllExecTypes = []ExecType{ExecNew, ExecTrade, ExecCancelled, ExecRejected, ExecAmended}
+
+// SetNotifications limits the execution reports published for account's
+// orders to types, e.g. only ExecTrade for a high-rate participant that just
+// wants fills. Only delivery is filtered: every report is still built and
+// kept in its order's history, but unwanted ones never reach the execution
+// handlers, which spares the front ends. Empty types restores every report.
+func (g *Gateway) SetNotifications(account string, types []ExecType) error {
+	for _, t := range types {
+		if !slices.Contains(AllExecTypes, t) {
+			return fmt.Errorf("invalid notifications: unknown exec type %d", t)
+		}
+	}
+
+	g.notifyMu.Lock()
+	defer g.notifyMu.Unlock()
+	if len(types) == 0 {
+		delete(g.notifications, account)
+		return nil
+	}
+	g.notifications[account] = slices.Clone(types)
+	return nil
+}
+
+// Notifications returns the execution report types published for account.
+func (g *Gateway) Notifications(account string) []ExecType {
+	g.notifyMu.RLock()
+	defer g.notifyMu.RUnlock()
+	if types, ok := g.notifications[account]; ok {
+		return slices.Clone(types)
+	}
+	return slices.Clone(AllExecTypes)
+}
+
+// wants reports whether account receives reports of type t.
+func (g *Gateway) wants(account string, t ExecType) bool {
+	g.notifyMu.RLock()
+	defer g.notifyMu.RUnlock()
+	types, ok := g.notifications[account]
+	return !ok || slices.Contains(types, t)
+}
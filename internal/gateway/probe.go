@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"time"
+)
+
+// Probe orders go to their own symbol and account, so they never meet real
+// orders or count against a real account's limits.
+const (
+	ProbeSymbol  = "__PROBE__"
+	ProbeAccount = "__probe__"
+)
+
+// ProbeResult is the outcome of one Probe.
+type ProbeResult struct {
+	OK            bool   `json:"ok"`
+	LatencyMicros int64  `json:"latency_us"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Probe checks the order path end to end: it submits a one-lot limit order on
+// ProbeSymbol through the gateway, checks that it rested, and cancels it. It
+// fails if any step fails or the whole probe takes longer than timeout, e.g.
+// because a book lock is stuck.
+func (g *Gateway) Probe(timeout time.Duration) ProbeResult {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- g.probe() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		err = fmt.Errorf("probe timed out after %s", timeout)
+	}
+	result := ProbeResult{OK: err == nil, LatencyMicros: time.Since(start).Microseconds()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (g *Gateway) probe() error {
+	order := models.NewOrder("", ProbeSymbol, models.Buy, models.Limit, 1, 1)
+	order.Account = ProbeAccount
+	if _, err := g.Submit(order); err != nil {
+		return fmt.Errorf("submit: %w", err)
+	}
+	if order.Status != models.Accepted {
+		return fmt.Errorf("submit: order is %s, want %s", order.Status, models.Accepted)
+	}
+	cancelled, err := g.Cancel(order.ID)
+	if err != nil {
+		return fmt.Errorf("cancel: %w", err)
+	}
+	if cancelled.Status != models.Cancelled {
+		return fmt.Errorf("cancel: order is %s, want %s", cancelled.Status, models.Cancelled)
+	}
+	return nil
+}
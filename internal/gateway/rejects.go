@@ -0,0 +1,108 @@
+package gateway
+
+import "strings"
+
+// MaxRecentRejects is how many rejected orders a gateway remembers.
+const MaxRecentRejects = 1000
+
+// RejectCode groups rejection reasons for metrics and support.
+type RejectCode int
+
+const (
+	RejectValidation RejectCode = iota // malformed or breaks the symbol's rules
+	RejectRisk                         // credit, reduce-only or price band
+	RejectLiquidity                    // not enough to fill as required, or a post-only order would take
+	RejectRateLimit
+	RejectOther
+)
+
+func (c RejectCode) String() string {
+	switch c {
+	case RejectValidation:
+		return "VALIDATION"
+	case RejectRisk:
+		return "RISK"
+	case RejectLiquidity:
+		return "LIQUIDITY"
+	case RejectRateLimit:
+		return "RATE_LIMIT"
+	case RejectOther:
+		return "OTHER"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (c RejectCode) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + c.String() + `"`), nil
+}
+
+// rejectPrefixes map the start of engine, risk and gateway error messages to
+// their codes.
+var rejectPrefixes = []struct {
+	prefix string
+	code   RejectCode
+}{
+	{"invalid ", RejectValidation},
+	{"unknown ", RejectValidation},
+	{"duplicate order id", RejectValidation},
+	{"credit limit exceeded", RejectRisk},
+	{"reduce-only", RejectRisk},
+	{"price band", RejectRisk},
+	{"insufficient liquidity", RejectLiquidity},
+	{"fill or kill", RejectLiquidity},
+	{"sweep limit exceeded", RejectLiquidity},
+	{"minimum quantity", RejectLiquidity},
+	{"post only", RejectLiquidity},
+	{"rate limit exceeded", RejectRateLimit},
+}
+
+// ClassifyReject returns the code for an error an order was rejected with.
+func ClassifyReject(err error) RejectCode {
+	for _, p := range rejectPrefixes {
+		if strings.HasPrefix(err.Error(), p.prefix) {
+			return p.code
+		}
+	}
+	return RejectOther
+}
+
+// Rejection is an order the gateway refused, or the engine refused through
+// it.
+type Rejection struct {
+	OrderID   string     `json:"order_id"`
+	Account   string     `json:"account_id,omitempty"`
+	Symbol    string     `json:"symbol"`
+	Code      RejectCode `json:"code"`
+	Reason    string     `json:"reason"`
+	Timestamp int64      `json:"timestamp"`
+}
+
+// recordReject counts a rejection in the metrics and keeps it for
+// RecentRejects.
+func (g *Gateway) recordReject(r Rejection) {
+	if g.cfg.Metrics != nil {
+		g.cfg.Metrics.IncRejections(r.Code.String(), r.Symbol, r.Account)
+	}
+	g.rejectMu.Lock()
+	defer g.rejectMu.Unlock()
+	if len(g.rejects) == MaxRecentRejects {
+		g.rejects = append(g.rejects[:0], g.rejects[1:]...)
+	}
+	g.rejects = append(g.rejects, r)
+}
+
+// RecentRejects returns the most recent rejections, newest first, optionally
+// only account's or only symbol's.
+func (g *Gateway) RecentRejects(account, symbol string) []Rejection {
+	g.rejectMu.Lock()
+	defer g.rejectMu.Unlock()
+	out := make([]Rejection, 0)
+	for i := len(g.rejects) - 1; i >= 0; i-- {
+		r := g.rejects[i]
+		if (account == "" || r.Account == account) && (symbol == "" || r.Symbol == symbol) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+package gateway
+
+import (
+	"fmt"
+	"repello/internal/matching"
+	"repello/internal/models"
+)
+
+// ExecType is what an execution report describes.
+type ExecType int
+
+const (
+	ExecNew       ExecType = iota // the order was accepted, before it matched
+	ExecTrade                     // the order traded
+	ExecCancelled                 // the order was cancelled or expired
+	ExecRejected                  // the order was refused on entry
+	ExecAmended                   // the order was reduced or its peg repriced in place
+	ExecNotice                    // a market-wide notice, not about any one order
+)
+
+func (t ExecType) String() string {
+	switch t {
+	case ExecNew:
+		return "NEW"
+	case ExecTrade:
+		return "TRADE"
+	case ExecCancelled:
+		return "CANCELLED"
+	case ExecRejected:
+		return "REJECTED"
+	case ExecAmended:
+		return "AMENDED"
+	case ExecNotice:
+		return "NOTICE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (t ExecType) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+func (t *ExecType) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
+		str = str[1 : len(str)-1]
+	}
+	switch str {
+	case "NEW":
+		*t = ExecNew
+	case "TRADE":
+		*t = ExecTrade
+	case "CANCELLED":
+		*t = ExecCancelled
+	case "REJECTED":
+		*t = ExecRejected
+	case "AMENDED":
+		*t = ExecAmended
+	case "NOTICE":
+		*t = ExecNotice
+	default:
+		return fmt.Errorf("unknown exec type: %s", str)
+	}
+	return nil
+}
+
+// ExecutionReport tells the owner of an order what happened to it.
+type ExecutionReport struct {
+	Type              ExecType           `json:"exec_type"`
+	OrderID           string             `json:"order_id"`
+	Account           string             `json:"account_id,omitempty"`
+	Symbol            string             `json:"symbol"`
+	Side              models.Side        `json:"side"`
+	Price             int64              `json:"price,omitempty"` // the order's limit price
+	Status            models.OrderStatus `json:"status"`
+	FilledQuantity    int64              `json:"filled_quantity"`
+	RemainingQuantity int64              `json:"remaining_quantity"`
+	TradeID           string             `json:"trade_id,omitempty"`
+	LastPrice         int64              `json:"last_price,omitempty"`
+	LastQuantity      int64              `json:"last_quantity,omitempty"`
+	Liquidity         *models.Liquidity  `json:"liquidity,omitempty"`
+	Reason            string             `json:"reason,omitempty"` // why it was rejected, or cancelled by the exchange
+	Timestamp         int64              `json:"timestamp"`
+}
+
+// ExecutionHandler receives execution reports. Apart from rejections, reports
+// are produced by engine events and handlers run under the symbol's book lock,
+// so they must be quick and must not call back into the engine.
+type ExecutionHandler func(report ExecutionReport)
+
+// report adds r to its order's history and publishes it if the account wants
+// reports of its type.
+func (g *Gateway) report(r ExecutionReport) {
+	g.recordHistory(r)
+	if len(g.handlers) > 0 && g.wants(r.Account, r.Type) {
+		g.publish(r)
+	}
+}
+
+func (g *Gateway) publish(report ExecutionReport) {
+	for _, h := range g.handlers {
+		h(report)
+	}
+}
+
+func (g *Gateway) orderReport(t ExecType, order *models.Order) ExecutionReport {
+	return ExecutionReport{
+		Type:              t,
+		OrderID:           order.ID,
+		Account:           order.Account,
+		Symbol:            order.Symbol,
+		Side:              order.Side,
+		Price:             order.Price,
+		Status:            order.Status,
+		FilledQuantity:    order.FilledQuantity,
+		RemainingQuantity: order.RemainingQuantity,
+		Reason:            order.CancelReason,
+		Timestamp:         g.now().UnixNano(),
+	}
+}
+
+func (g *Gateway) OrderAccepted(order *models.Order, top matching.BookTop) {
+	g.report(g.orderReport(ExecNew, order))
+}
+
+func (g *Gateway) OrderCancelled(order *models.Order, top matching.BookTop) {
+	g.report(g.orderReport(ExecCancelled, order))
+}
+
+func (g *Gateway) OrderAmended(order *models.Order, top matching.BookTop) {
+	g.report(g.orderReport(ExecAmended, order))
+}
+
+func (g *Gateway) TradeExecuted(trade *models.Trade, taker, maker *models.Order) {
+	for _, order := range []*models.Order{maker, taker} {
+		report := g.orderReport(ExecTrade, order)
+		report.TradeID = trade.ID
+		report.LastPrice = trade.Price
+		report.LastQuantity = trade.Quantity
+		liquidity := trade.LiquidityFor(order.ID)
+		report.Liquidity = &liquidity
+		report.Timestamp = trade.Timestamp
+		g.report(report)
+	}
+}
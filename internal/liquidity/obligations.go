@@ -0,0 +1,177 @@
+package liquidity
+
+import (
+	"fmt"
+	"repello/internal/matching"
+	"repello/internal/surveillance"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMinSamples is how many samples of a session an ObligationMonitor
+// waits for before judging it, so a slow start isn't flagged at once.
+const DefaultMinSamples = 300
+
+// Obligation is a designated market maker's quoting obligation in a symbol:
+// a two-sided quote, each side within MaxTicks ticks of the best price on that
+// side, for at least MinPresencePct of each session. Sessions are UTC days.
+type Obligation struct {
+	Account        string  `json:"account_id"`
+	Symbol         string  `json:"symbol"`
+	MaxTicks       int64   `json:"max_ticks"`
+	MinPresencePct float64 `json:"min_presence_pct"`
+}
+
+func (o Obligation) Validate() error {
+	if o.Account == "" || o.Symbol == "" {
+		return fmt.Errorf("invalid obligation: account_id and symbol are required")
+	}
+	if o.MaxTicks < 0 {
+		return fmt.Errorf("invalid obligation: max_ticks must not be negative")
+	}
+	if o.MinPresencePct < 0 || o.MinPresencePct > 100 {
+		return fmt.Errorf("invalid obligation: min_presence_pct must be between 0 and 100")
+	}
+	return nil
+}
+
+// ObligationStatus is how an obligation is being met in the current session.
+type ObligationStatus struct {
+	Obligation
+	Session     string  `json:"session"` // UTC date
+	Samples     int64   `json:"samples"`
+	Present     int64   `json:"present"`
+	PresencePct float64 `json:"presence_pct"`
+	Breached    bool    `json:"breached"`
+}
+
+func (s *ObligationStatus) pct() float64 {
+	if s.Samples == 0 {
+		return 0
+	}
+	return float64(s.Present) / float64(s.Samples) * 100
+}
+
+// ObligationMonitor checks quoting obligations against a Tracker's samples
+// and raises a QuoteObligation alert when one is breached: once presence so
+// far in a session has fallen below the minimum after DefaultMinSamples
+// samples, or when a session ends below it. Each obligation alerts at most
+// once per session.
+type ObligationMonitor struct {
+	engine     *matching.Engine
+	alerts     *surveillance.AlertStore
+	minSamples int64
+
+	mu          sync.Mutex
+	obligations map[key]*ObligationStatus
+}
+
+// NewObligationMonitor creates a monitor that judges obligations on tracker's
+// samples of engine, raising alerts into alerts.
+func NewObligationMonitor(engine *matching.Engine, tracker *Tracker, alerts *surveillance.AlertStore) *ObligationMonitor {
+	m := &ObligationMonitor{
+		engine:      engine,
+		alerts:      alerts,
+		minSamples:  DefaultMinSamples,
+		obligations: make(map[key]*ObligationStatus),
+	}
+	tracker.OnSample(m.observe)
+	return m
+}
+
+// SetObligation adds or replaces an obligation. A MinPresencePct of zero
+// removes it. Replacing one restarts its session count.
+func (m *ObligationMonitor) SetObligation(o Obligation) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := key{o.Account, o.Symbol}
+	if o.MinPresencePct == 0 {
+		delete(m.obligations, k)
+		return nil
+	}
+	m.obligations[k] = &ObligationStatus{Obligation: o}
+	return nil
+}
+
+// Status returns every obligation's standing in its current session, sorted
+// by symbol then account.
+func (m *ObligationMonitor) Status() []ObligationStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]ObligationStatus, 0, len(m.obligations))
+	for _, s := range m.obligations {
+		status := *s
+		status.PresencePct = s.pct()
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		return out[i].Account < out[j].Account
+	})
+	return out
+}
+
+func (m *ObligationMonitor) observe(snapshots []matching.QuoteSnapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, snapshot := range snapshots {
+		var tick int64
+		for k, s := range m.obligations {
+			if k.symbol != snapshot.Symbol {
+				continue
+			}
+			if tick == 0 {
+				tick = 1
+				if info, ok := m.engine.SymbolInfo(snapshot.Symbol); ok {
+					tick = info.TickSize
+				}
+			}
+
+			session := time.Unix(0, snapshot.Timestamp).UTC().Format("2006-01-02")
+			if s.Session != session {
+				if s.Samples > 0 && !s.Breached && s.pct() < s.MinPresencePct {
+					m.breach(s, "ended")
+				}
+				s.Session, s.Samples, s.Present, s.Breached = session, 0, 0, false
+			}
+
+			s.Samples++
+			if present(snapshot, k.account, s.MaxTicks*tick) {
+				s.Present++
+			}
+			if s.Samples >= m.minSamples && !s.Breached && s.pct() < s.MinPresencePct {
+				m.breach(s, "so far")
+			}
+		}
+	}
+}
+
+// present reports whether account quoted both sides within width of the
+// touch.
+func present(snapshot matching.QuoteSnapshot, account string, width int64) bool {
+	quote, ok := snapshot.Accounts[account]
+	if !ok || quote.Bid == 0 || quote.Ask == 0 {
+		return false
+	}
+	return snapshot.Top.BestBid-quote.Bid <= width && quote.Ask-snapshot.Top.BestAsk <= width
+}
+
+// breach raises an alert for s, scored by how far short it fell.
+func (m *ObligationMonitor) breach(s *ObligationStatus, when string) {
+	s.Breached = true
+	pct := s.pct()
+	m.alerts.Add(&surveillance.Alert{
+		Type:    surveillance.QuoteObligation,
+		Score:   1 - pct/s.MinPresencePct,
+		Account: s.Account,
+		Symbol:  s.Symbol,
+		Description: fmt.Sprintf("quoted two-sided within %d ticks %.1f%% of session %s %s, below the %.1f%% obligation",
+			s.MaxTicks, pct, s.Session, when, s.MinPresencePct),
+	})
+}
@@ -0,0 +1,65 @@
+package liquidity
+
+import (
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/surveillance"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObligationMonitor(t *testing.T) {
+	engine := matching.NewEngine(metrics.NewMetrics())
+	assert.NoError(t, engine.ConfigureSymbols(map[string]matching.SymbolConfig{"BTCUSD": {TickSize: 5}}))
+	tracker := NewTracker(engine, time.Second)
+	alerts := surveillance.NewAlertStore()
+	monitor := NewObligationMonitor(engine, tracker, alerts)
+	monitor.minSamples = 4
+	assert.NoError(t, monitor.SetObligation(Obligation{Account: "dmm", Symbol: "BTCUSD", MaxTicks: 2, MinPresencePct: 75}))
+	assert.Error(t, monitor.SetObligation(Obligation{Account: "dmm", Symbol: "BTCUSD", MinPresencePct: 120}))
+
+	day := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	sample := func(at time.Time, quote matching.AccountQuote) {
+		tracker.Sample([]matching.QuoteSnapshot{{
+			Symbol:    "BTCUSD",
+			Timestamp: at.UnixNano(),
+			Top:       matching.BookTop{BestBid: 100, BestAsk: 105},
+			Accounts:  map[string]matching.AccountQuote{"dmm": quote},
+		}})
+	}
+	inside := matching.AccountQuote{Bid: 90, Ask: 115}  // two ticks of 5 off each side
+	tooWide := matching.AccountQuote{Bid: 85, Ask: 105} // bid three ticks off
+	oneSided := matching.AccountQuote{Bid: 100}
+
+	sample(day, inside)
+	sample(day, tooWide)
+	sample(day, oneSided)
+	assert.Empty(t, alerts.List(), "too few samples to judge")
+	sample(day, inside)
+	status := monitor.Status()
+	assert.Equal(t, 50.0, status[0].PresencePct)
+	assert.True(t, status[0].Breached)
+	if list := alerts.List(); assert.Len(t, list, 1) {
+		assert.Equal(t, surveillance.QuoteObligation, list[0].Type)
+		assert.Equal(t, "dmm", list[0].Account)
+		assert.InDelta(t, 1-50.0/75, list[0].Score, 1e-9)
+	}
+
+	// Once per session; the next session starts afresh
+	sample(day, oneSided)
+	assert.Len(t, alerts.List(), 1)
+	next := day.AddDate(0, 0, 1)
+	sample(next, inside)
+	status = monitor.Status()
+	assert.Equal(t, "2026-03-03", status[0].Session)
+	assert.Equal(t, int64(1), status[0].Samples)
+	assert.False(t, status[0].Breached)
+
+	// A session that ends short is flagged when the next one starts
+	sample(next, oneSided)
+	sample(next.AddDate(0, 0, 1), inside)
+	assert.Len(t, alerts.List(), 2)
+	assert.Contains(t, alerts.List()[1].Description, "session 2026-03-03 ended")
+}
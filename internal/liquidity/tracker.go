@@ -0,0 +1,194 @@
+// Package liquidity measures how much liquidity each account provides, to
+// evaluate liquidity-provider program obligations.
+package liquidity
+
+import (
+	"context"
+	"repello/internal/matching"
+	"repello/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats is an account's liquidity provision in one symbol. The percentages
+// are of the samples taken of the symbol since the tracker started.
+type Stats struct {
+	Account       string `json:"account_id"`
+	Symbol        string `json:"symbol"`
+	MakerTrades   int64  `json:"maker_trades"`
+	MakerVolume   int64  `json:"maker_volume"`
+	MakerTurnover int64  `json:"maker_turnover"`
+	// AtBidPct and AtAskPct are how often the account was quoting at the
+	// best bid or ask; AtTouchPct how often at either.
+	AtBidPct   float64 `json:"at_bid_pct"`
+	AtAskPct   float64 `json:"at_ask_pct"`
+	AtTouchPct float64 `json:"at_touch_pct"`
+	// TimeAtTouch is AtTouchPct as time, in seconds.
+	TimeAtTouch float64 `json:"time_at_touch_secs"`
+	// TwoSidedPct is how often the account quoted both sides, and
+	// AvgQuotedSpread its average spread between them when it did.
+	TwoSidedPct     float64 `json:"two_sided_pct"`
+	AvgQuotedSpread float64 `json:"avg_quoted_spread"`
+}
+
+type key struct {
+	account string
+	symbol  string
+}
+
+type counters struct {
+	makerTrades, makerVolume, makerTurnover int64
+	atBid, atAsk, atTouch, twoSided         int64 // samples
+	spreadSum                               int64
+}
+
+// SampleHandler is called with every sample, after the tracker has counted
+// it.
+type SampleHandler func(snapshots []matching.QuoteSnapshot)
+
+// Tracker counts each account's maker fills from engine events and samples
+// the books at a fixed interval for whose quotes are at the touch.
+type Tracker struct {
+	engine   *matching.Engine
+	interval time.Duration
+	handlers []SampleHandler
+
+	mu       sync.Mutex
+	counters map[key]*counters
+	samples  map[string]int64 // by symbol
+}
+
+var _ matching.EventListener = (*Tracker)(nil)
+
+// NewTracker creates a tracker that samples engine every interval once Run is
+// called, and registers it as one of the engine's listeners.
+func NewTracker(engine *matching.Engine, interval time.Duration) *Tracker {
+	t := &Tracker{
+		engine:   engine,
+		interval: interval,
+		counters: make(map[key]*counters),
+		samples:  make(map[string]int64),
+	}
+	engine.AddEventListener(t)
+	return t
+}
+
+// OnSample registers h for every sample. Handlers must be registered before
+// Run is called.
+func (t *Tracker) OnSample(h SampleHandler) {
+	t.handlers = append(t.handlers, h)
+}
+
+// Run samples the books every interval until ctx is done.
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.Sample(t.engine.QuoteSnapshots())
+		}
+	}
+}
+
+func (t *Tracker) get(account, symbol string) *counters {
+	k := key{account, symbol}
+	c, ok := t.counters[k]
+	if !ok {
+		c = &counters{}
+		t.counters[k] = c
+	}
+	return c
+}
+
+// Sample counts one sample of the books and passes it to the handlers.
+func (t *Tracker) Sample(snapshots []matching.QuoteSnapshot) {
+	t.count(snapshots)
+	for _, h := range t.handlers {
+		h(snapshots)
+	}
+}
+
+func (t *Tracker) count(snapshots []matching.QuoteSnapshot) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range snapshots {
+		t.samples[s.Symbol]++
+		for account, quote := range s.Accounts {
+			c := t.get(account, s.Symbol)
+			atBid := quote.Bid != 0 && quote.Bid == s.Top.BestBid
+			atAsk := quote.Ask != 0 && quote.Ask == s.Top.BestAsk
+			if atBid {
+				c.atBid++
+			}
+			if atAsk {
+				c.atAsk++
+			}
+			if atBid || atAsk {
+				c.atTouch++
+			}
+			if quote.Bid != 0 && quote.Ask != 0 {
+				c.twoSided++
+				c.spreadSum += quote.Ask - quote.Bid
+			}
+		}
+	}
+}
+
+func (t *Tracker) OrderAccepted(order *models.Order, top matching.BookTop) {}
+
+func (t *Tracker) OrderCancelled(order *models.Order, top matching.BookTop) {}
+
+func (t *Tracker) TradeExecuted(trade *models.Trade, taker, maker *models.Order) {
+	if maker.Account == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.get(maker.Account, trade.Symbol)
+	c.makerTrades++
+	c.makerVolume += trade.Quantity
+	c.makerTurnover += trade.Price * trade.Quantity
+}
+
+// Report returns the stats of every account and symbol tracked, optionally
+// only account's or only symbol's, sorted by symbol then account.
+func (t *Tracker) Report(account, symbol string) []Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Stats, 0)
+	for k, c := range t.counters {
+		if (account != "" && k.account != account) || (symbol != "" && k.symbol != symbol) {
+			continue
+		}
+		stats := Stats{
+			Account:       k.account,
+			Symbol:        k.symbol,
+			MakerTrades:   c.makerTrades,
+			MakerVolume:   c.makerVolume,
+			MakerTurnover: c.makerTurnover,
+			TimeAtTouch:   (time.Duration(c.atTouch) * t.interval).Seconds(),
+		}
+		if samples := t.samples[k.symbol]; samples > 0 {
+			pct := func(n int64) float64 { return float64(n) / float64(samples) * 100 }
+			stats.AtBidPct = pct(c.atBid)
+			stats.AtAskPct = pct(c.atAsk)
+			stats.AtTouchPct = pct(c.atTouch)
+			stats.TwoSidedPct = pct(c.twoSided)
+		}
+		if c.twoSided > 0 {
+			stats.AvgQuotedSpread = float64(c.spreadSum) / float64(c.twoSided)
+		}
+		out = append(out, stats)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		return out[i].Account < out[j].Account
+	})
+	return out
+}
@@ -0,0 +1,52 @@
+package liquidity
+
+import (
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newOrder(id, account string, side models.Side, price, quantity int64) *models.Order {
+	order := models.NewOrder(id, "BTCUSD", side, models.Limit, price, quantity)
+	order.Account = account
+	return order
+}
+
+func TestTracker(t *testing.T) {
+	engine := matching.NewEngine(metrics.NewMetrics())
+	tracker := NewTracker(engine, time.Second)
+
+	engine.ProcessOrder(newOrder("mm-b", "mm", models.Buy, 99, 10))
+	engine.ProcessOrder(newOrder("mm-a", "mm", models.Sell, 102, 10))
+	engine.ProcessOrder(newOrder("lp-a", "lp", models.Sell, 101, 5))
+	tracker.Sample(engine.QuoteSnapshots())
+
+	// lp's ask is taken out; mm is now at both touches
+	engine.ProcessOrder(newOrder("t1", "taker", models.Buy, 101, 5))
+	tracker.Sample(engine.QuoteSnapshots())
+
+	report := tracker.Report("", "BTCUSD")
+	if assert.Len(t, report, 2) {
+		lp, mm := report[0], report[1]
+		assert.Equal(t, "lp", lp.Account)
+		assert.Equal(t, int64(1), lp.MakerTrades)
+		assert.Equal(t, int64(5), lp.MakerVolume)
+		assert.Equal(t, int64(505), lp.MakerTurnover)
+		assert.Equal(t, 50.0, lp.AtAskPct)
+		assert.Equal(t, 1.0, lp.TimeAtTouch)
+		assert.Zero(t, lp.TwoSidedPct)
+
+		assert.Equal(t, "mm", mm.Account)
+		assert.Equal(t, 100.0, mm.AtBidPct)
+		assert.Equal(t, 50.0, mm.AtAskPct)
+		assert.Equal(t, 100.0, mm.AtTouchPct)
+		assert.Equal(t, 100.0, mm.TwoSidedPct)
+		assert.Equal(t, 3.0, mm.AvgQuotedSpread)
+	}
+	// Takers provide no liquidity
+	assert.Empty(t, tracker.Report("taker", ""))
+}
@@ -0,0 +1,155 @@
+// Package marketmaker provides a synthetic liquidity bot that keeps books
+// non-empty for demos, the sandbox and load testing.
+package marketmaker
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"repello/internal/matching"
+	"repello/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Account owns the bot's quotes.
+const Account = "liquidity-bot"
+
+// SymbolConfig is a symbol the bot quotes, starting around StartPrice and
+// moving in multiples of Tick.
+type SymbolConfig struct {
+	Symbol     string
+	StartPrice int64
+	Tick       int64
+}
+
+// Config controls the bot.
+type Config struct {
+	Symbols  []SymbolConfig
+	Levels   int           // quotes per side
+	Quantity int64         // per quote
+	MaxStep  int64         // largest mid move per requote, in ticks
+	Interval time.Duration // time between requotes
+	Seed     uint64        // random walk seed
+}
+
+// DefaultConfig returns the settings the server uses, without any symbols.
+func DefaultConfig() Config {
+	return Config{
+		Levels:   5,
+		Quantity: 10,
+		MaxStep:  2,
+		Interval: 500 * time.Millisecond,
+		Seed:     uint64(time.Now().UnixNano()),
+	}
+}
+
+// ParseSymbols parses a comma-separated list of SYMBOL:PRICE:TICK entries, e.g.
+// "BTCUSD:50000:10,ETHUSD:3000:1".
+func ParseSymbols(spec string) ([]SymbolConfig, error) {
+	var symbols []SymbolConfig
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid symbol %q: want SYMBOL:PRICE:TICK", entry)
+		}
+		price, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("invalid symbol %q: price must be a positive integer", entry)
+		}
+		tick, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil || tick <= 0 {
+			return nil, fmt.Errorf("invalid symbol %q: tick must be a positive integer", entry)
+		}
+		symbols = append(symbols, SymbolConfig{Symbol: parts[0], StartPrice: price, Tick: tick})
+	}
+	return symbols, nil
+}
+
+// Bot continuously requotes its symbols around a random-walk mid price.
+type Bot struct {
+	engine *matching.Engine
+	cfg    Config
+	rng    *rand.Rand
+	mids   map[string]int64
+	quotes map[string][]string // live quote order IDs by symbol
+}
+
+// New creates a bot that quotes on engine. Call Run to start it.
+func New(engine *matching.Engine, cfg Config) *Bot {
+	b := &Bot{
+		engine: engine,
+		cfg:    cfg,
+		rng:    rand.New(rand.NewPCG(cfg.Seed, cfg.Seed)),
+		mids:   make(map[string]int64),
+		quotes: make(map[string][]string),
+	}
+	for _, sym := range cfg.Symbols {
+		b.mids[sym.Symbol] = sym.StartPrice
+	}
+	return b
+}
+
+// Run requotes every Interval until ctx is done.
+func (b *Bot) Run(ctx context.Context) {
+	b.Step()
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.Step()
+		}
+	}
+}
+
+// Step moves each symbol's mid and replaces the bot's quotes around it.
+func (b *Bot) Step() {
+	for _, sym := range b.cfg.Symbols {
+		mid := b.mids[sym.Symbol]
+		if b.cfg.MaxStep > 0 {
+			mid += (b.rng.Int64N(2*b.cfg.MaxStep+1) - b.cfg.MaxStep) * sym.Tick
+		}
+		// Keep the lowest bid above zero
+		if floor := sym.Tick * int64(b.cfg.Levels+1); mid < floor {
+			mid = floor
+		}
+		b.mids[sym.Symbol] = mid
+
+		for _, id := range b.quotes[sym.Symbol] {
+			b.engine.CancelOrder(id) // filled or already gone is fine
+		}
+		b.quotes[sym.Symbol] = b.quote(sym, mid)
+	}
+}
+
+func (b *Bot) quote(sym SymbolConfig, mid int64) []string {
+	ids := make([]string, 0, 2*b.cfg.Levels)
+	for i := 1; i <= b.cfg.Levels; i++ {
+		offset := sym.Tick * int64(i)
+		for _, q := range []struct {
+			side  models.Side
+			price int64
+		}{{models.Buy, mid - offset}, {models.Sell, mid + offset}} {
+			order := models.NewOrder(uuid.New().String(), sym.Symbol, q.side, models.Limit, q.price, b.cfg.Quantity)
+			order.Account = Account
+			result, err := b.engine.ProcessOrder(order)
+			if err != nil {
+				continue
+			}
+			result.Release()
+			ids = append(ids, order.ID)
+		}
+	}
+	return ids
+}
+
+// Mid returns the bot's current mid price for symbol.
+func (b *Bot) Mid(symbol string) int64 {
+	return b.mids[symbol]
+}
@@ -0,0 +1,47 @@
+package marketmaker
+
+import (
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBot_RequotesAroundRandomWalk(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := matching.NewEngine(m)
+	cfg := DefaultConfig()
+	cfg.Symbols = []SymbolConfig{{Symbol: "BTCUSD", StartPrice: 1000, Tick: 5}}
+	cfg.Levels = 3
+	cfg.Seed = 1
+	bot := New(engine, cfg)
+
+	for i := 0; i < 20; i++ {
+		bot.Step()
+
+		depth, _ := engine.GetOrderBookDepth("BTCUSD", 0)
+		assert.Len(t, depth.Bids, 3)
+		assert.Len(t, depth.Asks, 3)
+		mid := bot.Mid("BTCUSD")
+		assert.Equal(t, mid-5, depth.Bids[0].Price)
+		assert.Equal(t, mid+5, depth.Asks[0].Price)
+		assert.Equal(t, int64(0), (mid-1000)%5)
+	}
+	// Old quotes are cancelled, not left behind
+	assert.Equal(t, int64(6), m.OrdersInBook.Load())
+}
+
+func TestParseSymbols(t *testing.T) {
+	symbols, err := ParseSymbols("BTCUSD:50000:10, ETHUSD:3000:1")
+	assert.NoError(t, err)
+	assert.Equal(t, []SymbolConfig{
+		{Symbol: "BTCUSD", StartPrice: 50000, Tick: 10},
+		{Symbol: "ETHUSD", StartPrice: 3000, Tick: 1},
+	}, symbols)
+
+	for _, bad := range []string{"BTCUSD", "BTCUSD:0:1", "BTCUSD:100:x", ":100:1"} {
+		_, err := ParseSymbols(bad)
+		assert.Error(t, err, bad)
+	}
+}
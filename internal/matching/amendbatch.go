@@ -0,0 +1,261 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"sort"
+	"time"
+)
+
+// BatchAmendment is one order's change in a BatchAmendCommand. ReduceBy and
+// NewQuantity work as in AmendCommand; at most one may be set. Without a new
+// Price the order is reduced in place and keeps its priority. A new Price
+// replaces the order: it is cancelled, and its remaining quantity, less any
+// reduction, is entered at Price as a new order ReplacementID, behind the
+// orders already there. Quantity can only go down either way.
+type BatchAmendment struct {
+	OrderID       string
+	Price         int64
+	ReduceBy      int64
+	NewQuantity   int64
+	ReplacementID string
+}
+
+// AmendResult is what happened to one amendment of a batch. Order is the
+// amended order, or its replacement if it was repriced, in which case Match
+// is how the replacement matched. If the replacement was refused, Order is
+// the cancelled original and Err says why.
+type AmendResult struct {
+	OrderID string
+	Order   *models.Order
+	Match   *MatchResult
+	Err     error
+}
+
+// BatchAmendCommand amends many resting orders, e.g. to move a quote ladder.
+// Amendments are grouped by symbol, and each symbol's are checked together and
+// applied under a single hold of its book lock, so no other order sees the
+// book half amended. If any of a symbol's amendments is invalid, or any of
+// its replacements would be refused by the engine's own entry checks (such as
+// post-only, minimum quantity or the sweep limit) against the book without
+// the orders being replaced, none of them is applied. Replacements are still
+// entered one at a time and only then run the pre-trade checks, so one
+// refused by a risk check, or because of an earlier replacement that traded
+// or now rests, leaves its original cancelled while the rest go ahead.
+type BatchAmendCommand struct {
+	Amendments []BatchAmendment
+}
+
+func (c BatchAmendCommand) apply(e *Engine) (*CommandResult, error) {
+	results := make([]AmendResult, len(c.Amendments))
+	bySymbol := make(map[string][]int)
+	for i, a := range c.Amendments {
+		results[i].OrderID = a.OrderID
+		order, err := e.GetOrder(a.OrderID)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		bySymbol[order.Symbol] = append(bySymbol[order.Symbol], i)
+	}
+
+	symbols := make([]string, 0, len(bySymbol))
+	for symbol := range bySymbol {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		e.amendBatch(e.getOrderBook(symbol), c.Amendments, bySymbol[symbol], results)
+	}
+	return &CommandResult{Amends: results}, nil
+}
+
+// pendingAmendment is a checked amendment waiting to be applied.
+type pendingAmendment struct {
+	index       int
+	order       *models.Order
+	reduceBy    int64
+	replacement *models.Order // nil unless repriced
+}
+
+// amendBatch applies the amendments at indexes, all of orders in ob, writing
+// their outcomes to results.
+func (e *Engine) amendBatch(ob *OrderBook, amendments []BatchAmendment, indexes []int, results []AmendResult) {
+	ob.Lock()
+	defer ob.Unlock()
+	e.amendLocked(ob, amendments, indexes, results)
+}
+
+// amendLocked is amendBatch with the book already locked.
+func (e *Engine) amendLocked(ob *OrderBook, amendments []BatchAmendment, indexes []int, results []AmendResult) {
+	startTime := time.Now()
+	pending := make([]pendingAmendment, 0, len(indexes))
+	seen := make(map[string]bool)
+	failed := false
+	for _, i := range indexes {
+		p, err := e.checkAmendment(ob, amendments[i], seen)
+		if err != nil {
+			results[i].Err = err
+			failed = true
+			continue
+		}
+		p.index = i
+		pending = append(pending, p)
+	}
+	if failed {
+		for _, p := range pending {
+			results[p.index].Err = fmt.Errorf("amendment not applied: another amendment for %s is invalid", ob.Symbol)
+		}
+		return
+	}
+
+	// One-cancels-other pairs follow their orders to the replacements; the
+	// cancels below unlink the originals
+	partners := e.replacementPartners(ob, pending)
+
+	// Take out everything being replaced before entering any replacement, so
+	// a ladder moving through its own prices doesn't trade against itself.
+	// Each replacement is then put through the engine's own entry checks
+	// against that book, and if any would be refused the originals go back
+	// where they were.
+	for _, p := range pending {
+		if p.replacement != nil {
+			ob.RemoveOrder(p.order.ID)
+		}
+	}
+	for _, p := range pending {
+		if p.replacement == nil {
+			continue
+		}
+		probe := *p.replacement
+		if _, err := e.checkEntry(&probe, ob, startTime); err != nil {
+			results[p.index].Err = err
+			failed = true
+		}
+	}
+	if failed {
+		for _, p := range pending {
+			if p.replacement != nil {
+				ob.AddOrder(p.order)
+			}
+			if results[p.index].Err == nil {
+				results[p.index].Err = fmt.Errorf("amendment not applied: another amendment for %s was refused", ob.Symbol)
+			}
+		}
+		return
+	}
+
+	for _, p := range pending {
+		results[p.index].Order = p.order
+		if p.replacement == nil {
+			ob.ReduceOrder(p.order, p.reduceBy)
+			continue
+		}
+		p.order.SetStatus(models.Cancelled)
+		e.metrics.IncOrdersCancelled()
+		e.metrics.DecOrdersInBook()
+		e.metrics.IncSymbolCancels(ob.Symbol)
+		e.emitOrderCancelled(p.order, ob)
+	}
+	for _, p := range pending {
+		if p.replacement == nil {
+			continue
+		}
+		if partner := partners[p.replacement.ID]; partner != nil {
+			switch partner.Status {
+			case models.Filled:
+				results[p.index].Err = fmt.Errorf("cannot amend: the other order of its one-cancels-other pair has filled")
+				continue
+			case models.Accepted, models.PartialFill:
+				ob.link(p.replacement.ID, partner.ID)
+			}
+		}
+		if _, exists := e.AllOrders.LoadOrStore(p.replacement.ID, p.replacement); exists {
+			ob.unlink(p.replacement.ID)
+			results[p.index].Err = fmt.Errorf("duplicate order id: %s", p.replacement.ID)
+			continue
+		}
+		e.metrics.IncOrdersReceived()
+		match, err := e.enterOrder(p.replacement, ob, startTime)
+		if err != nil {
+			ob.unlink(p.replacement.ID)
+			results[p.index].Err = err
+			continue
+		}
+		results[p.index].Order, results[p.index].Match = p.replacement, match
+	}
+}
+
+// checkAmendment checks a against the locked book, building the replacement
+// order if a reprices. seen holds the order and replacement IDs of the
+// amendments checked before it.
+func (e *Engine) checkAmendment(ob *OrderBook, a BatchAmendment, seen map[string]bool) (pendingAmendment, error) {
+	p := pendingAmendment{}
+	if a.ReduceBy != 0 && a.NewQuantity != 0 {
+		return p, fmt.Errorf("invalid amendment: set at most one of reduce_by and new_quantity")
+	}
+	if seen[a.OrderID] {
+		return p, fmt.Errorf("invalid amendment: order %s is amended twice", a.OrderID)
+	}
+	seen[a.OrderID] = true
+
+	order, resting := ob.Orders[a.OrderID]
+	if !resting {
+		return p, fmt.Errorf("cannot amend: order is not resting in the book")
+	}
+	p.order = order
+	p.reduceBy = a.ReduceBy
+	if a.NewQuantity != 0 {
+		p.reduceBy = order.OriginalQuantity - a.NewQuantity
+	}
+	if p.reduceBy < 0 {
+		return p, fmt.Errorf("invalid quantity: can only reduce an order")
+	}
+	if p.reduceBy >= order.RemainingQuantity {
+		return p, fmt.Errorf("invalid quantity: reduction must leave some quantity open, cancel the order instead")
+	}
+
+	if a.Price == 0 || a.Price == order.Price {
+		if p.reduceBy == 0 {
+			return p, fmt.Errorf("invalid amendment: nothing to change")
+		}
+		return p, nil
+	}
+
+	if order.PegType != models.PegNone {
+		return p, fmt.Errorf("invalid price: pegged orders are priced by their peg")
+	}
+	if a.ReplacementID == "" {
+		return p, fmt.Errorf("invalid order id: a repriced order needs a replacement id")
+	}
+	if _, exists := e.AllOrders.Load(a.ReplacementID); exists || seen[a.ReplacementID] {
+		return p, fmt.Errorf("duplicate order id: %s", a.ReplacementID)
+	}
+	seen[a.ReplacementID] = true
+
+	replacement := models.NewOrder(a.ReplacementID, order.Symbol, order.Side, models.Limit, a.Price, order.RemainingQuantity-p.reduceBy)
+	replacement.Account = order.Account
+	replacement.TimeInForce = order.TimeInForce
+	if order.TimeInForce == models.GTD {
+		// A DAY order's expiry is the session close, resolved again on entry
+		replacement.ExpireAt = order.ExpireAt
+	}
+	replacement.ReduceOnly = order.ReduceOnly
+	replacement.Hidden = order.Hidden
+	replacement.PostOnly = order.PostOnly
+	replacement.PostOnlyReprice = order.PostOnlyReprice
+	replacement.DisplayQuantity = min(order.DisplayQuantity, replacement.OriginalQuantity)
+	replacement.Tag = order.Tag
+	replacement.Metadata = order.Metadata
+	if err := replacement.Validate(); err != nil {
+		return p, err
+	}
+	if err := ob.CheckPrice(a.Price); err != nil {
+		return p, err
+	}
+	if err := ob.checkSymbolRules(replacement); err != nil {
+		return p, err
+	}
+	p.replacement = replacement
+	return p, nil
+}
@@ -0,0 +1,203 @@
+package matching
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/emirpasic/gods/trees/redblacktree"
+	"github.com/emirpasic/gods/utils"
+)
+
+// BookSide stores the price levels for one side of an order book, ordered from
+// the best price to the worst.
+type BookSide interface {
+	Get(price int64) (*PriceLevel, bool)
+	Put(level *PriceLevel)
+	Remove(price int64)
+	Empty() bool
+	// Best returns the best price level, or nil if the side is empty.
+	Best() *PriceLevel
+	// Walk visits levels best price first until fn returns false.
+	Walk(fn func(level *PriceLevel) bool)
+}
+
+// treeSide is the default BookSide, backed by a red-black tree keyed by price.
+type treeSide struct {
+	tree *redblacktree.Tree
+}
+
+func newTreeSide(descending bool) *treeSide {
+	if descending {
+		return &treeSide{tree: redblacktree.NewWith(func(a, b interface{}) int {
+			return utils.Int64Comparator(b, a)
+		})}
+	}
+	return &treeSide{tree: redblacktree.NewWith(utils.Int64Comparator)}
+}
+
+func (s *treeSide) Get(price int64) (*PriceLevel, bool) {
+	level, found := s.tree.Get(price)
+	if !found {
+		return nil, false
+	}
+	return level.(*PriceLevel), true
+}
+
+func (s *treeSide) Put(level *PriceLevel) {
+	s.tree.Put(level.Price, level)
+}
+
+func (s *treeSide) Remove(price int64) {
+	s.tree.Remove(price)
+}
+
+func (s *treeSide) Empty() bool {
+	return s.tree.Empty()
+}
+
+func (s *treeSide) Best() *PriceLevel {
+	node := s.tree.Left() // left is the best price for either ordering
+	if node == nil {
+		return nil
+	}
+	return node.Value.(*PriceLevel)
+}
+
+func (s *treeSide) Walk(fn func(level *PriceLevel) bool) {
+	it := s.tree.Iterator()
+	it.Begin()
+	for it.Next() {
+		if !fn(it.Value().(*PriceLevel)) {
+			return
+		}
+	}
+}
+
+// LadderConfig bounds the prices a ladder book can hold. Prices must lie in
+// [MinPrice, MaxPrice] and be a whole number of ticks above MinPrice.
+type LadderConfig struct {
+	MinPrice int64
+	MaxPrice int64
+	TickSize int64
+}
+
+func (c LadderConfig) validate() error {
+	if c.TickSize <= 0 {
+		return fmt.Errorf("invalid ladder: tick size must be positive")
+	}
+	if c.MinPrice <= 0 || c.MaxPrice < c.MinPrice {
+		return fmt.Errorf("invalid ladder: price range must be positive and non-empty")
+	}
+	return nil
+}
+
+// checkPrice reports whether a limit price fits on the ladder.
+func (c LadderConfig) checkPrice(price int64) error {
+	if price < c.MinPrice || price > c.MaxPrice {
+		return fmt.Errorf("invalid price: must be between %d and %d", c.MinPrice, c.MaxPrice)
+	}
+	if (price-c.MinPrice)%c.TickSize != 0 {
+		return fmt.Errorf("invalid price: must be a multiple of tick size %d", c.TickSize)
+	}
+	return nil
+}
+
+// ladderSide is a BookSide for symbols with a dense, bounded price range. Levels
+// live in a contiguous array indexed by tick, and a bitmap of occupied ticks is
+// scanned a word at a time to find the best price.
+type ladderSide struct {
+	cfg        LadderConfig
+	levels     []*PriceLevel
+	occupied   []uint64
+	count      int
+	descending bool
+}
+
+func newLadderSide(cfg LadderConfig, descending bool) *ladderSide {
+	ticks := (cfg.MaxPrice-cfg.MinPrice)/cfg.TickSize + 1
+	return &ladderSide{
+		cfg:        cfg,
+		levels:     make([]*PriceLevel, ticks),
+		occupied:   make([]uint64, (ticks+63)/64),
+		descending: descending,
+	}
+}
+
+func (s *ladderSide) index(price int64) (int, bool) {
+	if s.cfg.checkPrice(price) != nil {
+		return 0, false
+	}
+	return int((price - s.cfg.MinPrice) / s.cfg.TickSize), true
+}
+
+func (s *ladderSide) Get(price int64) (*PriceLevel, bool) {
+	i, ok := s.index(price)
+	if !ok || s.levels[i] == nil {
+		return nil, false
+	}
+	return s.levels[i], true
+}
+
+func (s *ladderSide) Put(level *PriceLevel) {
+	i, ok := s.index(level.Price)
+	if !ok {
+		return // callers validate prices against the ladder first
+	}
+	if s.levels[i] == nil {
+		s.count++
+		s.occupied[i/64] |= 1 << (i % 64)
+	}
+	s.levels[i] = level
+}
+
+func (s *ladderSide) Remove(price int64) {
+	i, ok := s.index(price)
+	if !ok || s.levels[i] == nil {
+		return
+	}
+	s.levels[i] = nil
+	s.occupied[i/64] &^= 1 << (i % 64)
+	s.count--
+}
+
+func (s *ladderSide) Empty() bool {
+	return s.count == 0
+}
+
+func (s *ladderSide) Best() *PriceLevel {
+	var best *PriceLevel
+	s.Walk(func(level *PriceLevel) bool {
+		best = level
+		return false
+	})
+	return best
+}
+
+func (s *ladderSide) Walk(fn func(level *PriceLevel) bool) {
+	if s.count == 0 {
+		return
+	}
+	if s.descending {
+		for w := len(s.occupied) - 1; w >= 0; w-- {
+			word := s.occupied[w]
+			for word != 0 {
+				b := 63 - bits.LeadingZeros64(word)
+				if !fn(s.levels[w*64+b]) {
+					return
+				}
+				word &^= 1 << b
+			}
+		}
+		return
+	}
+	for w := 0; w < len(s.occupied); w++ {
+		word := s.occupied[w]
+		for word != 0 {
+			b := bits.TrailingZeros64(word)
+			if !fn(s.levels[w*64+b]) {
+				return
+			}
+			word &= word - 1
+		}
+	}
+}
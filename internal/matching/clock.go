@@ -0,0 +1,57 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"sync/atomic"
+	"time"
+)
+
+// ClockGuard is a PreTradeCheck that holds client-supplied transact times to
+// the exchange clock. Orders whose TransactTime is further than Tolerance from
+// the exchange clock are rejected, or with Restamp, given the exchange time
+// instead with the difference recorded in ClockSkew. Orders without a
+// TransactTime are not checked.
+type ClockGuard struct {
+	Tolerance time.Duration
+	Restamp   bool
+
+	restamped atomic.Int64
+	now       func() time.Time
+}
+
+var _ PreTradeCheck = (*ClockGuard)(nil)
+
+func NewClockGuard(tolerance time.Duration, restamp bool) *ClockGuard {
+	return &ClockGuard{Tolerance: tolerance, Restamp: restamp, now: time.Now}
+}
+
+// Restamped returns how many orders have had their transact time replaced.
+func (g *ClockGuard) Restamped() int64 {
+	return g.restamped.Load()
+}
+
+func (g *ClockGuard) CheckOrder(order *models.Order, notional int64) error {
+	if order.TransactTime == 0 {
+		return nil
+	}
+	now := g.now().UnixNano()
+	skew := order.TransactTime - now
+	if time.Duration(abs(skew)) <= g.Tolerance {
+		return nil
+	}
+	if !g.Restamp {
+		return fmt.Errorf("invalid transact time: %s from exchange clock, tolerance is %s", time.Duration(skew), g.Tolerance)
+	}
+	order.ClockSkew = skew
+	order.TransactTime = now
+	g.restamped.Add(1)
+	return nil
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,166 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"sort"
+)
+
+// Command is a request to change engine state through Dispatch. Every change
+// the gateway makes on behalf of order entry is a Command, so tooling such as
+// journaling or replay can record those uniformly. Other mutations are not:
+// sandbox seeding, the market-maker bot and warm-up call ProcessOrder and
+// CancelOrder directly, and operator actions such as PublishFixing,
+// ExpireOrders, ImportOrders, purges and Reset have no Command.
+type Command interface {
+	apply(e *Engine) (*CommandResult, error)
+}
+
+// CommandResult is what a command did. Match is set by NewOrderCommand, and
+// Matches by OCOCommand, one per order; Orders holds the orders a cancel,
+// amend or mass cancel changed, and Amends the outcome of each of a
+// BatchAmendCommand's amendments, in order.
+type CommandResult struct {
+	Match   *MatchResult
+	Matches []*MatchResult
+	Orders  []*models.Order
+	Amends  []AmendResult
+}
+
+// NewOrderCommand submits Order for matching, as ProcessOrder.
+type NewOrderCommand struct {
+	Order *models.Order
+}
+
+// CancelCommand cancels an active order, as CancelOrder.
+type CancelCommand struct {
+	OrderID string
+}
+
+// AmendCommand reduces a resting order in place, keeping its priority. Set
+// exactly one of ReduceBy and NewQuantity, the new total including filled
+// quantity.
+type AmendCommand struct {
+	OrderID     string
+	ReduceBy    int64
+	NewQuantity int64
+}
+
+// MassCancelCommand cancels every active order in Symbol, or in all symbols
+// if it is empty, that belongs to Account, or to anyone if it is empty.
+// Reason, if set, becomes each cancelled order's CancelReason.
+type MassCancelCommand struct {
+	Symbol  string
+	Account string
+	Reason  string
+}
+
+// Dispatch applies cmd to the engine.
+func (e *Engine) Dispatch(cmd Command) (*CommandResult, error) {
+	return cmd.apply(e)
+}
+
+func (c NewOrderCommand) apply(e *Engine) (*CommandResult, error) {
+	result, err := e.ProcessOrder(c.Order)
+	if err != nil {
+		return nil, err
+	}
+	return &CommandResult{Match: result}, nil
+}
+
+func (c CancelCommand) apply(e *Engine) (*CommandResult, error) {
+	order, err := e.CancelOrder(c.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	return &CommandResult{Orders: []*models.Order{order}}, nil
+}
+
+// Validate checks that exactly one change is requested.
+func (c AmendCommand) Validate() error {
+	if (c.ReduceBy == 0) == (c.NewQuantity == 0) {
+		return fmt.Errorf("invalid amendment: exactly one of reduce_by or new_quantity is required")
+	}
+	return nil
+}
+
+func (c AmendCommand) apply(e *Engine) (*CommandResult, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	var order *models.Order
+	var err error
+	if c.ReduceBy != 0 {
+		order, err = e.ReduceOrder(c.OrderID, c.ReduceBy)
+	} else {
+		order, err = e.ReduceOrderTo(c.OrderID, c.NewQuantity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &CommandResult{Orders: []*models.Order{order}}, nil
+}
+
+func (c MassCancelCommand) apply(e *Engine) (*CommandResult, error) {
+	e.mu.RLock()
+	books := make([]*OrderBook, 0, len(e.OrderBooks))
+	for symbol, ob := range e.OrderBooks {
+		if c.Symbol == "" || symbol == c.Symbol {
+			books = append(books, ob)
+		}
+	}
+	e.mu.RUnlock()
+	sort.Slice(books, func(i, j int) bool {
+		return books[i].Symbol < books[j].Symbol
+	})
+
+	cancelled := make([]*models.Order, 0)
+	for _, ob := range books {
+		cancelled = append(cancelled, e.massCancel(ob, c.Account, c.Reason)...)
+	}
+	return &CommandResult{Orders: cancelled}, nil
+}
+
+// massCancel cancels account's active orders in ob, or everyone's if account
+// is empty, in priority sequence order, giving them reason.
+func (e *Engine) massCancel(ob *OrderBook, account, reason string) []*models.Order {
+	ob.Lock()
+	defer ob.Unlock()
+
+	var resting, fixing []*models.Order
+	for _, order := range ob.Orders {
+		if account == "" || order.Account == account {
+			resting = append(resting, order)
+		}
+	}
+	for _, queue := range [][]*models.Order{ob.fixingBids, ob.fixingAsks, ob.stopBuys, ob.stopSells} {
+		for _, order := range queue {
+			if account == "" || order.Account == account {
+				fixing = append(fixing, order)
+			}
+		}
+	}
+
+	for _, order := range resting {
+		ob.RemoveOrder(order.ID)
+		e.metrics.DecOrdersInBook()
+		e.metrics.IncSymbolCancels(order.Symbol)
+	}
+	for _, order := range fixing {
+		if !ob.removeFixingOrder(order) {
+			ob.removeStop(order)
+		}
+	}
+
+	cancelled := append(resting, fixing...)
+	sort.Slice(cancelled, func(i, j int) bool {
+		return cancelled[i].Priority.Sequence < cancelled[j].Priority.Sequence
+	})
+	for _, order := range cancelled {
+		order.CancelReason = reason
+		order.SetStatus(models.Cancelled)
+		e.metrics.IncOrdersCancelled()
+		e.emitOrderCancelled(order, ob)
+	}
+	return cancelled
+}
@@ -0,0 +1,172 @@
+package matching
+
+import (
+	"fmt"
+	"math/rand"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// replayOutcome is everything a replay must reproduce exactly, per symbol.
+type replayOutcome struct {
+	Trades map[string][]scenarioTrade
+	Books  map[string]scenarioBook
+	Errors map[string][]string // per step, "" for success
+}
+
+// symbolRecorder captures trades per symbol. Listeners run under the symbol's
+// book lock, so each symbol's trades arrive in execution order.
+type symbolRecorder struct {
+	trades map[string][]scenarioTrade
+	mu     sync.Mutex
+}
+
+func (r *symbolRecorder) OrderAccepted(order *models.Order, top BookTop)  {}
+func (r *symbolRecorder) OrderCancelled(order *models.Order, top BookTop) {}
+
+func (r *symbolRecorder) TradeExecuted(trade *models.Trade, taker, maker *models.Order) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trades[trade.Symbol] = append(r.trades[trade.Symbol], scenarioTrade{
+		Buyer:     trade.BuyerOrderID,
+		Seller:    trade.SellerOrderID,
+		Aggressor: trade.AggressorSide,
+		Price:     trade.Price,
+		Quantity:  trade.Quantity,
+	})
+}
+
+// recordInput generates a reproducible stream of orders, cancels and reduces
+// across symbols.
+func recordInput(seed int64, symbols []string, n int) []scenarioStep {
+	r := rand.New(rand.NewSource(seed))
+	var steps []scenarioStep
+	placed := make(map[string][]string)
+	for i := 0; i < n; i++ {
+		symbol := symbols[r.Intn(len(symbols))]
+		ids := placed[symbol]
+		switch roll := r.Intn(10); {
+		case roll == 0 && len(ids) > 0:
+			steps = append(steps, scenarioStep{Action: "cancel", ID: ids[r.Intn(len(ids))], Symbol: symbol})
+		case roll == 1 && len(ids) > 0:
+			steps = append(steps, scenarioStep{Action: "reduce", ID: ids[r.Intn(len(ids))], Symbol: symbol, ReduceBy: 1})
+		default:
+			step := scenarioStep{
+				Action:   "order",
+				ID:       fmt.Sprintf("%s-%d", symbol, i),
+				Symbol:   symbol,
+				Side:     models.Side(r.Intn(2)),
+				Type:     models.Limit,
+				Price:    95 + r.Int63n(11),
+				Quantity: 1 + r.Int63n(20),
+			}
+			if r.Intn(15) == 0 {
+				step.Type, step.Price = models.Market, 0
+			}
+			steps = append(steps, step)
+			placed[symbol] = append(ids, step.ID)
+		}
+	}
+	return steps
+}
+
+// replay plays steps with each symbol's steps fed in order by its own
+// goroutine, as the per-symbol shards would see them, while readers query the
+// books concurrently. Only the interleaving between symbols varies.
+func replay(steps []scenarioStep, symbols []string) replayOutcome {
+	engine := NewEngine(metrics.NewMetrics())
+	recorder := &symbolRecorder{trades: make(map[string][]scenarioTrade)}
+	engine.AddEventListener(recorder)
+
+	shards := make(map[string][]scenarioStep)
+	for _, step := range steps {
+		shards[step.Symbol] = append(shards[step.Symbol], step)
+	}
+	errs := make(map[string][]string)
+	for _, symbol := range symbols {
+		errs[symbol] = make([]string, len(shards[symbol]))
+		engine.getOrderBook(symbol)
+	}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				symbol := symbols[n%len(symbols)]
+				engine.GetOrderBookDepth(symbol, 5)
+				engine.SimulateOrder(models.NewOrder("probe", symbol, models.Buy, models.Market, 0, 10))
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for _, symbol := range symbols {
+		writers.Add(1)
+		go func(symbol string) {
+			defer writers.Done()
+			for i, step := range shards[symbol] {
+				var err error
+				switch step.Action {
+				case "order":
+					var result *MatchResult
+					result, err = engine.ProcessOrder(models.NewOrder(step.ID, step.Symbol, step.Side, step.Type, step.Price, step.Quantity))
+					if err == nil {
+						result.Release()
+					}
+				case "cancel":
+					_, err = engine.CancelOrder(step.ID)
+				case "reduce":
+					_, err = engine.ReduceOrder(step.ID, step.ReduceBy)
+				}
+				if err != nil {
+					errs[symbol][i] = err.Error()
+				}
+			}
+		}(symbol)
+	}
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	out := replayOutcome{Trades: recorder.trades, Books: make(map[string]scenarioBook), Errors: errs}
+	for _, symbol := range symbols {
+		out.Books[symbol] = bookState(engine, symbol)
+	}
+	return out
+}
+
+// TestReplayDeterminism replays one recorded input under different
+// GOMAXPROCS settings and requires identical trades, errors and final books
+// every time. Replication depends on this.
+func TestReplayDeterminism(t *testing.T) {
+	symbols := []string{"BTCUSD", "ETHUSD", "SOLUSD", "XRPUSD"}
+	steps := recordInput(42, symbols, 4000)
+
+	prev := runtime.GOMAXPROCS(1)
+	defer runtime.GOMAXPROCS(prev)
+	want := replay(steps, symbols)
+	assert.NotEmpty(t, want.Trades["BTCUSD"], "input should produce trades")
+
+	for _, procs := range []int{1, 2, 4, runtime.NumCPU()} {
+		runtime.GOMAXPROCS(procs)
+		got := replay(steps, symbols)
+		for _, symbol := range symbols {
+			assert.Equal(t, want.Trades[symbol], got.Trades[symbol], "%s trades with GOMAXPROCS=%d", symbol, procs)
+			assert.Equal(t, want.Books[symbol], got.Books[symbol], "%s book with GOMAXPROCS=%d", symbol, procs)
+			assert.Equal(t, want.Errors[symbol], got.Errors[symbol], "%s errors with GOMAXPROCS=%d", symbol, procs)
+		}
+	}
+}
@@ -4,11 +4,10 @@ import (
 	"fmt"
 	"repello/internal/metrics"
 	"repello/internal/models"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/emirpasic/gods/trees/redblacktree"
-	"github.com/emirpasic/gods/utils"
 	"github.com/google/uuid"
 )
 
@@ -25,13 +24,84 @@ type PriceLevelData struct {
 }
 
 
-type PriceLevel []*models.Order
+// PriceLevel holds the orders resting at a single price, ordered by their
+// PriorityKey sequence, along with their aggregate remaining quantity so liquidity and depth queries
+// don't need to walk every order.
+type PriceLevel struct {
+	Price         int64
+	Orders        []*models.Order
+	TotalQuantity int64
+	// hidden is the part of TotalQuantity that icebergs don't show.
+	hidden int64
+}
+
+// Displayed is the level's quantity the book shows, leaving out what icebergs
+// hold back.
+func (pl *PriceLevel) Displayed() int64 {
+	return pl.TotalQuantity - pl.hidden
+}
+
+// hidden is the part of a resting order the book doesn't show.
+func hidden(order *models.Order) int64 {
+	return order.RemainingQuantity - order.Displayed()
+}
 
 type OrderBook struct {
 	Symbol string
-	Bids   *redblacktree.Tree // Price (int64) -> PriceLevel ([]*Order)
-	Asks   *redblacktree.Tree // Price (int64) -> PriceLevel ([]*Order)
+	Bids   BookSide // Best (highest) price first
+	Asks   BookSide // Best (lowest) price first
 	Orders map[string]*models.Order
+	ladder *LadderConfig // nil for tree-backed books
+	// expiring holds the resting orders that have an expiry time.
+	expiring map[string]*models.Order
+	// sweepLimit caps how far one aggressive order may match. Guarded by mu.
+	sweepLimit SweepLimit
+	// defaultTIF applies to limit orders without a time in force. Guarded by mu.
+	defaultTIF models.TimeInForce
+	// speedBump delays aggressive orders before they are sequenced. Guarded by mu.
+	speedBump time.Duration
+	// tickSize and lotSize, if set, divide every limit price and quantity.
+	// Guarded by mu.
+	tickSize int64
+	lotSize  int64
+	// orderTypes, if set, are the only order types accepted. Guarded by mu.
+	orderTypes []models.OrderType
+	// priceBandBps bounds limit prices around lastPrice, the price of the
+	// book's last trade. Guarded by mu.
+	priceBandBps int64
+	lastPrice    int64
+	// maxTriggeredStops caps the stops one incoming order activates; zero
+	// means DefaultMaxTriggeredStops. Guarded by mu.
+	maxTriggeredStops int
+	// sequence is the last priority sequence handed out. Sequences, not
+	// timestamps, decide time priority, so orders accepted in the same
+	// nanosecond still have a strict order. Guarded by mu.
+	sequence uint64
+	// lastTrade is the book's most recent trade, for snapshots. Guarded by mu.
+	lastTrade LastTrade
+	// pegged indexes the resting pegged orders by ID, so only they are
+	// looked at when the prices they peg to move. Guarded by mu.
+	pegged map[string]*models.Order
+	// linked pairs the IDs of one-cancels-other orders, both ways, and
+	// ocoFilled holds the partners of linked orders that have filled, until
+	// cancelLinked cancels them. Guarded by mu.
+	linked    map[string]string
+	ocoFilled []string
+	// stopBuys and stopSells hold StopMarket orders, in arrival order, until
+	// the last trade price triggers them. Guarded by mu.
+	stopBuys  []*models.Order
+	stopSells []*models.Order
+	// fixingBids and fixingAsks queue AtFixing orders, in arrival order,
+	// until PublishFixing crosses them. Guarded by mu.
+	fixingBids []*models.Order
+	fixingAsks []*models.Order
+	// lastTop is the book's top, and pegInputs the prices its pegged orders
+	// were last priced from, as of its last write unlock. Guarded by mu.
+	lastTop   BookTop
+	pegInputs pegInputs
+	// settle, set by the engine that owns the book, runs at every write
+	// unlock, still locked, to bring pegs and listeners up to date.
+	settle func(*OrderBook)
 	mu     sync.RWMutex
 }
 
@@ -39,42 +109,116 @@ func NewOrderBook(symbol string) *OrderBook {
 	return &OrderBook{
 		Symbol: symbol,
 		// Bids are sorted in descending order (highest price first)
-		Bids: redblacktree.NewWith(func(a, b interface{}) int {
-			return utils.Int64Comparator(b, a)
-		}),
+		Bids: newTreeSide(true),
 		// Asks are sorted in ascending order (lowest price first)
-		Asks:   redblacktree.NewWith(utils.Int64Comparator),
-		Orders: make(map[string]*models.Order),
+		Asks:     newTreeSide(false),
+		Orders:   make(map[string]*models.Order),
+		expiring: make(map[string]*models.Order),
+	}
+}
+
+// NewLadderOrderBook creates a book backed by fixed price ladders instead of
+// trees. It suits symbols whose prices stay within a known, dense tick range.
+func NewLadderOrderBook(symbol string, cfg LadderConfig) (*OrderBook, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &OrderBook{
+		Symbol:   symbol,
+		Bids:     newLadderSide(cfg, true),
+		Asks:     newLadderSide(cfg, false),
+		Orders:   make(map[string]*models.Order),
+		expiring: make(map[string]*models.Order),
+		ladder:   &cfg,
+	}, nil
+}
+
+// CheckPrice rejects limit prices the book cannot hold.
+func (ob *OrderBook) CheckPrice(price int64) error {
+	if ob.ladder == nil {
+		return nil
 	}
+	return ob.ladder.checkPrice(price)
 }
 
+func (ob *OrderBook) side(side models.Side) BookSide {
+	if side == models.Buy {
+		return ob.Bids
+	}
+	return ob.Asks
+}
+
+// oppositeSide returns the side an incoming order on side trades against.
+func (ob *OrderBook) oppositeSide(side models.Side) BookSide {
+	if side == models.Buy {
+		return ob.Asks
+	}
+	return ob.Bids
+}
+
+// assignPriority gives order the next place in the book's time priority.
+func (ob *OrderBook) assignPriority(order *models.Order) {
+	ob.sequence++
+	order.Priority = models.PriorityKey{Price: order.Price, Hidden: order.Hidden, Sequence: ob.sequence}
+}
+
+// AddOrder rests order at its PriorityKey, assigning one if it has none.
 func (ob *OrderBook) AddOrder(order *models.Order) {
 	if _, exists := ob.Orders[order.ID]; exists {
 		return
 	}
+	if order.Priority.Sequence == 0 {
+		ob.assignPriority(order)
+	}
+	if order.DisplayQuantity > 0 && order.VisibleQuantity == 0 {
+		order.VisibleQuantity = min(order.DisplayQuantity, order.RemainingQuantity)
+	}
 	ob.Orders[order.ID] = order
-
-	var tree *redblacktree.Tree
-	if order.Side == models.Buy {
-		tree = ob.Bids
-	} else {
-		tree = ob.Asks
+	if order.ExpireAt != 0 {
+		ob.expiring[order.ID] = order
+	}
+	if order.PegType != models.PegNone {
+		if ob.pegged == nil {
+			ob.pegged = make(map[string]*models.Order)
+		}
+		ob.pegged[order.ID] = order
 	}
 
-	price := order.Price
-	level, found := tree.Get(price)
+	bookSide := ob.side(order.Side)
+	price := order.Priority.Price
+	level, found := bookSide.Get(price)
 
 	if !found {
-		newLevel := make(PriceLevel, 0, 1)
-		newLevel = append(newLevel, order)
-		tree.Put(price, newLevel)
+		newLevel := &PriceLevel{
+			Price:  price,
+			Orders: make([]*models.Order, 0, 1),
+		}
+		newLevel.Orders = append(newLevel.Orders, order)
+		newLevel.TotalQuantity = order.RemainingQuantity
+		newLevel.hidden = hidden(order)
+		bookSide.Put(newLevel)
 	} else {
-		existingLevel := level.(PriceLevel)
-		existingLevel = append(existingLevel, order)
-		tree.Put(price, existingLevel)
+		// Almost always the newest order, so this is normally an append
+		i := level.queueIndex(order.Priority)
+		level.Orders = append(level.Orders, nil)
+		copy(level.Orders[i+1:], level.Orders[i:])
+		level.Orders[i] = order
+		level.TotalQuantity += order.RemainingQuantity
+		level.hidden += hidden(order)
 	}
 }
 
+// queueIndex returns where an order with key belongs in the level's queue.
+func (pl *PriceLevel) queueIndex(key models.PriorityKey) int {
+	return sort.Search(len(pl.Orders), func(i int) bool {
+		p := pl.Orders[i].Priority
+		if p.Hidden != key.Hidden {
+			return p.Hidden
+		}
+		return p.Sequence >= key.Sequence
+	})
+}
+
 func (ob *OrderBook) RemoveOrder(orderID string) *models.Order {
 	order, exists := ob.Orders[orderID]
 	if !exists {
@@ -82,44 +226,86 @@ func (ob *OrderBook) RemoveOrder(orderID string) *models.Order {
 	}
 
 	delete(ob.Orders, orderID)
+	delete(ob.expiring, orderID)
+	delete(ob.pegged, orderID)
 
-	var tree *redblacktree.Tree
-	if order.Side == models.Buy {
-		tree = ob.Bids
-	} else {
-		tree = ob.Asks
-	}
-
-	price := order.Price
-	level, found := tree.Get(price)
+	bookSide := ob.side(order.Side)
+	price := order.Priority.Price
+	priceLevel, found := bookSide.Get(price)
 	if !found {
 		return order // Should not happen in a consistent state
 	}
 
-	priceLevel := level.(PriceLevel)
-	for i, o := range priceLevel {
-		if o.ID == orderID {
-			// Remove the order
-			priceLevel = append(priceLevel[:i], priceLevel[i+1:]...)
-			break
-		}
+	if i := priceLevel.queueIndex(order.Priority); i < len(priceLevel.Orders) && priceLevel.Orders[i] == order {
+		priceLevel.Orders = append(priceLevel.Orders[:i], priceLevel.Orders[i+1:]...)
+		priceLevel.TotalQuantity -= order.RemainingQuantity
+		priceLevel.hidden -= hidden(order)
 	}
 
-	if len(priceLevel) == 0 {
-		tree.Remove(price)
-	} else {
-		tree.Put(price, priceLevel)
+	if len(priceLevel.Orders) == 0 {
+		bookSide.Remove(price)
 	}
 
 	return order
 }
 
+// FillOrder reduces a resting order by quantity, executed at time at (UnixNano),
+// keeping its price level's aggregate in step. Fully filled orders are removed
+// from the book, and icebergs whose visible slice has filled are replenished.
+// quantity must not exceed what the order shows.
+func (ob *OrderBook) FillOrder(order *models.Order, quantity, at int64) {
+	hiddenBefore := hidden(order)
+	order.Fill(quantity, at)
+	if order.DisplayQuantity > 0 {
+		order.VisibleQuantity -= quantity
+	}
+	if level, found := ob.side(order.Side).Get(order.Priority.Price); found {
+		level.TotalQuantity -= quantity
+		level.hidden += hidden(order) - hiddenBefore
+	}
+
+	if order.RemainingQuantity == 0 {
+		ob.RemoveOrder(order.ID)
+	} else if order.DisplayQuantity > 0 && order.VisibleQuantity == 0 {
+		ob.replenish(order)
+	}
+}
+
+// replenish shows the next slice of an iceberg whose visible slice has
+// filled. The new slice joins the back of the price level's queue, as a new
+// order would.
+func (ob *OrderBook) replenish(order *models.Order) {
+	ob.RemoveOrder(order.ID)
+	order.VisibleQuantity = min(order.DisplayQuantity, order.RemainingQuantity)
+	ob.assignPriority(order)
+	ob.AddOrder(order)
+}
+
+// ReduceOrder shrinks a resting order's quantity by quantity in place, so it
+// keeps its position in the price level's queue. An iceberg loses hidden
+// quantity first.
+func (ob *OrderBook) ReduceOrder(order *models.Order, quantity int64) {
+	hiddenBefore := hidden(order)
+	order.RemainingQuantity -= quantity
+	order.OriginalQuantity -= quantity
+	if order.VisibleQuantity > order.RemainingQuantity {
+		order.VisibleQuantity = order.RemainingQuantity
+	}
+	if level, found := ob.side(order.Side).Get(order.Priority.Price); found {
+		level.TotalQuantity -= quantity
+		level.hidden += hidden(order) - hiddenBefore
+	}
+}
+
 // Locking the order book
 func (ob *OrderBook) Lock() {
 	ob.mu.Lock()
 }
 
 func (ob *OrderBook) Unlock() {
+	if ob.settle != nil {
+		ob.settle(ob)
+	}
 	ob.mu.Unlock()
 }
 
@@ -133,70 +319,63 @@ func (ob *OrderBook) RUnlock() {
 }
 
 func (ob *OrderBook) GetBestBid() *models.Order {
-	if ob.Bids.Empty() {
+	priceLevel := ob.Bids.Best()
+	if priceLevel == nil || len(priceLevel.Orders) == 0 {
 		return nil
 	}
-	node := ob.Bids.Left() // For descending bids, left is the max
-	if node == nil {
-		return nil
-	}
-	priceLevel := node.Value.(PriceLevel)
-	if len(priceLevel) == 0 {
-		return nil
-	}
-	return priceLevel[0]
+	return priceLevel.Orders[0]
 }
 
 func (ob *OrderBook) GetBestAsk() *models.Order {
-	if ob.Asks.Empty() {
-		return nil
-	}
-	node := ob.Asks.Left() // For ascending asks, left is the min
-	if node == nil {
-		return nil
-	}
-	priceLevel := node.Value.(PriceLevel)
-	if len(priceLevel) == 0 {
+	priceLevel := ob.Asks.Best()
+	if priceLevel == nil || len(priceLevel.Orders) == 0 {
 		return nil
 	}
-	return priceLevel[0]
+	return priceLevel.Orders[0]
 }
 
 
 func (ob *OrderBook) CalculateLiquidity(side models.Side, maxNeeded int64) int64 {
-	var tree *redblacktree.Tree
 	// If incoming order is Buy, it consumes Asks.
 	// If incoming order is Sell, it consumes Bids.
-	if side == models.Buy {
-		tree = ob.Asks
-	} else {
-		tree = ob.Bids
-	}
-
-	if tree.Empty() {
-		return 0
-	}
+	var available int64 = 0
+	ob.oppositeSide(side).Walk(func(priceLevel *PriceLevel) bool {
+		available += priceLevel.TotalQuantity
+		return available < maxNeeded
+	})
+	return available
+}
 
-	it := tree.Iterator()
-	it.Begin()
+// CalculateLiquidityWithinPrice is like CalculateLiquidity but only counts levels
+// an incoming order on side could trade against at limitPrice or better. Levels
+// are sorted best-first, so the walk stops at the first unacceptable price or as
+// soon as maxNeeded is reached.
+func (ob *OrderBook) CalculateLiquidityWithinPrice(side models.Side, limitPrice, maxNeeded int64) int64 {
 	var available int64 = 0
-	for it.Next() {
-		priceLevel := it.Value().(PriceLevel)
-		for _, order := range priceLevel {
-			available += order.RemainingQuantity
-			if available >= maxNeeded {
-				return available
-			}
+	ob.oppositeSide(side).Walk(func(priceLevel *PriceLevel) bool {
+		if side == models.Buy && priceLevel.Price > limitPrice {
+			return false
 		}
-	}
+		if side == models.Sell && priceLevel.Price < limitPrice {
+			return false
+		}
+		available += priceLevel.TotalQuantity
+		return available < maxNeeded
+	})
 	return available
 }
 
-// returns the aggregated depth of the order book.
+// returns the aggregated depth of the order book. Only the quantity the book
+// shows is included: icebergs count for their visible slices, and levels
+// holding only hidden orders are left out.
 func (ob *OrderBook) GetDepth(depthLimit int) *OrderBookDepth {
 	ob.RLock()
 	defer ob.RUnlock()
+	return ob.depth(depthLimit)
+}
 
+// depth is GetDepth for callers already holding the book lock.
+func (ob *OrderBook) depth(depthLimit int) *OrderBookDepth {
 	depth := &OrderBookDepth{
 		Symbol:    ob.Symbol,
 		Timestamp: time.Now().UnixNano() / int64(time.Millisecond), // ms timestamp
@@ -205,40 +384,28 @@ func (ob *OrderBook) GetDepth(depthLimit int) *OrderBookDepth {
 	}
 
 	// Bids
-	itBids := ob.Bids.Iterator()
-	itBids.Begin()
-	count := 0
-	for itBids.Next() {
-		if depthLimit > 0 && count >= depthLimit {
-			break
+	ob.Bids.Walk(func(priceLevel *PriceLevel) bool {
+		if depthLimit > 0 && len(depth.Bids) >= depthLimit {
+			return false
 		}
-		price := itBids.Key().(int64)
-		priceLevel := itBids.Value().(PriceLevel)
-		var totalQuantity int64
-		for _, order := range priceLevel {
-			totalQuantity += order.RemainingQuantity
+		if priceLevel.Displayed() == 0 {
+			return true
 		}
-		depth.Bids = append(depth.Bids, PriceLevelData{Price: price, Quantity: totalQuantity})
-		count++
-	}
+		depth.Bids = append(depth.Bids, PriceLevelData{Price: priceLevel.Price, Quantity: priceLevel.Displayed()})
+		return true
+	})
 
 	// Asks
-	itAsks := ob.Asks.Iterator()
-	itAsks.Begin()
-	count = 0
-	for itAsks.Next() {
-		if depthLimit > 0 && count >= depthLimit {
-			break
+	ob.Asks.Walk(func(priceLevel *PriceLevel) bool {
+		if depthLimit > 0 && len(depth.Asks) >= depthLimit {
+			return false
 		}
-		price := itAsks.Key().(int64)
-		priceLevel := itAsks.Value().(PriceLevel)
-		var totalQuantity int64
-		for _, order := range priceLevel {
-			totalQuantity += order.RemainingQuantity
+		if priceLevel.Displayed() == 0 {
+			return true
 		}
-		depth.Asks = append(depth.Asks, PriceLevelData{Price: price, Quantity: totalQuantity})
-		count++
-	}
+		depth.Asks = append(depth.Asks, PriceLevelData{Price: priceLevel.Price, Quantity: priceLevel.Displayed()})
+		return true
+	})
 
 	return depth
 }
@@ -247,23 +414,117 @@ func (ob *OrderBook) GetDepth(depthLimit int) *OrderBookDepth {
 type MatchResult struct {
 	Order  *models.Order
 	Trades []*models.Trade
+	// SweepCapped is set when matching stopped at the symbol's sweep limit and
+	// the order's remainder was cancelled.
+	SweepCapped bool
+	// SkippedQuantity is the resting quantity a no-cross firm's order passed
+	// over because it was the firm's own. If it is set, the order's remainder
+	// was cancelled rather than rest crossing those orders.
+	SkippedQuantity int64
+	levels          int             // distinct price levels traded against
+	skippedLevels   []int64         // prices of the levels passed over
+	spare           []*models.Trade // Trade structs kept across reuses of this result
+}
+
+// resultPool recycles MatchResults and their trades so steady-state matching
+// doesn't allocate a fresh slice and Trade per fill.
+var resultPool = sync.Pool{
+	New: func() any { return &MatchResult{} },
+}
+
+func newMatchResult(order *models.Order) *MatchResult {
+	result := resultPool.Get().(*MatchResult)
+	result.Order = order
+	return result
+}
+
+// nextTrade appends a trade to the result, reusing a Trade from an earlier
+// cycle when one is available.
+func (r *MatchResult) nextTrade() *models.Trade {
+	n := len(r.Trades)
+	if n == len(r.spare) {
+		r.spare = append(r.spare, &models.Trade{})
+	}
+	trade := r.spare[n]
+	r.Trades = append(r.Trades, trade)
+	return trade
+}
+
+// Release returns the result to the engine for reuse. Callers that are done
+// with a result may release it; the result and its trades must not be used
+// afterwards.
+func (r *MatchResult) Release() {
+	r.Order = nil
+	r.Trades = r.Trades[:0]
+	r.SweepCapped = false
+	r.SkippedQuantity = 0
+	r.levels = 0
+	r.skippedLevels = r.skippedLevels[:0]
+	resultPool.Put(r)
 }
 
 
 type Engine struct {
 	OrderBooks map[string]*OrderBook
 	AllOrders  sync.Map // Map[string]*models.Order - Stores all orders for quick lookup
+	ladders    map[string]LadderConfig
+	listeners  []EventListener
+	topWatch   []TopListener   // the listeners that also implement TopListener
+	amendWatch []AmendListener // and AmendListener
+	checks     []PreTradeCheck
+	shadow     []shadowCheck
+	firms      FirmDirectory
+	stages     []TradeStage
 	mu         sync.RWMutex
 	metrics    *metrics.Metrics
+
+	// symbolConfigs are the overrides from ConfigureSymbols. Guarded by mu.
+	symbolConfigs map[string]SymbolConfig
+	// session is when DAY orders are cancelled. Guarded by mu.
+	session SessionClose
 }
 
 func NewEngine(m *metrics.Metrics) *Engine {
 	return &Engine{
 		OrderBooks: make(map[string]*OrderBook),
+		ladders:    make(map[string]LadderConfig),
 		metrics:    m,
 	}
 }
 
+// ConfigureLadder makes symbol use a price-ladder book instead of the default
+// tree. It must be called before the symbol's book is first used.
+func (e *Engine) ConfigureLadder(symbol string, cfg LadderConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, exists := e.OrderBooks[symbol]; exists {
+		return fmt.Errorf("order book for %s already exists", symbol)
+	}
+	e.ladders[symbol] = cfg
+	return nil
+}
+
+// Reset drops every order book and order, along with per-book settings such as
+// sweep limits; ladder configuration is kept. It is meant for sandboxes, where
+// losing an order placed concurrently with the reset is acceptable.
+func (e *Engine) Reset() {
+	e.mu.Lock()
+	books := e.OrderBooks
+	e.OrderBooks = make(map[string]*OrderBook)
+	e.mu.Unlock()
+
+	for _, ob := range books {
+		ob.Lock()
+		e.metrics.OrdersInBook.Add(-int64(len(ob.Orders)))
+		ob.Unlock()
+	}
+	e.AllOrders.Clear()
+}
+
 func (e *Engine) getOrderBook(symbol string) *OrderBook {
 	e.mu.RLock()
 	ob, exists := e.OrderBooks[symbol]
@@ -273,7 +534,12 @@ func (e *Engine) getOrderBook(symbol string) *OrderBook {
 		e.mu.Lock()
 		ob, exists = e.OrderBooks[symbol]
 		if !exists {
-			ob = NewOrderBook(symbol)
+			if cfg, ok := e.ladders[symbol]; ok {
+				ob, _ = NewLadderOrderBook(symbol, cfg) // cfg was validated by ConfigureLadder
+			} else {
+				ob = NewOrderBook(symbol)
+			}
+			ob.settle = e.settleBook
 			e.OrderBooks[symbol] = ob
 		}
 		e.mu.Unlock()
@@ -281,44 +547,90 @@ func (e *Engine) getOrderBook(symbol string) *OrderBook {
 	return ob
 }
 
-func (e *Engine) ProcessOrder(order *models.Order) (*MatchResult, error) {
+// ProcessOrder validates order and matches it against its book, resting any
+// remainder its time in force allows. An order it refuses is left with status
+// Rejected.
+func (e *Engine) ProcessOrder(order *models.Order) (result *MatchResult, err error) {
+	// Served before the order counts as received, so it isn't matching latency
+	e.applySpeedBump(order)
+
 	startTime := time.Now()
 	defer func() {
 		latency := time.Since(startTime).Microseconds()
 		e.metrics.AddLatency(latency)
+		e.metrics.AddSymbolLatency(order.Symbol, latency)
+		if err != nil {
+			order.SetStatus(models.Rejected)
+		}
 	}()
 
 	e.metrics.IncOrdersReceived()
 
+	if order.ID == "" {
+		return nil, fmt.Errorf("invalid order id: required")
+	}
 	if err := order.Validate(); err != nil {
 		return nil, err
 	}
 
-	e.AllOrders.Store(order.ID, order)
-
 	ob := e.getOrderBook(order.Symbol)
+	if order.Type == models.Limit {
+		if err := ob.CheckPrice(order.Price); err != nil {
+			return nil, err
+		}
+	}
+
+	// Gateways may take IDs from clients, so they must be checked for reuse
+	if _, exists := e.AllOrders.LoadOrStore(order.ID, order); exists {
+		return nil, fmt.Errorf("duplicate order id: %s", order.ID)
+	}
+
 	ob.Lock()
 	defer ob.Unlock()
+	return e.enterOrder(order, ob, startTime)
+}
 
-	// check liquidity for Market Orders
-	if order.Type == models.Market {
-		available := ob.CalculateLiquidity(order.Side, order.OriginalQuantity)
-		if available < order.OriginalQuantity {
-			// reject the order
-			e.AllOrders.Delete(order.ID)
-			return nil, fmt.Errorf("insufficient liquidity: only %d shares available, requested %d", available, order.OriginalQuantity)
-		}
+// enterOrder is ProcessOrder once order has been validated and registered in
+// AllOrders. It must be called with the book locked.
+func (e *Engine) enterOrder(order *models.Order, ob *OrderBook, startTime time.Time) (*MatchResult, error) {
+	if err := ob.checkSymbolRules(order); err != nil {
+		e.AllOrders.Delete(order.ID)
+		return nil, err
+	}
+
+	if order.Type == models.AtFixing {
+		return e.queueForFixing(order, ob)
+	}
+	if order.Type == models.StopMarket {
+		return e.queueStop(order, ob)
 	}
 
-	trades := make([]*models.Trade, 0)
+	noCross, err := e.checkEntry(order, ob, startTime)
+	if err != nil {
+		e.AllOrders.Delete(order.ID)
+		return nil, err
+	}
 
-	if order.Type == models.Limit {
-		trades = e.processLimitOrder(order, ob)
+	if err := e.runPreTradeChecks(order, ob); err != nil {
+		e.AllOrders.Delete(order.ID)
+		return nil, err
+	}
+
+	order.AcceptedAt = time.Now().UnixNano()
+	ob.assignPriority(order)
+	order.Status = models.Accepted
+	e.emitOrderAccepted(order, ob)
+	result := newMatchResult(order)
+
+	if noCross != "" {
+		e.matchNoCross(order, ob, result, noCross)
+	} else if order.Type == models.Limit {
+		e.processLimitOrder(order, ob, result)
 	} else if order.Type == models.Market {
-		trades = e.processMarketOrder(order, ob)
+		e.processMarketOrder(order, ob, result)
 	}
 
-	tradeCount := int64(len(trades))
+	tradeCount := int64(len(result.Trades))
 	e.metrics.IncTradesExecuted(tradeCount)
 	if tradeCount > 0 {
 		e.metrics.IncOrdersMatched(tradeCount + 1)
@@ -326,7 +638,7 @@ func (e *Engine) ProcessOrder(order *models.Order) (*MatchResult, error) {
 
 	if order.FilledQuantity > 0 {
 		if order.RemainingQuantity == 0 {
-			order.Status = models.Filled
+			order.SetStatus(models.Filled)
 		} else {
 			order.Status = models.PartialFill
 		}
@@ -335,33 +647,107 @@ func (e *Engine) ProcessOrder(order *models.Order) (*MatchResult, error) {
 	}
 
 	if order.RemainingQuantity > 0 {
-		if order.Type == models.Market {
-			// theoretically unreachable if liquidity check passed and we hold the lock
-			// but for safety: maybe log a warning?
+		if len(result.skippedLevels) > 0 {
+			order.CancelReason = NoCrossCancelReason
+		}
+		if result.SweepCapped || !order.TimeInForce.Rests() || len(result.skippedLevels) > 0 {
+			order.SetStatus(models.Cancelled)
+			e.metrics.IncOrdersCancelled()
+			e.emitOrderCancelled(order, ob)
 		} else {
 			ob.AddOrder(order)
 			e.metrics.IncOrdersInBook()
+			e.metrics.IncSymbolAdds(order.Symbol)
 		}
 	} else {
-		order.Status = models.Filled
+		order.SetStatus(models.Filled)
 	}
 
-	return &MatchResult{
-		Order:  order,
-		Trades: trades,
-	}, nil
+	if tradeCount > 0 {
+		e.cancelLinked(ob)
+		e.triggerStops(ob)
+	}
+	return result, nil
+}
+
+// checkEntry runs the engine's own checks on order against ob, whose lock
+// must be held, and prepares it to match: it resolves its time in force and
+// any market-to-limit, peg or post-only price. It returns the firm whose
+// liquidity order must skip, or "".
+func (e *Engine) checkEntry(order *models.Order, ob *OrderBook, startTime time.Time) (string, error) {
+	if order.Type == models.MarketToLimit {
+		if err := ob.convertToLimit(order); err != nil {
+			return "", err
+		}
+	}
+
+	if err := ob.resolveTimeInForce(order, startTime, e.sessionClose()); err != nil {
+		return "", err
+	}
+
+	if order.PegType != models.PegNone {
+		order.PegLimit = order.Price
+		price, err := ob.pegPrice(order)
+		if err != nil {
+			return "", err
+		}
+		order.Price = price
+	}
+
+	if order.PostOnly {
+		price, err := ob.postOnlyPrice(order)
+		if err != nil {
+			return "", err
+		}
+		order.Price = price
+	}
+
+	// check liquidity for Market Orders
+	if order.Type == models.Market {
+		available := ob.CalculateLiquidity(order.Side, order.OriginalQuantity)
+		if available < order.OriginalQuantity {
+			return "", fmt.Errorf("insufficient liquidity: only %d shares available, requested %d", available, order.OriginalQuantity)
+		}
+	}
+
+	if order.TimeInForce == models.FOK {
+		if err := ob.checkFillOrKill(order); err != nil {
+			return "", err
+		}
+	}
+
+	// A no-cross firm's orders can't count on its own resting liquidity
+	noCross := e.noCrossFirm(order)
+	if noCross != "" && (order.Type == models.Market || order.TimeInForce == models.FOK) {
+		if err := e.checkExternalLiquidity(order, ob, noCross); err != nil {
+			return "", err
+		}
+	}
+
+	if err := e.checkMinQuantity(order, ob, noCross); err != nil {
+		return "", err
+	}
+
+	if ob.sweepLimit.enabled() && ob.sweepLimit.Action == SweepReject {
+		if err := ob.checkSweep(order); err != nil {
+			return "", err
+		}
+	}
+	return noCross, nil
 }
 
-func (e *Engine) processLimitOrder(order *models.Order, ob *OrderBook) []*models.Trade {
-	trades := make([]*models.Trade, 0)
+func (e *Engine) processLimitOrder(order *models.Order, ob *OrderBook, result *MatchResult) {
 	if order.Side == models.Buy {
 		for order.RemainingQuantity > 0 && !ob.Asks.Empty() {
 			bestAsk := ob.GetBestAsk()
 			if order.Price < bestAsk.Price {
 				break
 			}
-			trade := e.executeTrade(order, bestAsk, ob)
-			trades = append(trades, trade)
+			if !ob.sweepLimit.allows(result, bestAsk.Price) {
+				result.SweepCapped = true
+				break
+			}
+			e.executeTrade(order, bestAsk, ob, result)
 		}
 	} else { // Sell side
 		for order.RemainingQuantity > 0 && !ob.Bids.Empty() {
@@ -369,62 +755,97 @@ func (e *Engine) processLimitOrder(order *models.Order, ob *OrderBook) []*models
 			if order.Price > bestBid.Price {
 				break
 			}
-			trade := e.executeTrade(order, bestBid, ob)
-			trades = append(trades, trade)
+			if !ob.sweepLimit.allows(result, bestBid.Price) {
+				result.SweepCapped = true
+				break
+			}
+			e.executeTrade(order, bestBid, ob, result)
 		}
 	}
-	return trades
 }
 
-func (e *Engine) processMarketOrder(order *models.Order, ob *OrderBook) []*models.Trade {
-	trades := make([]*models.Trade, 0)
+func (e *Engine) processMarketOrder(order *models.Order, ob *OrderBook, result *MatchResult) {
 	if order.Side == models.Buy {
 		for order.RemainingQuantity > 0 && !ob.Asks.Empty() {
 			bestAsk := ob.GetBestAsk()
-			trade := e.executeTrade(order, bestAsk, ob)
-			trades = append(trades, trade)
+			if !ob.sweepLimit.allows(result, bestAsk.Price) {
+				result.SweepCapped = true
+				break
+			}
+			e.executeTrade(order, bestAsk, ob, result)
 		}
 	} else { // Sell side
 		for order.RemainingQuantity > 0 && !ob.Bids.Empty() {
 			bestBid := ob.GetBestBid()
-			trade := e.executeTrade(order, bestBid, ob)
-			trades = append(trades, trade)
+			if !ob.sweepLimit.allows(result, bestBid.Price) {
+				result.SweepCapped = true
+				break
+			}
+			e.executeTrade(order, bestBid, ob, result)
 		}
 	}
-	return trades
 }
 
-func (e *Engine) executeTrade(incomingOrder, bookOrder *models.Order, ob *OrderBook) *models.Trade {
+func (e *Engine) executeTrade(incomingOrder, bookOrder *models.Order, ob *OrderBook, result *MatchResult) *models.Trade {
+	// An iceberg trades no more than its visible slice at a time
 	tradeQuantity := incomingOrder.RemainingQuantity
 	if bookOrder.RemainingQuantity < tradeQuantity {
 		tradeQuantity = bookOrder.RemainingQuantity
 	}
+	if bookOrder.DisplayQuantity > 0 && bookOrder.VisibleQuantity < tradeQuantity {
+		tradeQuantity = bookOrder.VisibleQuantity
+	}
 
 	tradePrice := bookOrder.Price
+	if n := len(result.Trades); n == 0 || result.Trades[n-1].Price != tradePrice {
+		result.levels++
+	}
+
+	trade := result.nextTrade()
+	*trade = models.Trade{
+		ID:            uuid.New().String(),
+		Symbol:        incomingOrder.Symbol,
+		BuyerOrderID:  getBuyerOrderID(incomingOrder, bookOrder),
+		SellerOrderID: getSellerOrderID(incomingOrder, bookOrder),
+		MakerOrderID:  bookOrder.ID,
+		TakerOrderID:  incomingOrder.ID,
+		AggressorSide: incomingOrder.Side,
+		Price:         tradePrice,
+		Quantity:      tradeQuantity,
+		Timestamp:     time.Now().UnixNano(),
+	}
 
-	trade := models.NewTrade(
-		uuid.New().String(),
-		getBuyerOrderID(incomingOrder, bookOrder),
-		getSellerOrderID(incomingOrder, bookOrder),
-		tradePrice,
-		tradeQuantity,
-	)
+	ob.lastPrice = tradePrice
+	ob.lastTrade = newLastTrade(trade)
 
 	// Update Incoming Order
-	incomingOrder.RemainingQuantity -= tradeQuantity
-	incomingOrder.FilledQuantity += tradeQuantity
+	incomingOrder.Fill(tradeQuantity, trade.Timestamp)
 
 	// Update Book Order
-	bookOrder.RemainingQuantity -= tradeQuantity
-	bookOrder.FilledQuantity += tradeQuantity
+	ob.FillOrder(bookOrder, tradeQuantity, trade.Timestamp)
+	for _, order := range []*models.Order{incomingOrder, bookOrder} {
+		if order.RemainingQuantity == 0 {
+			ob.noteFilled(order)
+		}
+	}
+	e.metrics.AddSymbolExecution(trade.Symbol, tradeQuantity, tradePrice)
 
 	if bookOrder.RemainingQuantity == 0 {
-		bookOrder.Status = models.Filled
-		ob.RemoveOrder(bookOrder.ID)
+		bookOrder.SetStatus(models.Filled)
 		e.metrics.DecOrdersInBook()
 	} else {
 		bookOrder.Status = models.PartialFill
 	}
+	// The taker was reported accepted before it matched, so its fills carry
+	// its status as it goes
+	if incomingOrder.RemainingQuantity == 0 {
+		incomingOrder.SetStatus(models.Filled)
+	} else {
+		incomingOrder.Status = models.PartialFill
+	}
+
+	e.enrichTrade(trade, incomingOrder, bookOrder)
+	e.emitTradeExecuted(trade, incomingOrder, bookOrder)
 
 	return trade
 }
@@ -450,33 +871,84 @@ func (e *Engine) CancelOrder(orderID string) (*models.Order, error) {
 	}
 	order := val.(*models.Order)
 
-	if order.Status == models.Filled {
-		return nil, fmt.Errorf("cannot cancel: order already filled")
-	}
-	if order.Status == models.Cancelled {
-		return order, nil
-	}
-
 	ob := e.getOrderBook(order.Symbol)
 	ob.Lock()
 	defer ob.Unlock()
 
-	// Double check status under lock to prevent race
+	// Status is only stable under the book lock; a concurrent match may be filling this order.
 	if order.Status == models.Filled {
 		return nil, fmt.Errorf("cannot cancel: order already filled")
 	}
+	if order.Status == models.Cancelled || order.Status == models.Expired {
+		return order, nil
+	}
+
+	e.cancelLocked(order, ob)
+	return order, nil
+}
 
-	removedOrder := ob.RemoveOrder(orderID)
-	if removedOrder != nil {
-		removedOrder.Status = models.Cancelled
+// cancelLocked takes an active order out of the book, or the stop or fixing
+// queue holding it, and marks it cancelled. It must be called with the book
+// locked.
+func (e *Engine) cancelLocked(order *models.Order, ob *OrderBook) {
+	if ob.RemoveOrder(order.ID) != nil {
+		order.SetStatus(models.Cancelled)
 		e.metrics.IncOrdersCancelled()
 		e.metrics.DecOrdersInBook()
-		return removedOrder, nil
+		e.metrics.IncSymbolCancels(order.Symbol)
 	} else {
-		order.Status = models.Cancelled
+		if !ob.removeFixingOrder(order) {
+			ob.removeStop(order)
+		}
+		order.SetStatus(models.Cancelled)
 		e.metrics.IncOrdersCancelled()
-		return order, nil
 	}
+	e.emitOrderCancelled(order, ob)
+}
+
+// ReduceOrder shrinks a resting order's remaining quantity by reduceBy without
+// losing its time priority.
+func (e *Engine) ReduceOrder(orderID string, reduceBy int64) (*models.Order, error) {
+	return e.reduceOrder(orderID, func(order *models.Order) int64 {
+		return reduceBy
+	})
+}
+
+// ReduceOrderTo shrinks a resting order so its total quantity (filled plus
+// remaining) becomes newQuantity, without losing its time priority.
+func (e *Engine) ReduceOrderTo(orderID string, newQuantity int64) (*models.Order, error) {
+	return e.reduceOrder(orderID, func(order *models.Order) int64 {
+		return order.OriginalQuantity - newQuantity
+	})
+}
+
+// reduceOrder applies the reduction computed by amount, which runs under the
+// book lock so it sees the order's current quantities.
+func (e *Engine) reduceOrder(orderID string, amount func(order *models.Order) int64) (*models.Order, error) {
+	val, ok := e.AllOrders.Load(orderID)
+	if !ok {
+		return nil, fmt.Errorf("order not found")
+	}
+	order := val.(*models.Order)
+
+	ob := e.getOrderBook(order.Symbol)
+	ob.Lock()
+	defer ob.Unlock()
+
+	if _, resting := ob.Orders[orderID]; !resting {
+		return nil, fmt.Errorf("cannot reduce: order is not resting in the book")
+	}
+
+	reduceBy := amount(order)
+	if reduceBy <= 0 {
+		return nil, fmt.Errorf("invalid quantity: can only reduce an order")
+	}
+	if reduceBy >= order.RemainingQuantity {
+		return nil, fmt.Errorf("invalid quantity: reduction must leave some quantity open, cancel the order instead")
+	}
+
+	ob.ReduceOrder(order, reduceBy)
+	return order, nil
 }
 
 func (e *Engine) GetOrder(orderID string) (*models.Order, error) {
@@ -487,6 +959,59 @@ func (e *Engine) GetOrder(orderID string) (*models.Order, error) {
 	return val.(*models.Order), nil
 }
 
+// QueuePosition describes where a resting order sits in its price level's queue.
+type QueuePosition struct {
+	OrderID       string `json:"order_id"`
+	Price         int64  `json:"price"`
+	Sequence      uint64 `json:"sequence"`
+	OrdersAhead   int    `json:"orders_ahead"`
+	QuantityAhead int64  `json:"quantity_ahead"`
+	LevelOrders   int    `json:"level_orders"`
+	LevelQuantity int64  `json:"level_quantity"`
+}
+
+// GetQueuePosition reports how many orders, and how much quantity, rest ahead
+// of orderID at its price. Like depth, it only counts what is displayed, so
+// hidden orders don't show; a hidden order still counts itself in the level.
+func (e *Engine) GetQueuePosition(orderID string) (*QueuePosition, error) {
+	val, ok := e.AllOrders.Load(orderID)
+	if !ok {
+		return nil, fmt.Errorf("order not found")
+	}
+	order := val.(*models.Order)
+
+	ob := e.getOrderBook(order.Symbol)
+	ob.RLock()
+	defer ob.RUnlock()
+
+	if _, resting := ob.Orders[orderID]; !resting {
+		return nil, fmt.Errorf("order is not resting in the book")
+	}
+	level, found := ob.side(order.Side).Get(order.Priority.Price)
+	if !found {
+		return nil, fmt.Errorf("order is not resting in the book")
+	}
+
+	pos := &QueuePosition{
+		OrderID:       orderID,
+		Price:         level.Price,
+		Sequence:      order.Priority.Sequence,
+		LevelQuantity: level.Displayed(),
+	}
+	ahead := level.queueIndex(order.Priority)
+	for i, o := range level.Orders {
+		if o.Displayed() == 0 && o != order {
+			continue
+		}
+		pos.LevelOrders++
+		if i < ahead {
+			pos.OrdersAhead++
+			pos.QuantityAhead += o.Displayed()
+		}
+	}
+	return pos, nil
+}
+
 func (e *Engine) GetOrderBookDepth(symbol string, depthLimit int) (*OrderBookDepth, error) {
 	ob := e.getOrderBook(symbol)
 	return ob.GetDepth(depthLimit), nil
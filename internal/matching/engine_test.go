@@ -2,10 +2,18 @@ package matching
 
 import (
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"repello/internal/metrics"
 	"repello/internal/models"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -27,6 +35,15 @@ func TestProcessOrder_SimpleMatch(t *testing.T) {
 	assert.Equal(t, int64(100), result.Trades[0].Price)
 	assert.Equal(t, int64(0), buyOrder.RemainingQuantity)
 
+	// The incoming buy took liquidity from the resting sell
+	trade := result.Trades[0]
+	assert.Equal(t, "BTCUSD", trade.Symbol)
+	assert.Equal(t, models.Buy, trade.AggressorSide)
+	assert.Equal(t, "buyer1", trade.TakerOrderID)
+	assert.Equal(t, "seller1", trade.MakerOrderID)
+	assert.Equal(t, models.Taker, trade.LiquidityFor("buyer1"))
+	assert.Equal(t, models.Maker, trade.LiquidityFor("seller1"))
+
 	// Check if the book is empty
 	ob := engine.getOrderBook("BTCUSD")
 	assert.True(t, ob.Bids.Empty())
@@ -114,6 +131,632 @@ func TestProcessOrder_MarketOrderRejection(t *testing.T) {
 	assert.Equal(t, int64(5), bestAsk.RemainingQuantity) // Nothing matched
 }
 
+func TestPriceLevel_AggregateQuantity(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+
+	engine.ProcessOrder(models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 5))
+	engine.ProcessOrder(models.NewOrder("seller2", "BTCUSD", models.Sell, models.Limit, 100, 7))
+	engine.ProcessOrder(models.NewOrder("seller3", "BTCUSD", models.Sell, models.Limit, 101, 4))
+
+	ob := engine.getOrderBook("BTCUSD")
+	level, _ := ob.Asks.Get(100)
+	assert.Equal(t, int64(12), level.TotalQuantity)
+
+	// Partial fill of the first order at 100
+	engine.ProcessOrder(models.NewOrder("buyer1", "BTCUSD", models.Buy, models.Limit, 100, 3))
+	assert.Equal(t, int64(9), level.TotalQuantity)
+
+	// Cancel the second order at 100
+	engine.CancelOrder("seller2")
+	assert.Equal(t, int64(2), level.TotalQuantity)
+
+	assert.Equal(t, int64(6), ob.CalculateLiquidity(models.Buy, 100))
+
+	depth := ob.GetDepth(0)
+	assert.Equal(t, []PriceLevelData{{Price: 100, Quantity: 2}, {Price: 101, Quantity: 4}}, depth.Asks)
+}
+
+func TestCalculateLiquidityWithinPrice(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+
+	engine.ProcessOrder(models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 5))
+	engine.ProcessOrder(models.NewOrder("seller2", "BTCUSD", models.Sell, models.Limit, 101, 5))
+	engine.ProcessOrder(models.NewOrder("seller3", "BTCUSD", models.Sell, models.Limit, 105, 5))
+	engine.ProcessOrder(models.NewOrder("buyer1", "BTCUSD", models.Buy, models.Limit, 90, 4))
+	engine.ProcessOrder(models.NewOrder("buyer2", "BTCUSD", models.Buy, models.Limit, 88, 4))
+
+	ob := engine.getOrderBook("BTCUSD")
+
+	// Buy side only sees asks at or below the limit
+	assert.Equal(t, int64(10), ob.CalculateLiquidityWithinPrice(models.Buy, 104, 100))
+	assert.Equal(t, int64(15), ob.CalculateLiquidityWithinPrice(models.Buy, 105, 100))
+	assert.Equal(t, int64(0), ob.CalculateLiquidityWithinPrice(models.Buy, 99, 100))
+
+	// Stops at the first level that satisfies the requirement
+	assert.Equal(t, int64(5), ob.CalculateLiquidityWithinPrice(models.Buy, 105, 3))
+
+	// Sell side only sees bids at or above the limit
+	assert.Equal(t, int64(4), ob.CalculateLiquidityWithinPrice(models.Sell, 89, 100))
+	assert.Equal(t, int64(8), ob.CalculateLiquidityWithinPrice(models.Sell, 88, 100))
+}
+
+func TestLadderBook_MatchesLikeTree(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+	assert.NoError(t, engine.ConfigureLadder("BTCUSD", LadderConfig{MinPrice: 1, MaxPrice: 1000, TickSize: 1}))
+
+	// Spread levels across several bitmap words
+	engine.ProcessOrder(models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 700, 5))
+	engine.ProcessOrder(models.NewOrder("seller2", "BTCUSD", models.Sell, models.Limit, 130, 5))
+	engine.ProcessOrder(models.NewOrder("buyer1", "BTCUSD", models.Buy, models.Limit, 10, 5))
+	engine.ProcessOrder(models.NewOrder("buyer2", "BTCUSD", models.Buy, models.Limit, 65, 5))
+
+	ob := engine.getOrderBook("BTCUSD")
+	assert.Equal(t, "seller2", ob.GetBestAsk().ID)
+	assert.Equal(t, "buyer2", ob.GetBestBid().ID)
+
+	buyOrder := models.NewOrder("buyer3", "BTCUSD", models.Buy, models.Limit, 700, 8)
+	result, err := engine.ProcessOrder(buyOrder)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(result.Trades))
+	assert.Equal(t, int64(130), result.Trades[0].Price)
+	assert.Equal(t, int64(700), result.Trades[1].Price)
+	assert.Equal(t, int64(2), ob.GetBestAsk().RemainingQuantity)
+
+	depth := ob.GetDepth(0)
+	assert.Equal(t, []PriceLevelData{{Price: 65, Quantity: 5}, {Price: 10, Quantity: 5}}, depth.Bids)
+	assert.Equal(t, []PriceLevelData{{Price: 700, Quantity: 2}}, depth.Asks)
+
+	_, err = engine.CancelOrder("buyer2")
+	assert.NoError(t, err)
+	assert.Equal(t, "buyer1", ob.GetBestBid().ID)
+}
+
+func TestLadderBook_RejectsOffLadderPrices(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+	assert.NoError(t, engine.ConfigureLadder("BTCUSD", LadderConfig{MinPrice: 100, MaxPrice: 200, TickSize: 5}))
+
+	_, err := engine.ProcessOrder(models.NewOrder("o1", "BTCUSD", models.Buy, models.Limit, 201, 1))
+	assert.Error(t, err)
+	_, err = engine.ProcessOrder(models.NewOrder("o2", "BTCUSD", models.Buy, models.Limit, 102, 1))
+	assert.Error(t, err)
+	_, err = engine.ProcessOrder(models.NewOrder("o3", "BTCUSD", models.Buy, models.Limit, 105, 1))
+	assert.NoError(t, err)
+
+	_, err = engine.GetOrder("o1")
+	assert.Error(t, err)
+
+	// Ladders can't be swapped in once a book exists
+	assert.Error(t, engine.ConfigureLadder("BTCUSD", LadderConfig{MinPrice: 1, MaxPrice: 10, TickSize: 1}))
+}
+
+func TestSweepLimit_CancelRemainder(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+	engine.SetSweepLimit("BTCUSD", SweepLimit{MaxLevels: 2})
+
+	engine.ProcessOrder(models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 2))
+	engine.ProcessOrder(models.NewOrder("seller2", "BTCUSD", models.Sell, models.Limit, 100, 2))
+	engine.ProcessOrder(models.NewOrder("seller3", "BTCUSD", models.Sell, models.Limit, 101, 2))
+	engine.ProcessOrder(models.NewOrder("seller4", "BTCUSD", models.Sell, models.Limit, 102, 2))
+
+	buyOrder := models.NewOrder("buyer1", "BTCUSD", models.Buy, models.Limit, 105, 10)
+	result, err := engine.ProcessOrder(buyOrder)
+
+	assert.NoError(t, err)
+	assert.True(t, result.SweepCapped)
+	assert.Equal(t, 3, len(result.Trades)) // two orders at 100, one at 101
+	assert.Equal(t, int64(6), buyOrder.FilledQuantity)
+	assert.Equal(t, models.Cancelled, buyOrder.Status)
+
+	// The remainder must not rest on the book
+	ob := engine.getOrderBook("BTCUSD")
+	assert.True(t, ob.Bids.Empty())
+	assert.Equal(t, "seller4", ob.GetBestAsk().ID)
+}
+
+func TestSweepLimit_Reject(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+	engine.SetSweepLimit("BTCUSD", SweepLimit{MaxMatches: 2, Action: SweepReject})
+
+	engine.ProcessOrder(models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 2))
+	engine.ProcessOrder(models.NewOrder("seller2", "BTCUSD", models.Sell, models.Limit, 100, 2))
+	engine.ProcessOrder(models.NewOrder("seller3", "BTCUSD", models.Sell, models.Limit, 101, 2))
+
+	// Needs three resting orders
+	_, err := engine.ProcessOrder(models.NewOrder("buyer1", "BTCUSD", models.Buy, models.Market, 0, 5))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "sweep limit exceeded")
+	assert.Equal(t, int64(6), engine.getOrderBook("BTCUSD").CalculateLiquidity(models.Buy, 100))
+
+	// Fits within two
+	result, err := engine.ProcessOrder(models.NewOrder("buyer2", "BTCUSD", models.Buy, models.Market, 0, 4))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(result.Trades))
+}
+
+func TestSweepLimit_Iceberg(t *testing.T) {
+	iceberg := func(engine *Engine) {
+		order := models.NewOrder("ice", "BTCUSD", models.Sell, models.Limit, 100, 6)
+		order.DisplayQuantity = 2
+		_, err := engine.ProcessOrder(order)
+		assert.NoError(t, err)
+	}
+
+	// Each slice of an iceberg is a match of its own
+	engine := NewEngine(metrics.NewMetrics())
+	engine.SetSweepLimit("BTCUSD", SweepLimit{MaxMatches: 2, Action: SweepReject})
+	iceberg(engine)
+	_, err := engine.SimulateOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 100, 5))
+	assert.ErrorContains(t, err, "sweep limit exceeded")
+	_, err = engine.ProcessOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 100, 5))
+	assert.ErrorContains(t, err, "sweep limit exceeded")
+	result, err := engine.ProcessOrder(models.NewOrder("b2", "BTCUSD", models.Buy, models.Limit, 100, 4))
+	assert.NoError(t, err)
+	assert.Len(t, result.Trades, 2)
+
+	// and a simulation caps where matching would
+	engine = NewEngine(metrics.NewMetrics())
+	engine.SetSweepLimit("BTCUSD", SweepLimit{MaxMatches: 2})
+	iceberg(engine)
+	sim, err := engine.SimulateOrder(models.NewOrder("b3", "BTCUSD", models.Buy, models.Limit, 100, 5))
+	assert.NoError(t, err)
+	assert.True(t, sim.SweepCapped)
+	assert.Equal(t, int64(4), sim.FilledQuantity)
+	result, err = engine.ProcessOrder(models.NewOrder("b3", "BTCUSD", models.Buy, models.Limit, 100, 5))
+	assert.NoError(t, err)
+	assert.True(t, result.SweepCapped)
+	assert.Equal(t, sim.FilledQuantity, result.Order.FilledQuantity)
+}
+
+func TestOrderLifecycleTimestamps(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+
+	sellOrder := models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 10)
+	engine.ProcessOrder(sellOrder)
+	assert.NotZero(t, sellOrder.AcceptedAt)
+	assert.Zero(t, sellOrder.FirstFillAt)
+	assert.Zero(t, sellOrder.CompletedAt)
+
+	buyOrder := models.NewOrder("buyer1", "BTCUSD", models.Buy, models.Limit, 100, 4)
+	engine.ProcessOrder(buyOrder)
+	assert.NotZero(t, sellOrder.FirstFillAt)
+	assert.Zero(t, sellOrder.CompletedAt)
+	assert.Equal(t, sellOrder.FirstFillAt, buyOrder.FirstFillAt)
+	assert.NotZero(t, buyOrder.CompletedAt)
+	assert.GreaterOrEqual(t, buyOrder.FirstFillAt, buyOrder.AcceptedAt)
+
+	// A second fill doesn't move FirstFillAt
+	firstFill := sellOrder.FirstFillAt
+	engine.ProcessOrder(models.NewOrder("buyer2", "BTCUSD", models.Buy, models.Limit, 100, 2))
+	assert.Equal(t, firstFill, sellOrder.FirstFillAt)
+
+	engine.CancelOrder("seller1")
+	assert.NotZero(t, sellOrder.CompletedAt)
+}
+
+func TestReduceOrder_KeepsPriority(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+
+	engine.ProcessOrder(models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 10))
+	engine.ProcessOrder(models.NewOrder("seller2", "BTCUSD", models.Sell, models.Limit, 100, 10))
+
+	order, err := engine.ReduceOrder("seller1", 6)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), order.RemainingQuantity)
+	assert.Equal(t, int64(4), order.OriginalQuantity)
+
+	ob := engine.getOrderBook("BTCUSD")
+	level, _ := ob.Asks.Get(100)
+	assert.Equal(t, int64(14), level.TotalQuantity)
+
+	// seller1 is still first in the queue
+	result, _ := engine.ProcessOrder(models.NewOrder("buyer1", "BTCUSD", models.Buy, models.Limit, 100, 5))
+	assert.Equal(t, "seller1", result.Trades[0].MakerOrderID)
+	assert.Equal(t, int64(4), result.Trades[0].Quantity)
+
+	// Total quantity counts what's already filled
+	engine.ProcessOrder(models.NewOrder("buyer2", "BTCUSD", models.Buy, models.Limit, 100, 2))
+	order, err = engine.ReduceOrderTo("seller2", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), order.RemainingQuantity)
+	assert.Equal(t, int64(3), order.FilledQuantity)
+}
+
+func TestPriorityKey(t *testing.T) {
+	better := models.PriorityKey{Price: 101, Sequence: 9}
+	worse := models.PriorityKey{Price: 100, Sequence: 1}
+	assert.True(t, better.Before(worse, models.Buy))
+	assert.True(t, worse.Before(better, models.Sell))
+	earlier := models.PriorityKey{Price: 100, Sequence: 0}
+	assert.True(t, earlier.Before(worse, models.Sell))
+	assert.False(t, worse.Before(worse, models.Buy))
+
+	// The queue follows the key, not the order orders are added in
+	ob := NewOrderBook("BTCUSD")
+	late := models.NewOrder("late", "BTCUSD", models.Sell, models.Limit, 100, 1)
+	late.Priority = models.PriorityKey{Price: 100, Sequence: 7}
+	early := models.NewOrder("early", "BTCUSD", models.Sell, models.Limit, 100, 1)
+	early.Priority = models.PriorityKey{Price: 100, Sequence: 3}
+	ob.AddOrder(late)
+	ob.AddOrder(early)
+	level, _ := ob.Asks.Get(100)
+	assert.Equal(t, []*models.Order{early, late}, level.Orders)
+
+	ob.RemoveOrder("late")
+	assert.Equal(t, []*models.Order{early}, level.Orders)
+
+	// Accepted orders get increasing sequences
+	engine := NewEngine(metrics.NewMetrics())
+	first := models.NewOrder("first", "BTCUSD", models.Sell, models.Limit, 100, 1)
+	second := models.NewOrder("second", "BTCUSD", models.Sell, models.Limit, 100, 1)
+	engine.ProcessOrder(first)
+	engine.ProcessOrder(second)
+	assert.True(t, first.Priority.Before(second.Priority, models.Sell))
+}
+
+func TestSequenceBreaksTimestampTies(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+
+	// Identical client timestamps, as under batch intake
+	first := models.NewOrder("first", "BTCUSD", models.Sell, models.Limit, 100, 5)
+	second := models.NewOrder("second", "BTCUSD", models.Sell, models.Limit, 100, 5)
+	second.Timestamp = first.Timestamp
+	engine.ProcessOrder(first)
+	engine.ProcessOrder(second)
+
+	result, _ := engine.ProcessOrder(models.NewOrder("buyer", "BTCUSD", models.Buy, models.Limit, 100, 5))
+	assert.Equal(t, "first", result.Trades[0].MakerOrderID)
+
+	// Sequences are per book
+	other := models.NewOrder("other", "ETHUSD", models.Sell, models.Limit, 100, 5)
+	engine.ProcessOrder(other)
+	assert.Equal(t, uint64(1), other.Priority.Sequence)
+}
+
+func TestOrderTagsAndMetadata(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+
+	order := models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 5)
+	order.Tag = "momentum-v2"
+	order.Metadata = map[string]string{"desk": "crypto"}
+	_, err := engine.ProcessOrder(order)
+	assert.NoError(t, err)
+	got, _ := engine.GetOrder("seller1")
+	assert.Equal(t, "momentum-v2", got.Tag)
+	assert.Equal(t, "crypto", got.Metadata["desk"])
+
+	order = models.NewOrder("seller2", "BTCUSD", models.Sell, models.Limit, 100, 5)
+	order.Tag = strings.Repeat("x", models.MaxTagLength+1)
+	_, err = engine.ProcessOrder(order)
+	assert.ErrorContains(t, err, "invalid tag")
+
+	order = models.NewOrder("seller3", "BTCUSD", models.Sell, models.Limit, 100, 5)
+	order.Metadata = map[string]string{"": "empty key"}
+	_, err = engine.ProcessOrder(order)
+	assert.ErrorContains(t, err, "invalid metadata")
+}
+
+func TestSymbolActivityMetrics(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+
+	engine.ProcessOrder(models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 5))
+	engine.ProcessOrder(models.NewOrder("seller2", "BTCUSD", models.Sell, models.Limit, 101, 5))
+	engine.ProcessOrder(models.NewOrder("buyer1", "BTCUSD", models.Buy, models.Limit, 101, 7))
+	engine.CancelOrder("seller2")
+	// Immediate remainders never rest, so they are neither adds nor cancels
+	ioc := models.NewOrder("buyer2", "BTCUSD", models.Buy, models.Limit, 90, 1)
+	ioc.TimeInForce = models.IOC
+	engine.ProcessOrder(ioc)
+
+	activity := m.Activity("BTCUSD")
+	assert.Equal(t, int64(2), activity.Adds)
+	assert.Equal(t, int64(1), activity.Cancels)
+	assert.Equal(t, int64(2), activity.Executions)
+	assert.Equal(t, int64(7), activity.Volume)
+	assert.Equal(t, int64(5*100+2*101), activity.Turnover)
+}
+
+func TestPurgeStaleOrders(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+
+	engine.ProcessOrder(models.NewOrder("old1", "BTCUSD", models.Sell, models.Limit, 101, 5))
+	engine.ProcessOrder(models.NewOrder("old2", "BTCUSD", models.Buy, models.Limit, 99, 5))
+	engine.ProcessOrder(models.NewOrder("fresh", "BTCUSD", models.Sell, models.Limit, 102, 5))
+	engine.ProcessOrder(models.NewOrder("other", "ETHUSD", models.Sell, models.Limit, 102, 5))
+	for _, id := range []string{"old1", "old2", "other"} {
+		order, _ := engine.GetOrder(id)
+		order.AcceptedAt -= int64(2 * time.Hour)
+	}
+
+	purged, err := engine.PurgeStaleOrders("BTCUSD", time.Hour, time.Now())
+	assert.NoError(t, err)
+	if assert.Len(t, purged, 2) {
+		assert.Equal(t, "old1", purged[0].ID)
+		assert.Equal(t, "old2", purged[1].ID)
+		assert.Equal(t, models.Cancelled, purged[0].Status)
+	}
+	ob := engine.getOrderBook("BTCUSD")
+	assert.Equal(t, "fresh", ob.GetBestAsk().ID)
+	assert.Nil(t, ob.GetBestBid())
+	other, _ := engine.GetOrder("other")
+	assert.Equal(t, models.Accepted, other.Status)
+
+	_, err = engine.PurgeStaleOrders("NOPE", time.Hour, time.Now())
+	assert.EqualError(t, err, "order book not found")
+	_, err = engine.PurgeStaleOrders("BTCUSD", 0, time.Now())
+	assert.Error(t, err)
+}
+
+func TestClockGuard(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	strict := NewClockGuard(time.Second, false)
+	strict.now = func() time.Time { return now }
+	restamp := NewClockGuard(time.Second, true)
+	restamp.now = func() time.Time { return now }
+
+	engine := NewEngine(metrics.NewMetrics())
+	engine.AddPreTradeCheck(strict)
+	order := models.NewOrder("ok", "BTCUSD", models.Sell, models.Limit, 100, 1)
+	order.TransactTime = now.Add(-500 * time.Millisecond).UnixNano()
+	_, err := engine.ProcessOrder(order)
+	assert.NoError(t, err)
+	assert.Zero(t, order.ClockSkew)
+
+	order = models.NewOrder("late", "BTCUSD", models.Sell, models.Limit, 100, 1)
+	order.TransactTime = now.Add(-2 * time.Second).UnixNano()
+	_, err = engine.ProcessOrder(order)
+	assert.ErrorContains(t, err, "invalid transact time")
+
+	engine = NewEngine(metrics.NewMetrics())
+	engine.AddPreTradeCheck(restamp)
+	order = models.NewOrder("ahead", "BTCUSD", models.Sell, models.Limit, 100, 1)
+	order.TransactTime = now.Add(3 * time.Second).UnixNano()
+	_, err = engine.ProcessOrder(order)
+	assert.NoError(t, err)
+	assert.Equal(t, now.UnixNano(), order.TransactTime)
+	assert.Equal(t, int64(3*time.Second), order.ClockSkew)
+	assert.Equal(t, int64(1), restamp.Restamped())
+
+	// Orders without a transact time aren't checked
+	_, err = engine.ProcessOrder(models.NewOrder("none", "BTCUSD", models.Sell, models.Limit, 100, 1))
+	assert.NoError(t, err)
+}
+
+func TestSpeedBump(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+	assert.NoError(t, engine.SetSpeedBump("BTCUSD", 200*time.Millisecond))
+	assert.Error(t, engine.SetSpeedBump("BTCUSD", -time.Second))
+
+	// Passive orders go straight in
+	start := time.Now()
+	engine.ProcessOrder(models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 5))
+	engine.ProcessOrder(models.NewOrder("buyer0", "BTCUSD", models.Buy, models.Limit, 99, 5))
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+	// The maker pulls its quote while the aggressor waits out the bump
+	done := make(chan *MatchResult)
+	go func() {
+		result, _ := engine.ProcessOrder(models.NewOrder("buyer1", "BTCUSD", models.Buy, models.Limit, 100, 5))
+		done <- result
+	}()
+	time.Sleep(20 * time.Millisecond)
+	_, err := engine.CancelOrder("seller1")
+	assert.NoError(t, err)
+	result := <-done
+	assert.Empty(t, result.Trades)
+	assert.Less(t, int64(200*time.Millisecond), time.Since(start).Nanoseconds())
+
+	bumps, err := ParseSpeedBumps("BTCUSD:350us, ETHUSD:1ms")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{"BTCUSD": 350 * time.Microsecond, "ETHUSD": time.Millisecond}, bumps)
+	_, err = ParseSpeedBumps("BTCUSD")
+	assert.Error(t, err)
+}
+
+func TestReduceOrder_Rejections(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+
+	engine.ProcessOrder(models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 10))
+
+	_, err := engine.ReduceOrder("missing", 1)
+	assert.EqualError(t, err, "order not found")
+	_, err = engine.ReduceOrder("seller1", 10)
+	assert.Error(t, err)
+	_, err = engine.ReduceOrderTo("seller1", 12)
+	assert.Error(t, err)
+
+	engine.CancelOrder("seller1")
+	_, err = engine.ReduceOrder("seller1", 1)
+	assert.Error(t, err)
+}
+
+func TestSimulateOrder_DoesNotMutate(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+
+	engine.ProcessOrder(models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 5))
+	engine.ProcessOrder(models.NewOrder("seller2", "BTCUSD", models.Sell, models.Limit, 100, 5))
+	engine.ProcessOrder(models.NewOrder("seller3", "BTCUSD", models.Sell, models.Limit, 110, 10))
+
+	sim, err := engine.SimulateOrder(models.NewOrder("probe", "BTCUSD", models.Buy, models.Market, 0, 15))
+	assert.NoError(t, err)
+	assert.Equal(t, []SimulatedFill{{Price: 100, Quantity: 10}, {Price: 110, Quantity: 5}}, sim.Fills)
+	assert.Equal(t, int64(15), sim.FilledQuantity)
+	assert.Equal(t, int64(100), sim.ReferencePrice)
+	assert.InDelta(t, 103.333, sim.AveragePrice, 0.001)
+	assert.InDelta(t, 3.333, sim.Slippage, 0.001)
+
+	// A limit order stops at its price
+	sim, err = engine.SimulateOrder(models.NewOrder("probe", "BTCUSD", models.Buy, models.Limit, 105, 15))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), sim.FilledQuantity)
+	assert.Equal(t, int64(5), sim.RemainingQuantity)
+	assert.Equal(t, 0.0, sim.Slippage)
+
+	_, err = engine.SimulateOrder(models.NewOrder("probe", "BTCUSD", models.Buy, models.Market, 0, 100))
+	assert.Error(t, err)
+
+	// A fill-or-kill that would be killed is refused, as it would be for real
+	fok := models.NewOrder("probe", "BTCUSD", models.Buy, models.Limit, 105, 15)
+	fok.TimeInForce = models.FOK
+	_, err = engine.SimulateOrder(fok)
+	assert.ErrorContains(t, err, "fill or kill")
+	fok.OriginalQuantity, fok.RemainingQuantity = 10, 10
+	sim, err = engine.SimulateOrder(fok)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), sim.FilledQuantity)
+
+	// Simulations don't need an order ID, real orders do
+	_, err = engine.SimulateOrder(models.NewOrder("", "BTCUSD", models.Buy, models.Limit, 105, 1))
+	assert.NoError(t, err)
+	_, err = engine.ProcessOrder(models.NewOrder("", "BTCUSD", models.Buy, models.Limit, 105, 1))
+	assert.EqualError(t, err, "invalid order id: required")
+
+	// Nothing changed
+	depth, _ := engine.GetOrderBookDepth("BTCUSD", 0)
+	assert.Equal(t, []PriceLevelData{{Price: 100, Quantity: 10}, {Price: 110, Quantity: 10}}, depth.Asks)
+	_, err = engine.GetOrder("probe")
+	assert.Error(t, err)
+	assert.Equal(t, int64(0), m.TradesExecuted.Load())
+}
+
+func TestGetQueuePosition(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+
+	engine.ProcessOrder(models.NewOrder("buyer1", "BTCUSD", models.Buy, models.Limit, 100, 5))
+	engine.ProcessOrder(models.NewOrder("buyer2", "BTCUSD", models.Buy, models.Limit, 100, 7))
+	engine.ProcessOrder(models.NewOrder("buyer3", "BTCUSD", models.Buy, models.Limit, 100, 3))
+
+	pos, err := engine.GetQueuePosition("buyer3")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), pos.Sequence)
+	assert.Equal(t, 2, pos.OrdersAhead)
+	assert.Equal(t, int64(12), pos.QuantityAhead)
+	assert.Equal(t, 3, pos.LevelOrders)
+	assert.Equal(t, int64(15), pos.LevelQuantity)
+
+	// Fills at the front move the order up
+	engine.ProcessOrder(models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 8))
+	pos, _ = engine.GetQueuePosition("buyer3")
+	assert.Equal(t, 1, pos.OrdersAhead)
+	assert.Equal(t, int64(4), pos.QuantityAhead)
+
+	engine.ProcessOrder(models.NewOrder("seller2", "BTCUSD", models.Sell, models.Limit, 100, 7))
+	_, err = engine.GetQueuePosition("buyer3")
+	assert.EqualError(t, err, "order is not resting in the book")
+	_, err = engine.GetQueuePosition("missing")
+	assert.EqualError(t, err, "order not found")
+}
+
+func TestTimeInForce_IOCAndFOK(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+
+	engine.ProcessOrder(models.NewOrder("seller1", "BTCUSD", models.Sell, models.Limit, 100, 5))
+
+	ioc := models.NewOrder("ioc", "BTCUSD", models.Buy, models.Limit, 100, 8)
+	ioc.TimeInForce = models.IOC
+	result, err := engine.ProcessOrder(ioc)
+	assert.NoError(t, err)
+	assert.Len(t, result.Trades, 1)
+	assert.Equal(t, models.Cancelled, ioc.Status)
+	assert.Nil(t, engine.getOrderBook("BTCUSD").GetBestBid())
+
+	engine.ProcessOrder(models.NewOrder("seller2", "BTCUSD", models.Sell, models.Limit, 100, 5))
+	fok := models.NewOrder("fok", "BTCUSD", models.Buy, models.Limit, 100, 8)
+	fok.TimeInForce = models.FOK
+	_, err = engine.ProcessOrder(fok)
+	assert.EqualError(t, err, "fill or kill: only 5 shares available, requested 8")
+	assert.Equal(t, models.Rejected, fok.Status)
+	assert.NotZero(t, fok.CompletedAt)
+	assert.Equal(t, int64(5), engine.getOrderBook("BTCUSD").GetBestAsk().RemainingQuantity)
+
+	fok = models.NewOrder("fok2", "BTCUSD", models.Buy, models.Limit, 100, 5)
+	fok.TimeInForce = models.FOK
+	_, err = engine.ProcessOrder(fok)
+	assert.NoError(t, err)
+	assert.Equal(t, models.Filled, fok.Status)
+
+	// Market orders default to IOC and can't rest
+	market := models.NewOrder("market", "BTCUSD", models.Buy, models.Market, 0, 1)
+	market.TimeInForce = models.GTC
+	_, err = engine.ProcessOrder(market)
+	assert.EqualError(t, err, "invalid time in force: market orders must be IOC or FOK")
+}
+
+func TestTimeInForce_SymbolDefaultAndExpiry(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+
+	assert.Error(t, engine.SetDefaultTimeInForce("ETHUSD", models.GTD))
+	assert.NoError(t, engine.SetDefaultTimeInForce("ETHUSD", models.DAY))
+
+	day := models.NewOrder("day", "ETHUSD", models.Buy, models.Limit, 100, 5)
+	engine.ProcessOrder(day)
+	assert.Equal(t, models.DAY, day.TimeInForce)
+	assert.Greater(t, day.ExpireAt, day.AcceptedAt)
+
+	gtc := models.NewOrder("gtc", "BTCUSD", models.Buy, models.Limit, 100, 5)
+	engine.ProcessOrder(gtc)
+	assert.Equal(t, models.GTC, gtc.TimeInForce)
+
+	gtd := models.NewOrder("gtd", "BTCUSD", models.Buy, models.Limit, 99, 5)
+	gtd.TimeInForce = models.GTD
+	gtd.ExpireAt = day.ExpireAt - 1
+	_, err := engine.ProcessOrder(gtd)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, engine.ExpireOrders(time.Now()))
+	assert.Equal(t, 1, engine.ExpireOrders(time.Unix(0, gtd.ExpireAt)))
+	assert.Equal(t, models.Expired, gtd.Status)
+	assert.Equal(t, 1, engine.ExpireOrders(time.Unix(0, day.ExpireAt)))
+	assert.Equal(t, models.Cancelled, day.Status)
+	assert.Equal(t, SessionCloseCancelReason, day.CancelReason)
+	assert.Equal(t, models.Accepted, gtc.Status)
+	assert.Equal(t, int64(1), m.OrdersInBook.Load())
+}
+
+func TestGetAccountExposure(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+
+	place := func(id, account, symbol string, side models.Side, price, qty int64) {
+		order := models.NewOrder(id, symbol, side, models.Limit, price, qty)
+		order.Account = account
+		engine.ProcessOrder(order)
+	}
+	place("a1", "alice", "BTCUSD", models.Buy, 100, 5)
+	place("a2", "alice", "BTCUSD", models.Sell, 110, 2)
+	place("a3", "alice", "ETHUSD", models.Buy, 20, 10)
+	place("b1", "bob", "BTCUSD", models.Sell, 105, 3)
+	place("b2", "bob", "BTCUSD", models.Buy, 105, 1) // fills against b1
+
+	exposure := engine.GetAccountExposure("alice")
+	assert.Equal(t, 3, exposure.OpenOrders)
+	assert.Equal(t, int64(700), exposure.BuyNotional)
+	assert.Equal(t, int64(220), exposure.SellNotional)
+	assert.Equal(t, []SymbolExposure{
+		{Symbol: "BTCUSD", BuyOrders: 1, SellOrders: 1, BuyQuantity: 5, SellQuantity: 2, BuyNotional: 500, SellNotional: 220},
+		{Symbol: "ETHUSD", BuyOrders: 1, BuyQuantity: 10, BuyNotional: 200},
+	}, exposure.Symbols)
+
+	exposure = engine.GetAccountExposure("bob")
+	assert.Equal(t, 1, exposure.OpenOrders)
+	assert.Equal(t, int64(210), exposure.SellNotional)
+
+	assert.Empty(t, engine.GetAccountExposure("carol").Symbols)
+}
+
 func TestEngineConcurrency(t *testing.T) {
 	m := metrics.NewMetrics()
 	engine := NewEngine(m)
@@ -164,6 +807,7 @@ func BenchmarkPlaceOrder(b *testing.B) {
 
 	b.ResetTimer()
 
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		order := models.NewOrder(
 			fmt.Sprintf("bench-%d", i),
@@ -173,6 +817,1214 @@ func BenchmarkPlaceOrder(b *testing.B) {
 			1000,
 			1,
 		)
-		engine.ProcessOrder(order)
+		if result, err := engine.ProcessOrder(order); err == nil {
+			result.Release()
+		}
+	}
+}
+
+// BenchmarkPlaceOrderParallel spreads limit orders from all Ps across 1, 10 and
+// 100 symbols to show how well symbol-level locking scales.
+func BenchmarkPlaceOrderParallel(b *testing.B) {
+	for _, numSymbols := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("symbols=%d", numSymbols), func(b *testing.B) {
+			engine := NewEngine(metrics.NewMetrics())
+			symbols := make([]string, numSymbols)
+			for i := range symbols {
+				symbols[i] = fmt.Sprintf("SYM%d", i)
+			}
+
+			var seq atomic.Int64
+			recorder := newLatencyRecorder()
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				samples := make([]time.Duration, 0, 1024)
+				for pb.Next() {
+					n := seq.Add(1)
+					side := models.Buy
+					if n%2 == 0 {
+						side = models.Sell
+					}
+					order := models.NewOrder(
+						strconv.FormatInt(n, 10),
+						symbols[n%int64(numSymbols)],
+						side,
+						models.Limit,
+						1000+n%10,
+						1,
+					)
+					start := time.Now()
+					engine.ProcessOrder(order)
+					samples = append(samples, time.Since(start))
+				}
+				recorder.add(samples)
+			})
+
+			recorder.report(b)
+		})
 	}
 }
+
+// BenchmarkCancelHeavy places and immediately cancels resting orders, with one
+// aggressive order every ten operations.
+func BenchmarkCancelHeavy(b *testing.B) {
+	engine := NewEngine(metrics.NewMetrics())
+	symbol := "BTCUSD"
+
+	var seq atomic.Int64
+	recorder := newLatencyRecorder()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		samples := make([]time.Duration, 0, 1024)
+		for pb.Next() {
+			n := seq.Add(1)
+			id := strconv.FormatInt(n, 10)
+			start := time.Now()
+			if n%10 == 0 {
+				engine.ProcessOrder(models.NewOrder(id, symbol, models.Buy, models.Limit, 1010, 1))
+			} else {
+				engine.ProcessOrder(models.NewOrder(id, symbol, models.Sell, models.Limit, 1000+n%10, 1))
+				engine.CancelOrder(id)
+			}
+			samples = append(samples, time.Since(start))
+		}
+		recorder.add(samples)
+	})
+
+	recorder.report(b)
+}
+
+// BenchmarkDeepBookSweep measures a market order that walks every level of a
+// 1000-level book, for both book structures. Refilling the book is excluded
+// from the timing.
+func BenchmarkDeepBookSweep(b *testing.B) {
+	const levels = 1000
+	for _, ladder := range []bool{false, true} {
+		name := "book=tree"
+		if ladder {
+			name = "book=ladder"
+		}
+		b.Run(name, func(b *testing.B) {
+			engine := NewEngine(metrics.NewMetrics())
+			symbol := "BTCUSD"
+			if ladder {
+				engine.ConfigureLadder(symbol, LadderConfig{MinPrice: 1000, MaxPrice: 1000 + levels, TickSize: 1})
+			}
+
+			recorder := newLatencyRecorder()
+			samples := make([]time.Duration, 0, b.N)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				for l := 0; l < levels; l++ {
+					engine.ProcessOrder(models.NewOrder(fmt.Sprintf("ask-%d-%d", i, l), symbol, models.Sell, models.Limit, int64(1000+l), 1))
+				}
+				b.StartTimer()
+
+				start := time.Now()
+				engine.ProcessOrder(models.NewOrder(fmt.Sprintf("sweep-%d", i), symbol, models.Buy, models.Market, 0, levels))
+				samples = append(samples, time.Since(start))
+			}
+
+			recorder.add(samples)
+			recorder.report(b)
+		})
+	}
+}
+
+// BenchmarkDepthQueryContention issues depth snapshots while other goroutines
+// keep submitting orders to the same book.
+func BenchmarkDepthQueryContention(b *testing.B) {
+	engine := NewEngine(metrics.NewMetrics())
+	symbol := "BTCUSD"
+	for i := 0; i < 1000; i++ {
+		engine.ProcessOrder(models.NewOrder(fmt.Sprintf("bid-%d", i), symbol, models.Buy, models.Limit, int64(500+i%100), 1))
+		engine.ProcessOrder(models.NewOrder(fmt.Sprintf("ask-%d", i), symbol, models.Sell, models.Limit, int64(700+i%100), 1))
+	}
+
+	var seq atomic.Int64
+	recorder := newLatencyRecorder()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		samples := make([]time.Duration, 0, 1024)
+		for pb.Next() {
+			n := seq.Add(1)
+			start := time.Now()
+			if n%4 == 0 {
+				// Writers rest orders away from the spread so the book keeps its shape.
+				engine.ProcessOrder(models.NewOrder(strconv.FormatInt(n, 10), symbol, models.Buy, models.Limit, 500+n%100, 1))
+			} else {
+				engine.GetOrderBookDepth(symbol, 10)
+			}
+			samples = append(samples, time.Since(start))
+		}
+		recorder.add(samples)
+	})
+
+	recorder.report(b)
+}
+
+// latencyRecorder gathers per-goroutine latency samples so benchmarks can
+// report a p99 alongside ns/op.
+type latencyRecorder struct {
+	mu      sync.Mutex
+	samples []time.Duration
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{}
+}
+
+func (r *latencyRecorder) add(samples []time.Duration) {
+	r.mu.Lock()
+	r.samples = append(r.samples, samples...)
+	r.mu.Unlock()
+}
+
+func (r *latencyRecorder) report(b *testing.B) {
+	if len(r.samples) == 0 {
+		return
+	}
+	slices.Sort(r.samples)
+	idx := int(math.Ceil(float64(len(r.samples))*0.99)) - 1
+	b.ReportMetric(float64(r.samples[idx].Nanoseconds()), "p99-ns")
+}
+
+func TestImportExportOrders(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+	engine.ProcessOrder(models.NewOrder("live", "BTCUSD", models.Sell, models.Limit, 105, 5))
+
+	imported := func(id string, side models.Side, price, qty, ts int64) *models.Order {
+		order := models.NewOrder(id, "BTCUSD", side, models.Limit, price, qty)
+		order.Timestamp = ts
+		return order
+	}
+
+	// Crossing the book or reusing an ID refuses the whole file
+	problems := engine.ImportOrders([]*models.Order{
+		imported("b1", models.Buy, 100, 5, 1),
+		imported("b2", models.Buy, 106, 5, 2),
+		imported("live", models.Sell, 110, 5, 3),
+	}, false)
+	if assert.Len(t, problems, 1) {
+		assert.Equal(t, 2, problems[0].Index)
+		assert.Equal(t, "duplicate order id", problems[0].Error)
+	}
+	problems = engine.ImportOrders([]*models.Order{
+		imported("b1", models.Buy, 100, 5, 1),
+		imported("b2", models.Buy, 106, 5, 2),
+	}, false)
+	if assert.Len(t, problems, 1) {
+		assert.Equal(t, 1, problems[0].Index)
+		assert.Contains(t, problems[0].Error, "crossed book")
+	}
+	_, err := engine.GetOrder("b1")
+	assert.Error(t, err)
+
+	// A dry run checks without touching the book
+	orders := []*models.Order{
+		imported("late", models.Buy, 100, 5, 20),
+		imported("early", models.Buy, 100, 3, 10),
+		imported("ask", models.Sell, 106, 2, 15),
+	}
+	assert.Empty(t, engine.ImportOrders(orders, true))
+	_, err = engine.GetOrder("early")
+	assert.Error(t, err)
+
+	// Priority follows the original timestamps, not the file order
+	assert.Empty(t, engine.ImportOrders(orders, false))
+	ob := engine.getOrderBook("BTCUSD")
+	assert.Equal(t, "early", ob.GetBestBid().ID)
+	assert.Equal(t, models.Accepted, orders[0].Status)
+
+	exported := engine.ExportOrders("BTCUSD")
+	ids := make([]string, len(exported))
+	for i, order := range exported {
+		ids[i] = order.ID
+	}
+	assert.Equal(t, []string{"early", "late", "live", "ask"}, ids)
+
+	// Exported orders recreate the book in another engine
+	other := NewEngine(metrics.NewMetrics())
+	copies := make([]*models.Order, len(exported))
+	for i := range exported {
+		copies[i] = imported(exported[i].ID, exported[i].Side, exported[i].Price, exported[i].RemainingQuantity, exported[i].Timestamp)
+	}
+	assert.Empty(t, other.ImportOrders(copies, false))
+	result, err := other.ProcessOrder(models.NewOrder("sweep", "BTCUSD", models.Sell, models.Limit, 100, 8))
+	assert.NoError(t, err)
+	if assert.Len(t, result.Trades, 2) {
+		assert.Equal(t, "early", result.Trades[0].BuyerOrderID)
+		assert.Equal(t, "late", result.Trades[1].BuyerOrderID)
+	}
+}
+
+func TestSymbolConfigs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "symbols.json")
+	os.WriteFile(path, []byte(`{
+		"BTCUSD": {"policy": "FIFO", "order_types": ["LIMIT"], "price_band_bps": 500,
+			"sweep_limit": {"max_levels": 2, "action": "REJECT"}, "speed_bump": "0s"},
+		"ETHUSD": {"default_time_in_force": "IOC"}
+	}`), 0644)
+	configs, err := ReadSymbolConfigs(path)
+	assert.NoError(t, err)
+	engine := NewEngine(metrics.NewMetrics())
+	assert.NoError(t, engine.ConfigureSymbols(configs))
+	assert.Equal(t, SweepReject, engine.SymbolConfigs()["BTCUSD"].SweepLimit.Action)
+
+	_, err = engine.ProcessOrder(models.NewOrder("m1", "BTCUSD", models.Buy, models.Market, 0, 1))
+	assert.EqualError(t, err, "invalid type: MARKET orders are not accepted for BTCUSD")
+
+	// The band only applies once the symbol has traded
+	engine.ProcessOrder(models.NewOrder("s1", "BTCUSD", models.Sell, models.Limit, 1000, 1))
+	engine.ProcessOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 1000, 1))
+	_, err = engine.ProcessOrder(models.NewOrder("s2", "BTCUSD", models.Sell, models.Limit, 1051, 1))
+	assert.ErrorContains(t, err, "price band")
+	_, err = engine.ProcessOrder(models.NewOrder("s3", "BTCUSD", models.Sell, models.Limit, 1050, 1))
+	assert.NoError(t, err)
+
+	order := models.NewOrder("e1", "ETHUSD", models.Buy, models.Limit, 100, 1)
+	engine.ProcessOrder(order)
+	assert.Equal(t, models.IOC, order.TimeInForce)
+
+	// An invalid reload changes nothing
+	err = engine.ConfigureSymbols(map[string]SymbolConfig{"BTCUSD": {PriceBandBps: -1}})
+	assert.ErrorContains(t, err, "BTCUSD: invalid price band")
+	assert.Len(t, engine.SymbolConfigs(), 2)
+
+	// Symbols left out of a reload go back to the defaults
+	assert.NoError(t, engine.ConfigureSymbols(map[string]SymbolConfig{"ETHUSD": {}}))
+	_, err = engine.ProcessOrder(models.NewOrder("s4", "BTCUSD", models.Sell, models.Limit, 2000, 1))
+	assert.NoError(t, err)
+	_, err = engine.ProcessOrder(models.NewOrder("m2", "BTCUSD", models.Buy, models.Market, 0, 1))
+	assert.NoError(t, err)
+
+	_, err = ReadSymbolConfigs(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+	os.WriteFile(path, []byte(`{"BTCUSD": {"policy": "PRO_RATA"}}`), 0644)
+	_, err = ReadSymbolConfigs(path)
+	assert.ErrorContains(t, err, "unsupported matching policy")
+}
+
+func TestSymbolInfo(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+	assert.NoError(t, engine.ConfigureLadder("LADDER", LadderConfig{MinPrice: 100, MaxPrice: 200, TickSize: 5}))
+	assert.NoError(t, engine.ConfigureSymbols(map[string]SymbolConfig{
+		"BTCUSD": {TickSize: 10, LotSize: 100, OrderTypes: []models.OrderType{models.Market}},
+	}))
+	engine.ProcessOrder(models.NewOrder("e1", "ETHUSD", models.Buy, models.Limit, 100, 1))
+
+	assert.Equal(t, []string{"BTCUSD", "ETHUSD", "LADDER"}, engine.Symbols())
+	_, ok := engine.SymbolInfo("NOPE")
+	assert.False(t, ok)
+
+	info, ok := engine.SymbolInfo("LADDER")
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), info.TickSize)
+	assert.Equal(t, int64(100), info.MinPrice)
+	assert.Equal(t, models.GTC, info.DefaultTimeInForce)
+	assert.Len(t, info.TimeInForces, 5)
+
+	info, _ = engine.SymbolInfo("BTCUSD")
+	assert.Equal(t, int64(10), info.TickSize)
+	assert.Equal(t, int64(100), info.LotSize)
+	assert.Equal(t, []models.OrderType{models.Market}, info.OrderTypes)
+	assert.Equal(t, []models.TimeInForce{models.IOC, models.FOK}, info.TimeInForces)
+
+	_, err := engine.ProcessOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Market, 0, 150))
+	assert.ErrorContains(t, err, "lot size 100")
+}
+
+func TestPublishFixing(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+	engine.ProcessOrder(models.NewOrder("ask", "BTCUSD", models.Sell, models.Limit, 100, 5))
+
+	// Fixing orders wait instead of trading with the continuous book
+	b1 := models.NewOrder("b1", "BTCUSD", models.Buy, models.AtFixing, 0, 4)
+	result, err := engine.ProcessOrder(b1)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Trades)
+	assert.Equal(t, models.Accepted, b1.Status)
+
+	s1 := models.NewOrder("s1", "BTCUSD", models.Sell, models.AtFixing, 0, 3)
+	engine.ProcessOrder(s1)
+	b2 := models.NewOrder("b2", "BTCUSD", models.Buy, models.AtFixing, 0, 2)
+	engine.ProcessOrder(b2)
+	s2 := models.NewOrder("s2", "BTCUSD", models.Sell, models.AtFixing, 0, 2)
+	engine.ProcessOrder(s2)
+	gone := models.NewOrder("gone", "BTCUSD", models.Sell, models.AtFixing, 0, 9)
+	engine.ProcessOrder(gone)
+	engine.CancelOrder("gone")
+
+	_, err = engine.ProcessOrder(models.NewOrder("bad", "BTCUSD", models.Buy, models.AtFixing, 100, 1))
+	assert.EqualError(t, err, "invalid price: fixing orders trade at the fixing price")
+
+	fixing, err := engine.PublishFixing("BTCUSD", 105)
+	assert.NoError(t, err)
+	assert.Len(t, fixing.Trades, 3)
+	for _, trade := range fixing.Trades {
+		assert.Equal(t, int64(105), trade.Price)
+	}
+	assert.Equal(t, "b1", fixing.Trades[0].MakerOrderID)
+	assert.Equal(t, int64(3), fixing.Trades[0].Quantity)
+	assert.Equal(t, "s2", fixing.Trades[1].SellerOrderID)
+	assert.Equal(t, int64(1), fixing.Trades[1].Quantity)
+	assert.Equal(t, "b2", fixing.Trades[2].BuyerOrderID)
+	assert.Equal(t, int64(1), fixing.Trades[2].Quantity)
+
+	// b2 only got 1 of 2; its remainder doesn't wait for the next fixing
+	assert.Equal(t, []*models.Order{b2}, fixing.Cancelled)
+	assert.Equal(t, models.Filled, b1.Status)
+	assert.Equal(t, models.Cancelled, b2.Status)
+	assert.Equal(t, int64(5), engine.getOrderBook("BTCUSD").GetBestAsk().RemainingQuantity)
+
+	fixing, _ = engine.PublishFixing("BTCUSD", 110)
+	assert.Empty(t, fixing.Trades)
+	_, err = engine.PublishFixing("ETHUSD", 100)
+	assert.EqualError(t, err, "order book not found")
+}
+
+func TestMinQuantity(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+	engine.ProcessOrder(models.NewOrder("a1", "BTCUSD", models.Sell, models.Limit, 100, 3))
+	engine.ProcessOrder(models.NewOrder("a2", "BTCUSD", models.Sell, models.Limit, 102, 4))
+
+	order := models.NewOrder("ioc", "BTCUSD", models.Buy, models.Limit, 100, 10)
+	order.TimeInForce = models.IOC
+	order.MinQuantity = 5
+	_, err := engine.ProcessOrder(order)
+	assert.EqualError(t, err, "minimum quantity: only 3 shares available immediately, requested at least 5")
+	_, err = engine.SimulateOrder(order)
+	assert.Error(t, err)
+
+	// A resting order that wouldn't trade at all just posts
+	order = models.NewOrder("post", "BTCUSD", models.Buy, models.Limit, 99, 10)
+	order.MinQuantity = 5
+	result, err := engine.ProcessOrder(order)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Trades)
+	assert.Equal(t, models.Accepted, order.Status)
+
+	// but one that would trade less than its minimum is rejected
+	order = models.NewOrder("short", "BTCUSD", models.Buy, models.Limit, 101, 10)
+	order.MinQuantity = 5
+	_, err = engine.ProcessOrder(order)
+	assert.Error(t, err)
+
+	order = models.NewOrder("enough", "BTCUSD", models.Buy, models.Limit, 102, 10)
+	order.MinQuantity = 5
+	result, err = engine.ProcessOrder(order)
+	assert.NoError(t, err)
+	assert.Len(t, result.Trades, 2)
+	assert.Equal(t, int64(7), order.FilledQuantity)
+
+	order = models.NewOrder("big", "BTCUSD", models.Buy, models.Limit, 102, 10)
+	order.MinQuantity = 11
+	_, err = engine.ProcessOrder(order)
+	assert.EqualError(t, err, "invalid min quantity: must be between 0 and the order quantity")
+}
+
+func TestDispatch(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+	newOrder := func(id, symbol, account string, side models.Side, price int64) *models.Order {
+		order := models.NewOrder(id, symbol, side, models.Limit, price, 10)
+		order.Account = account
+		return order
+	}
+
+	for _, order := range []*models.Order{
+		newOrder("a1", "BTCUSD", "alice", models.Buy, 100),
+		newOrder("b1", "BTCUSD", "bob", models.Buy, 99),
+		newOrder("a2", "ETHUSD", "alice", models.Sell, 50),
+		newOrder("a3", "BTCUSD", "alice", models.Sell, 105),
+	} {
+		result, err := engine.Dispatch(NewOrderCommand{Order: order})
+		assert.NoError(t, err)
+		assert.Equal(t, order, result.Match.Order)
+	}
+
+	result, err := engine.Dispatch(AmendCommand{OrderID: "b1", NewQuantity: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), result.Orders[0].RemainingQuantity)
+	_, err = engine.Dispatch(AmendCommand{OrderID: "b1"})
+	assert.EqualError(t, err, "invalid amendment: exactly one of reduce_by or new_quantity is required")
+
+	result, err = engine.Dispatch(MassCancelCommand{Account: "alice"})
+	assert.NoError(t, err)
+	var ids []string
+	for _, order := range result.Orders {
+		ids = append(ids, order.ID)
+		assert.Equal(t, models.Cancelled, order.Status)
+	}
+	assert.Equal(t, []string{"a1", "a3", "a2"}, ids)
+	assert.Equal(t, "b1", engine.getOrderBook("BTCUSD").GetBestBid().ID)
+	assert.Nil(t, engine.getOrderBook("BTCUSD").GetBestAsk())
+
+	result, err = engine.Dispatch(CancelCommand{OrderID: "b1"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Cancelled, result.Orders[0].Status)
+}
+
+func TestBatchAmend(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+	for _, order := range []*models.Order{
+		models.NewOrder("bid1", "BTCUSD", models.Buy, models.Limit, 99, 10),
+		models.NewOrder("bid2", "BTCUSD", models.Buy, models.Limit, 98, 10),
+		models.NewOrder("ask1", "BTCUSD", models.Sell, models.Limit, 100, 10),
+		models.NewOrder("ask2", "BTCUSD", models.Sell, models.Limit, 101, 10),
+		models.NewOrder("other", "ETHUSD", models.Buy, models.Limit, 50, 10),
+		models.NewOrder("taker", "ETHUSD", models.Sell, models.Limit, 55, 5),
+	} {
+		engine.ProcessOrder(order)
+	}
+
+	// Shift the BTCUSD ladder up two ticks: bid1 moves onto ask1's old price,
+	// which only works because ask1 moves first
+	result, err := engine.Dispatch(BatchAmendCommand{Amendments: []BatchAmendment{
+		{OrderID: "bid1", Price: 101, ReplacementID: "bid1b"},
+		{OrderID: "bid2", Price: 100, ReduceBy: 4, ReplacementID: "bid2b"},
+		{OrderID: "ask1", Price: 102, ReplacementID: "ask1b"},
+		{OrderID: "ask2", NewQuantity: 7},
+		{OrderID: "other", Price: 56, ReplacementID: "otherb"},
+		{OrderID: "missing", Price: 1, ReplacementID: "x"},
+	}})
+	assert.NoError(t, err)
+	amends := result.Amends
+	assert.Len(t, amends, 6)
+
+	// ask2 is reduced in place, so bid1b trades its remaining 7 at 101
+	assert.NoError(t, amends[3].Err)
+	assert.Equal(t, "ask2", amends[3].Order.ID)
+	assert.NoError(t, amends[0].Err)
+	assert.Equal(t, "bid1b", amends[0].Order.ID)
+	assert.Len(t, amends[0].Match.Trades, 1)
+	assert.Equal(t, int64(7), amends[0].Match.Trades[0].Quantity)
+	assert.Equal(t, "ask2", amends[0].Match.Trades[0].MakerOrderID)
+
+	assert.Equal(t, int64(6), amends[1].Order.RemainingQuantity)
+	bid1, _ := engine.GetOrder("bid1")
+	assert.Equal(t, models.Cancelled, bid1.Status)
+	assert.Equal(t, int64(101), engine.getOrderBook("BTCUSD").GetBestBid().Price)
+	assert.Equal(t, int64(102), engine.getOrderBook("BTCUSD").GetBestAsk().Price)
+
+	// Repricing can cross like any new order
+	assert.NoError(t, amends[4].Err)
+	assert.Equal(t, models.PartialFill, amends[4].Order.Status)
+	assert.Equal(t, int64(55), amends[4].Match.Trades[0].Price)
+	assert.EqualError(t, amends[5].Err, "order not found")
+
+	// One bad amendment stops the rest of its symbol's
+	result, _ = engine.Dispatch(BatchAmendCommand{Amendments: []BatchAmendment{
+		{OrderID: "bid2b", ReduceBy: 1},
+		{OrderID: "ask1b", ReduceBy: 10},
+	}})
+	assert.EqualError(t, result.Amends[0].Err, "amendment not applied: another amendment for BTCUSD is invalid")
+	assert.Error(t, result.Amends[1].Err)
+	bid2b, _ := engine.GetOrder("bid2b")
+	assert.Equal(t, int64(6), bid2b.RemainingQuantity)
+
+	// DAY orders get a fresh session close; GTD orders keep their expiry
+	day := models.NewOrder("day", "BTCUSD", models.Buy, models.Limit, 90, 1)
+	day.TimeInForce = models.DAY
+	gtd := models.NewOrder("gtd", "BTCUSD", models.Buy, models.Limit, 90, 1)
+	gtd.TimeInForce = models.GTD
+	gtd.ExpireAt = time.Now().Add(time.Hour).UnixNano()
+	engine.ProcessOrder(day)
+	engine.ProcessOrder(gtd)
+	result, err = engine.Dispatch(BatchAmendCommand{Amendments: []BatchAmendment{
+		{OrderID: "day", Price: 91, ReplacementID: "day2"},
+		{OrderID: "gtd", Price: 91, ReplacementID: "gtd2"},
+	}})
+	assert.NoError(t, err)
+	for _, amend := range result.Amends {
+		assert.NoError(t, amend.Err)
+	}
+	day2, _ := engine.GetOrder("day2")
+	assert.Equal(t, day.ExpireAt, day2.ExpireAt)
+	gtd2, _ := engine.GetOrder("gtd2")
+	assert.Equal(t, gtd.ExpireAt, gtd2.ExpireAt)
+}
+
+func TestShift(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+	assert.NoError(t, engine.ConfigureSymbols(map[string]SymbolConfig{
+		"BTCUSD": {TickSize: 10, PriceBandBps: 200},
+	}))
+	newOrder := func(id, account string, side models.Side, price int64) *models.Order {
+		order := models.NewOrder(id, "BTCUSD", side, models.Limit, price, 5)
+		order.Account = account
+		engine.ProcessOrder(order)
+		return order
+	}
+	newOrder("s0", "other", models.Sell, 1000)
+	newOrder("b0", "other", models.Buy, 1000) // last price 1000, band ±20
+	newOrder("mm1", "mm", models.Buy, 990)
+	newOrder("mm2", "mm", models.Sell, 1020)
+	newOrder("mm3", "mm", models.Buy, 1000)
+	newOrder("b1", "other", models.Buy, 990)
+
+	n := 0
+	newID := func() string {
+		n++
+		return fmt.Sprintf("r%d", n)
+	}
+	_, err := engine.Dispatch(ShiftCommand{Symbol: "BTCUSD", Account: "mm", NewID: newID})
+	assert.EqualError(t, err, "invalid shift: ticks must not be zero")
+
+	// mm2 would leave the band, so nothing moves
+	result, err := engine.Dispatch(ShiftCommand{Symbol: "BTCUSD", Account: "mm", Ticks: 1, NewID: newID})
+	assert.NoError(t, err)
+	assert.ErrorContains(t, result.Amends[1].Err, "price band")
+	assert.Equal(t, models.Accepted, engine.getOrderBook("BTCUSD").Orders["mm1"].Status)
+
+	// Pegged orders are left to follow their peg
+	pegged := models.NewOrder("mm4", "BTCUSD", models.Buy, models.Limit, 1000, 5)
+	pegged.Account = "mm"
+	pegged.PegType = models.PegPrimary
+	pegged.PegOffset = 1
+	_, err = engine.ProcessOrder(pegged)
+	assert.NoError(t, err)
+
+	result, err = engine.Dispatch(ShiftCommand{Symbol: "BTCUSD", Account: "mm", Ticks: -1, NewID: newID})
+	assert.NoError(t, err)
+	var ids []string
+	for _, amend := range result.Amends {
+		assert.NoError(t, amend.Err)
+		ids = append(ids, amend.OrderID+">"+amend.Order.ID)
+	}
+	assert.Equal(t, []string{"mm1>r4", "mm2>r5", "mm3>r6"}, ids)
+
+	// Replacements keep their relative priority and queue behind b1 at 990
+	level, _ := engine.getOrderBook("BTCUSD").Bids.Get(990)
+	assert.Equal(t, "b1", level.Orders[0].ID)
+	assert.Equal(t, "r6", level.Orders[1].ID)
+	assert.Equal(t, int64(980), engine.getOrderBook("BTCUSD").Orders["r4"].Price)
+	assert.Equal(t, int64(1010), engine.getOrderBook("BTCUSD").Orders["r5"].Price)
+	assert.Equal(t, int64(980), engine.getOrderBook("BTCUSD").Orders["mm4"].Price)
+
+	// A replacement the engine would refuse leaves the whole ladder in place
+	engine = NewEngine(metrics.NewMetrics())
+	postOnly := models.NewOrder("p1", "BTCUSD", models.Buy, models.Limit, 100, 5)
+	postOnly.Account = "mm"
+	postOnly.PostOnly = true
+	engine.ProcessOrder(postOnly)
+	newOrder("p2", "mm", models.Buy, 99)
+	newOrder("ask", "other", models.Sell, 102)
+	result, err = engine.Dispatch(ShiftCommand{Symbol: "BTCUSD", Account: "mm", Ticks: 2, NewID: newID})
+	assert.NoError(t, err)
+	assert.ErrorContains(t, result.Amends[0].Err, "post only")
+	assert.EqualError(t, result.Amends[1].Err, "amendment not applied: another amendment for BTCUSD was refused")
+	book := engine.getOrderBook("BTCUSD")
+	for _, id := range []string{"p1", "p2"} {
+		order, _ := engine.GetOrder(id)
+		assert.Equal(t, models.Accepted, order.Status)
+		assert.Contains(t, book.Orders, id)
+	}
+	assert.Equal(t, int64(100), book.GetBestBid().Price)
+	assert.Len(t, book.Orders, 3)
+}
+
+func TestWarmUp(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+	assert.NoError(t, engine.ConfigureLadder("ETHUSD", LadderConfig{MinPrice: 1, MaxPrice: 1000, TickSize: 1}))
+
+	engine.WarmUp(WarmUpConfig{Symbols: []string{"BTCUSD"}, OrdersPerBook: 100, SyntheticOrders: 500})
+	_, ok := engine.SymbolInfo("BTCUSD")
+	assert.True(t, ok)
+	assert.NotNil(t, engine.OrderBooks["ETHUSD"].ladder)
+	assert.NotContains(t, engine.OrderBooks, "WARMUP")
+	assert.Zero(t, m.OrdersReceived.Load())
+
+	_, err := engine.ProcessOrder(models.NewOrder("b1", "ETHUSD", models.Buy, models.Limit, 100, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), engine.OrderBooks["ETHUSD"].Orders["b1"].Priority.Sequence)
+}
+
+func TestSessionClose(t *testing.T) {
+	_, err := ParseSessionClose("4pm", "UTC")
+	assert.Error(t, err)
+	_, err = ParseSessionClose("16:00", "Nowhere/Special")
+	assert.Error(t, err)
+	session, err := ParseSessionClose("16:00", "America/New_York")
+	assert.NoError(t, err)
+	ny := session.Location
+
+	// The close follows local time across the switch to daylight saving
+	after := time.Date(2026, 3, 7, 17, 0, 0, 0, ny)
+	assert.Equal(t, time.Date(2026, 3, 8, 16, 0, 0, 0, ny), session.next(after))
+	assert.Equal(t, 22*time.Hour, session.next(after).Sub(after))
+	before := time.Date(2026, 3, 9, 15, 59, 0, 0, ny)
+	assert.Equal(t, time.Date(2026, 3, 9, 16, 0, 0, 0, ny), session.next(before))
+	assert.Equal(t, time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC), SessionClose{}.next(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)))
+
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+	engine.SetSessionClose(session)
+	day := models.NewOrder("day", "BTCUSD", models.Buy, models.Limit, 100, 5)
+	day.TimeInForce = models.DAY
+	_, err = engine.ProcessOrder(day)
+	assert.NoError(t, err)
+	expiry := time.Unix(0, day.ExpireAt).In(ny)
+	assert.Equal(t, 16, expiry.Hour())
+	assert.Zero(t, expiry.Minute())
+	assert.WithinDuration(t, time.Now(), expiry, 25*time.Hour)
+
+	info, _ := engine.SymbolInfo("BTCUSD")
+	assert.Equal(t, SessionSchedule{Continuous: true, DayOrderExpiry: "16:00", TimeZone: "America/New_York"}, info.Session)
+
+	assert.Equal(t, 1, engine.ExpireOrders(expiry))
+	assert.Equal(t, models.Cancelled, day.Status)
+	assert.Equal(t, int64(1), m.OrdersCancelled.Load())
+	order, err := engine.GetOrder("day")
+	assert.NoError(t, err)
+	assert.Equal(t, models.Cancelled, order.Status)
+	assert.Equal(t, SessionCloseCancelReason, order.CancelReason)
+}
+
+func TestStopMarketOrders(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+	stop := func(id string, side models.Side, stopPrice, quantity int64) *models.Order {
+		order := models.NewOrder(id, "BTCUSD", side, models.StopMarket, 0, quantity)
+		order.StopPrice = stopPrice
+		return order
+	}
+
+	_, err := engine.ProcessOrder(stop("bad", models.Buy, 0, 5))
+	assert.ErrorContains(t, err, "invalid stop price")
+	priced := stop("priced", models.Buy, 101, 5)
+	priced.Price = 101
+	_, err = engine.ProcessOrder(priced)
+	assert.ErrorContains(t, err, "invalid price")
+
+	engine.ProcessOrder(models.NewOrder("s1", "BTCUSD", models.Sell, models.Limit, 101, 10))
+	engine.ProcessOrder(models.NewOrder("s2", "BTCUSD", models.Sell, models.Limit, 102, 10))
+	buyStop := stop("buy-stop", models.Buy, 101, 5)
+	_, err = engine.ProcessOrder(buyStop)
+	assert.NoError(t, err)
+	assert.Equal(t, models.Accepted, buyStop.Status)
+	assert.NotContains(t, engine.OrderBooks["BTCUSD"].Orders, "buy-stop")
+
+	// The trade at 101 triggers the stop once the incoming order is done
+	result, err := engine.ProcessOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 101, 2))
+	assert.NoError(t, err)
+	assert.Len(t, result.Trades, 1)
+	assert.Equal(t, models.Filled, buyStop.Status)
+	assert.Equal(t, models.IOC, buyStop.TimeInForce)
+	assert.NotZero(t, buyStop.TriggeredAt)
+	assert.Equal(t, int64(3), engine.OrderBooks["BTCUSD"].Orders["s1"].RemainingQuantity)
+	assert.Equal(t, int64(2), m.TradesExecuted.Load())
+
+	// A stop the last trade has already reached is refused
+	late := stop("late", models.Buy, 100, 1)
+	_, err = engine.ProcessOrder(late)
+	assert.ErrorContains(t, err, "invalid stop price")
+	assert.Equal(t, models.Rejected, late.Status)
+
+	cancelled := stop("cancelled", models.Sell, 50, 1)
+	engine.ProcessOrder(cancelled)
+	_, err = engine.CancelOrder("cancelled")
+	assert.NoError(t, err)
+	assert.Equal(t, models.Cancelled, cancelled.Status)
+	assert.Empty(t, engine.OrderBooks["BTCUSD"].stopSells)
+
+	// Triggered stops trade in arrival order, and what they can't fill is
+	// cancelled
+	engine.ProcessOrder(models.NewOrder("b95", "BTCUSD", models.Buy, models.Limit, 95, 1))
+	engine.ProcessOrder(models.NewOrder("b94", "BTCUSD", models.Buy, models.Limit, 94, 5))
+	st1 := stop("st1", models.Sell, 96, 1)
+	st2 := stop("st2", models.Sell, 95, 3)
+	st3 := stop("st3", models.Sell, 94, 5)
+	for _, order := range []*models.Order{st1, st2, st3} {
+		_, err = engine.ProcessOrder(order)
+		assert.NoError(t, err)
+	}
+	engine.ProcessOrder(models.NewOrder("s95", "BTCUSD", models.Sell, models.Limit, 95, 1))
+	assert.Equal(t, models.Filled, st1.Status)
+	assert.Equal(t, models.Filled, st2.Status)
+	assert.Equal(t, models.Cancelled, st3.Status)
+	assert.Equal(t, int64(1), st3.FilledQuantity)
+	assert.LessOrEqual(t, st1.CompletedAt, st2.CompletedAt)
+	assert.True(t, engine.OrderBooks["BTCUSD"].Bids.Empty())
+}
+
+func TestStopCascade(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+	assert.NoError(t, engine.ConfigureSymbols(map[string]SymbolConfig{"BTCUSD": {MaxTriggeredStops: 2}}))
+	for _, price := range []int64{99, 98, 97, 96, 95} {
+		engine.ProcessOrder(models.NewOrder(fmt.Sprintf("b%d", price), "BTCUSD", models.Buy, models.Limit, price, 1))
+	}
+	// Each stop's trade triggers the next one down
+	var stops []*models.Order
+	for _, price := range []int64{99, 98, 97} {
+		order := models.NewOrder(fmt.Sprintf("st%d", price), "BTCUSD", models.Sell, models.StopMarket, 0, 1)
+		order.StopPrice = price
+		_, err := engine.ProcessOrder(order)
+		assert.NoError(t, err)
+		stops = append(stops, order)
+	}
+
+	engine.ProcessOrder(models.NewOrder("s99", "BTCUSD", models.Sell, models.Limit, 99, 1))
+	assert.Equal(t, models.Filled, stops[0].Status)
+	assert.Equal(t, models.Filled, stops[1].Status)
+	// The third is triggered, but over the limit, so it waits for the next trade
+	assert.Equal(t, models.Accepted, stops[2].Status)
+	assert.Contains(t, engine.OrderBooks["BTCUSD"].stopSells, stops[2])
+
+	engine.ProcessOrder(models.NewOrder("s96", "BTCUSD", models.Sell, models.Limit, 96, 1))
+	assert.Equal(t, models.Filled, stops[2].Status)
+	assert.Empty(t, engine.OrderBooks["BTCUSD"].stopSells)
+	assert.True(t, engine.OrderBooks["BTCUSD"].Bids.Empty())
+
+	assert.Error(t, SymbolConfig{MaxTriggeredStops: -1}.Validate())
+}
+
+func TestShadowChecks(t *testing.T) {
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+	engine.AddShadowCheck("clock-skew", NewClockGuard(time.Second, false))
+	restamp := NewClockGuard(time.Second, true)
+	engine.AddShadowCheck("restamp", restamp)
+
+	// The shadowed checks would reject and restamp the order, but do neither
+	order := models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 100, 5)
+	order.Account = "alice"
+	order.TransactTime = time.Now().Add(-time.Minute).UnixNano()
+	transactTime := order.TransactTime
+	_, err := engine.ProcessOrder(order)
+	assert.NoError(t, err)
+	assert.Equal(t, models.Accepted, order.Status)
+	assert.Equal(t, transactTime, order.TransactTime)
+	assert.Zero(t, order.ClockSkew)
+	assert.Equal(t, int64(1), restamp.Restamped())
+
+	_, err = engine.ProcessOrder(models.NewOrder("b2", "BTCUSD", models.Buy, models.Limit, 100, 5))
+	assert.NoError(t, err)
+
+	report := m.ShadowRejections()
+	assert.Equal(t, map[string]int64{"clock-skew": 1}, report.Total)
+	assert.Equal(t, map[string]int64{"clock-skew": 1}, report.ByAccount["alice"])
+	assert.Empty(t, m.Rejections().Total)
+}
+
+func TestIcebergOrders(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+
+	iceberg := models.NewOrder("ice", "BTCUSD", models.Sell, models.Limit, 100, 10)
+	iceberg.DisplayQuantity = 3
+	_, err := engine.ProcessOrder(iceberg)
+	assert.NoError(t, err)
+	_, err = engine.ProcessOrder(models.NewOrder("s2", "BTCUSD", models.Sell, models.Limit, 100, 2))
+	assert.NoError(t, err)
+
+	// Depth shows the visible slice only
+	depth, _ := engine.GetOrderBookDepth("BTCUSD", 0)
+	assert.Equal(t, []PriceLevelData{{Price: 100, Quantity: 5}}, depth.Asks)
+
+	// The slice fills, and the next one queues behind s2
+	result, err := engine.ProcessOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 100, 4))
+	assert.NoError(t, err)
+	assert.Len(t, result.Trades, 2)
+	assert.Equal(t, "ice", result.Trades[0].MakerOrderID)
+	assert.Equal(t, int64(3), result.Trades[0].Quantity)
+	assert.Equal(t, "s2", result.Trades[1].MakerOrderID)
+	assert.Equal(t, int64(1), result.Trades[1].Quantity)
+	assert.Equal(t, int64(7), iceberg.RemainingQuantity)
+	assert.Equal(t, int64(3), iceberg.VisibleQuantity)
+
+	depth, _ = engine.GetOrderBookDepth("BTCUSD", 0)
+	assert.Equal(t, []PriceLevelData{{Price: 100, Quantity: 4}}, depth.Asks)
+	pos, err := engine.GetQueuePosition("ice")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pos.OrdersAhead)
+	assert.Equal(t, int64(1), pos.QuantityAhead)
+	assert.Equal(t, int64(4), pos.LevelQuantity)
+
+	// Hidden quantity still trades, and is reduced first
+	ob := engine.getOrderBook("BTCUSD")
+	assert.Equal(t, int64(8), ob.CalculateLiquidity(models.Buy, 100))
+	_, err = engine.ReduceOrder("ice", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), iceberg.VisibleQuantity)
+	depth, _ = engine.GetOrderBookDepth("BTCUSD", 0)
+	assert.Equal(t, []PriceLevelData{{Price: 100, Quantity: 3}}, depth.Asks)
+
+	result, err = engine.ProcessOrder(models.NewOrder("b2", "BTCUSD", models.Buy, models.Limit, 100, 3))
+	assert.NoError(t, err)
+	assert.Len(t, result.Trades, 2)
+	assert.Equal(t, models.Filled, iceberg.Status)
+	assert.True(t, ob.Asks.Empty())
+
+	ioc := models.NewOrder("ice-ioc", "BTCUSD", models.Sell, models.Limit, 100, 10)
+	ioc.DisplayQuantity = 3
+	ioc.TimeInForce = models.IOC
+	_, err = engine.ProcessOrder(ioc)
+	assert.ErrorContains(t, err, "invalid display quantity")
+
+	tooLarge := models.NewOrder("ice-large", "BTCUSD", models.Sell, models.Limit, 100, 10)
+	tooLarge.DisplayQuantity = 11
+	_, err = engine.ProcessOrder(tooLarge)
+	assert.ErrorContains(t, err, "invalid display quantity")
+}
+
+func TestHiddenOrders(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+
+	hidden := func(id string, price, quantity int64) *models.Order {
+		order := models.NewOrder(id, "BTCUSD", models.Sell, models.Limit, price, quantity)
+		order.Account = "dark"
+		order.Hidden = true
+		return order
+	}
+	_, err := engine.ProcessOrder(hidden("h1", 100, 5))
+	assert.NoError(t, err)
+	_, err = engine.ProcessOrder(hidden("h2", 101, 4))
+	assert.NoError(t, err)
+	shown := models.NewOrder("d1", "BTCUSD", models.Sell, models.Limit, 100, 3)
+	shown.Account = "lit"
+	_, err = engine.ProcessOrder(shown)
+	assert.NoError(t, err)
+
+	// Only the displayed order shows, and it queues ahead of the earlier hidden one
+	depth, _ := engine.GetOrderBookDepth("BTCUSD", 0)
+	assert.Equal(t, []PriceLevelData{{Price: 100, Quantity: 3}}, depth.Asks)
+	pos, err := engine.GetQueuePosition("h1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pos.OrdersAhead)
+	assert.Equal(t, 2, pos.LevelOrders)
+
+	// Queue positions don't give away hidden orders either
+	_, err = engine.ProcessOrder(hidden("h3", 100, 2))
+	assert.NoError(t, err)
+	pos, err = engine.GetQueuePosition("h3")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pos.OrdersAhead) // d1, not h1
+	assert.Equal(t, int64(3), pos.QuantityAhead)
+	assert.Equal(t, 2, pos.LevelOrders)
+	pos, err = engine.GetQueuePosition("d1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pos.LevelOrders)
+	_, err = engine.CancelOrder("h3")
+	assert.NoError(t, err)
+	snapshot := engine.QuoteSnapshots()[0]
+	assert.Equal(t, int64(100), snapshot.Top.BestAsk)
+	assert.NotContains(t, snapshot.Accounts, "dark")
+
+	result, err := engine.ProcessOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 100, 4))
+	assert.NoError(t, err)
+	assert.Len(t, result.Trades, 2)
+	assert.Equal(t, "d1", result.Trades[0].MakerOrderID)
+	assert.Equal(t, int64(3), result.Trades[0].Quantity)
+	assert.Equal(t, "h1", result.Trades[1].MakerOrderID)
+	assert.Equal(t, int64(1), result.Trades[1].Quantity)
+
+	// Only hidden orders are left, so the book shows no asks at all
+	depth, _ = engine.GetOrderBookDepth("BTCUSD", 0)
+	assert.Empty(t, depth.Asks)
+	assert.Zero(t, engine.QuoteSnapshots()[0].Top.BestAsk)
+
+	market := models.NewOrder("m1", "BTCUSD", models.Buy, models.Market, 0, 1)
+	market.Hidden = true
+	_, err = engine.ProcessOrder(market)
+	assert.ErrorContains(t, err, "invalid hidden order")
+}
+
+func TestPostOnlyOrders(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+
+	_, err := engine.ProcessOrder(models.NewOrder("s1", "BTCUSD", models.Sell, models.Limit, 100, 5))
+	assert.NoError(t, err)
+	_, err = engine.ProcessOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 95, 5))
+	assert.NoError(t, err)
+
+	postOnly := func(id string, side models.Side, price int64, reprice bool) *models.Order {
+		order := models.NewOrder(id, "BTCUSD", side, models.Limit, price, 2)
+		order.PostOnly = true
+		order.PostOnlyReprice = reprice
+		return order
+	}
+
+	// One that doesn't cross rests as usual
+	result, err := engine.ProcessOrder(postOnly("p1", models.Buy, 99, false))
+	assert.NoError(t, err)
+	assert.Empty(t, result.Trades)
+	assert.Equal(t, models.Accepted, result.Order.Status)
+
+	// One that would take is rejected, and never reaches the book
+	_, err = engine.ProcessOrder(postOnly("p2", models.Buy, 100, false))
+	assert.ErrorContains(t, err, "post only: order would take liquidity at 100")
+	_, ok := engine.AllOrders.Load("p2")
+	assert.False(t, ok)
+
+	// or is moved one tick behind the opposite best if it asks to be
+	result, err = engine.ProcessOrder(postOnly("p3", models.Sell, 90, true))
+	assert.NoError(t, err)
+	assert.Empty(t, result.Trades)
+	assert.Equal(t, int64(100), result.Order.Price)
+	depth, _ := engine.GetOrderBookDepth("BTCUSD", 0)
+	assert.Equal(t, PriceLevelData{Price: 100, Quantity: 7}, depth.Asks[0])
+	assert.Equal(t, PriceLevelData{Price: 99, Quantity: 2}, depth.Bids[0])
+
+	sim, err := engine.SimulateOrder(postOnly("p4", models.Buy, 101, false))
+	assert.Nil(t, sim)
+	assert.ErrorContains(t, err, "post only")
+
+	ioc := postOnly("p5", models.Buy, 90, false)
+	ioc.TimeInForce = models.IOC
+	_, err = engine.ProcessOrder(ioc)
+	assert.ErrorContains(t, err, "invalid post only")
+}
+
+func TestMarketSnapshot(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+
+	_, ok := engine.MarketSnapshot("BTCUSD", 0)
+	assert.False(t, ok)
+
+	for i, price := range []int64{101, 102, 103} {
+		_, err := engine.ProcessOrder(models.NewOrder(fmt.Sprintf("s%d", i), "BTCUSD", models.Sell, models.Limit, price, 5))
+		assert.NoError(t, err)
+	}
+	snapshot, ok := engine.MarketSnapshot("BTCUSD", 0)
+	assert.True(t, ok)
+	assert.Nil(t, snapshot.LastTrade)
+	assert.Equal(t, uint64(3), snapshot.Sequence)
+
+	_, err := engine.ProcessOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Market, 0, 7))
+	assert.NoError(t, err)
+
+	snapshot, ok = engine.MarketSnapshot("BTCUSD", 1)
+	assert.True(t, ok)
+	assert.Empty(t, snapshot.Bids)
+	assert.Equal(t, []PriceLevelData{{Price: 102, Quantity: 3}}, snapshot.Asks)
+	assert.Equal(t, int64(102), snapshot.LastTrade.Price)
+	assert.Equal(t, int64(2), snapshot.LastTrade.Quantity)
+	assert.Equal(t, models.Buy, snapshot.LastTrade.AggressorSide)
+	assert.Equal(t, int64(2), snapshot.Stats.Executions)
+	assert.Equal(t, int64(7), snapshot.Stats.Volume)
+}
+
+// amendRecorder records the orders the engine amends in place.
+type amendRecorder struct {
+	ids []string
+}
+
+func (r *amendRecorder) OrderAccepted(order *models.Order, top BookTop)                {}
+func (r *amendRecorder) OrderCancelled(order *models.Order, top BookTop)               {}
+func (r *amendRecorder) TradeExecuted(trade *models.Trade, taker, maker *models.Order) {}
+func (r *amendRecorder) OrderAmended(order *models.Order, top BookTop) {
+	r.ids = append(r.ids, order.ID)
+}
+
+func TestPeggedOrders(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+	amends := &amendRecorder{}
+	engine.AddEventListener(amends)
+
+	peg := func(id string, side models.Side, pegType models.PegType, limit int64) *models.Order {
+		order := models.NewOrder(id, "BTCUSD", side, models.Limit, limit, 2)
+		order.PegType = pegType
+		return order
+	}
+
+	// Nothing to peg to yet
+	_, err := engine.ProcessOrder(peg("m1", models.Buy, models.PegMidpoint, 200))
+	assert.ErrorContains(t, err, "insufficient liquidity")
+
+	_, err = engine.ProcessOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 100, 5))
+	assert.NoError(t, err)
+	_, err = engine.ProcessOrder(models.NewOrder("s1", "BTCUSD", models.Sell, models.Limit, 111, 5))
+	assert.NoError(t, err)
+
+	// An off-tick midpoint rounds away from the opposite side
+	result, err := engine.ProcessOrder(peg("m1", models.Buy, models.PegMidpoint, 200))
+	assert.NoError(t, err)
+	assert.Empty(t, result.Trades)
+	assert.Equal(t, int64(105), result.Order.Price)
+	assert.Equal(t, int64(200), result.Order.PegLimit)
+	primary := peg("p1", models.Sell, models.PegPrimary, 1)
+	primary.PegOffset = 1
+	result, err = engine.ProcessOrder(primary)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(112), result.Order.Price)
+
+	// Both follow the book as it moves
+	_, err = engine.ProcessOrder(models.NewOrder("b2", "BTCUSD", models.Buy, models.Limit, 104, 5))
+	assert.NoError(t, err)
+	_, err = engine.ProcessOrder(models.NewOrder("s2", "BTCUSD", models.Sell, models.Limit, 108, 5))
+	assert.NoError(t, err)
+	order, _ := engine.GetOrder("m1")
+	assert.Equal(t, int64(106), order.Price)
+	order, _ = engine.GetOrder("p1")
+	assert.Equal(t, int64(109), order.Price)
+	assert.Equal(t, []string{"m1", "p1", "m1"}, amends.ids) // in priority order
+	assert.Equal(t, int64(3), engine.metrics.OrdersRepriced.Load())
+
+	// Orders that don't move what they peg to leave them alone
+	_, err = engine.ProcessOrder(models.NewOrder("b3", "BTCUSD", models.Buy, models.Limit, 90, 5))
+	assert.NoError(t, err)
+	assert.Len(t, amends.ids, 3)
+
+	// but never past their limit
+	_, err = engine.ProcessOrder(peg("m2", models.Buy, models.PegMidpoint, 105))
+	assert.NoError(t, err)
+	order, _ = engine.GetOrder("m2")
+	assert.Equal(t, int64(105), order.Price)
+
+	// and one that can no longer be priced stays where it last was
+	_, err = engine.CancelOrder("s2")
+	assert.NoError(t, err)
+	_, err = engine.CancelOrder("s1")
+	assert.NoError(t, err)
+	order, _ = engine.GetOrder("m1")
+	assert.Equal(t, int64(107), order.Price)
+	depth, _ := engine.GetOrderBookDepth("BTCUSD", 0)
+	assert.Equal(t, []PriceLevelData{{Price: 112, Quantity: 2}}, depth.Asks)
+
+	amended, err := engine.Dispatch(BatchAmendCommand{Amendments: []BatchAmendment{
+		{OrderID: "m1", Price: 101, ReplacementID: "m1b"},
+	}})
+	assert.NoError(t, err)
+	assert.ErrorContains(t, amended.Amends[0].Err, "pegged orders are priced by their peg")
+}
+
+func TestMarketToLimitOrders(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+
+	_, err := engine.ProcessOrder(models.NewOrder("m0", "BTCUSD", models.Buy, models.MarketToLimit, 0, 1))
+	assert.ErrorContains(t, err, "insufficient liquidity")
+
+	_, err = engine.ProcessOrder(models.NewOrder("s1", "BTCUSD", models.Sell, models.Limit, 100, 3))
+	assert.NoError(t, err)
+	_, err = engine.ProcessOrder(models.NewOrder("s2", "BTCUSD", models.Sell, models.Limit, 101, 5))
+	assert.NoError(t, err)
+
+	// Takes the best level only, then rests the remainder at its price
+	result, err := engine.ProcessOrder(models.NewOrder("m1", "BTCUSD", models.Buy, models.MarketToLimit, 0, 5))
+	assert.NoError(t, err)
+	assert.Len(t, result.Trades, 1)
+	assert.Equal(t, int64(100), result.Trades[0].Price)
+	assert.Equal(t, int64(3), result.Trades[0].Quantity)
+	assert.Equal(t, models.PartialFill, result.Order.Status)
+	assert.Equal(t, models.Limit, result.Order.Type)
+	assert.Equal(t, models.GTC, result.Order.TimeInForce)
+
+	depth, _ := engine.GetOrderBookDepth("BTCUSD", 0)
+	assert.Equal(t, []PriceLevelData{{Price: 100, Quantity: 2}}, depth.Bids)
+	assert.Equal(t, []PriceLevelData{{Price: 101, Quantity: 5}}, depth.Asks)
+
+	priced := models.NewOrder("m2", "BTCUSD", models.Buy, models.MarketToLimit, 101, 1)
+	_, err = engine.ProcessOrder(priced)
+	assert.ErrorContains(t, err, "invalid price")
+}
+
+func TestOCOOrders(t *testing.T) {
+	engine := NewEngine(metrics.NewMetrics())
+	trade := func(id string, price int64) {
+		_, err := engine.ProcessOrder(models.NewOrder(id+"-s", "BTCUSD", models.Sell, models.Limit, price, 1))
+		assert.NoError(t, err)
+		_, err = engine.ProcessOrder(models.NewOrder(id+"-b", "BTCUSD", models.Buy, models.Limit, price, 1))
+		assert.NoError(t, err)
+	}
+	pair := func(id string) (*models.Order, *models.Order) {
+		takeProfit := models.NewOrder(id+"-tp", "BTCUSD", models.Sell, models.Limit, 110, 2)
+		stopLoss := models.NewOrder(id+"-sl", "BTCUSD", models.Sell, models.StopMarket, 0, 2)
+		stopLoss.StopPrice = 95
+		takeProfit.Account, stopLoss.Account = "trader", "trader"
+		return takeProfit, stopLoss
+	}
+	trade("t1", 100)
+
+	// The take-profit fills, so the stop-loss is cancelled
+	takeProfit, stopLoss := pair("p1")
+	matches, err := engine.ProcessOCO(takeProfit, stopLoss)
+	assert.NoError(t, err)
+	assert.Len(t, matches, 2)
+	_, err = engine.ProcessOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 110, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, models.Filled, takeProfit.Status)
+	assert.Equal(t, models.Cancelled, stopLoss.Status)
+	assert.Equal(t, OCOCancelReason, stopLoss.CancelReason)
+
+	// The stop-loss triggers and fills, so the take-profit is cancelled
+	_, err = engine.ProcessOrder(models.NewOrder("bid", "BTCUSD", models.Buy, models.Limit, 90, 5))
+	assert.NoError(t, err)
+	takeProfit, stopLoss = pair("p2")
+	_, err = engine.ProcessOCO(takeProfit, stopLoss)
+	assert.NoError(t, err)
+	trade("t2", 95)
+	assert.Equal(t, models.Filled, stopLoss.Status)
+	assert.Equal(t, models.Cancelled, takeProfit.Status)
+	depth, _ := engine.GetOrderBookDepth("BTCUSD", 0)
+	assert.Empty(t, depth.Asks)
+
+	// Cancelling one leg leaves the other working alone
+	takeProfit, stopLoss = pair("p3")
+	stopLoss.StopPrice = 80
+	_, err = engine.ProcessOCO(takeProfit, stopLoss)
+	assert.NoError(t, err)
+	_, err = engine.CancelOrder(stopLoss.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.Accepted, takeProfit.Status)
+	assert.Empty(t, engine.getOrderBook("BTCUSD").linked)
+
+	// A repriced leg stays linked through its replacement
+	_, err = engine.CancelOrder("bid")
+	assert.NoError(t, err)
+	legs := func(id string) (*models.Order, *models.Order) {
+		upper := models.NewOrder(id+"-up", "BTCUSD", models.Sell, models.Limit, 120, 1)
+		lower := models.NewOrder(id+"-down", "BTCUSD", models.Buy, models.Limit, 85, 1)
+		upper.Account, lower.Account = "trader", "trader"
+		_, err := engine.ProcessOCO(upper, lower)
+		assert.NoError(t, err)
+		return upper, lower
+	}
+	_, lower := legs("p5")
+	res, err := engine.Dispatch(BatchAmendCommand{Amendments: []BatchAmendment{{OrderID: "p5-up", Price: 121, ReplacementID: "p5-up2"}}})
+	assert.NoError(t, err)
+	assert.NoError(t, res.Amends[0].Err)
+	replacement := res.Amends[0].Order
+	_, err = engine.ProcessOrder(models.NewOrder("s5", "BTCUSD", models.Sell, models.Limit, 85, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, models.Filled, lower.Status)
+	assert.Equal(t, models.Cancelled, replacement.Status)
+	assert.Equal(t, OCOCancelReason, replacement.CancelReason)
+
+	// So do both legs when a shift moves them together
+	legs("p6")
+	n := 0
+	res, err = engine.Dispatch(ShiftCommand{Symbol: "BTCUSD", Account: "trader", Ticks: 1, NewID: func() string {
+		n++
+		return fmt.Sprintf("shifted-%d", n)
+	}})
+	assert.NoError(t, err)
+	replacements := make(map[string]*models.Order)
+	for _, a := range res.Amends {
+		assert.NoError(t, a.Err)
+		replacements[a.OrderID] = a.Order
+	}
+	_, err = engine.ProcessOrder(models.NewOrder("s6", "BTCUSD", models.Sell, models.Limit, 86, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, models.Filled, replacements["p6-down"].Status)
+	assert.Equal(t, models.Cancelled, replacements["p6-up"].Status)
+	assert.Equal(t, models.Accepted, replacements[takeProfit.ID].Status) // p3's, unpaired
+	assert.Empty(t, engine.getOrderBook("BTCUSD").linked)
+
+	// Both orders must share an account and symbol
+	takeProfit, stopLoss = pair("p4")
+	stopLoss.Symbol = "ETHUSD"
+	_, err = engine.ProcessOCO(takeProfit, stopLoss)
+	assert.ErrorContains(t, err, "invalid oco")
+	assert.Equal(t, models.Rejected, takeProfit.Status)
+	_, err = engine.GetOrder(takeProfit.ID)
+	assert.Error(t, err)
+}
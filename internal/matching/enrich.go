@@ -0,0 +1,25 @@
+package matching
+
+import "repello/internal/models"
+
+// TradeStage is one step of the enrichment pipeline every trade passes through
+// after it executes and before it is published to listeners and callers, e.g.
+// to add fees or regulatory flags. Stages run in the order they were added,
+// under the symbol's book lock, so they must be quick and must not call back
+// into the engine.
+type TradeStage interface {
+	Name() string
+	Enrich(trade *models.Trade, taker, maker *models.Order)
+}
+
+// AddTradeStage appends s to the enrichment pipeline. Stages must be added
+// before the engine starts processing orders.
+func (e *Engine) AddTradeStage(s TradeStage) {
+	e.stages = append(e.stages, s)
+}
+
+func (e *Engine) enrichTrade(trade *models.Trade, taker, maker *models.Order) {
+	for _, s := range e.stages {
+		s.Enrich(trade, taker, maker)
+	}
+}
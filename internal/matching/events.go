@@ -0,0 +1,133 @@
+package matching
+
+import "repello/internal/models"
+
+// BookTop is the best bid and ask of a book when an event happened. A zero
+// price means that side was empty.
+type BookTop struct {
+	BestBid int64
+	BestAsk int64
+}
+
+// EventListener observes engine activity, e.g. for surveillance. Callbacks run
+// synchronously under the symbol's book lock, so they must be quick and must
+// not call back into the engine.
+type EventListener interface {
+	// OrderAccepted fires once an incoming order has passed its checks,
+	// before it matches, and again when a stop order triggers. Its trades
+	// follow, then OrderCancelled for any remainder that can't rest.
+	OrderAccepted(order *models.Order, top BookTop)
+	OrderCancelled(order *models.Order, top BookTop)
+	TradeExecuted(trade *models.Trade, taker, maker *models.Order)
+}
+
+// TopListener is an EventListener that also wants to know whenever a book's
+// best displayed prices move, which OrderAccepted, whose top is the book's
+// before the order matches, doesn't always show. TopChanged runs under the
+// book lock, after the events that moved it.
+type TopListener interface {
+	TopChanged(symbol string, top BookTop)
+}
+
+// AmendListener is an EventListener that also wants to know when the engine
+// changes a resting order in place, as when it reprices a pegged order.
+type AmendListener interface {
+	OrderAmended(order *models.Order, top BookTop)
+}
+
+// AddEventListener registers l for all future events. Listeners must be added
+// before the engine starts processing orders.
+func (e *Engine) AddEventListener(l EventListener) {
+	e.listeners = append(e.listeners, l)
+	if tl, ok := l.(TopListener); ok {
+		e.topWatch = append(e.topWatch, tl)
+	}
+	if al, ok := l.(AmendListener); ok {
+		e.amendWatch = append(e.amendWatch, al)
+	}
+}
+
+// top is the book's best displayed prices, so hidden orders don't show in
+// market data.
+func (ob *OrderBook) top() BookTop {
+	var top BookTop
+	if bid := displayedBest(ob.Bids); bid != nil {
+		top.BestBid = bid.Price
+	}
+	if ask := displayedBest(ob.Asks); ask != nil {
+		top.BestAsk = ask.Price
+	}
+	return top
+}
+
+// displayedBest returns the best level on side that shows any quantity, or
+// nil if there is none.
+func displayedBest(side BookSide) *PriceLevel {
+	var best *PriceLevel
+	side.Walk(func(level *PriceLevel) bool {
+		if level.Displayed() > 0 {
+			best = level
+			return false
+		}
+		return true
+	})
+	return best
+}
+
+func (e *Engine) emitOrderAccepted(order *models.Order, ob *OrderBook) {
+	if len(e.listeners) == 0 {
+		return
+	}
+	top := ob.top()
+	for _, l := range e.listeners {
+		l.OrderAccepted(order, top)
+	}
+}
+
+func (e *Engine) emitOrderCancelled(order *models.Order, ob *OrderBook) {
+	// An OCO order's partner works on alone once it has gone unfilled
+	ob.unlink(order.ID)
+	if len(e.listeners) == 0 {
+		return
+	}
+	top := ob.top()
+	for _, l := range e.listeners {
+		l.OrderCancelled(order, top)
+	}
+}
+
+// settleBook runs as a book's write lock is released: it reprices the book's
+// pegged orders, if what they peg to has moved, and then tells TopListeners
+// if its top has.
+func (e *Engine) settleBook(ob *OrderBook) {
+	if len(ob.pegged) > 0 {
+		e.repricePegs(ob)
+	}
+	if len(e.topWatch) == 0 {
+		return
+	}
+	top := ob.top()
+	if top == ob.lastTop {
+		return
+	}
+	ob.lastTop = top
+	for _, l := range e.topWatch {
+		l.TopChanged(ob.Symbol, top)
+	}
+}
+
+func (e *Engine) emitOrderAmended(order *models.Order, ob *OrderBook) {
+	if len(e.amendWatch) == 0 {
+		return
+	}
+	top := ob.top()
+	for _, l := range e.amendWatch {
+		l.OrderAmended(order, top)
+	}
+}
+
+func (e *Engine) emitTradeExecuted(trade *models.Trade, taker, maker *models.Order) {
+	for _, l := range e.listeners {
+		l.TradeExecuted(trade, taker, maker)
+	}
+}
@@ -0,0 +1,77 @@
+package matching
+
+import (
+	"repello/internal/models"
+	"sort"
+)
+
+// SymbolExposure sums an account's resting orders in one symbol. Notional is
+// price times remaining quantity.
+type SymbolExposure struct {
+	Symbol       string `json:"symbol"`
+	BuyOrders    int    `json:"buy_orders"`
+	SellOrders   int    `json:"sell_orders"`
+	BuyQuantity  int64  `json:"buy_quantity"`
+	SellQuantity int64  `json:"sell_quantity"`
+	BuyNotional  int64  `json:"buy_notional"`
+	SellNotional int64  `json:"sell_notional"`
+}
+
+// AccountExposure is an account's open orders across all symbols.
+type AccountExposure struct {
+	Account      string           `json:"account_id"`
+	OpenOrders   int              `json:"open_orders"`
+	BuyNotional  int64            `json:"buy_notional"`
+	SellNotional int64            `json:"sell_notional"`
+	Symbols      []SymbolExposure `json:"symbols"`
+}
+
+// GetAccountExposure totals the resting orders belonging to account, by symbol.
+// Each book is read under its own lock, so the totals across symbols are not
+// one atomic snapshot.
+func (e *Engine) GetAccountExposure(account string) *AccountExposure {
+	e.mu.RLock()
+	books := make([]*OrderBook, 0, len(e.OrderBooks))
+	for _, ob := range e.OrderBooks {
+		books = append(books, ob)
+	}
+	e.mu.RUnlock()
+
+	exposure := &AccountExposure{
+		Account: account,
+		Symbols: make([]SymbolExposure, 0),
+	}
+	for _, ob := range books {
+		ob.RLock()
+		sym := SymbolExposure{Symbol: ob.Symbol}
+		for _, order := range ob.Orders {
+			if order.Account != account {
+				continue
+			}
+			notional := order.Price * order.RemainingQuantity
+			if order.Side == models.Buy {
+				sym.BuyOrders++
+				sym.BuyQuantity += order.RemainingQuantity
+				sym.BuyNotional += notional
+			} else {
+				sym.SellOrders++
+				sym.SellQuantity += order.RemainingQuantity
+				sym.SellNotional += notional
+			}
+		}
+		ob.RUnlock()
+
+		if sym.BuyOrders+sym.SellOrders == 0 {
+			continue
+		}
+		exposure.OpenOrders += sym.BuyOrders + sym.SellOrders
+		exposure.BuyNotional += sym.BuyNotional
+		exposure.SellNotional += sym.SellNotional
+		exposure.Symbols = append(exposure.Symbols, sym)
+	}
+
+	sort.Slice(exposure.Symbols, func(i, j int) bool {
+		return exposure.Symbols[i].Symbol < exposure.Symbols[j].Symbol
+	})
+	return exposure
+}
@@ -0,0 +1,148 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FixingResult is what publishing a fixing price did to a symbol's
+// match-at-fixing book.
+type FixingResult struct {
+	Symbol string          `json:"symbol"`
+	Price  int64           `json:"price"`
+	Trades []*models.Trade `json:"trades"`
+	// Cancelled holds the orders, or their remainders, left without a
+	// counterparty. Fixing orders only work until the next fixing.
+	Cancelled []*models.Order `json:"cancelled"`
+}
+
+// queueForFixing accepts an AtFixing order into the book's match-at-fixing
+// queue instead of matching it. It must be called with the book locked.
+func (e *Engine) queueForFixing(order *models.Order, ob *OrderBook) (*MatchResult, error) {
+	if err := e.runPreTradeChecks(order, ob); err != nil {
+		e.AllOrders.Delete(order.ID)
+		return nil, err
+	}
+
+	order.AcceptedAt = time.Now().UnixNano()
+	ob.assignPriority(order)
+	order.Status = models.Accepted
+	if order.Side == models.Buy {
+		ob.fixingBids = append(ob.fixingBids, order)
+	} else {
+		ob.fixingAsks = append(ob.fixingAsks, order)
+	}
+
+	e.emitOrderAccepted(order, ob)
+	return newMatchResult(order), nil
+}
+
+// removeFixingOrder takes order out of the match-at-fixing queue, reporting
+// whether it was there. It must be called with the book locked.
+func (ob *OrderBook) removeFixingOrder(order *models.Order) bool {
+	queue := &ob.fixingAsks
+	if order.Side == models.Buy {
+		queue = &ob.fixingBids
+	}
+	i := slices.Index(*queue, order)
+	if i < 0 {
+		return false
+	}
+	*queue = slices.Delete(*queue, i, i+1)
+	return true
+}
+
+// PublishFixing crosses symbol's match-at-fixing orders at price, in arrival
+// order on each side. Within each trade the earlier order is the maker.
+// Orders left without a counterparty are cancelled, so the queue is empty
+// afterwards.
+func (e *Engine) PublishFixing(symbol string, price int64) (*FixingResult, error) {
+	if price <= 0 {
+		return nil, fmt.Errorf("invalid price: must be positive")
+	}
+	e.mu.RLock()
+	ob, exists := e.OrderBooks[symbol]
+	e.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("order book not found")
+	}
+	if err := ob.CheckPrice(price); err != nil {
+		return nil, err
+	}
+
+	ob.Lock()
+	defer ob.Unlock()
+
+	result := &FixingResult{
+		Symbol:    symbol,
+		Price:     price,
+		Trades:    make([]*models.Trade, 0),
+		Cancelled: make([]*models.Order, 0),
+	}
+
+	bids, asks := ob.fixingBids, ob.fixingAsks
+	ob.fixingBids, ob.fixingAsks = nil, nil
+	for len(bids) > 0 && len(asks) > 0 {
+		buy, sell := bids[0], asks[0]
+		maker, taker := buy, sell
+		if sell.Priority.Sequence < buy.Priority.Sequence {
+			maker, taker = sell, buy
+		}
+
+		quantity := min(buy.RemainingQuantity, sell.RemainingQuantity)
+		trade := &models.Trade{
+			ID:            uuid.New().String(),
+			Symbol:        symbol,
+			BuyerOrderID:  buy.ID,
+			SellerOrderID: sell.ID,
+			MakerOrderID:  maker.ID,
+			TakerOrderID:  taker.ID,
+			AggressorSide: taker.Side,
+			Price:         price,
+			Quantity:      quantity,
+			Timestamp:     time.Now().UnixNano(),
+		}
+		ob.lastPrice = price
+		ob.lastTrade = newLastTrade(trade)
+
+		for _, order := range []*models.Order{buy, sell} {
+			order.Fill(quantity, trade.Timestamp)
+			if order.RemainingQuantity == 0 {
+				order.SetStatus(models.Filled)
+				ob.noteFilled(order)
+			} else {
+				order.Status = models.PartialFill
+			}
+		}
+		e.metrics.IncTradesExecuted(1)
+		e.metrics.IncOrdersMatched(2)
+		e.metrics.AddSymbolExecution(symbol, quantity, price)
+
+		e.enrichTrade(trade, taker, maker)
+		e.emitTradeExecuted(trade, taker, maker)
+		result.Trades = append(result.Trades, trade)
+
+		if buy.RemainingQuantity == 0 {
+			bids = bids[1:]
+		}
+		if sell.RemainingQuantity == 0 {
+			asks = asks[1:]
+		}
+	}
+
+	for _, order := range append(bids, asks...) {
+		order.SetStatus(models.Cancelled)
+		e.metrics.IncOrdersCancelled()
+		e.emitOrderCancelled(order, ob)
+		result.Cancelled = append(result.Cancelled, order)
+	}
+	if len(result.Trades) > 0 {
+		e.cancelLinked(ob)
+		e.triggerStops(ob)
+	}
+	return result, nil
+}
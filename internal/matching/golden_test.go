@@ -0,0 +1,100 @@
+package matching
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"repello/internal/models"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+// goldenState is everything a scenario leaves behind, in a stable order, so a
+// change to matching semantics shows up as a diff of the golden file.
+type goldenState struct {
+	Trades []scenarioTrade         `json:"trades"`
+	Books  map[string]scenarioBook `json:"books"`
+	Orders map[string]goldenOrder  `json:"orders"`
+}
+
+type goldenOrder struct {
+	Status      string             `json:"status"`
+	TimeInForce models.TimeInForce `json:"tif"`
+	Filled      int64              `json:"filled"`
+	Remaining   int64              `json:"remaining"`
+}
+
+func captureGolden(e *Engine, trades []scenarioTrade) goldenState {
+	state := goldenState{
+		Trades: trades,
+		Books:  make(map[string]scenarioBook),
+		Orders: make(map[string]goldenOrder),
+	}
+	if state.Trades == nil {
+		state.Trades = []scenarioTrade{}
+	}
+
+	e.mu.RLock()
+	symbols := make([]string, 0, len(e.OrderBooks))
+	for symbol := range e.OrderBooks {
+		symbols = append(symbols, symbol)
+	}
+	e.mu.RUnlock()
+	for _, symbol := range symbols {
+		state.Books[symbol] = bookState(e, symbol)
+	}
+
+	e.AllOrders.Range(func(key, value any) bool {
+		order := value.(*models.Order)
+		state.Orders[order.ID] = goldenOrder{
+			Status:      order.Status.String(),
+			TimeInForce: order.TimeInForce,
+			Filled:      order.FilledQuantity,
+			Remaining:   order.RemainingQuantity,
+		}
+		return true
+	})
+	return state
+}
+
+// TestGoldenBookState runs every scenario and compares the resulting trades,
+// books and orders with testdata/golden. Run with -update to accept changes.
+func TestGoldenBookState(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "scenarios", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			engine, trades := runScenario(t, loadScenario(t, path))
+
+			// encoding/json sorts map keys, so the output is stable
+			got, err := json.MarshalIndent(captureGolden(engine, trades), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", "golden", name+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%s (run with -update to create it)", err)
+			}
+			if !bytes.Equal(want, got) {
+				t.Errorf("%s differs from the golden file; rerun with -update and review the diff.\ngot:\n%s", golden, got)
+			}
+		})
+	}
+}
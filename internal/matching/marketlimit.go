@@ -0,0 +1,19 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+)
+
+// convertToLimit turns a market-to-limit order into a limit order at the best
+// opposite price, so it trades against that level only and rests whatever is
+// left there. It must be called with the book locked, read or write.
+func (ob *OrderBook) convertToLimit(order *models.Order) error {
+	best := ob.oppositeSide(order.Side).Best()
+	if best == nil {
+		return fmt.Errorf("insufficient liquidity: no opposite price for a market-to-limit order")
+	}
+	order.Type = models.Limit
+	order.Price = best.Price
+	return nil
+}
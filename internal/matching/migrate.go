@@ -0,0 +1,171 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"sort"
+	"time"
+)
+
+// ImportError says why one order of an import was refused. Index is the
+// order's position in the import.
+type ImportError struct {
+	Index   int    `json:"index"`
+	OrderID string `json:"order_id"`
+	Error   string `json:"error"`
+}
+
+// ImportOrders rests orders migrated from another engine directly in their
+// books, without matching. Orders rest in order of their Timestamp, ties in
+// the order given, behind anything already in the book. The import is all or
+// nothing: if any order is invalid, duplicates an ID, or would cross the book
+// (including other imported orders), nothing is imported and every problem is
+// returned. With dryRun the orders are only checked.
+func (e *Engine) ImportOrders(orders []*models.Order, dryRun bool) []ImportError {
+	now, session := time.Now(), e.sessionClose()
+	var problems []ImportError
+	fail := func(i int, err error) {
+		problems = append(problems, ImportError{Index: i, OrderID: orders[i].ID, Error: err.Error()})
+	}
+
+	// Priority follows the original timestamps
+	sequence := make([]int, len(orders))
+	for i := range sequence {
+		sequence[i] = i
+	}
+	sort.SliceStable(sequence, func(a, b int) bool {
+		return orders[sequence[a]].Timestamp < orders[sequence[b]].Timestamp
+	})
+
+	books := make(map[string]*OrderBook)
+	seen := make(map[string]bool)
+	for i, order := range orders {
+		if order.ID == "" {
+			fail(i, fmt.Errorf("invalid order id: required"))
+			continue
+		}
+		if err := order.Validate(); err != nil {
+			fail(i, err)
+			continue
+		}
+		if order.Type != models.Limit {
+			fail(i, fmt.Errorf("invalid type: only limit orders can be imported"))
+			continue
+		}
+		if _, exists := e.AllOrders.Load(order.ID); exists || seen[order.ID] {
+			fail(i, fmt.Errorf("duplicate order id"))
+			continue
+		}
+		seen[order.ID] = true
+		books[order.Symbol] = e.getOrderBook(order.Symbol)
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	// Lock every book involved, in a fixed order, so the checks still hold
+	// when the orders are added.
+	symbols := make([]string, 0, len(books))
+	for symbol := range books {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		books[symbol].Lock()
+		defer books[symbol].Unlock()
+	}
+
+	// The best prices each book would have once everything is imported
+	type touch struct{ bid, ask int64 }
+	touches := make(map[string]*touch)
+	for symbol, ob := range books {
+		t := &touch{}
+		if best := ob.Bids.Best(); best != nil {
+			t.bid = best.Price
+		}
+		if best := ob.Asks.Best(); best != nil {
+			t.ask = best.Price
+		}
+		touches[symbol] = t
+	}
+	for i, order := range orders {
+		ob := books[order.Symbol]
+		if err := ob.CheckPrice(order.Price); err != nil {
+			fail(i, err)
+			continue
+		}
+		if err := ob.resolveTimeInForce(order, now, session); err != nil {
+			fail(i, err)
+			continue
+		}
+		if !order.TimeInForce.Rests() {
+			fail(i, fmt.Errorf("invalid time in force: %s orders can't rest", order.TimeInForce))
+			continue
+		}
+		t := touches[order.Symbol]
+		if order.Side == models.Buy && (t.bid == 0 || order.Price > t.bid) {
+			t.bid = order.Price
+		}
+		if order.Side == models.Sell && (t.ask == 0 || order.Price < t.ask) {
+			t.ask = order.Price
+		}
+	}
+	for symbol, t := range touches {
+		if t.bid != 0 && t.ask != 0 && t.bid >= t.ask {
+			for i, order := range orders {
+				if order.Symbol == symbol && (order.Side == models.Buy && order.Price >= t.ask || order.Side == models.Sell && order.Price <= t.bid) {
+					fail(i, fmt.Errorf("crossed book: %s bid %d would meet ask %d", symbol, t.bid, t.ask))
+				}
+			}
+		}
+	}
+	if len(problems) > 0 || dryRun {
+		sort.Slice(problems, func(a, b int) bool { return problems[a].Index < problems[b].Index })
+		return problems
+	}
+
+	for _, i := range sequence {
+		order := orders[i]
+		ob := books[order.Symbol]
+		order.Status = models.Accepted
+		order.AcceptedAt = now.UnixNano()
+		order.Priority = models.PriorityKey{}
+		e.AllOrders.Store(order.ID, order)
+		ob.AddOrder(order)
+		e.metrics.IncOrdersInBook()
+		e.metrics.IncSymbolAdds(order.Symbol)
+		e.emitOrderAccepted(order, ob)
+	}
+	return nil
+}
+
+// ExportOrders returns copies of the orders resting in symbol's book, or in
+// every book if symbol is empty, in priority order: books by symbol, bids
+// before asks, best first. Importing them in this order, with timestamps that
+// don't go backwards, recreates the books' priority.
+func (e *Engine) ExportOrders(symbol string) []models.Order {
+	e.mu.RLock()
+	books := make([]*OrderBook, 0, len(e.OrderBooks))
+	for s, ob := range e.OrderBooks {
+		if symbol == "" || s == symbol {
+			books = append(books, ob)
+		}
+	}
+	e.mu.RUnlock()
+	sort.Slice(books, func(a, b int) bool { return books[a].Symbol < books[b].Symbol })
+
+	orders := make([]models.Order, 0)
+	for _, ob := range books {
+		ob.RLock()
+		for _, side := range []BookSide{ob.Bids, ob.Asks} {
+			side.Walk(func(level *PriceLevel) bool {
+				for _, o := range level.Orders {
+					orders = append(orders, *o)
+				}
+				return true
+			})
+		}
+		ob.RUnlock()
+	}
+	return orders
+}
@@ -0,0 +1,30 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+)
+
+// checkMinQuantity enforces order's taker-side minimum: it may only trade on
+// arrival if at least MinQuantity can execute at once. An order that can't
+// is rejected, unless it may rest and wouldn't trade at all, in which case
+// it is posted as usual. Once resting, the minimum no longer applies. firm is
+// the order's no-cross firm, if any. It must be called with the book locked.
+func (e *Engine) checkMinQuantity(order *models.Order, ob *OrderBook, firm string) error {
+	if order.MinQuantity == 0 {
+		return nil
+	}
+	var available int64
+	switch {
+	case firm != "":
+		available = e.externalLiquidity(order, ob, firm, order.MinQuantity)
+	case order.Type == models.Limit:
+		available = ob.CalculateLiquidityWithinPrice(order.Side, order.Price, order.MinQuantity)
+	default:
+		available = ob.CalculateLiquidity(order.Side, order.MinQuantity)
+	}
+	if available >= order.MinQuantity || (available == 0 && order.Type == models.Limit && order.TimeInForce.Rests()) {
+		return nil
+	}
+	return fmt.Errorf("minimum quantity: only %d shares available immediately, requested at least %d", available, order.MinQuantity)
+}
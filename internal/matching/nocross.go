@@ -0,0 +1,112 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"slices"
+)
+
+// NoCrossCancelReason is the CancelReason of a no-cross order's remainder,
+// cancelled because resting at its limit would cross the firm's own orders it
+// passed over.
+const NoCrossCancelReason = "no-cross: would rest through the firm's own orders"
+
+// FirmDirectory tells the engine which firm each account belongs to, for
+// broker options that depend on it.
+type FirmDirectory interface {
+	FirmOf(account string) (string, bool)
+	// NoCross reports whether firm's marketable orders skip price levels
+	// holding only the firm's own resting orders, rather than trading with
+	// themselves.
+	NoCross(firm string) bool
+}
+
+// SetFirmDirectory enables per-firm broker options. It must be called before
+// the engine starts processing orders. Like pre-trade checks, the directory
+// is called under the symbol's book lock.
+func (e *Engine) SetFirmDirectory(d FirmDirectory) {
+	e.firms = d
+}
+
+// noCrossFirm returns the firm whose own liquidity order must skip, or "" if
+// order may trade with anyone.
+func (e *Engine) noCrossFirm(order *models.Order) string {
+	if e.firms == nil {
+		return ""
+	}
+	firm, ok := e.firms.FirmOf(order.Account)
+	if !ok || !e.firms.NoCross(firm) {
+		return ""
+	}
+	return firm
+}
+
+// internal reports whether every order at level belongs to firm. Levels that
+// also hold other firms' orders trade normally, in time priority.
+func (e *Engine) internal(level *PriceLevel, firm string) bool {
+	for _, resting := range level.Orders {
+		if f, ok := e.firms.FirmOf(resting.Account); !ok || f != firm {
+			return false
+		}
+	}
+	return true
+}
+
+// matchNoCross matches order like processLimitOrder and processMarketOrder,
+// but passes over price levels holding only firm's orders and trades with
+// the next external liquidity instead. The quantity passed over is added to
+// result.SkippedQuantity. Those levels are still in the book, so if any was
+// passed over the order's remainder can't rest; enterOrder cancels it.
+func (e *Engine) matchNoCross(order *models.Order, ob *OrderBook, result *MatchResult, firm string) {
+	for order.RemainingQuantity > 0 {
+		var maker *models.Order
+		ob.oppositeSide(order.Side).Walk(func(level *PriceLevel) bool {
+			if order.Type == models.Limit && !crosses(order, level.Price) {
+				return false
+			}
+			if e.internal(level, firm) {
+				if !slices.Contains(result.skippedLevels, level.Price) {
+					result.skippedLevels = append(result.skippedLevels, level.Price)
+					result.SkippedQuantity += level.TotalQuantity
+				}
+				return true
+			}
+			maker = level.Orders[0]
+			return false
+		})
+		if maker == nil {
+			return
+		}
+		if !ob.sweepLimit.allows(result, maker.Price) {
+			result.SweepCapped = true
+			return
+		}
+		e.executeTrade(order, maker, ob, result)
+	}
+}
+
+// checkExternalLiquidity returns an error unless the book holds enough
+// liquidity outside firm's own levels to fill order completely.
+func (e *Engine) checkExternalLiquidity(order *models.Order, ob *OrderBook, firm string) error {
+	available := e.externalLiquidity(order, ob, firm, order.OriginalQuantity)
+	if available < order.OriginalQuantity {
+		return fmt.Errorf("insufficient liquidity: only %d shares available from other firms, requested %d", available, order.OriginalQuantity)
+	}
+	return nil
+}
+
+// externalLiquidity is like CalculateLiquidityWithinPrice, but leaves out
+// levels holding only firm's own orders.
+func (e *Engine) externalLiquidity(order *models.Order, ob *OrderBook, firm string, maxNeeded int64) int64 {
+	var available int64
+	ob.oppositeSide(order.Side).Walk(func(level *PriceLevel) bool {
+		if order.Type == models.Limit && !crosses(order, level.Price) {
+			return false
+		}
+		if !e.internal(level, firm) {
+			available += level.TotalQuantity
+		}
+		return available < maxNeeded
+	})
+	return available
+}
@@ -0,0 +1,193 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"time"
+)
+
+// OCOCancelReason is the CancelReason of an order cancelled because the other
+// order of its one-cancels-other pair filled.
+const OCOCancelReason = "oco: linked order filled"
+
+// OCOCommand submits two linked orders, as ProcessOCO.
+type OCOCommand struct {
+	First, Second *models.Order
+}
+
+func (c OCOCommand) apply(e *Engine) (*CommandResult, error) {
+	matches, err := e.ProcessOCO(c.First, c.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &CommandResult{Matches: matches}, nil
+}
+
+// ProcessOCO enters two orders as a one-cancels-other pair, such as a
+// take-profit limit and a stop-loss: once either fills completely the other
+// is cancelled. If either is cancelled or expires unfilled, the other works on
+// alone. Both must be for the same account and symbol, and able to rest, and
+// they are entered under one hold of the book lock, first then second. If the
+// first fills on entry the second is cancelled without being entered, after
+// an accepted event of its own, and its match is nil. If the second is rejected the first is cancelled, keeping
+// whatever it traded.
+func (e *Engine) ProcessOCO(first, second *models.Order) (matches []*MatchResult, err error) {
+	startTime := time.Now()
+	orders := []*models.Order{first, second}
+	defer func() {
+		if err != nil {
+			for _, order := range orders {
+				if order.Status != models.Cancelled {
+					order.SetStatus(models.Rejected)
+				}
+			}
+		}
+	}()
+
+	for _, order := range orders {
+		e.metrics.IncOrdersReceived()
+		if order.ID == "" {
+			return nil, fmt.Errorf("invalid order id: required")
+		}
+		if err := order.Validate(); err != nil {
+			return nil, err
+		}
+		if order.Type == models.Market || !order.TimeInForce.Rests() {
+			return nil, fmt.Errorf("invalid oco: both orders must be able to rest")
+		}
+	}
+	if first.Symbol != second.Symbol || first.Account != second.Account {
+		return nil, fmt.Errorf("invalid oco: both orders must be for the same account and symbol")
+	}
+	if first.ID == second.ID {
+		return nil, fmt.Errorf("duplicate order id: %s", second.ID)
+	}
+
+	ob := e.getOrderBook(first.Symbol)
+	for i, order := range orders {
+		if order.Type == models.Limit {
+			if err := ob.CheckPrice(order.Price); err != nil {
+				return nil, err
+			}
+		}
+		if _, exists := e.AllOrders.LoadOrStore(order.ID, order); exists {
+			if i == 1 {
+				e.AllOrders.Delete(first.ID)
+			}
+			return nil, fmt.Errorf("duplicate order id: %s", order.ID)
+		}
+	}
+
+	ob.Lock()
+	defer ob.Unlock()
+	for _, order := range orders {
+		if err := ob.checkSymbolRules(order); err != nil {
+			e.AllOrders.Delete(first.ID)
+			e.AllOrders.Delete(second.ID)
+			return nil, err
+		}
+	}
+
+	ob.link(first.ID, second.ID)
+	firstMatch, err := e.enterOrder(first, ob, startTime)
+	if err != nil {
+		ob.unlink(first.ID)
+		e.AllOrders.Delete(second.ID)
+		return nil, err
+	}
+	if second.Status == models.Cancelled {
+		return []*MatchResult{firstMatch, nil}, nil
+	}
+
+	secondMatch, err := e.enterOrder(second, ob, startTime)
+	if err != nil {
+		ob.unlink(first.ID)
+		if first.Status == models.Accepted || first.Status == models.PartialFill {
+			e.cancelLocked(first, ob)
+		}
+		return nil, err
+	}
+	return []*MatchResult{firstMatch, secondMatch}, nil
+}
+
+// link makes a and b a one-cancels-other pair.
+func (ob *OrderBook) link(a, b string) {
+	if ob.linked == nil {
+		ob.linked = make(map[string]string)
+	}
+	ob.linked[a] = b
+	ob.linked[b] = a
+}
+
+// replacementPartners returns the partners of the linked orders pending
+// replaces, by replacement ID. A partner being replaced too is given as its
+// replacement, which is still Accepted until it is entered. It must be
+// called before the replaced orders are cancelled.
+func (e *Engine) replacementPartners(ob *OrderBook, pending []pendingAmendment) map[string]*models.Order {
+	if len(ob.linked) == 0 {
+		return nil
+	}
+	replacedBy := make(map[string]*models.Order)
+	for _, p := range pending {
+		if p.replacement != nil {
+			replacedBy[p.order.ID] = p.replacement
+		}
+	}
+	partners := make(map[string]*models.Order)
+	for id, replacement := range replacedBy {
+		partnerID, ok := ob.linked[id]
+		if !ok {
+			continue
+		}
+		if partner, ok := replacedBy[partnerID]; ok {
+			partners[replacement.ID] = partner
+		} else if val, ok := e.AllOrders.Load(partnerID); ok {
+			partners[replacement.ID] = val.(*models.Order)
+		}
+	}
+	return partners
+}
+
+// unlink dissolves id's pair, if it has one.
+func (ob *OrderBook) unlink(id string) {
+	if partner, ok := ob.linked[id]; ok {
+		delete(ob.linked, id)
+		delete(ob.linked, partner)
+	}
+}
+
+// noteFilled queues the partner of a linked order that has just filled for
+// cancellation. The partner isn't cancelled at once, since it may be in the
+// middle of the side being matched against.
+func (ob *OrderBook) noteFilled(order *models.Order) {
+	if partner, ok := ob.linked[order.ID]; ok {
+		ob.unlink(order.ID)
+		ob.ocoFilled = append(ob.ocoFilled, partner)
+	}
+}
+
+// cancelLinked cancels the partners of linked orders that have filled. It
+// must be called with the book locked, once matching has finished.
+func (e *Engine) cancelLinked(ob *OrderBook) {
+	for len(ob.ocoFilled) > 0 {
+		id := ob.ocoFilled[0]
+		ob.ocoFilled = ob.ocoFilled[1:]
+		val, ok := e.AllOrders.Load(id)
+		if !ok {
+			continue
+		}
+		order := val.(*models.Order)
+		if order.Status != models.Accepted && order.Status != models.PartialFill {
+			continue
+		}
+		if order.AcceptedAt == 0 {
+			// The second order of a pair whose first filled on entry was
+			// never entered; announce it so its cancel isn't for an unknown
+			// order
+			order.AcceptedAt = time.Now().UnixNano()
+			e.emitOrderAccepted(order, ob)
+		}
+		order.CancelReason = OCOCancelReason
+		e.cancelLocked(order, ob)
+	}
+}
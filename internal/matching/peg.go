@@ -0,0 +1,130 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"sort"
+)
+
+// pegReference returns the best displayed price on side among orders that
+// aren't pegged, or 0 if there is none. Pegged orders are left out so they
+// don't chase each other.
+func (ob *OrderBook) pegReference(side models.Side) int64 {
+	var price int64
+	ob.side(side).Walk(func(level *PriceLevel) bool {
+		for _, order := range level.Orders {
+			if order.PegType == models.PegNone && order.Displayed() > 0 {
+				price = level.Price
+				return false
+			}
+		}
+		return true
+	})
+	return price
+}
+
+// pegPrice returns where order's peg puts it in the book as it stands. It
+// must be called with the book locked, read or write.
+func (ob *OrderBook) pegPrice(order *models.Order) (int64, error) {
+	bid, ask := ob.pegReference(models.Buy), ob.pegReference(models.Sell)
+	tick := ob.tick()
+
+	var price int64
+	switch order.PegType {
+	case models.PegPrimary:
+		price = bid
+		if order.Side == models.Sell {
+			price = ask
+		}
+		if price == 0 {
+			return 0, fmt.Errorf("insufficient liquidity: no %s price to peg to", order.Side)
+		}
+	case models.PegMidpoint:
+		if bid == 0 || ask == 0 {
+			return 0, fmt.Errorf("insufficient liquidity: no midpoint to peg to")
+		}
+		// Off-tick midpoints round away from the opposite side
+		half := (ask - bid) / 2 / tick * tick
+		price = bid + half
+		if order.Side == models.Sell {
+			price = ask - half
+		}
+	default:
+		return 0, fmt.Errorf("unknown peg type: %s", order.PegType)
+	}
+
+	if order.Side == models.Buy {
+		price = min(price-order.PegOffset*tick, order.PegLimit)
+		if best := ob.Asks.Best(); best != nil && price >= best.Price {
+			price = best.Price - tick
+		}
+	} else {
+		price = max(price+order.PegOffset*tick, order.PegLimit)
+		if best := ob.Bids.Best(); best != nil && price <= best.Price {
+			price = best.Price + tick
+		}
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("invalid peg offset: would price the order at %d", price)
+	}
+	if err := ob.CheckPrice(price); err != nil {
+		return 0, err
+	}
+	return price, nil
+}
+
+// pegInputs are the prices a book's pegged orders are priced from: the
+// reference prices and, since pegs never cross, the best price on each side.
+type pegInputs struct {
+	refBid, refAsk   int64
+	bestBid, bestAsk int64
+}
+
+func (ob *OrderBook) currentPegInputs() pegInputs {
+	in := pegInputs{
+		refBid: ob.pegReference(models.Buy),
+		refAsk: ob.pegReference(models.Sell),
+	}
+	if best := ob.Bids.Best(); best != nil {
+		in.bestBid = best.Price
+	}
+	if best := ob.Asks.Best(); best != nil {
+		in.bestAsk = best.Price
+	}
+	return in
+}
+
+// repricePegs moves every resting pegged order whose peg price has changed,
+// if the prices they peg to have moved since they were last priced. A moved
+// order goes to the back of its new level, as a new order would, and is
+// reported amended. An order whose peg can't be priced, say because a side
+// has emptied, stays where it is. It must be called with the book locked.
+func (e *Engine) repricePegs(ob *OrderBook) {
+	in := ob.currentPegInputs()
+	if in == ob.pegInputs {
+		return
+	}
+
+	pegged := make([]*models.Order, 0, len(ob.pegged))
+	for _, order := range ob.pegged {
+		pegged = append(pegged, order)
+	}
+	sort.Slice(pegged, func(i, j int) bool {
+		return pegged[i].Priority.Sequence < pegged[j].Priority.Sequence
+	})
+
+	for _, order := range pegged {
+		price, err := ob.pegPrice(order)
+		if err != nil || price == order.Price {
+			continue
+		}
+		ob.RemoveOrder(order.ID)
+		order.Price = price
+		ob.assignPriority(order)
+		ob.AddOrder(order)
+		e.metrics.IncOrdersRepriced()
+		e.emitOrderAmended(order, ob)
+	}
+	// Moved pegs may have changed the best prices themselves
+	ob.pegInputs = ob.currentPegInputs()
+}
@@ -0,0 +1,33 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+)
+
+// postOnlyPrice returns the price a post-only order can rest at without
+// taking liquidity: its own if it doesn't cross the opposite side, otherwise
+// one tick behind the opposite best if it asked to be repriced. An order that
+// would cross and can't be repriced is rejected. It must be called with the
+// book locked, read or write.
+func (ob *OrderBook) postOnlyPrice(order *models.Order) (int64, error) {
+	best := ob.oppositeSide(order.Side).Best()
+	if best == nil || !crosses(order, best.Price) {
+		return order.Price, nil
+	}
+	if !order.PostOnlyReprice {
+		return 0, fmt.Errorf("post only: order would take liquidity at %d", best.Price)
+	}
+
+	price := best.Price - ob.tick()
+	if order.Side == models.Sell {
+		price = best.Price + ob.tick()
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("post only: no price below %d to reprice to", best.Price)
+	}
+	if err := ob.CheckPrice(price); err != nil {
+		return 0, err
+	}
+	return price, nil
+}
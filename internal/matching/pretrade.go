@@ -0,0 +1,56 @@
+package matching
+
+import "repello/internal/models"
+
+// PreTradeCheck vets orders after the engine's own validation and before they
+// match, e.g. for risk limits. Checks run synchronously under the symbol's book
+// lock, so they must be quick and must not call back into the engine.
+type PreTradeCheck interface {
+	// CheckOrder returns an error to reject order. It may also reduce the
+	// order's quantity. notional is what the order can cost at most: price
+	// times quantity for limit orders, the cost of sweeping the book for
+	// market orders, and the last trade price times quantity for fixing
+	// orders.
+	CheckOrder(order *models.Order, notional int64) error
+}
+
+// AddPreTradeCheck registers c for all future orders. Checks must be added
+// before the engine starts processing orders.
+func (e *Engine) AddPreTradeCheck(c PreTradeCheck) {
+	e.checks = append(e.checks, c)
+}
+
+func (e *Engine) runPreTradeChecks(order *models.Order, ob *OrderBook) error {
+	for _, c := range e.checks {
+		// A check may shrink the order, so each sees its current notional
+		if err := c.CheckOrder(order, ob.notional(order)); err != nil {
+			return err
+		}
+	}
+	e.runShadowChecks(order, ob)
+	return nil
+}
+
+// notional is the most order can cost given the current book.
+func (ob *OrderBook) notional(order *models.Order) int64 {
+	if order.Type == models.Limit {
+		return order.Price * order.OriginalQuantity
+	}
+	if order.Type == models.AtFixing {
+		// The fixing price isn't known yet; the last trade is the best guess
+		return ob.lastPrice * order.OriginalQuantity
+	}
+	if order.Type == models.StopMarket {
+		// It will trade at about its stop price, once triggered
+		return order.StopPrice * order.OriginalQuantity
+	}
+	var notional int64
+	remaining := order.OriginalQuantity
+	ob.oppositeSide(order.Side).Walk(func(priceLevel *PriceLevel) bool {
+		qty := min(remaining, priceLevel.TotalQuantity)
+		notional += priceLevel.Price * qty
+		remaining -= qty
+		return remaining > 0
+	})
+	return notional
+}
@@ -0,0 +1,48 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"sort"
+	"time"
+)
+
+// PurgeStaleOrders cancels every order resting in symbol's book that was
+// accepted more than maxAge before now, oldest first, and returns them.
+// Listeners see ordinary cancellations. It is an operator tool for staging
+// books that accumulate abandoned liquidity.
+func (e *Engine) PurgeStaleOrders(symbol string, maxAge time.Duration, now time.Time) ([]*models.Order, error) {
+	if maxAge <= 0 {
+		return nil, fmt.Errorf("invalid age: must be positive")
+	}
+	e.mu.RLock()
+	ob, exists := e.OrderBooks[symbol]
+	e.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("order book not found")
+	}
+
+	cutoff := now.Add(-maxAge).UnixNano()
+	ob.Lock()
+	defer ob.Unlock()
+
+	stale := make([]*models.Order, 0)
+	for _, order := range ob.Orders {
+		if order.AcceptedAt <= cutoff {
+			stale = append(stale, order)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		return stale[i].Priority.Sequence < stale[j].Priority.Sequence
+	})
+
+	for _, order := range stale {
+		ob.RemoveOrder(order.ID)
+		order.SetStatus(models.Cancelled)
+		e.metrics.IncOrdersCancelled()
+		e.metrics.DecOrdersInBook()
+		e.metrics.IncSymbolCancels(order.Symbol)
+		e.emitOrderCancelled(order, ob)
+	}
+	return stale, nil
+}
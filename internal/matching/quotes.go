@@ -0,0 +1,77 @@
+package matching
+
+import (
+	"repello/internal/models"
+	"sort"
+	"time"
+)
+
+// AccountQuote is an account's best resting prices in a symbol and the
+// quantity it shows at each. Zero prices mean it has no orders on that side.
+type AccountQuote struct {
+	Bid         int64 `json:"bid,omitempty"`
+	BidQuantity int64 `json:"bid_quantity,omitempty"`
+	Ask         int64 `json:"ask,omitempty"`
+	AskQuantity int64 `json:"ask_quantity,omitempty"`
+}
+
+// QuoteSnapshot is a symbol's top of book and every account's best quotes at
+// one moment, for judging who provides liquidity at the touch.
+type QuoteSnapshot struct {
+	Symbol    string
+	Timestamp int64 // UnixNano
+	Top       BookTop
+	Accounts  map[string]AccountQuote
+}
+
+// QuoteSnapshots takes a QuoteSnapshot of every book, sorted by symbol. Each
+// book is read under its own lock and scanned in full, so this is for periodic
+// sampling rather than the order path. Orders without an account, and hidden
+// orders, are left out.
+func (e *Engine) QuoteSnapshots() []QuoteSnapshot {
+	e.mu.RLock()
+	books := make([]*OrderBook, 0, len(e.OrderBooks))
+	for _, ob := range e.OrderBooks {
+		books = append(books, ob)
+	}
+	e.mu.RUnlock()
+	sort.Slice(books, func(i, j int) bool {
+		return books[i].Symbol < books[j].Symbol
+	})
+
+	snapshots := make([]QuoteSnapshot, 0, len(books))
+	for _, ob := range books {
+		ob.RLock()
+		snapshot := QuoteSnapshot{
+			Symbol:    ob.Symbol,
+			Timestamp: time.Now().UnixNano(),
+			Top:       ob.top(),
+			Accounts:  make(map[string]AccountQuote),
+		}
+		for _, order := range ob.Orders {
+			if order.Account == "" || order.Hidden {
+				continue
+			}
+			quote := snapshot.Accounts[order.Account]
+			if order.Side == models.Buy {
+				if order.Price > quote.Bid {
+					quote.Bid, quote.BidQuantity = order.Price, 0
+				}
+				if order.Price == quote.Bid {
+					quote.BidQuantity += order.Displayed()
+				}
+			} else {
+				if quote.Ask == 0 || order.Price < quote.Ask {
+					quote.Ask, quote.AskQuantity = order.Price, 0
+				}
+				if order.Price == quote.Ask {
+					quote.AskQuantity += order.Displayed()
+				}
+			}
+			snapshot.Accounts[order.Account] = quote
+		}
+		ob.RUnlock()
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
@@ -0,0 +1,110 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"slices"
+	"sort"
+)
+
+// SymbolTrading is the only symbol status so far: every known symbol trades
+// continuously.
+const SymbolTrading = "TRADING"
+
+// SessionSchedule describes when a symbol trades. Trading is continuous;
+// DAY orders are cancelled at DayOrderExpiry each day.
+type SessionSchedule struct {
+	Continuous     bool   `json:"continuous"`
+	DayOrderExpiry string `json:"day_order_expiry"` // HH:MM in TimeZone
+	TimeZone       string `json:"time_zone"`
+}
+
+// SymbolInfo is the reference data clients need to trade a symbol.
+type SymbolInfo struct {
+	Symbol             string               `json:"symbol"`
+	Status             string               `json:"status"`
+	TickSize           int64                `json:"tick_size"`
+	LotSize            int64                `json:"lot_size"`
+	MinPrice           int64                `json:"min_price,omitempty"` // ladder books only
+	MaxPrice           int64                `json:"max_price,omitempty"`
+	PriceBandBps       int64                `json:"price_band_bps,omitempty"`
+	OrderTypes         []models.OrderType   `json:"order_types"`
+	TimeInForces       []models.TimeInForce `json:"time_in_forces"`
+	DefaultTimeInForce models.TimeInForce   `json:"default_time_in_force"`
+	Session            SessionSchedule      `json:"session"`
+}
+
+// Symbols returns every symbol the engine knows, sorted: those with a book
+// and those configured with a ladder.
+func (e *Engine) Symbols() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	symbols := make([]string, 0, len(e.OrderBooks))
+	for symbol := range e.OrderBooks {
+		symbols = append(symbols, symbol)
+	}
+	for symbol := range e.ladders {
+		if _, ok := e.OrderBooks[symbol]; !ok {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// SymbolInfo returns symbol's reference data, or false if the engine doesn't
+// know symbol.
+func (e *Engine) SymbolInfo(symbol string) (SymbolInfo, bool) {
+	info := SymbolInfo{
+		Symbol:   symbol,
+		Status:   SymbolTrading,
+		TickSize: 1,
+		LotSize:  1,
+	}
+	session := e.sessionClose()
+	info.Session = SessionSchedule{
+		Continuous:     true,
+		DayOrderExpiry: fmt.Sprintf("%02d:%02d", session.Hour, session.Minute),
+		TimeZone:       session.location().String(),
+	}
+
+	e.mu.RLock()
+	ob, exists := e.OrderBooks[symbol]
+	ladder, laddered := e.ladders[symbol]
+	e.mu.RUnlock()
+	if !exists && !laddered {
+		return SymbolInfo{}, false
+	}
+	if laddered {
+		info.TickSize = ladder.TickSize
+		info.MinPrice = ladder.MinPrice
+		info.MaxPrice = ladder.MaxPrice
+	}
+
+	info.OrderTypes = []models.OrderType{models.Limit, models.Market, models.AtFixing, models.StopMarket, models.MarketToLimit}
+	info.DefaultTimeInForce = models.GTC
+	if exists {
+		ob.RLock()
+		if ob.tickSize > 0 {
+			info.TickSize = ob.tickSize
+		}
+		if ob.lotSize > 0 {
+			info.LotSize = ob.lotSize
+		}
+		if len(ob.orderTypes) > 0 {
+			info.OrderTypes = slices.Clone(ob.orderTypes)
+		}
+		if ob.defaultTIF != models.TIFDefault {
+			info.DefaultTimeInForce = ob.defaultTIF
+		}
+		info.PriceBandBps = ob.priceBandBps
+		ob.RUnlock()
+	}
+
+	// Market orders only take IOC and FOK
+	info.TimeInForces = []models.TimeInForce{models.IOC, models.FOK}
+	if slices.Contains(info.OrderTypes, models.Limit) {
+		info.TimeInForces = []models.TimeInForce{models.GTC, models.DAY, models.IOC, models.FOK, models.GTD}
+	}
+	return info, true
+}
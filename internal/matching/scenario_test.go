@@ -0,0 +1,207 @@
+package matching
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A scenario is a declarative matching test read from testdata/scenarios. Steps
+// run in order against a fresh engine, then the trades, book and order states
+// are compared with the expectations.
+type scenario struct {
+	Description string         `json:"description"`
+	Steps       []scenarioStep `json:"steps"`
+	Expect      struct {
+		Trades []scenarioTrade           `json:"trades"`
+		Book   map[string]scenarioBook   `json:"book"`
+		Orders map[string]scenarioStatus `json:"orders"`
+	} `json:"expect"`
+}
+
+// scenarioStep is one action. Action is "order", "cancel" or "reduce"; Error,
+// if set, must appear in the error the action returns.
+type scenarioStep struct {
+	Action      string             `json:"action"`
+	ID          string             `json:"id"`
+	Account     string             `json:"account,omitempty"`
+	Symbol      string             `json:"symbol,omitempty"`
+	Side        models.Side        `json:"side,omitempty"`
+	Type        models.OrderType   `json:"type,omitempty"`
+	Price       int64              `json:"price,omitempty"`
+	Quantity    int64              `json:"quantity,omitempty"`
+	TimeInForce models.TimeInForce `json:"tif,omitempty"`
+	ReduceBy    int64              `json:"reduce_by,omitempty"`
+	Error       string             `json:"error,omitempty"`
+}
+
+type scenarioTrade struct {
+	Buyer     string      `json:"buyer"`
+	Seller    string      `json:"seller"`
+	Aggressor models.Side `json:"aggressor"`
+	Price     int64       `json:"price"`
+	Quantity  int64       `json:"quantity"`
+}
+
+// scenarioBook lists a symbol's levels best price first, each with its orders
+// in queue order.
+type scenarioBook struct {
+	Bids []scenarioLevel `json:"bids"`
+	Asks []scenarioLevel `json:"asks"`
+}
+
+type scenarioLevel struct {
+	Price  int64           `json:"price"`
+	Orders []scenarioOrder `json:"orders"`
+}
+
+type scenarioOrder struct {
+	ID        string `json:"id"`
+	Remaining int64  `json:"remaining"`
+}
+
+type scenarioStatus struct {
+	Status string `json:"status"`
+	Filled int64  `json:"filled"`
+}
+
+// tradeRecorder captures every trade in execution order.
+type tradeRecorder struct {
+	trades []scenarioTrade
+}
+
+func (r *tradeRecorder) OrderAccepted(order *models.Order, top BookTop)  {}
+func (r *tradeRecorder) OrderCancelled(order *models.Order, top BookTop) {}
+
+func (r *tradeRecorder) TradeExecuted(trade *models.Trade, taker, maker *models.Order) {
+	r.trades = append(r.trades, scenarioTrade{
+		Buyer:     trade.BuyerOrderID,
+		Seller:    trade.SellerOrderID,
+		Aggressor: trade.AggressorSide,
+		Price:     trade.Price,
+		Quantity:  trade.Quantity,
+	})
+}
+
+func loadScenario(t *testing.T, path string) scenario {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sc scenario
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sc); err != nil {
+		t.Fatalf("parsing %s: %s", path, err)
+	}
+	return sc
+}
+
+// runScenario plays sc's steps and returns the engine and the trades it made.
+func runScenario(t *testing.T, sc scenario) (*Engine, []scenarioTrade) {
+	t.Helper()
+	engine := NewEngine(metrics.NewMetrics())
+	recorder := &tradeRecorder{}
+	engine.AddEventListener(recorder)
+
+	for i, step := range sc.Steps {
+		var err error
+		switch step.Action {
+		case "order":
+			order := models.NewOrder(step.ID, step.Symbol, step.Side, step.Type, step.Price, step.Quantity)
+			order.Account = step.Account
+			order.TimeInForce = step.TimeInForce
+			var result *MatchResult
+			result, err = engine.ProcessOrder(order)
+			if err == nil {
+				result.Release()
+			}
+		case "cancel":
+			_, err = engine.CancelOrder(step.ID)
+		case "reduce":
+			_, err = engine.ReduceOrder(step.ID, step.ReduceBy)
+		default:
+			t.Fatalf("step %d: unknown action %q", i, step.Action)
+		}
+
+		if step.Error == "" {
+			assert.NoError(t, err, "step %d (%s %s)", i, step.Action, step.ID)
+		} else if assert.Error(t, err, "step %d (%s %s)", i, step.Action, step.ID) {
+			assert.Contains(t, err.Error(), step.Error, "step %d", i)
+		}
+	}
+	return engine, recorder.trades
+}
+
+// bookState captures symbol's book in scenario form.
+func bookState(e *Engine, symbol string) scenarioBook {
+	ob := e.getOrderBook(symbol)
+	ob.RLock()
+	defer ob.RUnlock()
+
+	levels := func(side BookSide) []scenarioLevel {
+		out := make([]scenarioLevel, 0)
+		side.Walk(func(level *PriceLevel) bool {
+			l := scenarioLevel{Price: level.Price, Orders: make([]scenarioOrder, 0, len(level.Orders))}
+			for _, o := range level.Orders {
+				l.Orders = append(l.Orders, scenarioOrder{ID: o.ID, Remaining: o.RemainingQuantity})
+			}
+			out = append(out, l)
+			return true
+		})
+		return out
+	}
+	return scenarioBook{Bids: levels(ob.Bids), Asks: levels(ob.Asks)}
+}
+
+func TestScenarios(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "scenarios", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no scenarios found")
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			sc := loadScenario(t, path)
+			engine, trades := runScenario(t, sc)
+
+			if sc.Expect.Trades == nil {
+				sc.Expect.Trades = []scenarioTrade{}
+			}
+			if trades == nil {
+				trades = []scenarioTrade{}
+			}
+			assert.Equal(t, sc.Expect.Trades, trades, "trades")
+
+			for symbol, want := range sc.Expect.Book {
+				if want.Bids == nil {
+					want.Bids = []scenarioLevel{}
+				}
+				if want.Asks == nil {
+					want.Asks = []scenarioLevel{}
+				}
+				assert.Equal(t, want, bookState(engine, symbol), "book for %s", symbol)
+			}
+
+			for id, want := range sc.Expect.Orders {
+				order, err := engine.GetOrder(id)
+				if !assert.NoError(t, err, "order %s", id) {
+					continue
+				}
+				assert.Equal(t, want, scenarioStatus{Status: order.Status.String(), Filled: order.FilledQuantity}, "order %s", id)
+			}
+		})
+	}
+}
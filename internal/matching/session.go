@@ -0,0 +1,71 @@
+package matching
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SessionCloseCancelReason is the CancelReason of a DAY order cancelled at
+// the end of its session.
+const SessionCloseCancelReason = "session close"
+
+// SessionClose is the time of day the trading session ends, when every
+// resting DAY order is cancelled. The zero value is midnight UTC.
+type SessionClose struct {
+	Hour     int
+	Minute   int
+	Location *time.Location // nil is UTC
+}
+
+// ParseSessionClose reads a close written as HH:MM in the IANA time zone
+// zone, e.g. "16:00" in "America/New_York".
+func ParseSessionClose(hhmm, zone string) (SessionClose, error) {
+	var c SessionClose
+	t, err := time.Parse("15:04", strings.TrimSpace(hhmm))
+	if err != nil {
+		return c, fmt.Errorf("invalid session close %q: want HH:MM", hhmm)
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return c, fmt.Errorf("invalid session time zone %q: %v", zone, err)
+	}
+	c.Hour, c.Minute, c.Location = t.Hour(), t.Minute(), loc
+	return c, nil
+}
+
+func (c SessionClose) location() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+	return c.Location
+}
+
+// next returns the first close after t. It follows the time zone's clock, so
+// a 16:00 close stays at 16:00 local time across daylight saving changes.
+func (c SessionClose) next(t time.Time) time.Time {
+	local := t.In(c.location())
+	y, m, d := local.Date()
+	close := time.Date(y, m, d, c.Hour, c.Minute, 0, 0, local.Location())
+	if !close.After(t) {
+		close = time.Date(y, m, d+1, c.Hour, c.Minute, 0, 0, local.Location())
+	}
+	return close
+}
+
+// SetSessionClose sets when the trading session closes each day. DAY orders
+// accepted afterwards expire at the next close, and are swept from the book
+// by ExpireOrders. Orders already resting keep their expiry, so it should be
+// set before orders are taken.
+func (e *Engine) SetSessionClose(c SessionClose) {
+	e.mu.Lock()
+	e.session = c
+	e.mu.Unlock()
+}
+
+// sessionClose returns the configured session close.
+func (e *Engine) sessionClose() SessionClose {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.session
+}
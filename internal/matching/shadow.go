@@ -0,0 +1,33 @@
+package matching
+
+import (
+	"log"
+	"repello/internal/models"
+)
+
+// shadowCheck is a pre-trade check running in shadow mode.
+type shadowCheck struct {
+	name  string
+	check PreTradeCheck
+}
+
+// AddShadowCheck registers c in shadow mode, for measuring a new rule before
+// enforcing it. It sees every order the enforced checks pass, but on a copy,
+// so it can't shrink the order, and an order it would reject goes ahead: the
+// rejection is counted in the metrics' shadow rejections under name, and
+// logged. Checks must be added before the engine starts processing orders.
+func (e *Engine) AddShadowCheck(name string, c PreTradeCheck) {
+	e.shadow = append(e.shadow, shadowCheck{name: name, check: c})
+}
+
+// runShadowChecks records what the shadow checks would have rejected order
+// for. It must be called with the book locked.
+func (e *Engine) runShadowChecks(order *models.Order, ob *OrderBook) {
+	for _, s := range e.shadow {
+		probe := *order
+		if err := s.check.CheckOrder(&probe, ob.notional(&probe)); err != nil {
+			e.metrics.IncShadowRejections(s.name, order.Symbol, order.Account)
+			log.Printf("shadow check %s: would reject order %s (account %q, %s): %v", s.name, order.ID, order.Account, order.Symbol, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"sort"
+)
+
+// ShiftCommand moves all of Account's resting orders in Symbol by Ticks ticks,
+// up if positive, in a single pass under the book lock. Each order is replaced
+// as by a BatchAmendCommand repricing it, in its original priority order, so
+// the replacements are checked against the symbol's price band and, for firms
+// with the no-cross option, don't trade with the firm's own orders. If any
+// order's new price is invalid, or its replacement fails the engine's own
+// entry checks, none moves; a replacement refused by a pre-trade risk check
+// leaves its original cancelled, as in a batch amend. Pegged orders are left
+// out, since they follow their peg. NewID names the replacements.
+type ShiftCommand struct {
+	Symbol  string
+	Account string
+	Ticks   int64
+	NewID   func() string
+}
+
+// Validate checks that the shift moves something.
+func (c ShiftCommand) Validate() error {
+	if c.Symbol == "" || c.Account == "" {
+		return fmt.Errorf("invalid shift: symbol and account_id are required")
+	}
+	if c.Ticks == 0 {
+		return fmt.Errorf("invalid shift: ticks must not be zero")
+	}
+	return nil
+}
+
+func (c ShiftCommand) apply(e *Engine) (*CommandResult, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	e.mu.RLock()
+	ob, exists := e.OrderBooks[c.Symbol]
+	e.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("order book not found")
+	}
+
+	ob.Lock()
+	defer ob.Unlock()
+
+	var orders []*models.Order
+	for _, order := range ob.Orders {
+		if order.Account == c.Account && order.PegType == models.PegNone {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].Priority.Sequence < orders[j].Priority.Sequence
+	})
+
+	tick := ob.tick()
+	amendments := make([]BatchAmendment, len(orders))
+	indexes := make([]int, len(orders))
+	results := make([]AmendResult, len(orders))
+	for i, order := range orders {
+		amendments[i] = BatchAmendment{
+			OrderID:       order.ID,
+			Price:         order.Price + c.Ticks*tick,
+			ReplacementID: c.NewID(),
+		}
+		indexes[i] = i
+		results[i].OrderID = order.ID
+	}
+	e.amendLocked(ob, amendments, indexes, results)
+	return &CommandResult{Amends: results}, nil
+}
+
+// tick is the book's price increment. It must be called with the book locked.
+func (ob *OrderBook) tick() int64 {
+	switch {
+	case ob.tickSize > 0:
+		return ob.tickSize
+	case ob.ladder != nil:
+		return ob.ladder.TickSize
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,172 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"time"
+)
+
+// SimulatedFill is the quantity an order would take at one price level.
+type SimulatedFill struct {
+	Price    int64 `json:"price"`
+	Quantity int64 `json:"quantity"`
+}
+
+// Simulation is what an order would have received had it been submitted.
+type Simulation struct {
+	Symbol            string           `json:"symbol"`
+	Side              models.Side      `json:"side"`
+	Type              models.OrderType `json:"type"`
+	Quantity          int64            `json:"quantity"`
+	FilledQuantity    int64            `json:"filled_quantity"`
+	RemainingQuantity int64            `json:"remaining_quantity"`
+	Fills             []SimulatedFill  `json:"fills"`
+	// ReferencePrice is the best opposite price before the order, or 0 if that
+	// side of the book is empty.
+	ReferencePrice int64   `json:"reference_price,omitempty"`
+	AveragePrice   float64 `json:"average_price,omitempty"`
+	// Slippage is how far AveragePrice is from ReferencePrice, positive when
+	// the order pays more (buys) or receives less (sells) than the touch.
+	Slippage float64 `json:"slippage"`
+	// SweepCapped is set when the symbol's sweep limit would cancel the rest.
+	SweepCapped bool `json:"sweep_capped,omitempty"`
+}
+
+// SimulateOrder runs order against the current book under a read lock and
+// reports the fills it would get, without changing the book or the order. It
+// returns the same errors ProcessOrder would reject the order with, time in
+// force and fill-or-kill included, except for the pre-trade checks, such as
+// credit, which only run on real orders.
+func (e *Engine) SimulateOrder(order *models.Order) (*Simulation, error) {
+	if err := order.Validate(); err != nil {
+		return nil, err
+	}
+	if order.Type == models.AtFixing {
+		return nil, fmt.Errorf("invalid type: fixing orders can't be simulated before the fixing")
+	}
+	if order.Type == models.StopMarket {
+		return nil, fmt.Errorf("invalid type: stop orders can't be simulated before they trigger")
+	}
+
+	ob := e.getOrderBook(order.Symbol)
+	if order.Type == models.Limit {
+		if err := ob.CheckPrice(order.Price); err != nil {
+			return nil, err
+		}
+	}
+
+	ob.RLock()
+	defer ob.RUnlock()
+
+	if order.Type == models.MarketToLimit {
+		converted := *order
+		if err := ob.convertToLimit(&converted); err != nil {
+			return nil, err
+		}
+		order = &converted
+	}
+
+	resolved := *order
+	if err := ob.resolveTimeInForce(&resolved, time.Now(), e.sessionClose()); err != nil {
+		return nil, err
+	}
+	order = &resolved
+
+	if order.PegType != models.PegNone {
+		pegged := *order
+		pegged.PegLimit = order.Price
+		price, err := ob.pegPrice(&pegged)
+		if err != nil {
+			return nil, err
+		}
+		pegged.Price = price
+		order = &pegged
+	}
+	if order.PostOnly {
+		price, err := ob.postOnlyPrice(order)
+		if err != nil {
+			return nil, err
+		}
+		repriced := *order
+		repriced.Price = price
+		order = &repriced
+	}
+
+	if order.Type == models.Market {
+		available := ob.CalculateLiquidity(order.Side, order.OriginalQuantity)
+		if available < order.OriginalQuantity {
+			return nil, fmt.Errorf("insufficient liquidity: only %d shares available, requested %d", available, order.OriginalQuantity)
+		}
+	}
+
+	if order.TimeInForce == models.FOK {
+		if err := ob.checkFillOrKill(order); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.checkMinQuantity(order, ob, ""); err != nil {
+		return nil, err
+	}
+
+	limit := ob.sweepLimit
+	if limit.enabled() && limit.Action == SweepReject {
+		if err := ob.checkSweep(order); err != nil {
+			return nil, err
+		}
+	}
+
+	sim := &Simulation{
+		Symbol:   order.Symbol,
+		Side:     order.Side,
+		Type:     order.Type,
+		Quantity: order.OriginalQuantity,
+		Fills:    make([]SimulatedFill, 0),
+	}
+
+	remaining := order.OriginalQuantity
+	matches := 0
+	var notional int64
+
+	ob.oppositeSide(order.Side).Walk(func(priceLevel *PriceLevel) bool {
+		if sim.ReferencePrice == 0 {
+			sim.ReferencePrice = priceLevel.Price
+		}
+		if order.Type == models.Limit && !crosses(order, priceLevel.Price) {
+			return false
+		}
+		if limit.MaxLevels > 0 && len(sim.Fills) >= limit.MaxLevels {
+			sim.SweepCapped = true
+			return false
+		}
+
+		fill := SimulatedFill{Price: priceLevel.Price}
+		priceLevel.eachFill(remaining, func(quantity int64) bool {
+			if limit.MaxMatches > 0 && matches >= limit.MaxMatches {
+				sim.SweepCapped = true
+				return false
+			}
+			fill.Quantity += quantity
+			remaining -= quantity
+			matches++
+			return true
+		})
+		if fill.Quantity > 0 {
+			sim.Fills = append(sim.Fills, fill)
+			notional += fill.Price * fill.Quantity
+		}
+		return remaining > 0 && !sim.SweepCapped
+	})
+
+	sim.FilledQuantity = order.OriginalQuantity - remaining
+	sim.RemainingQuantity = remaining
+	if sim.FilledQuantity > 0 {
+		sim.AveragePrice = float64(notional) / float64(sim.FilledQuantity)
+		sim.Slippage = sim.AveragePrice - float64(sim.ReferencePrice)
+		if order.Side == models.Sell {
+			sim.Slippage = -sim.Slippage
+		}
+	}
+
+	return sim, nil
+}
@@ -0,0 +1,73 @@
+package matching
+
+import (
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"time"
+)
+
+// LastTrade is the most recent trade in a book.
+type LastTrade struct {
+	TradeID       string      `json:"trade_id"`
+	Price         int64       `json:"price"`
+	Quantity      int64       `json:"quantity"`
+	AggressorSide models.Side `json:"aggressor_side"`
+	Timestamp     int64       `json:"timestamp"` // UnixNano
+}
+
+func newLastTrade(trade *models.Trade) LastTrade {
+	return LastTrade{
+		TradeID:       trade.ID,
+		Price:         trade.Price,
+		Quantity:      trade.Quantity,
+		AggressorSide: trade.AggressorSide,
+		Timestamp:     trade.Timestamp,
+	}
+}
+
+// MarketSnapshot is everything a client needs to initialize its view of a
+// symbol. Depth, last trade and sequence are read under one book lock, so
+// they are consistent with each other.
+type MarketSnapshot struct {
+	Symbol string `json:"symbol"`
+	// Sequence is the last time priority sequence the book handed out; orders
+	// accepted later have higher ones.
+	Sequence  uint64                       `json:"sequence"`
+	Timestamp int64                        `json:"timestamp"` // UnixNano
+	Bids      []PriceLevelData             `json:"bids"`
+	Asks      []PriceLevelData             `json:"asks"`
+	LastTrade *LastTrade                   `json:"last_trade,omitempty"`
+	Stats     metrics.SymbolActivityReport `json:"stats"`
+}
+
+// MarketSnapshot returns symbol's top depthLimit levels a side (all of them
+// if depthLimit is 0), last trade, sequence and activity, or false if the
+// engine doesn't know symbol.
+func (e *Engine) MarketSnapshot(symbol string, depthLimit int) (MarketSnapshot, bool) {
+	e.mu.RLock()
+	_, exists := e.OrderBooks[symbol]
+	_, laddered := e.ladders[symbol]
+	e.mu.RUnlock()
+	if !exists && !laddered {
+		return MarketSnapshot{}, false
+	}
+
+	ob := e.getOrderBook(symbol)
+	ob.RLock()
+	depth := ob.depth(depthLimit)
+	snapshot := MarketSnapshot{
+		Symbol:    symbol,
+		Sequence:  ob.sequence,
+		Timestamp: time.Now().UnixNano(),
+		Bids:      depth.Bids,
+		Asks:      depth.Asks,
+	}
+	if ob.lastTrade.TradeID != "" {
+		last := ob.lastTrade
+		snapshot.LastTrade = &last
+	}
+	ob.RUnlock()
+
+	snapshot.Stats = e.metrics.Activity(symbol)
+	return snapshot, true
+}
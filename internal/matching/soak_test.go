@@ -0,0 +1,195 @@
+package matching
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSoak drives mixed traffic against a live engine and periodically asserts
+// conservation invariants. It only runs when SOAK_DURATION is set, e.g.
+//
+//	SOAK_DURATION=2h go test -run TestSoak -timeout 0 ./internal/matching
+func TestSoak(t *testing.T) {
+	durationParam := os.Getenv("SOAK_DURATION")
+	if durationParam == "" {
+		t.Skip("SOAK_DURATION not set")
+	}
+	duration, err := time.ParseDuration(durationParam)
+	if err != nil {
+		t.Fatalf("invalid SOAK_DURATION: %v", err)
+	}
+
+	interval := 5 * time.Second
+	if v := os.Getenv("SOAK_INTERVAL"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil {
+			t.Fatalf("invalid SOAK_INTERVAL: %v", err)
+		}
+	}
+
+	m := metrics.NewMetrics()
+	engine := NewEngine(m)
+	symbols := []string{"BTCUSD", "ETHUSD", "SOLUSD", "XRPUSD"}
+	// Exercise the ladder book alongside the default tree
+	if err := engine.ConfigureLadder("XRPUSD", LadderConfig{MinPrice: 1, MaxPrice: 200, TickSize: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for _, symbol := range symbols {
+		engine.getOrderBook(symbol)
+	}
+
+	var tradedQuantity atomic.Int64
+	var tradeCount atomic.Int64
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	workers := 8
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(id int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(id)))
+			var placed []string
+			for n := 0; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				// Cancel roughly one in five orders we've placed.
+				if len(placed) > 0 && r.Intn(5) == 0 {
+					i := r.Intn(len(placed))
+					engine.CancelOrder(placed[i])
+					placed[i] = placed[len(placed)-1]
+					placed = placed[:len(placed)-1]
+					continue
+				}
+
+				// Occasionally shrink one in place instead.
+				if len(placed) > 0 && r.Intn(10) == 0 {
+					engine.ReduceOrder(placed[r.Intn(len(placed))], 1)
+					continue
+				}
+
+				side := models.Buy
+				if r.Intn(2) == 0 {
+					side = models.Sell
+				}
+				orderType := models.Limit
+				if r.Intn(10) == 0 {
+					orderType = models.Market
+				}
+				order := models.NewOrder(
+					fmt.Sprintf("soak-%d-%d", id, n),
+					symbols[r.Intn(len(symbols))],
+					side,
+					orderType,
+					int64(95+r.Intn(11)),
+					int64(1+r.Intn(20)),
+				)
+				result, err := engine.ProcessOrder(order)
+				if err != nil {
+					continue
+				}
+				for _, trade := range result.Trades {
+					tradedQuantity.Add(trade.Quantity)
+				}
+				tradeCount.Add(int64(len(result.Trades)))
+				// The order may already be filled by another worker, in which case
+				// a later cancel is simply rejected.
+				if orderType == models.Limit && len(placed) < 1000 {
+					placed = append(placed, order.ID)
+				}
+			}
+		}(w)
+	}
+
+	deadline := time.After(duration)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case <-deadline:
+			break loop
+		case <-ticker.C:
+			assertSoakInvariants(t, engine, m, nil, nil)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+	assertSoakInvariants(t, engine, m, &tradedQuantity, &tradeCount)
+}
+
+// assertSoakInvariants freezes every book and checks the engine for drift.
+// The traded totals are only comparable once all workers have stopped, so they
+// are optional.
+func assertSoakInvariants(t *testing.T, e *Engine, m *metrics.Metrics, tradedQuantity, tradeCount *atomic.Int64) {
+	t.Helper()
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, ob := range e.OrderBooks {
+		ob.Lock()
+		defer ob.Unlock()
+	}
+
+	var bought, sold, resting int64
+	e.AllOrders.Range(func(_, v any) bool {
+		o := v.(*models.Order)
+		if o.RemainingQuantity < 0 || o.FilledQuantity < 0 {
+			t.Errorf("negative quantity: %s", o)
+		}
+		if o.RemainingQuantity+o.FilledQuantity != o.OriginalQuantity {
+			t.Errorf("quantity not conserved: %s", o)
+		}
+		if o.Side == models.Buy {
+			bought += o.FilledQuantity
+		} else {
+			sold += o.FilledQuantity
+		}
+		return true
+	})
+	if bought != sold {
+		t.Errorf("total bought %d != total sold %d", bought, sold)
+	}
+
+	for symbol, ob := range e.OrderBooks {
+		resting += int64(len(ob.Orders))
+		for _, bookSide := range []BookSide{ob.Bids, ob.Asks} {
+			bookSide.Walk(func(level *PriceLevel) bool {
+				var sum int64
+				for _, o := range level.Orders {
+					sum += o.RemainingQuantity
+				}
+				if sum != level.TotalQuantity {
+					t.Errorf("%s level %d aggregate %d != sum of orders %d", symbol, level.Price, level.TotalQuantity, sum)
+				}
+				return true
+			})
+		}
+		bid, ask := ob.GetBestBid(), ob.GetBestAsk()
+		if bid != nil && ask != nil && bid.Price >= ask.Price {
+			t.Errorf("%s book is crossed: bid %d >= ask %d", symbol, bid.Price, ask.Price)
+		}
+	}
+	if got := m.OrdersInBook.Load(); got != resting {
+		t.Errorf("metrics orders_in_book %d != resting orders %d", got, resting)
+	}
+
+	if tradedQuantity != nil && tradedQuantity.Load() != bought {
+		t.Errorf("traded quantity %d != total bought %d", tradedQuantity.Load(), bought)
+	}
+	if tradeCount != nil && m.TradesExecuted.Load() != tradeCount.Load() {
+		t.Errorf("metrics trades_executed %d != trades observed %d", m.TradesExecuted.Load(), tradeCount.Load())
+	}
+}
@@ -0,0 +1,75 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"strings"
+	"time"
+)
+
+// SetSpeedBump delays symbol's aggressive orders, those that would trade on
+// arrival, by delay before they are sequenced. Passive orders and cancels are
+// not delayed, so resting orders can be pulled while an aggressor waits. It is
+// a market-structure experiment for research deployments; zero turns it off.
+func (e *Engine) SetSpeedBump(symbol string, delay time.Duration) error {
+	if delay < 0 {
+		return fmt.Errorf("invalid speed bump: must not be negative")
+	}
+	ob := e.getOrderBook(symbol)
+	ob.Lock()
+	ob.speedBump = delay
+	ob.Unlock()
+	return nil
+}
+
+// applySpeedBump holds order for its book's speed bump if it is aggressive at
+// arrival. Whether it still is after the delay is up to the book by then.
+func (e *Engine) applySpeedBump(order *models.Order) {
+	e.mu.RLock()
+	ob, exists := e.OrderBooks[order.Symbol]
+	e.mu.RUnlock()
+	if !exists {
+		return
+	}
+
+	ob.RLock()
+	delay := ob.speedBump
+	aggressive := delay > 0 && ob.marketable(order)
+	ob.RUnlock()
+	if aggressive {
+		time.Sleep(delay)
+	}
+}
+
+// marketable reports whether order would trade against the book right now.
+func (ob *OrderBook) marketable(order *models.Order) bool {
+	if order.Type == models.AtFixing || order.Type == models.StopMarket {
+		return false
+	}
+	best := ob.oppositeSide(order.Side).Best()
+	if best == nil {
+		return false
+	}
+	return order.Type == models.Market || order.Type == models.MarketToLimit || crosses(order, best.Price)
+}
+
+// ParseSpeedBumps reads per-symbol speed bumps written as SYMBOL:DELAY[,...],
+// e.g. "BTCUSD:350us".
+func ParseSpeedBumps(s string) (map[string]time.Duration, error) {
+	bumps := make(map[string]time.Duration)
+	if strings.TrimSpace(s) == "" {
+		return bumps, nil
+	}
+	for _, entry := range strings.Split(s, ",") {
+		symbol, delay, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || symbol == "" {
+			return nil, fmt.Errorf("invalid speed bump %q: want SYMBOL:DELAY", entry)
+		}
+		d, err := time.ParseDuration(delay)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid speed bump %q: bad delay", entry)
+		}
+		bumps[symbol] = d
+	}
+	return bumps, nil
+}
@@ -0,0 +1,138 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+	"slices"
+	"time"
+)
+
+// DefaultMaxTriggeredStops is how many stop orders one incoming order may
+// activate in a symbol without its own max_triggered_stops.
+const DefaultMaxTriggeredStops = 100
+
+// queueStop accepts a StopMarket order into the book's stop book, where it
+// waits for the last trade price to reach its stop price. It must be called
+// with the book locked.
+func (e *Engine) queueStop(order *models.Order, ob *OrderBook) (*MatchResult, error) {
+	if ob.stopTriggered(order) {
+		e.AllOrders.Delete(order.ID)
+		return nil, fmt.Errorf("invalid stop price: the last trade at %d has already reached it", ob.lastPrice)
+	}
+	if err := e.runPreTradeChecks(order, ob); err != nil {
+		e.AllOrders.Delete(order.ID)
+		return nil, err
+	}
+
+	order.AcceptedAt = time.Now().UnixNano()
+	ob.assignPriority(order)
+	order.Status = models.Accepted
+	if order.Side == models.Buy {
+		ob.stopBuys = append(ob.stopBuys, order)
+	} else {
+		ob.stopSells = append(ob.stopSells, order)
+	}
+
+	e.emitOrderAccepted(order, ob)
+	return newMatchResult(order), nil
+}
+
+// stopTriggered reports whether the book's last trade has reached order's
+// stop price.
+func (ob *OrderBook) stopTriggered(order *models.Order) bool {
+	if ob.lastPrice == 0 {
+		return false
+	}
+	if order.Side == models.Buy {
+		return ob.lastPrice >= order.StopPrice
+	}
+	return ob.lastPrice <= order.StopPrice
+}
+
+// removeStop takes order out of the stop book, reporting whether it was
+// there. It must be called with the book locked.
+func (ob *OrderBook) removeStop(order *models.Order) bool {
+	queue := &ob.stopSells
+	if order.Side == models.Buy {
+		queue = &ob.stopBuys
+	}
+	i := slices.Index(*queue, order)
+	if i < 0 {
+		return false
+	}
+	*queue = slices.Delete(*queue, i, i+1)
+	return true
+}
+
+// nextTriggeredStop removes and returns the earliest stop order the last
+// trade has triggered, or nil if there is none.
+func (ob *OrderBook) nextTriggeredStop() *models.Order {
+	var next *models.Order
+	for _, queue := range [][]*models.Order{ob.stopBuys, ob.stopSells} {
+		for _, order := range queue {
+			if ob.stopTriggered(order) && (next == nil || order.Priority.Sequence < next.Priority.Sequence) {
+				next = order
+			}
+		}
+	}
+	if next != nil {
+		ob.removeStop(next)
+	}
+	return next
+}
+
+// triggerStops activates every stop order the book's last trade price has
+// reached, earliest first. It runs once the order whose trades moved the price
+// has finished matching, rather than between its fills, and keeps going while
+// the activated orders' own trades trigger more, up to the book's limit on
+// stops per incoming order. Stops still triggered past it stay in the stop
+// book and are activated after the book's next trade, so a cascade is paced
+// by incoming orders rather than run to the end under one lock hold. It must
+// be called with the book locked.
+func (e *Engine) triggerStops(ob *OrderBook) {
+	if len(ob.stopBuys) == 0 && len(ob.stopSells) == 0 {
+		return
+	}
+	limit := ob.maxTriggeredStops
+	if limit == 0 {
+		limit = DefaultMaxTriggeredStops
+	}
+	for activated := 0; activated < limit; activated++ {
+		order := ob.nextTriggeredStop()
+		if order == nil {
+			return
+		}
+		e.activateStop(order, ob)
+		e.cancelLinked(ob)
+	}
+}
+
+// activateStop trades a triggered stop order as an IOC market order,
+// cancelling whatever the book can't fill.
+func (e *Engine) activateStop(order *models.Order, ob *OrderBook) {
+	order.TriggeredAt = time.Now().UnixNano()
+	order.TimeInForce = models.IOC
+	e.emitOrderAccepted(order, ob)
+	result := newMatchResult(order)
+	defer result.Release()
+
+	if noCross := e.noCrossFirm(order); noCross != "" {
+		e.matchNoCross(order, ob, result, noCross)
+	} else {
+		e.processMarketOrder(order, ob, result)
+	}
+
+	tradeCount := int64(len(result.Trades))
+	e.metrics.IncTradesExecuted(tradeCount)
+	if tradeCount > 0 {
+		e.metrics.IncOrdersMatched(tradeCount + 1)
+	}
+
+	if order.RemainingQuantity > 0 {
+		order.SetStatus(models.Cancelled)
+		e.metrics.IncOrdersCancelled()
+		e.emitOrderCancelled(order, ob)
+	} else {
+		order.SetStatus(models.Filled)
+	}
+}
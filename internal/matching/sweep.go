@@ -0,0 +1,166 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/models"
+)
+
+// SweepAction decides what happens to an order that would sweep past its
+// symbol's SweepLimit.
+type SweepAction int
+
+const (
+	// SweepCancelRemainder matches up to the limit and cancels the rest.
+	SweepCancelRemainder SweepAction = iota
+	// SweepReject rejects the order outright without matching.
+	SweepReject
+)
+
+func (a SweepAction) String() string {
+	switch a {
+	case SweepCancelRemainder:
+		return "CANCEL_REMAINDER"
+	case SweepReject:
+		return "REJECT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (a SweepAction) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + a.String() + `"`), nil
+}
+
+func (a *SweepAction) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
+		str = str[1 : len(str)-1]
+	}
+	switch str {
+	case "", "CANCEL_REMAINDER":
+		*a = SweepCancelRemainder
+	case "REJECT":
+		*a = SweepReject
+	default:
+		return fmt.Errorf("unknown sweep action: %s", str)
+	}
+	return nil
+}
+
+// SweepLimit caps how much of the book a single aggressive order may consume
+// in one matching cycle, so one enormous order can't hold the book lock for
+// long. Zero values mean no cap.
+type SweepLimit struct {
+	MaxLevels  int         `json:"max_levels,omitempty"`
+	MaxMatches int         `json:"max_matches,omitempty"`
+	Action     SweepAction `json:"action,omitempty"`
+}
+
+func (l SweepLimit) enabled() bool {
+	return l.MaxLevels > 0 || l.MaxMatches > 0
+}
+
+// allows reports whether another fill at price fits under the limit, given the
+// fills already in result.
+func (l SweepLimit) allows(result *MatchResult, price int64) bool {
+	n := len(result.Trades)
+	if l.MaxMatches > 0 && n >= l.MaxMatches {
+		return false
+	}
+	if l.MaxLevels > 0 && result.levels >= l.MaxLevels && result.Trades[n-1].Price != price {
+		return false
+	}
+	return true
+}
+
+// SetSweepLimit sets the sweep protection for symbol. It applies to orders
+// processed after the call.
+func (e *Engine) SetSweepLimit(symbol string, limit SweepLimit) {
+	ob := e.getOrderBook(symbol)
+	ob.Lock()
+	ob.sweepLimit = limit
+	ob.Unlock()
+}
+
+// checkSweep walks the resting orders an incoming order would trade against
+// and returns an error if filling it would break the book's sweep limit.
+func (ob *OrderBook) checkSweep(order *models.Order) error {
+	limit := ob.sweepLimit
+	remaining := order.RemainingQuantity
+	levels, matches := 0, 0
+	var exceeded error
+
+	ob.oppositeSide(order.Side).Walk(func(priceLevel *PriceLevel) bool {
+		if order.Type == models.Limit && !crosses(order, priceLevel.Price) {
+			return false
+		}
+		levels++
+		if limit.MaxLevels > 0 && levels > limit.MaxLevels {
+			exceeded = fmt.Errorf("sweep limit exceeded: order would match more than %d price levels", limit.MaxLevels)
+			return false
+		}
+		priceLevel.eachFill(remaining, func(quantity int64) bool {
+			matches++
+			if limit.MaxMatches > 0 && matches > limit.MaxMatches {
+				exceeded = fmt.Errorf("sweep limit exceeded: order would match more than %d resting orders", limit.MaxMatches)
+				return false
+			}
+			remaining -= quantity
+			return true
+		})
+		return exceeded == nil && remaining > 0
+	})
+
+	return exceeded
+}
+
+// eachFill calls fill with the quantity of each trade an incoming order for
+// quantity would make against the level, in the order matching makes them.
+// An iceberg trades one visible slice at a time, each new slice joining the
+// back of the level's displayed orders, so it can make several. It stops once
+// quantity is used up or fill returns false.
+func (level *PriceLevel) eachFill(quantity int64, fill func(quantity int64) bool) {
+	type slice struct {
+		remaining, visible, display int64
+	}
+	var shown, hidden []slice
+	for _, order := range level.Orders {
+		s := slice{remaining: order.RemainingQuantity, visible: order.RemainingQuantity}
+		if order.DisplayQuantity > 0 {
+			s.visible, s.display = order.VisibleQuantity, order.DisplayQuantity
+		}
+		if order.Hidden {
+			hidden = append(hidden, s)
+		} else {
+			shown = append(shown, s)
+		}
+	}
+
+	for i := 0; i < len(shown)+len(hidden) && quantity > 0; i++ {
+		var s slice
+		if i < len(shown) {
+			s = shown[i]
+		} else {
+			s = hidden[i-len(shown)]
+		}
+		n := min(quantity, s.visible)
+		if !fill(n) {
+			return
+		}
+		quantity -= n
+		s.remaining -= n
+		s.visible -= n
+		if s.display > 0 && s.visible == 0 && s.remaining > 0 && i < len(shown) {
+			s.visible = min(s.display, s.remaining)
+			shown = append(shown, s)
+		}
+	}
+}
+
+// crosses reports whether a limit order can trade at price.
+func crosses(order *models.Order, price int64) bool {
+	if order.Side == models.Buy {
+		return order.Price >= price
+	}
+	return order.Price <= price
+}
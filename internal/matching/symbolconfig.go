@@ -0,0 +1,210 @@
+package matching
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"repello/internal/models"
+	"slices"
+	"time"
+)
+
+// MatchingPolicy decides which resting orders an aggressive order trades
+// against first. Price-time (FIFO) is the only policy so far.
+type MatchingPolicy int
+
+const (
+	FIFO MatchingPolicy = iota
+)
+
+func (p MatchingPolicy) String() string {
+	switch p {
+	case FIFO:
+		return "FIFO"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (p MatchingPolicy) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + p.String() + `"`), nil
+}
+
+func (p *MatchingPolicy) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
+		str = str[1 : len(str)-1]
+	}
+	switch str {
+	case "", "FIFO":
+		*p = FIFO
+	default:
+		return fmt.Errorf("unsupported matching policy: %s", str)
+	}
+	return nil
+}
+
+// Duration is a time.Duration written as a string such as "350us" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration: %s", data)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %s", s)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// SymbolConfig overrides the engine's matching behaviour for one symbol, so
+// markets with different microstructures can share a deployment. Zero fields
+// keep the engine's defaults.
+type SymbolConfig struct {
+	Policy MatchingPolicy `json:"policy,omitempty"`
+	// TickSize and LotSize require limit prices and quantities to be
+	// multiples of them. Ladder books also keep their own tick.
+	TickSize int64 `json:"tick_size,omitempty"`
+	LotSize  int64 `json:"lot_size,omitempty"`
+	// OrderTypes lists the order types the symbol accepts; empty allows all.
+	OrderTypes         []models.OrderType `json:"order_types,omitempty"`
+	DefaultTimeInForce models.TimeInForce `json:"default_time_in_force,omitempty"`
+	SweepLimit         SweepLimit         `json:"sweep_limit,omitempty"`
+	// PriceBandBps rejects limit orders priced further than this many basis
+	// points from the symbol's last trade. There is no band before the
+	// first trade.
+	PriceBandBps int64    `json:"price_band_bps,omitempty"`
+	SpeedBump    Duration `json:"speed_bump,omitempty"`
+	// MaxTriggeredStops caps how many stop orders one incoming order may
+	// activate, counting those triggered by other stops' trades. Zero means
+	// DefaultMaxTriggeredStops.
+	MaxTriggeredStops int `json:"max_triggered_stops,omitempty"`
+}
+
+func (c SymbolConfig) Validate() error {
+	if c.TickSize < 0 || c.LotSize < 0 {
+		return fmt.Errorf("invalid tick or lot size: must not be negative")
+	}
+	for _, t := range c.OrderTypes {
+		if t != models.Limit && t != models.Market && t != models.AtFixing && t != models.StopMarket && t != models.MarketToLimit {
+			return fmt.Errorf("invalid order types: unknown type %d", t)
+		}
+	}
+	switch c.DefaultTimeInForce {
+	case models.TIFDefault, models.GTC, models.DAY, models.IOC, models.FOK:
+	default:
+		return fmt.Errorf("invalid time in force: %s can't be a symbol default", c.DefaultTimeInForce)
+	}
+	if c.SweepLimit.MaxLevels < 0 || c.SweepLimit.MaxMatches < 0 {
+		return fmt.Errorf("invalid sweep limit: must not be negative")
+	}
+	if c.PriceBandBps < 0 {
+		return fmt.Errorf("invalid price band: must not be negative")
+	}
+	if c.SpeedBump < 0 {
+		return fmt.Errorf("invalid speed bump: must not be negative")
+	}
+	if c.MaxTriggeredStops < 0 {
+		return fmt.Errorf("invalid max triggered stops: must not be negative")
+	}
+	return nil
+}
+
+// ConfigureSymbols replaces every symbol's overrides with configs, keyed by
+// symbol. Symbols configured before but missing from configs go back to the
+// defaults, so configs can be reloaded from a file while the engine runs. It
+// also replaces settings made with SetSweepLimit, SetDefaultTimeInForce and
+// SetSpeedBump on those symbols. Nothing changes if any config is invalid.
+func (e *Engine) ConfigureSymbols(configs map[string]SymbolConfig) error {
+	for symbol, cfg := range configs {
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", symbol, err)
+		}
+	}
+
+	e.mu.Lock()
+	previous := e.symbolConfigs
+	e.symbolConfigs = make(map[string]SymbolConfig, len(configs))
+	for symbol, cfg := range configs {
+		e.symbolConfigs[symbol] = cfg
+	}
+	e.mu.Unlock()
+
+	for symbol := range previous {
+		if _, ok := configs[symbol]; !ok {
+			e.getOrderBook(symbol).configure(SymbolConfig{})
+		}
+	}
+	for symbol, cfg := range configs {
+		e.getOrderBook(symbol).configure(cfg)
+	}
+	return nil
+}
+
+// SymbolConfigs returns the overrides set by ConfigureSymbols.
+func (e *Engine) SymbolConfigs() map[string]SymbolConfig {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	configs := make(map[string]SymbolConfig, len(e.symbolConfigs))
+	for symbol, cfg := range e.symbolConfigs {
+		configs[symbol] = cfg
+	}
+	return configs
+}
+
+// ReadSymbolConfigs reads a JSON object of SymbolConfigs keyed by symbol.
+func ReadSymbolConfigs(path string) (map[string]SymbolConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs map[string]SymbolConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return configs, nil
+}
+
+func (ob *OrderBook) configure(cfg SymbolConfig) {
+	ob.Lock()
+	defer ob.Unlock()
+	ob.tickSize = cfg.TickSize
+	ob.lotSize = cfg.LotSize
+	ob.orderTypes = slices.Clone(cfg.OrderTypes)
+	ob.defaultTIF = cfg.DefaultTimeInForce
+	ob.sweepLimit = cfg.SweepLimit
+	ob.priceBandBps = cfg.PriceBandBps
+	ob.speedBump = time.Duration(cfg.SpeedBump)
+	ob.maxTriggeredStops = cfg.MaxTriggeredStops
+}
+
+// checkSymbolRules enforces the book's order type whitelist, tick and lot
+// sizes, and price band. It must be called with the book locked.
+func (ob *OrderBook) checkSymbolRules(order *models.Order) error {
+	if len(ob.orderTypes) > 0 && !slices.Contains(ob.orderTypes, order.Type) {
+		return fmt.Errorf("invalid type: %s orders are not accepted for %s", order.Type, ob.Symbol)
+	}
+	if ob.tickSize > 0 && order.Type == models.Limit && order.Price%ob.tickSize != 0 {
+		return fmt.Errorf("invalid price: must be a multiple of tick size %d", ob.tickSize)
+	}
+	if ob.lotSize > 0 && order.OriginalQuantity%ob.lotSize != 0 {
+		return fmt.Errorf("invalid quantity: must be a multiple of lot size %d", ob.lotSize)
+	}
+	if ob.lotSize > 0 && order.DisplayQuantity%ob.lotSize != 0 {
+		return fmt.Errorf("invalid display quantity: must be a multiple of lot size %d", ob.lotSize)
+	}
+	if ob.priceBandBps > 0 && ob.lastPrice > 0 && order.Type == models.Limit {
+		width := ob.lastPrice * ob.priceBandBps / 10000
+		if order.Price < ob.lastPrice-width || order.Price > ob.lastPrice+width {
+			return fmt.Errorf("price band: %d is outside %d ± %d", order.Price, ob.lastPrice, width)
+		}
+	}
+	return nil
+}
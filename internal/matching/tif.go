@@ -0,0 +1,120 @@
+package matching
+
+import (
+	"context"
+	"fmt"
+	"repello/internal/models"
+	"time"
+)
+
+// SetDefaultTimeInForce sets the time in force given to symbol's limit orders
+// that don't specify one. Without a default they are GTC; market orders are
+// always IOC unless they ask for FOK.
+func (e *Engine) SetDefaultTimeInForce(symbol string, tif models.TimeInForce) error {
+	switch tif {
+	case models.GTC, models.DAY, models.IOC, models.FOK:
+	default:
+		return fmt.Errorf("invalid time in force: %s can't be a symbol default", tif)
+	}
+
+	ob := e.getOrderBook(symbol)
+	ob.Lock()
+	ob.defaultTIF = tif
+	ob.Unlock()
+	return nil
+}
+
+// resolveTimeInForce fills in order's time in force from the book's default and
+// works out when it expires, DAY orders at the next session close. It must be
+// called with the book locked.
+func (ob *OrderBook) resolveTimeInForce(order *models.Order, now time.Time, session SessionClose) error {
+	if order.TimeInForce == models.TIFDefault {
+		switch {
+		case order.Type == models.Market:
+			order.TimeInForce = models.IOC
+		case ob.defaultTIF != models.TIFDefault:
+			order.TimeInForce = ob.defaultTIF
+		default:
+			order.TimeInForce = models.GTC
+		}
+	}
+
+	switch order.TimeInForce {
+	case models.DAY:
+		order.ExpireAt = session.next(now).UnixNano()
+	case models.GTD:
+		if order.ExpireAt <= now.UnixNano() {
+			return fmt.Errorf("invalid expiry: expiry time has already passed")
+		}
+	}
+	return nil
+}
+
+// checkFillOrKill returns an error unless order can be filled completely right
+// now, including within the book's sweep limit.
+func (ob *OrderBook) checkFillOrKill(order *models.Order) error {
+	var available int64
+	if order.Type == models.Limit {
+		available = ob.CalculateLiquidityWithinPrice(order.Side, order.Price, order.OriginalQuantity)
+	} else {
+		available = ob.CalculateLiquidity(order.Side, order.OriginalQuantity)
+	}
+	if available < order.OriginalQuantity {
+		return fmt.Errorf("fill or kill: only %d shares available, requested %d", available, order.OriginalQuantity)
+	}
+	if ob.sweepLimit.enabled() {
+		return ob.checkSweep(order)
+	}
+	return nil
+}
+
+// ExpireOrders removes every resting DAY or GTD order whose expiry is at or
+// before now, and returns how many it removed. DAY orders are cancelled at
+// their session close, with SessionCloseCancelReason; GTD orders expire.
+func (e *Engine) ExpireOrders(now time.Time) int {
+	e.mu.RLock()
+	books := make([]*OrderBook, 0, len(e.OrderBooks))
+	for _, ob := range e.OrderBooks {
+		books = append(books, ob)
+	}
+	e.mu.RUnlock()
+
+	cutoff := now.UnixNano()
+	expired := 0
+	for _, ob := range books {
+		ob.Lock()
+		for id, order := range ob.expiring {
+			if order.ExpireAt > cutoff {
+				continue
+			}
+			ob.RemoveOrder(id)
+			if order.TimeInForce == models.DAY {
+				order.CancelReason = SessionCloseCancelReason
+				order.SetStatus(models.Cancelled)
+			} else {
+				order.SetStatus(models.Expired)
+			}
+			e.metrics.IncOrdersCancelled()
+			e.metrics.DecOrdersInBook()
+			e.metrics.IncSymbolCancels(order.Symbol)
+			e.emitOrderCancelled(order, ob)
+			expired++
+		}
+		ob.Unlock()
+	}
+	return expired
+}
+
+// RunExpiry calls ExpireOrders every interval until ctx is done.
+func (e *Engine) RunExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			e.ExpireOrders(now)
+		}
+	}
+}
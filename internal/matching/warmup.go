@@ -0,0 +1,79 @@
+package matching
+
+import (
+	"fmt"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"time"
+)
+
+// WarmUpConfig controls Engine.WarmUp.
+type WarmUpConfig struct {
+	// Symbols get their books created up front, in addition to every symbol
+	// with a symbol config or ladder.
+	Symbols []string
+	// OrdersPerBook pre-sizes each empty book's order index for this many
+	// resting orders, so it doesn't grow under load. Zero leaves it to grow.
+	OrdersPerBook int
+	// SyntheticOrders is how many orders to match on a scratch engine before
+	// taking real ones, to grow the heap and prime the match result pool.
+	// Zero skips it.
+	SyntheticOrders int
+}
+
+// WarmUp prepares the engine for its first orders so they don't pay for book
+// creation and map growth. Synthetic orders run on a separate engine, so the
+// books, sequences, metrics and listeners of this one are untouched. It
+// should run at startup, before orders are taken, and returns how long it
+// took.
+func (e *Engine) WarmUp(cfg WarmUpConfig) time.Duration {
+	start := time.Now()
+
+	e.mu.RLock()
+	symbols := append([]string(nil), cfg.Symbols...)
+	for symbol := range e.symbolConfigs {
+		symbols = append(symbols, symbol)
+	}
+	for symbol := range e.ladders {
+		symbols = append(symbols, symbol)
+	}
+	e.mu.RUnlock()
+
+	for _, symbol := range symbols {
+		ob := e.getOrderBook(symbol)
+		if cfg.OrdersPerBook <= 0 {
+			continue
+		}
+		ob.Lock()
+		if len(ob.Orders) == 0 {
+			ob.Orders = make(map[string]*models.Order, cfg.OrdersPerBook)
+		}
+		ob.Unlock()
+	}
+
+	if cfg.SyntheticOrders > 0 {
+		warmUpLoad(cfg.SyntheticOrders)
+	}
+	return time.Since(start)
+}
+
+// warmUpLoad matches n orders on a scratch book: bids and asks stepping
+// through a narrow price range, so some rest, some cross several levels and
+// some are cancelled.
+func warmUpLoad(n int) {
+	scratch := NewEngine(metrics.NewMetrics())
+	for i := 0; i < n; i++ {
+		side := models.Buy
+		if i%2 == 1 {
+			side = models.Sell
+		}
+		price := int64(1000 + i%7 - 3)
+		order := models.NewOrder(fmt.Sprintf("warmup-%d", i), "WARMUP", side, models.Limit, price, int64(1+i%5))
+		if result, err := scratch.ProcessOrder(order); err == nil {
+			result.Release()
+		}
+		if i%10 == 9 {
+			scratch.CancelOrder(order.ID)
+		}
+	}
+}
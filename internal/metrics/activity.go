@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ActivityWindowSeconds is how far back per-symbol event rates look.
+const ActivityWindowSeconds = 60
+
+// SymbolActivity counts the book events of a single symbol: orders added to
+// the book, resting orders cancelled or expired, and executions with their
+// volume and notional turnover. The engine records a symbol's events under its
+// book lock, so every counter has a single writer.
+type SymbolActivity struct {
+	Adds       atomic.Int64
+	Cancels    atomic.Int64
+	Executions atomic.Int64
+	Volume     atomic.Int64 // quantity traded
+	Turnover   atomic.Int64 // notional traded, price times quantity
+
+	addRate, cancelRate, executionRate rateWindow
+}
+
+// rateWindow counts events in one-second buckets over the last
+// ActivityWindowSeconds.
+type rateWindow struct {
+	secs   [ActivityWindowSeconds]atomic.Int64
+	counts [ActivityWindowSeconds]atomic.Int64
+}
+
+func (w *rateWindow) add(sec int64) {
+	i := sec % ActivityWindowSeconds
+	if w.secs[i].Load() != sec {
+		w.secs[i].Store(sec)
+		w.counts[i].Store(0)
+	}
+	w.counts[i].Add(1)
+}
+
+// perSecond averages the completed seconds of the window ending before now.
+func (w *rateWindow) perSecond(now int64) float64 {
+	var total int64
+	for i := range w.secs {
+		if sec := w.secs[i].Load(); sec < now && sec >= now-ActivityWindowSeconds {
+			total += w.counts[i].Load()
+		}
+	}
+	return float64(total) / ActivityWindowSeconds
+}
+
+// SymbolActivityReport is a symbol's activity totals and recent rates.
+type SymbolActivityReport struct {
+	Symbol           string  `json:"symbol"`
+	Adds             int64   `json:"adds"`
+	Cancels          int64   `json:"cancels"`
+	Executions       int64   `json:"executions"`
+	Volume           int64   `json:"volume"`
+	Turnover         int64   `json:"turnover"`
+	AddsPerSec       float64 `json:"adds_per_sec"`
+	CancelsPerSec    float64 `json:"cancels_per_sec"`
+	ExecutionsPerSec float64 `json:"executions_per_sec"`
+}
+
+func (m *Metrics) activity(symbol string) *SymbolActivity {
+	val, ok := m.symbolActivity.Load(symbol)
+	if !ok {
+		val, _ = m.symbolActivity.LoadOrStore(symbol, &SymbolActivity{})
+	}
+	return val.(*SymbolActivity)
+}
+
+// IncSymbolAdds records an order coming to rest in symbol's book.
+func (m *Metrics) IncSymbolAdds(symbol string) {
+	a := m.activity(symbol)
+	a.Adds.Add(1)
+	a.addRate.add(time.Now().Unix())
+}
+
+// IncSymbolCancels records a resting order leaving symbol's book unfilled,
+// by cancel or expiry.
+func (m *Metrics) IncSymbolCancels(symbol string) {
+	a := m.activity(symbol)
+	a.Cancels.Add(1)
+	a.cancelRate.add(time.Now().Unix())
+}
+
+// AddSymbolExecution records a trade of quantity at price in symbol.
+func (m *Metrics) AddSymbolExecution(symbol string, quantity, price int64) {
+	a := m.activity(symbol)
+	a.Executions.Add(1)
+	a.Volume.Add(quantity)
+	a.Turnover.Add(quantity * price)
+	a.executionRate.add(time.Now().Unix())
+}
+
+// Activity reports symbol's book activity.
+func (m *Metrics) Activity(symbol string) SymbolActivityReport {
+	return m.activity(symbol).report(symbol, time.Now().Unix())
+}
+
+func (a *SymbolActivity) report(symbol string, now int64) SymbolActivityReport {
+	return SymbolActivityReport{
+		Symbol:           symbol,
+		Adds:             a.Adds.Load(),
+		Cancels:          a.Cancels.Load(),
+		Executions:       a.Executions.Load(),
+		Volume:           a.Volume.Load(),
+		Turnover:         a.Turnover.Load(),
+		AddsPerSec:       a.addRate.perSecond(now),
+		CancelsPerSec:    a.cancelRate.perSecond(now),
+		ExecutionsPerSec: a.executionRate.perSecond(now),
+	}
+}
+
+// topSymbolActivity reports the n symbols with the most book events.
+func (m *Metrics) topSymbolActivity(n int) []SymbolActivityReport {
+	now := time.Now().Unix()
+	reports := make([]SymbolActivityReport, 0)
+	m.symbolActivity.Range(func(k, v any) bool {
+		reports = append(reports, v.(*SymbolActivity).report(k.(string), now))
+		return true
+	})
+
+	events := func(r SymbolActivityReport) int64 { return r.Adds + r.Cancels + r.Executions }
+	sort.Slice(reports, func(i, j int) bool {
+		if events(reports[i]) != events(reports[j]) {
+			return events(reports[i]) > events(reports[j])
+		}
+		return reports[i].Symbol < reports[j].Symbol
+	})
+	if len(reports) > n {
+		reports = reports[:n]
+	}
+	return reports
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateWindow(t *testing.T) {
+	var w rateWindow
+	for i := 0; i < 30; i++ {
+		w.add(1000)
+	}
+	w.add(1001)
+	w.add(1059)
+
+	// Only completed seconds count
+	assert.Equal(t, 30.0/ActivityWindowSeconds, w.perSecond(1001))
+	assert.Equal(t, 32.0/ActivityWindowSeconds, w.perSecond(1060))
+	// Second 1000 has left the window
+	assert.Equal(t, 2.0/ActivityWindowSeconds, w.perSecond(1061))
+
+	// A reused bucket starts from zero
+	w.add(1060)
+	assert.Equal(t, 3.0/ActivityWindowSeconds, w.perSecond(1061))
+}
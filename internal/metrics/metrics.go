@@ -3,12 +3,18 @@ package metrics
 import (
 	"encoding/json"
 	"math"
+	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 const (
 	MaxLatencyMicros = 100000 // Track up to 100ms with 1us precision
+
+	// Per-symbol histograms are kept smaller since there is one per symbol
+	MaxSymbolLatencyMicros = 10000 // Track up to 10ms with 1us precision
+	TopSymbolsReported     = 10    // Busiest symbols included in /metrics
 )
 
 type Metrics struct {
@@ -16,14 +22,32 @@ type Metrics struct {
 	OrdersReceived  atomic.Int64
 	OrdersMatched   atomic.Int64
 	OrdersCancelled atomic.Int64
+	OrdersRepriced  atomic.Int64 // pegged orders moved with the book
 	OrdersInBook    atomic.Int64
 	TradesExecuted  atomic.Int64
 	TotalLatency    atomic.Int64 // in microseconds
+	// RecoveryDuration is how long the server spent recovering state at
+	// startup, in microseconds
+	RecoveryDuration atomic.Int64
 	
 	// Histogram for accurate percentiles
 	// Index i stores count of requests taking i microseconds.
 	// Last index stores all requests >= MaxLatencyMicros
 	LatencyHistogram [MaxLatencyMicros + 1]atomic.Int64
+
+	// Map[string]*SymbolLatency - matching latency broken down by symbol
+	symbolLatency sync.Map
+	// Map[string]*SymbolActivity - book events broken down by symbol
+	symbolActivity sync.Map
+	rejections     rejections
+	shadow         rejections // by shadow-mode check rather than reason
+}
+
+// SymbolLatency tracks matching latency for a single symbol.
+type SymbolLatency struct {
+	Count            atomic.Int64
+	TotalLatency     atomic.Int64 // in microseconds
+	LatencyHistogram [MaxSymbolLatencyMicros + 1]atomic.Int64
 }
 
 func NewMetrics() *Metrics {
@@ -44,6 +68,10 @@ func (m *Metrics) IncOrdersCancelled() {
 	m.OrdersCancelled.Add(1)
 }
 
+func (m *Metrics) IncOrdersRepriced() {
+	m.OrdersRepriced.Add(1)
+}
+
 func (m *Metrics) IncOrdersInBook() {
 	m.OrdersInBook.Add(1)
 }
@@ -52,6 +80,10 @@ func (m *Metrics) DecOrdersInBook() {
 	m.OrdersInBook.Add(-1)
 }
 
+func (m *Metrics) SetRecoveryDuration(d time.Duration) {
+	m.RecoveryDuration.Store(d.Microseconds())
+}
+
 func (m *Metrics) IncTradesExecuted(count int64) {
 	m.TradesExecuted.Add(count)
 }
@@ -67,23 +99,89 @@ func (m *Metrics) AddLatency(microseconds int64) {
 	m.LatencyHistogram[idx].Add(1)
 }
 
+func (m *Metrics) AddSymbolLatency(symbol string, microseconds int64) {
+	val, ok := m.symbolLatency.Load(symbol)
+	if !ok {
+		val, _ = m.symbolLatency.LoadOrStore(symbol, &SymbolLatency{})
+	}
+	sl := val.(*SymbolLatency)
+	sl.Count.Add(1)
+	sl.TotalLatency.Add(microseconds)
+
+	idx := microseconds
+	if idx > MaxSymbolLatencyMicros {
+		idx = MaxSymbolLatencyMicros
+	}
+	sl.LatencyHistogram[idx].Add(1)
+}
+
 // calculatePercentile returns the latency value (in ms) below which the given percentile falls
 func (m *Metrics) calculatePercentile(p float64, totalCount int64) float64 {
+	return percentile(m.LatencyHistogram[:], p, totalCount)
+}
+
+// percentile walks a 1us-bucket histogram whose last bucket holds everything
+// at or above its range, returning the latency in ms.
+func percentile(histogram []atomic.Int64, p float64, totalCount int64) float64 {
 	if totalCount == 0 {
 		return 0
 	}
 	targetCount := int64(math.Ceil(float64(totalCount) * p))
 	var currentCount int64 = 0
-	
-	for i := 0; i <= MaxLatencyMicros; i++ {
-		count := m.LatencyHistogram[i].Load()
+
+	for i := range histogram {
+		count := histogram[i].Load()
 		currentCount += count
 		if currentCount >= targetCount {
 			// Convert micros to millis
 			return float64(i) / 1000.0
 		}
 	}
-	return float64(MaxLatencyMicros) / 1000.0
+	return float64(len(histogram)-1) / 1000.0
+}
+
+type symbolLatencyReport struct {
+	Symbol     string  `json:"symbol"`
+	Orders     int64   `json:"orders"`
+	AvgLatency float64 `json:"latency_avg_ms"`
+	P50        float64 `json:"latency_p50_ms"`
+	P99        float64 `json:"latency_p99_ms"`
+	P999       float64 `json:"latency_p999_ms"`
+}
+
+// topSymbolLatency reports latency percentiles for the n symbols that have
+// processed the most orders.
+func (m *Metrics) topSymbolLatency(n int) []symbolLatencyReport {
+	reports := make([]symbolLatencyReport, 0)
+	histograms := make(map[string]*SymbolLatency)
+	m.symbolLatency.Range(func(k, v any) bool {
+		symbol, sl := k.(string), v.(*SymbolLatency)
+		histograms[symbol] = sl
+		reports = append(reports, symbolLatencyReport{Symbol: symbol, Orders: sl.Count.Load()})
+		return true
+	})
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Orders != reports[j].Orders {
+			return reports[i].Orders > reports[j].Orders
+		}
+		return reports[i].Symbol < reports[j].Symbol
+	})
+	if len(reports) > n {
+		reports = reports[:n]
+	}
+
+	for i := range reports {
+		sl := histograms[reports[i].Symbol]
+		count := reports[i].Orders
+		if count > 0 {
+			reports[i].AvgLatency = float64(sl.TotalLatency.Load()) / float64(count) / 1000.0
+		}
+		reports[i].P50 = percentile(sl.LatencyHistogram[:], 0.50, count)
+		reports[i].P99 = percentile(sl.LatencyHistogram[:], 0.99, count)
+		reports[i].P999 = percentile(sl.LatencyHistogram[:], 0.999, count)
+	}
+	return reports
 }
 
 func (m *Metrics) MarshalJSON() ([]byte, error) {
@@ -108,6 +206,7 @@ func (m *Metrics) MarshalJSON() ([]byte, error) {
 		"orders_received":           totalOrders,
 		"orders_matched":            m.OrdersMatched.Load(),
 		"orders_cancelled":          m.OrdersCancelled.Load(),
+		"orders_repriced":           m.OrdersRepriced.Load(),
 		"orders_in_book":            m.OrdersInBook.Load(),
 		"trades_executed":           m.TradesExecuted.Load(),
 		"latency_avg_ms":            avgLatency,
@@ -115,5 +214,10 @@ func (m *Metrics) MarshalJSON() ([]byte, error) {
 		"latency_p99_ms":            p99,
 		"latency_p999_ms":           p999,
 		"throughput_orders_per_sec": throughput,
+		"recovery_duration_ms":      float64(m.RecoveryDuration.Load()) / 1000.0,
+		"symbol_latency":            m.topSymbolLatency(TopSymbolsReported),
+		"symbol_activity":           m.topSymbolActivity(TopSymbolsReported),
+		"rejections":                m.rejectionTotals(),
+		"shadow_rejections":         m.shadow.totals(),
 	})
 }
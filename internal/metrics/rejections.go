@@ -0,0 +1,102 @@
+package metrics
+
+import "sync"
+
+// rejections counts rejected orders by reason code, in total and per symbol
+// and account. Rejections are rare next to orders, so a mutex is enough.
+type rejections struct {
+	mu        sync.Mutex
+	total     map[string]int64
+	bySymbol  map[string]map[string]int64
+	byAccount map[string]map[string]int64
+}
+
+// RejectionReport is rejection counts keyed by reason code.
+type RejectionReport struct {
+	Total     map[string]int64            `json:"total"`
+	BySymbol  map[string]map[string]int64 `json:"by_symbol"`
+	ByAccount map[string]map[string]int64 `json:"by_account"`
+}
+
+// IncRejections records an order from account in symbol rejected for reason.
+// Either may be empty, e.g. for orders without an account.
+func (m *Metrics) IncRejections(reason, symbol, account string) {
+	m.rejections.inc(reason, symbol, account)
+}
+
+// IncShadowRejections records an order from account in symbol that the
+// shadow-mode check rule would have rejected.
+func (m *Metrics) IncShadowRejections(rule, symbol, account string) {
+	m.shadow.inc(rule, symbol, account)
+}
+
+func (r *rejections) inc(reason, symbol, account string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.total == nil {
+		r.total = make(map[string]int64)
+		r.bySymbol = make(map[string]map[string]int64)
+		r.byAccount = make(map[string]map[string]int64)
+	}
+	r.total[reason]++
+	if symbol != "" {
+		count(r.bySymbol, symbol, reason)
+	}
+	if account != "" {
+		count(r.byAccount, account, reason)
+	}
+}
+
+func count(counts map[string]map[string]int64, key, reason string) {
+	if counts[key] == nil {
+		counts[key] = make(map[string]int64)
+	}
+	counts[key][reason]++
+}
+
+// Rejections reports the rejection counts so far.
+func (m *Metrics) Rejections() RejectionReport {
+	return m.rejections.report()
+}
+
+// ShadowRejections reports, keyed by rule, the orders shadow-mode checks
+// would have rejected so far.
+func (m *Metrics) ShadowRejections() RejectionReport {
+	return m.shadow.report()
+}
+
+func (r *rejections) report() RejectionReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return RejectionReport{
+		Total:     copyCounts(r.total),
+		BySymbol:  copyNested(r.bySymbol),
+		ByAccount: copyNested(r.byAccount),
+	}
+}
+
+func copyCounts(counts map[string]int64) map[string]int64 {
+	out := make(map[string]int64, len(counts))
+	for k, v := range counts {
+		out[k] = v
+	}
+	return out
+}
+
+func copyNested(counts map[string]map[string]int64) map[string]map[string]int64 {
+	out := make(map[string]map[string]int64, len(counts))
+	for k, v := range counts {
+		out[k] = copyCounts(v)
+	}
+	return out
+}
+
+func (m *Metrics) rejectionTotals() map[string]int64 {
+	return m.rejections.totals()
+}
+
+func (r *rejections) totals() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return copyCounts(r.total)
+}
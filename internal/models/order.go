@@ -12,6 +12,8 @@ const (
 	PartialFill
 	Filled
 	Cancelled
+	Expired
+	Rejected // refused on entry; it never reached the book
 )
 
 func (os OrderStatus) String() string {
@@ -24,6 +26,10 @@ func (os OrderStatus) String() string {
 		return "FILLED"
 	case Cancelled:
 		return "CANCELLED"
+	case Expired:
+		return "EXPIRED"
+	case Rejected:
+		return "REJECTED"
 	default:
 		return "UNKNOWN"
 	}
@@ -76,6 +82,17 @@ type OrderType int
 const (
 	Limit OrderType = iota
 	Market
+	// AtFixing orders carry no price: they wait for the symbol's next fixing
+	// price, such as a closing price, and trade at it.
+	AtFixing
+	// StopMarket orders carry a StopPrice instead of a price: they wait until
+	// the symbol's last trade reaches it, at or above for a buy and at or
+	// below for a sell, then trade as IOC market orders.
+	StopMarket
+	// MarketToLimit orders carry no price: they trade at the best opposite
+	// price level only, and any remainder rests as a limit order at that
+	// price instead of walking the book.
+	MarketToLimit
 )
 
 func (ot OrderType) String() string {
@@ -84,6 +101,12 @@ func (ot OrderType) String() string {
 		return "LIMIT"
 	case Market:
 		return "MARKET"
+	case AtFixing:
+		return "FIXING"
+	case StopMarket:
+		return "STOP_MARKET"
+	case MarketToLimit:
+		return "MARKET_TO_LIMIT"
 	default:
 		return "UNKNOWN"
 	}
@@ -103,24 +126,210 @@ func (ot *OrderType) UnmarshalJSON(data []byte) error {
 		*ot = Limit
 	case "MARKET":
 		*ot = Market
+	case "FIXING":
+		*ot = AtFixing
+	case "STOP_MARKET":
+		*ot = StopMarket
+	case "MARKET_TO_LIMIT":
+		*ot = MarketToLimit
 	default:
 		return fmt.Errorf("unknown order type: %s", str)
 	}
 	return nil
 }
 
+// TimeInForce says how long an order stays working. The zero value means the
+// order didn't specify one and takes its symbol's default.
+type TimeInForce int
+
+const (
+	TIFDefault TimeInForce = iota
+	GTC                    // good till cancelled
+	DAY                    // cancelled at the end of the trading day
+	IOC                    // immediate or cancel: match what it can, cancel the rest
+	FOK                    // fill or kill: fill completely at once or not at all
+	GTD                    // good till date: expires at ExpireAt
+)
+
+func (tif TimeInForce) String() string {
+	switch tif {
+	case TIFDefault:
+		return ""
+	case GTC:
+		return "GTC"
+	case DAY:
+		return "DAY"
+	case IOC:
+		return "IOC"
+	case FOK:
+		return "FOK"
+	case GTD:
+		return "GTD"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Rests reports whether an order with this time in force may rest in the book.
+func (tif TimeInForce) Rests() bool {
+	return tif != IOC && tif != FOK
+}
+
+func (tif TimeInForce) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + tif.String() + `"`), nil
+}
+
+func (tif *TimeInForce) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
+		str = str[1 : len(str)-1]
+	}
+	switch str {
+	case "":
+		*tif = TIFDefault
+	case "GTC":
+		*tif = GTC
+	case "DAY":
+		*tif = DAY
+	case "IOC":
+		*tif = IOC
+	case "FOK":
+		*tif = FOK
+	case "GTD":
+		*tif = GTD
+	default:
+		return fmt.Errorf("unknown time in force: %s", str)
+	}
+	return nil
+}
+
+// PegType is what a pegged order's price tracks.
+type PegType int
+
+const (
+	PegNone     PegType = iota
+	PegMidpoint         // halfway between the best bid and offer
+	PegPrimary          // the best price on the order's own side
+)
+
+func (p PegType) String() string {
+	switch p {
+	case PegNone:
+		return ""
+	case PegMidpoint:
+		return "MIDPOINT"
+	case PegPrimary:
+		return "PRIMARY"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (p PegType) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + p.String() + `"`), nil
+}
+
+func (p *PegType) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
+		str = str[1 : len(str)-1]
+	}
+	switch str {
+	case "":
+		*p = PegNone
+	case "MIDPOINT":
+		*p = PegMidpoint
+	case "PRIMARY":
+		*p = PegPrimary
+	default:
+		return fmt.Errorf("unknown peg type: %s", str)
+	}
+	return nil
+}
+
+// PriorityKey is a resting order's place in the book's queue: better prices
+// first, then displayed orders ahead of hidden ones, then lower sequence
+// numbers. The book assigns it when the order is
+// accepted and it is the only thing matching and queue positions go by. An
+// order keeps its key through in-place reductions; any change that should cost
+// priority must take a new key.
+type PriorityKey struct {
+	Price    int64
+	Hidden   bool   // hidden orders queue behind displayed ones at their price
+	Sequence uint64 // assigned by the book, increasing in arrival order
+}
+
+// Before reports whether k is ahead of other in the queue for side.
+func (k PriorityKey) Before(other PriorityKey, side Side) bool {
+	if k.Price != other.Price {
+		if side == Buy {
+			return k.Price > other.Price
+		}
+		return k.Price < other.Price
+	}
+	if k.Hidden != other.Hidden {
+		return other.Hidden
+	}
+	return k.Sequence < other.Sequence
+}
+
+// MaxOrderIDLength caps order IDs, which gateways may take from clients.
+const MaxOrderIDLength = 64
+
+// Limits on client-supplied order tags and metadata.
+const (
+	MaxTagLength       = 64
+	MaxMetadataEntries = 16
+	MaxMetadataKey     = 64
+	MaxMetadataValue   = 256
+)
+
 // Order represents a single order in the order book.
 type Order struct {
-	ID                string      `json:"order_id"`
-	Symbol            string      `json:"symbol"`
-	Side              Side        `json:"side"`
-	Type              OrderType   `json:"type"`
-	Price             int64       `json:"price,omitempty"`
-	OriginalQuantity  int64       `json:"quantity"`
-	RemainingQuantity int64       `json:"remaining_quantity"`
-	FilledQuantity    int64       `json:"filled_quantity"`
-	Status            OrderStatus `json:"status"`
-	Timestamp         int64       `json:"timestamp"`
+	ID                string            `json:"order_id"`
+	Account           string            `json:"account_id,omitempty"`
+	Symbol            string            `json:"symbol"`
+	Side              Side              `json:"side"`
+	Type              OrderType         `json:"type"`
+	Price             int64             `json:"price,omitempty"`
+	OriginalQuantity  int64             `json:"quantity"`
+	RemainingQuantity int64             `json:"remaining_quantity"`
+	FilledQuantity    int64             `json:"filled_quantity"`
+	Status            OrderStatus       `json:"status"`
+	TimeInForce       TimeInForce       `json:"time_in_force,omitempty"`
+	ExpireAt          int64             `json:"expire_at,omitempty"`   // UnixNano; GTD orders, and DAY orders once accepted
+	ReduceOnly        bool              `json:"reduce_only,omitempty"` // may only shrink the account's position
+	Hidden            bool              `json:"hidden,omitempty"`      // rests without being shown in depth or market data
+	Tag               string            `json:"tag,omitempty"`         // client strategy tag, echoed back untouched
+	Metadata          map[string]string `json:"metadata,omitempty"`    // client key/value pairs, echoed back untouched
+	Timestamp         int64             `json:"timestamp"`
+	TransactTime      int64             `json:"transact_time,omitempty"` // client-supplied UnixNano, held to the exchange clock
+	ClockSkew         int64             `json:"clock_skew,omitempty"`    // ns the client's transact time was off by, if it was restamped
+	MinQuantity       int64             `json:"min_quantity,omitempty"`  // least the order must trade on arrival, or it trades nothing
+	CancelReason      string            `json:"cancel_reason,omitempty"` // set when the exchange, not the owner, cancelled the order
+	StopPrice         int64             `json:"stop_price,omitempty"`    // StopMarket orders: last trade price that triggers them
+	Priority          PriorityKey       `json:"-"`
+	DisplayQuantity   int64             `json:"display_quantity,omitempty"` // iceberg orders: the most the book shows at once
+	VisibleQuantity   int64             `json:"visible_quantity,omitempty"` // iceberg orders: what is left of the slice shown now
+
+	// Post-only orders never take liquidity: one that would cross on arrival
+	// is rejected or, with PostOnlyReprice, moved one tick behind the far side.
+	PostOnly        bool `json:"post_only,omitempty"`
+	PostOnlyReprice bool `json:"post_only_reprice,omitempty"`
+
+	// Pegged orders are repriced as the book moves, to the midpoint or the
+	// best price on their own side less PegOffset ticks of aggressiveness.
+	// They never go past PegLimit, the price they were entered with, and never
+	// cross the opposite side, so they never take liquidity once resting.
+	PegType   PegType `json:"peg_type,omitempty"`
+	PegOffset int64   `json:"peg_offset,omitempty"`
+	PegLimit  int64   `json:"peg_limit,omitempty"`
+
+	// Lifecycle timestamps (UnixNano), zero until the event happens
+	AcceptedAt  int64 `json:"accepted_at,omitempty"`
+	FirstFillAt int64 `json:"first_fill_at,omitempty"`
+	TriggeredAt int64 `json:"triggered_at,omitempty"` // stop orders
+	CompletedAt int64 `json:"completed_at,omitempty"` // filled, cancelled or expired
 }
 
 func NewOrder(id, symbol string, side Side, orderType OrderType, price, quantity int64) *Order {
@@ -140,16 +349,150 @@ func NewOrder(id, symbol string, side Side, orderType OrderType, price, quantity
 
 // returns the string representation of an Order for logging.
 func (o *Order) String() string {
-	return fmt.Sprintf("Order[ID: %s, Symbol: %s, Side: %s, Type: %s, Price: %d, Quantity: %d/%d, Status: %s, Timestamp: %d]",
-		o.ID, o.Symbol, o.Side, o.Type, o.Price, o.RemainingQuantity, o.OriginalQuantity, o.Status, o.Timestamp)
+	return fmt.Sprintf("Order[ID: %s, Symbol: %s, Side: %s, Type: %s, Price: %d, Quantity: %d/%d, Status: %s, TIF: %s, Timestamp: %d]",
+		o.ID, o.Symbol, o.Side, o.Type, o.Price, o.RemainingQuantity, o.OriginalQuantity, o.Status, o.TimeInForce, o.Timestamp)
+}
+
+// Fill applies an execution of quantity at time at (UnixNano).
+func (o *Order) Fill(quantity, at int64) {
+	if o.FilledQuantity == 0 {
+		o.FirstFillAt = at
+	}
+	o.RemainingQuantity -= quantity
+	o.FilledQuantity += quantity
+}
+
+// Displayed returns how much of a resting order the book shows: all of it,
+// unless it is an iceberg or hidden.
+func (o *Order) Displayed() int64 {
+	if o.Hidden {
+		return 0
+	}
+	if o.DisplayQuantity == 0 {
+		return o.RemainingQuantity
+	}
+	return o.VisibleQuantity
+}
+
+// SetStatus moves the order to status, stamping CompletedAt the first time it
+// reaches a terminal state.
+func (o *Order) SetStatus(status OrderStatus) {
+	o.Status = status
+	if (status == Filled || status == Cancelled || status == Expired || status == Rejected) && o.CompletedAt == 0 {
+		o.CompletedAt = time.Now().UnixNano()
+	}
 }
 
 func (o *Order) Validate() error {
+	if len(o.ID) > MaxOrderIDLength {
+		return fmt.Errorf("invalid order id: longer than %d bytes", MaxOrderIDLength)
+	}
 	if o.Type == Limit && o.Price <= 0 {
 		return fmt.Errorf("invalid price: must be positive for limit orders")
 	}
+	if o.Type == AtFixing && o.Price != 0 {
+		return fmt.Errorf("invalid price: fixing orders trade at the fixing price")
+	}
+	if o.Type == StopMarket && o.Price != 0 {
+		return fmt.Errorf("invalid price: stop market orders trade at the market once triggered")
+	}
+	if o.Type == MarketToLimit && o.Price != 0 {
+		return fmt.Errorf("invalid price: market-to-limit orders take the best opposite price")
+	}
+	if o.Type == StopMarket && o.StopPrice <= 0 {
+		return fmt.Errorf("invalid stop price: must be positive for stop orders")
+	}
+	if o.Type != StopMarket && o.StopPrice != 0 {
+		return fmt.Errorf("invalid stop price: only stop orders take one")
+	}
 	if o.OriginalQuantity <= 0 {
 		return fmt.Errorf("invalid quantity: must be positive")
 	}
+	if o.MinQuantity < 0 || o.MinQuantity > o.OriginalQuantity {
+		return fmt.Errorf("invalid min quantity: must be between 0 and the order quantity")
+	}
+	if o.Type == AtFixing && o.MinQuantity != 0 {
+		return fmt.Errorf("invalid min quantity: fixing orders don't trade on arrival")
+	}
+	if o.Type == StopMarket && o.MinQuantity != 0 {
+		return fmt.Errorf("invalid min quantity: stop orders don't trade on arrival")
+	}
+	if o.DisplayQuantity < 0 || o.DisplayQuantity > o.OriginalQuantity {
+		return fmt.Errorf("invalid display quantity: must be between 0 and the order quantity")
+	}
+	if o.DisplayQuantity != 0 && o.Type != Limit {
+		return fmt.Errorf("invalid display quantity: only limit orders can be icebergs")
+	}
+	if o.DisplayQuantity != 0 && !o.TimeInForce.Rests() {
+		return fmt.Errorf("invalid display quantity: IOC and FOK orders never rest")
+	}
+	if o.Hidden && o.Type != Limit {
+		return fmt.Errorf("invalid hidden order: only limit orders can be hidden")
+	}
+	if o.Hidden && !o.TimeInForce.Rests() {
+		return fmt.Errorf("invalid hidden order: IOC and FOK orders never rest")
+	}
+	if o.Hidden && o.DisplayQuantity != 0 {
+		return fmt.Errorf("invalid hidden order: a hidden order shows nothing, so it can't be an iceberg")
+	}
+	if o.PostOnly && o.Type != Limit {
+		return fmt.Errorf("invalid post only: only limit orders can be post-only")
+	}
+	if o.PostOnly && !o.TimeInForce.Rests() {
+		return fmt.Errorf("invalid post only: IOC and FOK orders never rest")
+	}
+	if o.PostOnlyReprice && !o.PostOnly {
+		return fmt.Errorf("invalid post only: repricing needs a post-only order")
+	}
+	if o.PegType != PegNone && o.Type != Limit {
+		return fmt.Errorf("invalid peg: only limit orders can be pegged")
+	}
+	if o.PegType != PegNone && !o.TimeInForce.Rests() {
+		return fmt.Errorf("invalid peg: IOC and FOK orders never rest")
+	}
+	if o.PegType != PegNone && o.DisplayQuantity != 0 {
+		return fmt.Errorf("invalid peg: pegged orders can't be icebergs")
+	}
+	if o.PegOffset != 0 && o.PegType == PegNone {
+		return fmt.Errorf("invalid peg offset: only pegged orders take one")
+	}
+	if o.TransactTime < 0 {
+		return fmt.Errorf("invalid transact time: must not be negative")
+	}
+	switch o.TimeInForce {
+	case TIFDefault, IOC, FOK:
+	case GTC, DAY, GTD:
+		if o.Type == Market {
+			return fmt.Errorf("invalid time in force: market orders must be IOC or FOK")
+		}
+	default:
+		return fmt.Errorf("invalid time in force: unknown value %d", o.TimeInForce)
+	}
+	if o.Type == AtFixing && o.TimeInForce != TIFDefault {
+		return fmt.Errorf("invalid time in force: fixing orders work until the next fixing")
+	}
+	if o.Type == StopMarket && o.TimeInForce != TIFDefault {
+		return fmt.Errorf("invalid time in force: stop orders work until triggered, then as IOC")
+	}
+	if o.TimeInForce == GTD && o.ExpireAt <= 0 {
+		return fmt.Errorf("invalid expiry: GTD orders need an expiry time")
+	}
+	if o.TimeInForce != GTD && o.ExpireAt != 0 {
+		return fmt.Errorf("invalid expiry: only GTD orders take an expiry time")
+	}
+	if len(o.Tag) > MaxTagLength {
+		return fmt.Errorf("invalid tag: longer than %d bytes", MaxTagLength)
+	}
+	if len(o.Metadata) > MaxMetadataEntries {
+		return fmt.Errorf("invalid metadata: more than %d entries", MaxMetadataEntries)
+	}
+	for k, v := range o.Metadata {
+		if k == "" || len(k) > MaxMetadataKey {
+			return fmt.Errorf("invalid metadata: keys must be 1 to %d bytes", MaxMetadataKey)
+		}
+		if len(v) > MaxMetadataValue {
+			return fmt.Errorf("invalid metadata: value for %q longer than %d bytes", k, MaxMetadataValue)
+		}
+	}
 	return nil
 }
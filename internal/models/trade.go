@@ -5,28 +5,87 @@ import (
 	"time"
 )
 
+// Liquidity says whether an order in a trade added liquidity (was resting in
+// the book) or removed it (was the incoming aggressor).
+type Liquidity int
+
+const (
+	Maker Liquidity = iota
+	Taker
+)
+
+func (l Liquidity) String() string {
+	switch l {
+	case Maker:
+		return "MAKER"
+	case Taker:
+		return "TAKER"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (l Liquidity) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + l.String() + `"`), nil
+}
+
 type Trade struct {
 	ID            string
+	Symbol        string
 	BuyerOrderID  string
 	SellerOrderID string
+	MakerOrderID  string // resting order
+	TakerOrderID  string // incoming order
+	AggressorSide Side   // side of the taker
 	Price         int64
 	Quantity      int64
 	Timestamp     int64
+
+	// Set by the engine's enrichment stages before the trade is published
+	MakerFee int64    // negative for a rebate
+	TakerFee int64    // negative for a rebate
+	Flags    []string // e.g. regulatory markers
+	// SettlementRef identifies the trade to the clearing system
+	SettlementRef string
 }
 
-func NewTrade(id, buyerOrderID, sellerOrderID string, price, quantity int64) *Trade {
-	return &Trade{
+func NewTrade(id, symbol string, aggressorSide Side, takerOrderID, makerOrderID string, price, quantity int64) *Trade {
+	trade := &Trade{
 		ID:            id,
-		BuyerOrderID:  buyerOrderID,
-		SellerOrderID: sellerOrderID,
+		Symbol:        symbol,
+		MakerOrderID:  makerOrderID,
+		TakerOrderID:  takerOrderID,
+		AggressorSide: aggressorSide,
 		Price:         price,
 		Quantity:      quantity,
 		Timestamp:     time.Now().UnixNano(),
 	}
+	if aggressorSide == Buy {
+		trade.BuyerOrderID, trade.SellerOrderID = takerOrderID, makerOrderID
+	} else {
+		trade.BuyerOrderID, trade.SellerOrderID = makerOrderID, takerOrderID
+	}
+	return trade
+}
+
+// LiquidityFor returns whether orderID was the maker or taker in this trade.
+func (t *Trade) LiquidityFor(orderID string) Liquidity {
+	if orderID == t.TakerOrderID {
+		return Taker
+	}
+	return Maker
+}
+
+// FeeFor returns the fee charged to orderID's side of this trade.
+func (t *Trade) FeeFor(orderID string) int64 {
+	if orderID == t.TakerOrderID {
+		return t.TakerFee
+	}
+	return t.MakerFee
 }
 
 // returns the string representation of a Trade for logging.
 func (t *Trade) String() string {
-	return fmt.Sprintf("Trade[ID: %s, BuyerOrderID: %s, SellerOrderID: %s, Price: %d, Quantity: %d, Timestamp: %d]",
-		t.ID, t.BuyerOrderID, t.SellerOrderID, t.Price, t.Quantity, t.Timestamp)
+	return fmt.Sprintf("Trade[ID: %s, Symbol: %s, BuyerOrderID: %s, SellerOrderID: %s, Maker: %s, Taker: %s, Aggressor: %s, Price: %d, Quantity: %d, Timestamp: %d]",
+		t.ID, t.Symbol, t.BuyerOrderID, t.SellerOrderID, t.MakerOrderID, t.TakerOrderID, t.AggressorSide, t.Price, t.Quantity, t.Timestamp)
 }
@@ -0,0 +1,256 @@
+// Package risk holds pre-trade and post-trade risk controls that plug into the
+// matching engine.
+package risk
+
+import (
+	"fmt"
+	"repello/internal/matching"
+	"repello/internal/models"
+	"sort"
+	"sync"
+)
+
+// CreditConfig controls how firm credit is replenished. Cancelled, expired
+// and unfilled quantity always returns its credit.
+type CreditConfig struct {
+	// ReplenishOnFill returns credit as orders fill, so the limit caps open
+	// orders only. Otherwise fills keep consuming credit until the limit is
+	// raised.
+	ReplenishOnFill bool
+}
+
+// FirmCredit is a clearing firm's credit limit and how much of it is in use.
+type FirmCredit struct {
+	Firm      string   `json:"firm_id"`
+	Limit     int64    `json:"credit_limit"`
+	Open      int64    `json:"open_notional"`   // reserved by working orders
+	Filled    int64    `json:"filled_notional"` // consumed by fills
+	Available int64    `json:"available"`
+	Accounts  []string `json:"accounts"`
+	NoCross   bool     `json:"no_cross"`
+}
+
+type firm struct {
+	id       string
+	limit    int64
+	open     int64
+	filled   int64
+	accounts []string
+	noCross  bool
+}
+
+// reservation is the credit an order holds against its firm.
+type reservation struct {
+	firm   *firm
+	amount int64
+}
+
+// CreditLimits enforces clearing-firm notional limits shared by all accounts
+// under each firm. Accounts without a firm are not limited. It is both a
+// matching.PreTradeCheck, reserving credit as orders are accepted, and a
+// matching.EventListener, releasing it as they fill or are cancelled.
+type CreditLimits struct {
+	cfg      CreditConfig
+	firms    map[string]*firm
+	accounts map[string]*firm
+	reserved map[string]*reservation // by order ID
+	mu       sync.Mutex
+}
+
+var (
+	_ matching.PreTradeCheck = (*CreditLimits)(nil)
+	_ matching.EventListener = (*CreditLimits)(nil)
+	_ matching.FirmDirectory = (*CreditLimits)(nil)
+)
+
+func NewCreditLimits(cfg CreditConfig) *CreditLimits {
+	return &CreditLimits{
+		cfg:      cfg,
+		firms:    make(map[string]*firm),
+		accounts: make(map[string]*firm),
+		reserved: make(map[string]*reservation),
+	}
+}
+
+// SetLimit creates firmID or changes its limit. Lowering a limit below what is
+// in use doesn't cancel anything, it only blocks new orders.
+func (c *CreditLimits) SetLimit(firmID string, limit int64) error {
+	if firmID == "" {
+		return fmt.Errorf("invalid firm: id is required")
+	}
+	if limit < 0 {
+		return fmt.Errorf("invalid credit limit: must not be negative")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.firms[firmID]
+	if !ok {
+		f = &firm{id: firmID}
+		c.firms[firmID] = f
+	}
+	f.limit = limit
+	return nil
+}
+
+// SetNoCross turns firmID's no-cross option on or off. With it, the firm's
+// marketable orders skip price levels holding only the firm's own resting
+// orders and trade with the next external liquidity, so the firm never
+// internalizes at a level it alone occupies.
+func (c *CreditLimits) SetNoCross(firmID string, enabled bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.firms[firmID]
+	if !ok {
+		return fmt.Errorf("firm not found")
+	}
+	f.noCross = enabled
+	return nil
+}
+
+// NoCross reports whether firmID has the no-cross option.
+func (c *CreditLimits) NoCross(firmID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.firms[firmID]
+	return ok && f.noCross
+}
+
+// AssignAccount puts account under firmID, moving it from any previous firm.
+// Credit already reserved by the account's working orders stays with the firm
+// it was reserved against.
+func (c *CreditLimits) AssignAccount(account, firmID string) error {
+	if account == "" {
+		return fmt.Errorf("invalid account: id is required")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.firms[firmID]
+	if !ok {
+		return fmt.Errorf("firm not found")
+	}
+	if prev, ok := c.accounts[account]; ok {
+		prev.accounts = removeString(prev.accounts, account)
+	}
+	c.accounts[account] = f
+	f.accounts = append(f.accounts, account)
+	sort.Strings(f.accounts)
+	return nil
+}
+
+// Firm returns firmID's limit and usage.
+func (c *CreditLimits) Firm(firmID string) (FirmCredit, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.firms[firmID]
+	if !ok {
+		return FirmCredit{}, fmt.Errorf("firm not found")
+	}
+	return FirmCredit{
+		Firm:      firmID,
+		Limit:     f.limit,
+		Open:      f.open,
+		Filled:    f.filled,
+		Available: f.available(),
+		Accounts:  append([]string{}, f.accounts...),
+		NoCross:   f.noCross,
+	}, nil
+}
+
+// FirmOf returns the firm account belongs to.
+func (c *CreditLimits) FirmOf(account string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.accounts[account]
+	if !ok {
+		return "", false
+	}
+	return f.id, true
+}
+
+func (f *firm) available() int64 {
+	return f.limit - f.open - f.filled
+}
+
+// CheckOrder rejects order if its firm lacks the credit for it, and otherwise
+// reserves the credit.
+func (c *CreditLimits) CheckOrder(order *models.Order, notional int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.accounts[order.Account]
+	if !ok {
+		return nil
+	}
+	if available := f.available(); notional > available {
+		return fmt.Errorf("credit limit exceeded: order needs %d notional, firm has %d available", notional, available)
+	}
+	f.open += notional
+	c.reserved[order.ID] = &reservation{firm: f, amount: notional}
+	return nil
+}
+
+func (c *CreditLimits) OrderAccepted(order *models.Order, top matching.BookTop) {
+	if order.Status == models.Accepted || order.Status == models.PartialFill {
+		return // resting; its reservation stays
+	}
+	c.mu.Lock()
+	c.release(order.ID)
+	c.mu.Unlock()
+}
+
+func (c *CreditLimits) OrderCancelled(order *models.Order, top matching.BookTop) {
+	c.mu.Lock()
+	c.release(order.ID)
+	c.mu.Unlock()
+}
+
+func (c *CreditLimits) TradeExecuted(trade *models.Trade, taker, maker *models.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fill(taker, trade)
+	c.fill(maker, trade)
+}
+
+// fill moves the credit for trade's quantity from the order's reservation to
+// its firm's filled total.
+func (c *CreditLimits) fill(order *models.Order, trade *models.Trade) {
+	r, ok := c.reserved[order.ID]
+	if !ok {
+		return
+	}
+	// Limit orders reserved at their limit price; market orders at the prices
+	// they were expected to trade at.
+	unit := trade.Price
+	if order.Type == models.Limit {
+		unit = order.Price
+	}
+	release := min(r.amount, unit*trade.Quantity)
+	r.amount -= release
+	r.firm.open -= release
+	if !c.cfg.ReplenishOnFill {
+		r.firm.filled += trade.Price * trade.Quantity
+	}
+	if order.RemainingQuantity == 0 {
+		c.release(order.ID)
+	}
+}
+
+// release returns whatever credit orderID still holds.
+func (c *CreditLimits) release(orderID string) {
+	r, ok := c.reserved[orderID]
+	if !ok {
+		return
+	}
+	r.firm.open -= r.amount
+	delete(c.reserved, orderID)
+}
+
+func removeString(list []string, s string) []string {
+	for i, v := range list {
+		if v == s {
+			return append(list[:i], list[i+1:]...)
+		}
+	}
+	return list
+}
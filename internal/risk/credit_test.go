@@ -0,0 +1,193 @@
+package risk
+
+import (
+	"fmt"
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCreditEngine(cfg CreditConfig) (*matching.Engine, *CreditLimits) {
+	engine := matching.NewEngine(metrics.NewMetrics())
+	credit := NewCreditLimits(cfg)
+	engine.AddPreTradeCheck(credit)
+	engine.AddEventListener(credit)
+	return engine, credit
+}
+
+func newOrder(id, account string, side models.Side, price, quantity int64) *models.Order {
+	order := models.NewOrder(id, "BTCUSD", side, models.Limit, price, quantity)
+	order.Account = account
+	return order
+}
+
+func TestCreditLimits_SharedAcrossFirmAccounts(t *testing.T) {
+	engine, credit := newCreditEngine(CreditConfig{})
+	assert.NoError(t, credit.SetLimit("firm1", 1000))
+	assert.NoError(t, credit.AssignAccount("alice", "firm1"))
+	assert.NoError(t, credit.AssignAccount("bob", "firm1"))
+
+	_, err := engine.ProcessOrder(newOrder("a1", "alice", models.Buy, 100, 6))
+	assert.NoError(t, err)
+	_, err = engine.ProcessOrder(newOrder("b1", "bob", models.Buy, 100, 5))
+	assert.ErrorContains(t, err, "credit limit exceeded")
+
+	// Accounts outside any firm are not limited
+	_, err = engine.ProcessOrder(newOrder("c1", "carol", models.Sell, 90, 50))
+	assert.NoError(t, err)
+
+	// a1 filled 6 at its price; fills keep consuming credit by default
+	fc, _ := credit.Firm("firm1")
+	assert.Equal(t, int64(0), fc.Open)
+	assert.Equal(t, int64(600), fc.Filled)
+	assert.Equal(t, int64(400), fc.Available)
+
+	// Cancels return credit
+	_, err = engine.ProcessOrder(newOrder("b2", "bob", models.Sell, 95, 4))
+	assert.NoError(t, err)
+	fc, _ = credit.Firm("firm1")
+	assert.Equal(t, int64(380), fc.Open)
+	engine.CancelOrder("b2")
+	fc, _ = credit.Firm("firm1")
+	assert.Equal(t, int64(0), fc.Open)
+
+	// Raising the limit intraday lets bob trade again
+	assert.NoError(t, credit.SetLimit("firm1", 2000))
+	_, err = engine.ProcessOrder(newOrder("b3", "bob", models.Buy, 100, 5))
+	assert.NoError(t, err)
+}
+
+func TestCreditLimits_ReplenishOnFill(t *testing.T) {
+	engine, credit := newCreditEngine(CreditConfig{ReplenishOnFill: true})
+	credit.SetLimit("firm1", 1000)
+	credit.AssignAccount("alice", "firm1")
+
+	engine.ProcessOrder(newOrder("s1", "mm", models.Sell, 100, 100))
+	for i := 0; i < 3; i++ {
+		_, err := engine.ProcessOrder(newOrder(fmt.Sprintf("a%d", i), "alice", models.Buy, 100, 10))
+		assert.NoError(t, err)
+	}
+
+	fc, _ := credit.Firm("firm1")
+	assert.Equal(t, int64(0), fc.Open)
+	assert.Equal(t, int64(0), fc.Filled)
+	assert.Equal(t, int64(1000), fc.Available)
+}
+
+func TestCreditLimits_MarketOrderReservesSweepCost(t *testing.T) {
+	engine, credit := newCreditEngine(CreditConfig{})
+	credit.SetLimit("firm1", 500)
+	credit.AssignAccount("alice", "firm1")
+
+	engine.ProcessOrder(newOrder("s1", "mm", models.Sell, 100, 3))
+	engine.ProcessOrder(newOrder("s2", "mm", models.Sell, 110, 3))
+
+	market := models.NewOrder("m1", "BTCUSD", models.Buy, models.Market, 0, 5)
+	market.Account = "alice"
+	_, err := engine.ProcessOrder(market)
+	assert.ErrorContains(t, err, "order needs 520 notional")
+
+	market = models.NewOrder("m2", "BTCUSD", models.Buy, models.Market, 0, 4)
+	market.Account = "alice"
+	_, err = engine.ProcessOrder(market)
+	assert.NoError(t, err)
+	fc, _ := credit.Firm("firm1")
+	assert.Equal(t, int64(0), fc.Open)
+	assert.Equal(t, int64(410), fc.Filled)
+}
+
+func TestCreditLimits_Admin(t *testing.T) {
+	credit := NewCreditLimits(CreditConfig{})
+	assert.Error(t, credit.SetLimit("firm1", -1))
+	assert.EqualError(t, credit.AssignAccount("alice", "firm1"), "firm not found")
+
+	credit.SetLimit("firm1", 10)
+	credit.SetLimit("firm2", 10)
+	credit.AssignAccount("alice", "firm1")
+	credit.AssignAccount("alice", "firm2")
+	fc, _ := credit.Firm("firm1")
+	assert.Empty(t, fc.Accounts)
+	fc, _ = credit.Firm("firm2")
+	assert.Equal(t, []string{"alice"}, fc.Accounts)
+}
+
+func TestCreditLimits_NoCross(t *testing.T) {
+	engine, credit := newCreditEngine(CreditConfig{})
+	engine.SetFirmDirectory(credit)
+	credit.SetLimit("broker", 1_000_000)
+	credit.AssignAccount("alice", "broker")
+	credit.AssignAccount("bob", "broker")
+	assert.Error(t, credit.SetNoCross("nobody", true))
+	assert.NoError(t, credit.SetNoCross("broker", true))
+
+	engine.ProcessOrder(newOrder("own1", "bob", models.Sell, 100, 5))
+	engine.ProcessOrder(newOrder("ext1", "mm", models.Sell, 101, 3))
+	engine.ProcessOrder(newOrder("own2", "bob", models.Sell, 102, 2))
+	engine.ProcessOrder(newOrder("ext2", "mm", models.Sell, 102, 4))
+
+	// The order skips the firm's own level at 100 and trades at 101, then at
+	// 102, which other firms share, in time priority
+	result, err := engine.ProcessOrder(newOrder("a1", "alice", models.Buy, 102, 6))
+	assert.NoError(t, err)
+	if assert.Len(t, result.Trades, 3) {
+		assert.Equal(t, "ext1", result.Trades[0].MakerOrderID)
+		assert.Equal(t, "own2", result.Trades[1].MakerOrderID)
+		assert.Equal(t, "ext2", result.Trades[2].MakerOrderID)
+	}
+	assert.Equal(t, int64(5), result.SkippedQuantity)
+	own, _ := engine.GetOrder("own1")
+	assert.Equal(t, int64(5), own.RemainingQuantity)
+
+	// Fill or kill only counts other firms' liquidity
+	order := newOrder("a2", "alice", models.Buy, 102, 4)
+	order.TimeInForce = models.FOK
+	_, err = engine.ProcessOrder(order)
+	assert.ErrorContains(t, err, "from other firms")
+
+	// Other firms still trade with the broker's orders
+	result, err = engine.ProcessOrder(newOrder("m1", "mm", models.Buy, 100, 1))
+	assert.NoError(t, err)
+	assert.Len(t, result.Trades, 1)
+
+	credit.SetNoCross("broker", false)
+	result, err = engine.ProcessOrder(newOrder("a3", "alice", models.Buy, 100, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, "own1", result.Trades[0].MakerOrderID)
+	assert.Zero(t, result.SkippedQuantity)
+}
+
+func TestCreditLimits_NoCrossRemainder(t *testing.T) {
+	engine, credit := newCreditEngine(CreditConfig{})
+	engine.SetFirmDirectory(credit)
+	credit.SetLimit("broker", 1_000_000)
+	credit.AssignAccount("a", "broker")
+	credit.AssignAccount("b", "broker")
+	credit.SetNoCross("broker", true)
+
+	// A remainder resting at 101 would cross the firm's own offer at 100
+	engine.ProcessOrder(newOrder("own", "a", models.Sell, 100, 10))
+	result, err := engine.ProcessOrder(newOrder("b1", "b", models.Buy, 101, 10))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), result.SkippedQuantity)
+	assert.Equal(t, models.Cancelled, result.Order.Status)
+	assert.Equal(t, matching.NoCrossCancelReason, result.Order.CancelReason)
+
+	// Its fills with other firms stand
+	engine.ProcessOrder(newOrder("ext", "mm", models.Sell, 101, 4))
+	result, err = engine.ProcessOrder(newOrder("b2", "b", models.Buy, 101, 10))
+	assert.NoError(t, err)
+	assert.Len(t, result.Trades, 1)
+	assert.Equal(t, int64(4), result.Order.FilledQuantity)
+	assert.Equal(t, models.Cancelled, result.Order.Status)
+
+	// Without own liquidity in the way the remainder rests as usual
+	result, err = engine.ProcessOrder(newOrder("b3", "b", models.Buy, 99, 10))
+	assert.NoError(t, err)
+	assert.Equal(t, models.Accepted, result.Order.Status)
+
+	ob := engine.OrderBooks["BTCUSD"]
+	assert.Less(t, ob.GetBestBid().Price, ob.GetBestAsk().Price)
+}
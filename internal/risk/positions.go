@@ -0,0 +1,346 @@
+package risk
+
+import (
+	"fmt"
+	"log"
+	"repello/internal/matching"
+	"repello/internal/models"
+	"sync"
+)
+
+// MaxBreaches is how many breach events a PositionMonitor keeps.
+const MaxBreaches = 1000
+
+// AllSymbols as a limit's symbol applies it to every symbol without a limit
+// of its own.
+const AllSymbols = ""
+
+// PositionConfig controls a PositionMonitor.
+type PositionConfig struct {
+	// AutoReduceOnly switches an account to reduce-only mode when one of its
+	// trades breaches a limit.
+	AutoReduceOnly bool
+}
+
+// LimitScope says whether a position limit applies to an account or a firm.
+type LimitScope int
+
+const (
+	AccountScope LimitScope = iota
+	FirmScope
+)
+
+func (s LimitScope) String() string {
+	switch s {
+	case AccountScope:
+		return "ACCOUNT"
+	case FirmScope:
+		return "FIRM"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (s LimitScope) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+func (s *LimitScope) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
+		str = str[1 : len(str)-1]
+	}
+	switch str {
+	case "ACCOUNT":
+		*s = AccountScope
+	case "FIRM":
+		*s = FirmScope
+	default:
+		return fmt.Errorf("unknown limit scope: %s", str)
+	}
+	return nil
+}
+
+// Breach records a trade that left an account's or firm's net position beyond
+// its limit.
+type Breach struct {
+	Scope     LimitScope `json:"scope"`
+	ID        string     `json:"id"`         // account or firm
+	Account   string     `json:"account_id"` // account whose trade caused it
+	Symbol    string     `json:"symbol"`
+	Position  int64      `json:"position"`
+	Limit     int64      `json:"limit"`
+	TradeID   string     `json:"trade_id"`
+	Timestamp int64      `json:"timestamp"`
+}
+
+// AccountPositions is an account's net position per symbol.
+type AccountPositions struct {
+	Account    string           `json:"account_id"`
+	ReduceOnly bool             `json:"reduce_only"`
+	Positions  map[string]int64 `json:"positions"`
+}
+
+// PositionMonitor tracks net positions from trades, checks them against
+// account and firm limits after every trade, and enforces reduce-only mode.
+// It is a matching.EventListener and, for reduce-only, a
+// matching.PreTradeCheck.
+type PositionMonitor struct {
+	cfg   PositionConfig
+	firms *CreditLimits // firm membership; nil if there are no firms
+
+	accounts      map[string]map[string]int64 // account -> symbol -> net position
+	firmPositions map[string]map[string]int64
+	accountLimits map[string]map[string]int64 // account -> symbol -> max |position|
+	firmLimits    map[string]map[string]int64
+	reduceOnly    map[string]bool
+	breaches      []Breach
+	mu            sync.Mutex
+}
+
+var (
+	_ matching.PreTradeCheck = (*PositionMonitor)(nil)
+	_ matching.EventListener = (*PositionMonitor)(nil)
+)
+
+// NewPositionMonitor creates a monitor. firms supplies firm membership for
+// firm limits and may be nil.
+func NewPositionMonitor(cfg PositionConfig, firms *CreditLimits) *PositionMonitor {
+	return &PositionMonitor{
+		cfg:           cfg,
+		firms:         firms,
+		accounts:      make(map[string]map[string]int64),
+		firmPositions: make(map[string]map[string]int64),
+		accountLimits: make(map[string]map[string]int64),
+		firmLimits:    make(map[string]map[string]int64),
+		reduceOnly:    make(map[string]bool),
+	}
+}
+
+// SetLimit caps the absolute net position of the account or firm id in symbol,
+// or in every symbol for AllSymbols. A limit of zero removes it.
+func (p *PositionMonitor) SetLimit(scope LimitScope, id, symbol string, limit int64) error {
+	if id == "" {
+		return fmt.Errorf("invalid position limit: id is required")
+	}
+	if limit < 0 {
+		return fmt.Errorf("invalid position limit: must not be negative")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	limits := p.accountLimits
+	if scope == FirmScope {
+		limits = p.firmLimits
+	}
+	if limit == 0 {
+		delete(limits[id], symbol)
+		return nil
+	}
+	if limits[id] == nil {
+		limits[id] = make(map[string]int64)
+	}
+	limits[id][symbol] = limit
+	return nil
+}
+
+// SetReduceOnly turns reduce-only mode on or off for account.
+func (p *PositionMonitor) SetReduceOnly(account string, enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if enabled {
+		p.reduceOnly[account] = true
+	} else {
+		delete(p.reduceOnly, account)
+	}
+}
+
+// Positions returns account's net positions and mode.
+func (p *PositionMonitor) Positions(account string) AccountPositions {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := AccountPositions{
+		Account:    account,
+		ReduceOnly: p.reduceOnly[account],
+		Positions:  make(map[string]int64),
+	}
+	for symbol, qty := range p.accounts[account] {
+		out.Positions[symbol] = qty
+	}
+	return out
+}
+
+// Position returns account's net position in symbol.
+func (p *PositionMonitor) Position(account, symbol string) int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.accounts[account][symbol]
+}
+
+// OpenInterest is how much of each symbol is held open: exchange-wide, the
+// sum of the accounts' long net positions, which equals the sum of their
+// shorts; per firm, its net position.
+type OpenInterest struct {
+	Symbols map[string]int64            `json:"symbols"`
+	Firms   map[string]map[string]int64 `json:"firms"`
+}
+
+// OpenInterest returns the current open interest. Only trades by accounts
+// are counted.
+func (p *PositionMonitor) OpenInterest() OpenInterest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := OpenInterest{
+		Symbols: make(map[string]int64),
+		Firms:   make(map[string]map[string]int64),
+	}
+	for _, positions := range p.accounts {
+		for symbol, qty := range positions {
+			if qty > 0 {
+				out.Symbols[symbol] += qty
+			}
+		}
+	}
+	for firm, positions := range p.firmPositions {
+		out.Firms[firm] = make(map[string]int64, len(positions))
+		for symbol, qty := range positions {
+			out.Firms[firm][symbol] = qty
+		}
+	}
+	return out
+}
+
+// Breaches returns the most recent breach events, oldest first.
+func (p *PositionMonitor) Breaches() []Breach {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Breach{}, p.breaches...)
+}
+
+// CheckOrder enforces reduce-only. Orders from reduce-only accounts that could
+// increase or flip their position are rejected. Reduce-only orders that would
+// increase it are rejected, and ones larger than the position are shrunk to
+// close it exactly. Only the position at entry counts; resting orders are not
+// rechecked as the position changes.
+func (p *PositionMonitor) CheckOrder(order *models.Order, notional int64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	position := p.accounts[order.Account][order.Symbol]
+
+	if p.reduceOnly[order.Account] && !reduces(position, order.Side, order.OriginalQuantity) {
+		return fmt.Errorf("reduce-only: account may only reduce its %s position", order.Symbol)
+	}
+	if !order.ReduceOnly {
+		return nil
+	}
+	if !reduces(position, order.Side, 1) {
+		return fmt.Errorf("reduce-only: order would increase the %s position", order.Symbol)
+	}
+	if excess := order.OriginalQuantity - abs(position); excess > 0 {
+		order.OriginalQuantity -= excess
+		order.RemainingQuantity -= excess
+	}
+	return nil
+}
+
+// reduces reports whether trading quantity on side moves position towards
+// zero without passing it.
+func reduces(position int64, side models.Side, quantity int64) bool {
+	if side == models.Buy {
+		return position < 0 && quantity <= -position
+	}
+	return position > 0 && quantity <= position
+}
+
+func (p *PositionMonitor) OrderAccepted(order *models.Order, top matching.BookTop)  {}
+func (p *PositionMonitor) OrderCancelled(order *models.Order, top matching.BookTop) {}
+
+func (p *PositionMonitor) TradeExecuted(trade *models.Trade, taker, maker *models.Order) {
+	buyer, seller := taker, maker
+	if taker.Side == models.Sell {
+		buyer, seller = maker, taker
+	}
+
+	var firmOfBuyer, firmOfSeller string
+	if p.firms != nil {
+		firmOfBuyer, _ = p.firms.FirmOf(buyer.Account)
+		firmOfSeller, _ = p.firms.FirmOf(seller.Account)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.apply(trade, buyer.Account, firmOfBuyer, trade.Quantity)
+	p.apply(trade, seller.Account, firmOfSeller, -trade.Quantity)
+}
+
+// apply adds delta to the account's and firm's positions and checks them.
+func (p *PositionMonitor) apply(trade *models.Trade, account, firm string, delta int64) {
+	if account == "" {
+		return
+	}
+	pos := addPosition(p.accounts, account, trade.Symbol, delta)
+	if limit, ok := limitFor(p.accountLimits, account, trade.Symbol); ok {
+		p.check(trade, AccountScope, account, account, pos, delta, limit)
+	}
+
+	if firm == "" {
+		return
+	}
+	pos = addPosition(p.firmPositions, firm, trade.Symbol, delta)
+	if limit, ok := limitFor(p.firmLimits, firm, trade.Symbol); ok {
+		p.check(trade, FirmScope, firm, account, pos, delta, limit)
+	}
+}
+
+// check records a breach if a trade that grew the position left it beyond
+// limit.
+func (p *PositionMonitor) check(trade *models.Trade, scope LimitScope, id, account string, pos, delta, limit int64) {
+	grew := (pos > 0) == (delta > 0)
+	if abs(pos) <= limit || !grew {
+		return
+	}
+
+	breach := Breach{
+		Scope:     scope,
+		ID:        id,
+		Account:   account,
+		Symbol:    trade.Symbol,
+		Position:  pos,
+		Limit:     limit,
+		TradeID:   trade.ID,
+		Timestamp: trade.Timestamp,
+	}
+	if len(p.breaches) == MaxBreaches {
+		p.breaches = p.breaches[1:]
+	}
+	p.breaches = append(p.breaches, breach)
+	log.Printf("position limit breach: %s %s position %d in %s exceeds %d (trade %s by %s)",
+		scope, id, pos, trade.Symbol, limit, trade.ID, account)
+
+	if p.cfg.AutoReduceOnly {
+		p.reduceOnly[account] = true
+	}
+}
+
+func addPosition(positions map[string]map[string]int64, id, symbol string, delta int64) int64 {
+	if positions[id] == nil {
+		positions[id] = make(map[string]int64)
+	}
+	positions[id][symbol] += delta
+	return positions[id][symbol]
+}
+
+func limitFor(limits map[string]map[string]int64, id, symbol string) (int64, bool) {
+	if limit, ok := limits[id][symbol]; ok {
+		return limit, true
+	}
+	limit, ok := limits[id][AllSymbols]
+	return limit, ok
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
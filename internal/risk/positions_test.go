@@ -0,0 +1,112 @@
+package risk
+
+import (
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPositionEngine(cfg PositionConfig, firms *CreditLimits) (*matching.Engine, *PositionMonitor) {
+	engine := matching.NewEngine(metrics.NewMetrics())
+	monitor := NewPositionMonitor(cfg, firms)
+	engine.AddPreTradeCheck(monitor)
+	engine.AddEventListener(monitor)
+	return engine, monitor
+}
+
+func TestPositionMonitor_AccountBreachSwitchesToReduceOnly(t *testing.T) {
+	engine, monitor := newPositionEngine(PositionConfig{AutoReduceOnly: true}, nil)
+	assert.NoError(t, monitor.SetLimit(AccountScope, "alice", "BTCUSD", 10))
+
+	engine.ProcessOrder(newOrder("s1", "mm", models.Sell, 100, 50))
+	engine.ProcessOrder(newOrder("a1", "alice", models.Buy, 100, 8))
+	assert.Empty(t, monitor.Breaches())
+
+	engine.ProcessOrder(newOrder("a2", "alice", models.Buy, 100, 4))
+	breaches := monitor.Breaches()
+	if assert.Len(t, breaches, 1) {
+		assert.Equal(t, AccountScope, breaches[0].Scope)
+		assert.Equal(t, int64(12), breaches[0].Position)
+		assert.Equal(t, int64(10), breaches[0].Limit)
+	}
+	assert.True(t, monitor.Positions("alice").ReduceOnly)
+	assert.Equal(t, int64(-12), monitor.Position("mm", "BTCUSD"))
+
+	// Only risk-reducing orders get through now
+	_, err := engine.ProcessOrder(newOrder("a3", "alice", models.Buy, 100, 1))
+	assert.ErrorContains(t, err, "reduce-only")
+	_, err = engine.ProcessOrder(newOrder("a4", "alice", models.Sell, 90, 13))
+	assert.ErrorContains(t, err, "reduce-only")
+	_, err = engine.ProcessOrder(newOrder("a5", "alice", models.Sell, 110, 5))
+	assert.NoError(t, err)
+
+	monitor.SetReduceOnly("alice", false)
+	_, err = engine.ProcessOrder(newOrder("a6", "alice", models.Buy, 90, 1))
+	assert.NoError(t, err)
+}
+
+func TestPositionMonitor_FirmLimit(t *testing.T) {
+	firms := NewCreditLimits(CreditConfig{})
+	firms.SetLimit("firm1", 1_000_000)
+	firms.AssignAccount("alice", "firm1")
+	firms.AssignAccount("bob", "firm1")
+
+	engine, monitor := newPositionEngine(PositionConfig{}, firms)
+	monitor.SetLimit(FirmScope, "firm1", AllSymbols, 10)
+
+	engine.ProcessOrder(newOrder("s1", "mm", models.Sell, 100, 50))
+	engine.ProcessOrder(newOrder("a1", "alice", models.Buy, 100, 6))
+	engine.ProcessOrder(newOrder("b1", "bob", models.Buy, 100, 6))
+
+	breaches := monitor.Breaches()
+	if assert.Len(t, breaches, 1) {
+		assert.Equal(t, FirmScope, breaches[0].Scope)
+		assert.Equal(t, "firm1", breaches[0].ID)
+		assert.Equal(t, "bob", breaches[0].Account)
+		assert.Equal(t, int64(12), breaches[0].Position)
+	}
+	// Without AutoReduceOnly the account keeps trading
+	assert.False(t, monitor.Positions("bob").ReduceOnly)
+
+	// Reducing trades don't raise new breaches
+	engine.ProcessOrder(newOrder("m2", "mm", models.Buy, 95, 5))
+	engine.ProcessOrder(newOrder("b2", "bob", models.Sell, 95, 1))
+	assert.Equal(t, int64(5), monitor.Position("bob", "BTCUSD"))
+	assert.Len(t, monitor.Breaches(), 1)
+
+	oi := monitor.OpenInterest()
+	assert.Equal(t, int64(11), oi.Symbols["BTCUSD"])
+	assert.Equal(t, int64(11), oi.Firms["firm1"]["BTCUSD"])
+}
+
+func TestPositionMonitor_ReduceOnlyOrders(t *testing.T) {
+	engine, monitor := newPositionEngine(PositionConfig{}, nil)
+
+	engine.ProcessOrder(newOrder("s1", "mm", models.Sell, 100, 50))
+	engine.ProcessOrder(newOrder("a1", "alice", models.Buy, 100, 5))
+
+	// Adding to a long position is rejected
+	order := newOrder("a2", "alice", models.Buy, 100, 1)
+	order.ReduceOnly = true
+	_, err := engine.ProcessOrder(order)
+	assert.ErrorContains(t, err, "would increase")
+
+	// Selling more than the position is capped to close it
+	engine.ProcessOrder(newOrder("b1", "mm", models.Buy, 90, 50))
+	order = newOrder("a3", "alice", models.Sell, 90, 8)
+	order.ReduceOnly = true
+	_, err = engine.ProcessOrder(order)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), order.OriginalQuantity)
+	assert.Equal(t, models.Filled, order.Status)
+	assert.Equal(t, int64(0), monitor.Position("alice", "BTCUSD"))
+
+	// Flat accounts can't place reduce-only orders at all
+	order = newOrder("a4", "alice", models.Sell, 90, 1)
+	order.ReduceOnly = true
+	_, err = engine.ProcessOrder(order)
+	assert.Error(t, err)
+}
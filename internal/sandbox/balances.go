@@ -0,0 +1,94 @@
+package sandbox
+
+import (
+	"repello/internal/matching"
+	"repello/internal/models"
+	"sync"
+)
+
+// Balance is an account's fake cash and net position per symbol. Balances are
+// informational only; orders are not checked against them.
+type Balance struct {
+	Account   string           `json:"account_id"`
+	Cash      int64            `json:"cash"`
+	Positions map[string]int64 `json:"positions"`
+}
+
+// Balances settles sandbox trades against fake account balances. It is a
+// matching.EventListener. Every change is posted to a ledger, which can be
+// checked against the balances with Reconcile.
+type Balances struct {
+	startingCash int64
+	accounts     map[string]*Balance
+	mu           sync.Mutex
+
+	// ledger holds the entries since the oldest snapshot kept, oldest first.
+	// Guarded by mu, like the rest of the ledger.
+	ledger    []Entry
+	snapshots []Snapshot // oldest first
+	sequence  int64      // of the last entry posted
+	cfg       Config
+}
+
+var _ matching.EventListener = (*Balances)(nil)
+
+func NewBalances(cfg Config) *Balances {
+	return &Balances{
+		startingCash: cfg.StartingCash,
+		accounts:     make(map[string]*Balance),
+		cfg:          cfg,
+	}
+}
+
+// Get returns a copy of account's balance. Accounts that have not traded hold
+// only the starting cash.
+func (b *Balances) Get(account string) Balance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	balance := Balance{Account: account, Cash: b.startingCash, Positions: make(map[string]int64)}
+	if acct, ok := b.accounts[account]; ok {
+		balance.Cash = acct.Cash
+		for symbol, qty := range acct.Positions {
+			balance.Positions[symbol] = qty
+		}
+	}
+	return balance
+}
+
+func (b *Balances) Reset() {
+	b.mu.Lock()
+	b.post(Entry{Reason: ReasonReset})
+	b.mu.Unlock()
+}
+
+func (b *Balances) OrderAccepted(order *models.Order, top matching.BookTop)  {}
+func (b *Balances) OrderCancelled(order *models.Order, top matching.BookTop) {}
+
+func (b *Balances) TradeExecuted(trade *models.Trade, taker, maker *models.Order) {
+	buyer, seller := taker, maker
+	if taker.Side == models.Sell {
+		buyer, seller = maker, taker
+	}
+	notional := trade.Price * trade.Quantity
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if buyer.Account != "" {
+		b.open(buyer.Account)
+		b.post(Entry{Account: buyer.Account, Amount: -notional, Reason: ReasonTrade, TradeID: trade.ID})
+		b.post(Entry{Account: buyer.Account, Symbol: trade.Symbol, Amount: trade.Quantity, Reason: ReasonTrade, TradeID: trade.ID})
+	}
+	if seller.Account != "" {
+		b.open(seller.Account)
+		b.post(Entry{Account: seller.Account, Amount: notional, Reason: ReasonTrade, TradeID: trade.ID})
+		b.post(Entry{Account: seller.Account, Symbol: trade.Symbol, Amount: -trade.Quantity, Reason: ReasonTrade, TradeID: trade.ID})
+	}
+}
+
+// open credits account's starting cash the first time it trades.
+func (b *Balances) open(account string) {
+	if _, ok := b.accounts[account]; !ok {
+		b.post(Entry{Account: account, Amount: b.startingCash, Reason: ReasonOpening})
+	}
+}
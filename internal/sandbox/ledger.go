@@ -0,0 +1,201 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"log"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Reasons for ledger entries.
+const (
+	ReasonOpening = "OPENING" // an account's starting cash, credited when it first trades
+	ReasonTrade   = "TRADE"
+	ReasonReset   = "RESET" // every balance cleared; carries no account
+)
+
+// Entry is one balance movement in the ledger. Entries are only ever appended,
+// and replaying them in sequence order from a snapshot gives the balances.
+type Entry struct {
+	Sequence int64  `json:"sequence"`
+	Time     int64  `json:"time"` // UnixNano
+	Account  string `json:"account_id,omitempty"`
+	Symbol   string `json:"symbol,omitempty"` // set for position movements, empty for cash
+	Amount   int64  `json:"amount"`           // cash, or quantity for positions; negative is a debit
+	Reason   string `json:"reason"`
+	TradeID  string `json:"trade_id,omitempty"`
+}
+
+// Snapshot is every account's balance after the entry numbered Sequence.
+type Snapshot struct {
+	Sequence int64              `json:"sequence"`
+	Time     int64              `json:"time"` // UnixNano
+	Balances map[string]Balance `json:"balances"`
+}
+
+// Mismatch is a balance that differs from the ledger's replay.
+type Mismatch struct {
+	Account  string `json:"account_id"`
+	Symbol   string `json:"symbol,omitempty"` // empty for cash
+	Expected int64  `json:"expected"`         // from the snapshot and journal
+	Actual   int64  `json:"actual"`
+}
+
+// Reconciliation is the result of checking current balances against the
+// latest snapshot plus the entries journalled since.
+type Reconciliation struct {
+	OK               bool       `json:"ok"`
+	SnapshotSequence int64      `json:"snapshot_sequence"`
+	EntriesReplayed  int        `json:"entries_replayed"`
+	Accounts         int        `json:"accounts"`
+	Mismatches       []Mismatch `json:"mismatches,omitempty"`
+	Timestamp        int64      `json:"timestamp"`
+}
+
+// journalRecord is one line of the journal file: an entry or a snapshot.
+type journalRecord struct {
+	Entry    *Entry    `json:"entry,omitempty"`
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+}
+
+// post appends e to the ledger and applies it to the balances, snapshotting
+// them every SnapshotEvery entries. It must be called with b.mu held.
+func (b *Balances) post(e Entry) {
+	b.sequence++
+	e.Sequence = b.sequence
+	e.Time = time.Now().UnixNano()
+	b.ledger = append(b.ledger, e)
+	b.write(journalRecord{Entry: &e})
+	apply(b.accounts, e)
+
+	if e.Reason == ReasonReset || (b.cfg.SnapshotEvery > 0 && b.sequence%int64(b.cfg.SnapshotEvery) == 0) {
+		b.snapshot()
+	}
+}
+
+// snapshot records the current balances. Only the last KeepSnapshots are
+// kept in memory, along with the entries after the oldest of them; the
+// journal file, if any, keeps everything. It must be called with b.mu held.
+func (b *Balances) snapshot() {
+	s := Snapshot{Sequence: b.sequence, Time: time.Now().UnixNano(), Balances: copyAccounts(b.accounts)}
+	b.snapshots = append(b.snapshots, s)
+	b.write(journalRecord{Snapshot: &s})
+
+	if keep := b.cfg.KeepSnapshots; keep > 0 && len(b.snapshots) > keep {
+		b.snapshots = b.snapshots[len(b.snapshots)-keep:]
+		oldest := b.snapshots[0].Sequence
+		i := sort.Search(len(b.ledger), func(i int) bool { return b.ledger[i].Sequence > oldest })
+		b.ledger = append([]Entry(nil), b.ledger[i:]...)
+	}
+}
+
+func (b *Balances) write(r journalRecord) {
+	if b.cfg.Journal == nil {
+		return
+	}
+	if err := json.NewEncoder(b.cfg.Journal).Encode(r); err != nil {
+		log.Printf("sandbox ledger: journal write failed: %s", err)
+	}
+}
+
+// apply adds e's movement to accounts.
+func apply(accounts map[string]*Balance, e Entry) {
+	if e.Reason == ReasonReset {
+		clear(accounts)
+		return
+	}
+	acct, ok := accounts[e.Account]
+	if !ok {
+		acct = &Balance{Account: e.Account, Positions: make(map[string]int64)}
+		accounts[e.Account] = acct
+	}
+	if e.Symbol == "" {
+		acct.Cash += e.Amount
+	} else {
+		acct.Positions[e.Symbol] += e.Amount
+	}
+}
+
+func copyAccounts(accounts map[string]*Balance) map[string]Balance {
+	balances := make(map[string]Balance, len(accounts))
+	for account, acct := range accounts {
+		balances[account] = Balance{Account: account, Cash: acct.Cash, Positions: maps.Clone(acct.Positions)}
+	}
+	return balances
+}
+
+// Ledger returns the entries kept in memory with a sequence above after,
+// optionally only account's, oldest first.
+func (b *Balances) Ledger(account string, after int64) []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entries := make([]Entry, 0)
+	for _, e := range b.ledger {
+		if e.Sequence > after && (account == "" || e.Account == account) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Reconcile replays the entries since the latest snapshot onto it and checks
+// the result against the current balances.
+func (b *Balances) Reconcile() Reconciliation {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	replayed := make(map[string]*Balance)
+	r := Reconciliation{Timestamp: time.Now().UnixNano()}
+	if n := len(b.snapshots); n > 0 {
+		latest := b.snapshots[n-1]
+		r.SnapshotSequence = latest.Sequence
+		for account, balance := range latest.Balances {
+			balance.Positions = maps.Clone(balance.Positions)
+			replayed[account] = &balance
+		}
+	}
+	for _, e := range b.ledger {
+		if e.Sequence > r.SnapshotSequence {
+			apply(replayed, e)
+			r.EntriesReplayed++
+		}
+	}
+
+	accounts := slices.Collect(maps.Keys(replayed))
+	for account := range b.accounts {
+		if _, ok := replayed[account]; !ok {
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Strings(accounts)
+	empty := &Balance{}
+	for _, account := range accounts {
+		expected, actual := replayed[account], b.accounts[account]
+		if expected == nil {
+			expected = empty
+		}
+		if actual == nil {
+			actual = empty
+		}
+		if expected.Cash != actual.Cash {
+			r.Mismatches = append(r.Mismatches, Mismatch{Account: account, Expected: expected.Cash, Actual: actual.Cash})
+		}
+		symbols := slices.Collect(maps.Keys(expected.Positions))
+		for symbol := range actual.Positions {
+			if _, ok := expected.Positions[symbol]; !ok {
+				symbols = append(symbols, symbol)
+			}
+		}
+		sort.Strings(symbols)
+		for _, symbol := range symbols {
+			if e, a := expected.Positions[symbol], actual.Positions[symbol]; e != a {
+				r.Mismatches = append(r.Mismatches, Mismatch{Account: account, Symbol: symbol, Expected: e, Actual: a})
+			}
+		}
+	}
+	r.Accounts = len(accounts)
+	r.OK = len(r.Mismatches) == 0
+	return r
+}
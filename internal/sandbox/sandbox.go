@@ -0,0 +1,126 @@
+// Package sandbox runs a paper-trading copy of the exchange: its own engine and
+// books, with fake balances, so integrators can test against the real matching
+// logic without touching live state.
+package sandbox
+
+import (
+	"fmt"
+	"io"
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// LiquidityAccount owns the resting orders placed by Seed.
+const LiquidityAccount = "sandbox-liquidity"
+
+// Config holds sandbox settings.
+type Config struct {
+	// StartingCash is credited to each account the first time it trades.
+	StartingCash int64
+	// SnapshotEvery is how many ledger entries are posted between balance
+	// snapshots, 0 for none but those taken on reset. The last KeepSnapshots
+	// are kept in memory, with the entries since the oldest of them.
+	SnapshotEvery int
+	KeepSnapshots int
+	// Journal, if set, has every ledger entry and snapshot appended to it as
+	// a line of JSON.
+	Journal io.Writer
+}
+
+// DefaultConfig returns the settings the server uses.
+func DefaultConfig() Config {
+	return Config{StartingCash: 10_000_000, SnapshotEvery: 1000, KeepSnapshots: 10}
+}
+
+// SeedSpec describes synthetic liquidity for one symbol: Levels bids and asks
+// each, Tick apart, on either side of Mid.
+type SeedSpec struct {
+	Symbol   string `json:"symbol"`
+	Mid      int64  `json:"mid"`
+	Tick     int64  `json:"tick"`
+	Levels   int    `json:"levels"`
+	Quantity int64  `json:"quantity"` // per level
+}
+
+// Sandbox is an isolated engine plus the fake balances of its accounts.
+type Sandbox struct {
+	engine   *matching.Engine
+	metrics  *metrics.Metrics
+	balances *Balances
+}
+
+// New creates an empty sandbox.
+func New(cfg Config) *Sandbox {
+	m := metrics.NewMetrics()
+	engine := matching.NewEngine(m)
+	balances := NewBalances(cfg)
+	engine.AddEventListener(balances)
+	return &Sandbox{
+		engine:   engine,
+		metrics:  m,
+		balances: balances,
+	}
+}
+
+func (s *Sandbox) Engine() *matching.Engine {
+	return s.engine
+}
+
+func (s *Sandbox) Metrics() *metrics.Metrics {
+	return s.metrics
+}
+
+// Balance returns account's fake cash and positions.
+func (s *Sandbox) Balance(account string) Balance {
+	return s.balances.Get(account)
+}
+
+// Ledger returns the balance movements kept in memory after sequence after,
+// optionally only account's.
+func (s *Sandbox) Ledger(account string, after int64) []Entry {
+	return s.balances.Ledger(account, after)
+}
+
+// Reconcile checks the balances against the ledger.
+func (s *Sandbox) Reconcile() Reconciliation {
+	return s.balances.Reconcile()
+}
+
+// Reset clears every sandbox book, order and balance.
+func (s *Sandbox) Reset() {
+	s.engine.Reset()
+	s.balances.Reset()
+}
+
+// Seed places resting orders for spec on behalf of LiquidityAccount.
+func (s *Sandbox) Seed(spec SeedSpec) error {
+	if spec.Symbol == "" {
+		return fmt.Errorf("invalid seed: symbol is required")
+	}
+	if spec.Tick <= 0 || spec.Levels <= 0 || spec.Quantity <= 0 {
+		return fmt.Errorf("invalid seed: tick, levels and quantity must be positive")
+	}
+	if spec.Mid-spec.Tick*int64(spec.Levels) <= 0 {
+		return fmt.Errorf("invalid seed: mid is too low for %d levels of tick %d", spec.Levels, spec.Tick)
+	}
+
+	for i := 1; i <= spec.Levels; i++ {
+		offset := spec.Tick * int64(i)
+		for _, o := range []struct {
+			side  models.Side
+			price int64
+		}{{models.Buy, spec.Mid - offset}, {models.Sell, spec.Mid + offset}} {
+			order := models.NewOrder(uuid.New().String(), spec.Symbol, o.side, models.Limit, o.price, spec.Quantity)
+			order.Account = LiquidityAccount
+			result, err := s.engine.ProcessOrder(order)
+			if err != nil {
+				return fmt.Errorf("seeding %s: %w", spec.Symbol, err)
+			}
+			result.Release()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package sandbox
+
+import (
+	"bytes"
+	"repello/internal/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandbox_SeedTradeAndReset(t *testing.T) {
+	sb := New(Config{StartingCash: 1000})
+
+	assert.NoError(t, sb.Seed(SeedSpec{Symbol: "BTCUSD", Mid: 100, Tick: 1, Levels: 3, Quantity: 5}))
+	depth, _ := sb.Engine().GetOrderBookDepth("BTCUSD", 0)
+	assert.Len(t, depth.Bids, 3)
+	assert.Len(t, depth.Asks, 3)
+	assert.Equal(t, int64(99), depth.Bids[0].Price)
+	assert.Equal(t, int64(101), depth.Asks[0].Price)
+
+	order := models.NewOrder("buy1", "BTCUSD", models.Buy, models.Market, 0, 7)
+	order.Account = "alice"
+	_, err := sb.Engine().ProcessOrder(order)
+	assert.NoError(t, err)
+
+	alice := sb.Balance("alice")
+	assert.Equal(t, int64(1000-5*101-2*102), alice.Cash)
+	assert.Equal(t, int64(7), alice.Positions["BTCUSD"])
+	assert.Equal(t, int64(-7), sb.Balance(LiquidityAccount).Positions["BTCUSD"])
+
+	sb.Reset()
+	depth, _ = sb.Engine().GetOrderBookDepth("BTCUSD", 0)
+	assert.Empty(t, depth.Bids)
+	assert.Empty(t, depth.Asks)
+	assert.Equal(t, int64(1000), sb.Balance("alice").Cash)
+	assert.Equal(t, int64(0), sb.Metrics().OrdersInBook.Load())
+	_, err = sb.Engine().GetOrder("buy1")
+	assert.Error(t, err)
+}
+
+func TestSandbox_SeedValidation(t *testing.T) {
+	sb := New(DefaultConfig())
+
+	assert.Error(t, sb.Seed(SeedSpec{Mid: 100, Tick: 1, Levels: 1, Quantity: 1}))
+	assert.Error(t, sb.Seed(SeedSpec{Symbol: "BTCUSD", Mid: 100, Tick: 0, Levels: 1, Quantity: 1}))
+	assert.Error(t, sb.Seed(SeedSpec{Symbol: "BTCUSD", Mid: 10, Tick: 5, Levels: 2, Quantity: 1}))
+}
+
+func TestSandbox_LedgerReconciles(t *testing.T) {
+	var journal bytes.Buffer
+	sb := New(Config{StartingCash: 1000, SnapshotEvery: 4, KeepSnapshots: 1, Journal: &journal})
+	assert.NoError(t, sb.Seed(SeedSpec{Symbol: "BTCUSD", Mid: 100, Tick: 1, Levels: 1, Quantity: 5}))
+
+	order := models.NewOrder("buy1", "BTCUSD", models.Buy, models.Limit, 101, 2)
+	order.Account = "alice"
+	_, err := sb.Engine().ProcessOrder(order)
+	assert.NoError(t, err)
+
+	// Opening cash, then a cash and a position movement per side
+	alice := sb.Ledger("alice", 0)
+	if assert.Len(t, alice, 3) {
+		assert.Equal(t, Entry{Sequence: 1, Time: alice[0].Time, Account: "alice", Amount: 1000, Reason: ReasonOpening}, alice[0])
+		assert.Equal(t, int64(-202), alice[1].Amount)
+		assert.Equal(t, "BTCUSD", alice[2].Symbol)
+		assert.Equal(t, int64(2), alice[2].Amount)
+	}
+	r := sb.Reconcile()
+	assert.True(t, r.OK)
+	assert.Equal(t, int64(4), r.SnapshotSequence)
+	assert.Equal(t, 2, r.EntriesReplayed)
+	assert.Equal(t, 2, r.Accounts)
+
+	// A balance changed outside the ledger is caught
+	sb.balances.mu.Lock()
+	sb.balances.accounts["alice"].Cash += 5
+	sb.balances.mu.Unlock()
+	r = sb.Reconcile()
+	assert.False(t, r.OK)
+	assert.Equal(t, []Mismatch{{Account: "alice", Expected: 798, Actual: 803}}, r.Mismatches)
+
+	// Only entries after the oldest snapshot kept stay in memory
+	sb.Reset()
+	r = sb.Reconcile()
+	assert.True(t, r.OK)
+	assert.Equal(t, int64(7), r.SnapshotSequence)
+	assert.Empty(t, sb.Ledger("", 0))
+
+	lines := strings.Split(strings.TrimSpace(journal.String()), "\n")
+	assert.Len(t, lines, 9) // seven entries and two snapshots
+}
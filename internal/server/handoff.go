@@ -0,0 +1,56 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// TakeOver is the last step of a rolling restart. Once this process is
+// accepting on the shared SO_REUSEPORT ports, it records its PID in pidfile and
+// sends SIGTERM to the process previously recorded there, which then drains
+// its listeners and exits.
+func TakeOver(pidfile string) error {
+	data, err := os.ReadFile(pidfile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp := pidfile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, pidfile); err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+	oldPID, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || oldPID == os.Getpid() {
+		return nil
+	}
+	process, err := os.FindProcess(oldPID)
+	if err != nil {
+		return nil
+	}
+	if err := process.Signal(syscall.SIGTERM); err != nil && err != os.ErrProcessDone {
+		return fmt.Errorf("signal previous process %d: %w", oldPID, err)
+	}
+	return nil
+}
+
+// ReleasePIDFile removes pidfile if it still names this process, leaving it
+// alone if a newer process has already taken over.
+func ReleasePIDFile(pidfile string) {
+	data, err := os.ReadFile(pidfile)
+	if err != nil {
+		return
+	}
+	if strings.TrimSpace(string(data)) == strconv.Itoa(os.Getpid()) {
+		os.Remove(pidfile)
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"repello/internal/metrics"
+	"sync"
+	"time"
+)
+
+// State is a stage of the server's lifecycle. States only move forward:
+// Starting, optionally Recovering, Ready, Draining, Stopped; a failure may
+// skip straight to Draining or Stopped.
+type State int
+
+const (
+	Starting   State = iota // loading configuration and binding listeners
+	Recovering              // rebuilding engine state before taking orders
+	Ready                   // serving orders
+	Draining                // refusing new work while in-flight requests finish
+	Stopped
+)
+
+func (s State) String() string {
+	switch s {
+	case Starting:
+		return "STARTING"
+	case Recovering:
+		return "RECOVERING"
+	case Ready:
+		return "READY"
+	case Draining:
+		return "DRAINING"
+	case Stopped:
+		return "STOPPED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (s State) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// Transition records one state change.
+type Transition struct {
+	From      State `json:"from"`
+	To        State `json:"to"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// LifecycleStatus is a snapshot of a Lifecycle.
+type LifecycleStatus struct {
+	State       State        `json:"state"`
+	Since       int64        `json:"since"`
+	Transitions []Transition `json:"transitions"`
+}
+
+// Lifecycle tracks the server's state so orchestration can tell when it may
+// route orders to it and when it is going away. Transitions are logged, and
+// the time spent recovering is reported through metrics.
+type Lifecycle struct {
+	metrics     *metrics.Metrics
+	state       State
+	since       time.Time
+	transitions []Transition
+	mu          sync.Mutex
+	now         func() time.Time
+}
+
+// NewLifecycle creates a Lifecycle in the Starting state.
+func NewLifecycle(m *metrics.Metrics) *Lifecycle {
+	return &Lifecycle{metrics: m, state: Starting, since: time.Now(), now: time.Now}
+}
+
+// State returns the current state.
+func (l *Lifecycle) State() State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state
+}
+
+// Status returns the current state and every transition so far.
+func (l *Lifecycle) Status() LifecycleStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return LifecycleStatus{
+		State:       l.state,
+		Since:       l.since.UnixNano(),
+		Transitions: append([]Transition{}, l.transitions...),
+	}
+}
+
+// Transition moves to state to. Moving backwards is an error, and moving to
+// the current state does nothing.
+func (l *Lifecycle) Transition(to State) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	from := l.state
+	if to == from {
+		return nil
+	}
+	if to < from || from == Stopped || to == Recovering && from != Starting {
+		return fmt.Errorf("invalid lifecycle transition: %s -> %s", from, to)
+	}
+
+	now := l.now()
+	if from == Recovering {
+		l.metrics.SetRecoveryDuration(now.Sub(l.since))
+	}
+	l.state = to
+	l.since = now
+	l.transitions = append(l.transitions, Transition{From: from, To: to, Timestamp: now.UnixNano()})
+	log.Printf("Lifecycle: %s -> %s", from, to)
+	return nil
+}
+
+// Recover runs fn in the Recovering state, then moves to Ready if it
+// succeeded. Recovery code such as replaying a journal should run under it so
+// that its duration is measured.
+func (l *Lifecycle) Recover(fn func() error) error {
+	if err := l.Transition(Recovering); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return fmt.Errorf("recovery failed: %w", err)
+	}
+	return l.Transition(Ready)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"errors"
+	"repello/internal/metrics"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycle(t *testing.T) {
+	m := metrics.NewMetrics()
+	l := NewLifecycle(m)
+	now := time.Unix(1_700_000_000, 0)
+	l.now = func() time.Time { return now }
+	assert.Equal(t, Starting, l.State())
+
+	err := l.Recover(func() error {
+		assert.Equal(t, Recovering, l.State())
+		now = now.Add(1500 * time.Millisecond)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, Ready, l.State())
+	assert.Equal(t, int64(1_500_000), m.RecoveryDuration.Load())
+
+	// No going back
+	assert.Error(t, l.Transition(Recovering))
+	assert.Error(t, l.Transition(Starting))
+	assert.NoError(t, l.Transition(Ready))
+
+	assert.NoError(t, l.Transition(Draining))
+	assert.NoError(t, l.Transition(Stopped))
+	status := l.Status()
+	assert.Equal(t, Stopped, status.State)
+	assert.Len(t, status.Transitions, 4)
+	assert.Equal(t, Transition{From: Starting, To: Recovering, Timestamp: 1_700_000_000 * int64(time.Second)}, status.Transitions[0])
+}
+
+func TestLifecycle_FailedRecovery(t *testing.T) {
+	l := NewLifecycle(metrics.NewMetrics())
+	err := l.Recover(func() error { return errors.New("journal corrupt") })
+	assert.EqualError(t, err, "recovery failed: journal corrupt")
+	assert.Equal(t, Recovering, l.State())
+	assert.NoError(t, l.Transition(Stopped))
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Listener is a protocol front end (REST, WebSocket, gRPC, FIX, ...) serving
+// the engine on one address.
+type Listener interface {
+	Name() string
+	// Listen binds the listener's address without accepting yet.
+	Listen() error
+	// Serve blocks until the listener fails or is shut down.
+	Serve() error
+	// Shutdown stops accepting work and drains in-flight requests.
+	Shutdown(ctx context.Context) error
+}
+
+// Manager runs several listeners against the same engine and shuts them down
+// together.
+type Manager struct {
+	listeners       []Listener
+	shutdownTimeout time.Duration
+	onReady         func()
+	lifecycle       *Lifecycle
+}
+
+// NewManager creates a Manager that gives listeners up to shutdownTimeout to
+// drain when stopping.
+func NewManager(shutdownTimeout time.Duration) *Manager {
+	return &Manager{shutdownTimeout: shutdownTimeout}
+}
+
+// Add registers a listener. Listeners must be added before Run.
+func (m *Manager) Add(l Listener) {
+	m.listeners = append(m.listeners, l)
+}
+
+// OnReady registers fn to run once every listener has bound its address and
+// is about to start accepting.
+func (m *Manager) OnReady(fn func()) {
+	m.onReady = fn
+}
+
+// SetLifecycle makes Run drive l: Ready once every listener is bound, Draining
+// while they shut down, and Stopped when Run returns.
+func (m *Manager) SetLifecycle(l *Lifecycle) {
+	m.lifecycle = l
+}
+
+func (m *Manager) transition(to State) {
+	if m.lifecycle == nil {
+		return
+	}
+	if err := m.lifecycle.Transition(to); err != nil {
+		log.Printf("%s", err)
+	}
+}
+
+// Run starts every listener and blocks until ctx is cancelled or one of them
+// fails, then shuts all of them down. It returns the first listener error, or
+// nil if ctx was cancelled.
+func (m *Manager) Run(ctx context.Context) error {
+	defer m.transition(Stopped)
+	if len(m.listeners) == 0 {
+		return errors.New("no listeners enabled")
+	}
+
+	type serveResult struct {
+		name string
+		err  error
+	}
+	results := make(chan serveResult, len(m.listeners))
+
+	for i, l := range m.listeners {
+		if err := l.Listen(); err != nil {
+			// Nothing is serving yet; just release what was bound.
+			for _, bound := range m.listeners[:i] {
+				bound.Shutdown(context.Background())
+			}
+			return fmt.Errorf("listener %s: %w", l.Name(), err)
+		}
+	}
+	m.transition(Ready)
+	if m.onReady != nil {
+		m.onReady()
+	}
+
+	for _, l := range m.listeners {
+		log.Printf("Starting listener %s", l.Name())
+		go func(l Listener) {
+			results <- serveResult{name: l.Name(), err: l.Serve()}
+		}(l)
+	}
+
+	var runErr error
+	pending := len(m.listeners)
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down listeners...")
+	case r := <-results:
+		pending--
+		if r.err != nil {
+			log.Printf("Listener %s failed: %s", r.name, r.err)
+			runErr = r.err
+		}
+	}
+
+	m.transition(Draining)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, l := range m.listeners {
+		wg.Add(1)
+		go func(l Listener) {
+			defer wg.Done()
+			if err := l.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Listener %s did not shut down cleanly: %s", l.Name(), err)
+			}
+		}(l)
+	}
+	wg.Wait()
+
+	for ; pending > 0; pending-- {
+		if r := <-results; r.err != nil && runErr == nil {
+			runErr = r.err
+		}
+	}
+	return runErr
+}
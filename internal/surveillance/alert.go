@@ -0,0 +1,183 @@
+package surveillance
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type AlertType int
+
+const (
+	Spoofing AlertType = iota
+	Layering
+	// QuoteObligation is a designated market maker missing its quoting
+	// obligation.
+	QuoteObligation
+)
+
+func (t AlertType) String() string {
+	switch t {
+	case Spoofing:
+		return "SPOOFING"
+	case Layering:
+		return "LAYERING"
+	case QuoteObligation:
+		return "QUOTE_OBLIGATION"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (t AlertType) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+// AlertStatus tracks an alert through review.
+type AlertStatus int
+
+const (
+	Open AlertStatus = iota
+	Acknowledged
+	Resolved
+)
+
+func (s AlertStatus) String() string {
+	switch s {
+	case Open:
+		return "OPEN"
+	case Acknowledged:
+		return "ACKNOWLEDGED"
+	case Resolved:
+		return "RESOLVED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (s AlertStatus) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+func (s *AlertStatus) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
+		str = str[1 : len(str)-1]
+	}
+	status, err := ParseAlertStatus(str)
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+func ParseAlertStatus(str string) (AlertStatus, error) {
+	switch str {
+	case "OPEN":
+		return Open, nil
+	case "ACKNOWLEDGED":
+		return Acknowledged, nil
+	case "RESOLVED":
+		return Resolved, nil
+	default:
+		return Open, fmt.Errorf("unknown alert status: %s", str)
+	}
+}
+
+// Alert is a scored surveillance finding. Score runs from 0 (weak) to 1
+// (strong).
+type Alert struct {
+	ID          string      `json:"alert_id"`
+	Type        AlertType   `json:"type"`
+	Score       float64     `json:"score"`
+	Account     string      `json:"account_id"`
+	Symbol      string      `json:"symbol"`
+	OrderIDs    []string    `json:"order_ids,omitempty"`
+	TradeIDs    []string    `json:"trade_ids,omitempty"`
+	Description string      `json:"description"`
+	Status      AlertStatus `json:"status"`
+	Assignee    string      `json:"assignee,omitempty"`
+	CreatedAt   int64       `json:"created_at"`
+	UpdatedAt   int64       `json:"updated_at"`
+}
+
+// AlertUpdate changes an alert's review state. Nil fields are left alone.
+type AlertUpdate struct {
+	Status   *AlertStatus `json:"status,omitempty"`
+	Assignee *string      `json:"assignee,omitempty"`
+}
+
+// AlertStore keeps raised alerts in memory. It hands out copies, so callers
+// can't race with updates.
+type AlertStore struct {
+	mu     sync.RWMutex
+	alerts []*Alert
+	byID   map[string]*Alert
+	seq    atomic.Int64
+}
+
+func NewAlertStore() *AlertStore {
+	return &AlertStore{byID: make(map[string]*Alert)}
+}
+
+// Add assigns the alert an ID and creation time and stores it.
+func (s *AlertStore) Add(alert *Alert) {
+	alert.ID = fmt.Sprintf("ALERT-%d", s.seq.Add(1))
+	if alert.CreatedAt == 0 {
+		alert.CreatedAt = time.Now().UnixNano()
+	}
+	alert.Status = Open
+	alert.UpdatedAt = alert.CreatedAt
+	s.mu.Lock()
+	s.alerts = append(s.alerts, alert)
+	s.byID[alert.ID] = alert
+	s.mu.Unlock()
+	log.Printf("surveillance alert %s %s (score %.2f) account=%s symbol=%s: %s",
+		alert.ID, alert.Type, alert.Score, alert.Account, alert.Symbol, alert.Description)
+}
+
+// List returns all alerts, oldest first.
+func (s *AlertStore) List() []Alert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]Alert, len(s.alerts))
+	for i, alert := range s.alerts {
+		list[i] = *alert
+	}
+	return list
+}
+
+func (s *AlertStore) Get(id string) (Alert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	alert, ok := s.byID[id]
+	if !ok {
+		return Alert{}, fmt.Errorf("alert not found")
+	}
+	return *alert, nil
+}
+
+// Update applies a review change. Resolved alerts can only be reopened.
+func (s *AlertStore) Update(id string, update AlertUpdate) (Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alert, ok := s.byID[id]
+	if !ok {
+		return Alert{}, fmt.Errorf("alert not found")
+	}
+
+	if update.Status != nil {
+		if alert.Status == Resolved && *update.Status == Acknowledged {
+			return Alert{}, fmt.Errorf("invalid transition: resolved alerts must be reopened first")
+		}
+		alert.Status = *update.Status
+	}
+	if update.Assignee != nil {
+		alert.Assignee = *update.Assignee
+	}
+	alert.UpdatedAt = time.Now().UnixNano()
+	return *alert, nil
+}
@@ -0,0 +1,240 @@
+package surveillance
+
+import (
+	"fmt"
+	"math"
+	"repello/internal/matching"
+	"repello/internal/models"
+	"sync"
+	"time"
+)
+
+// Config tunes the spoofing and layering heuristics.
+type Config struct {
+	// Orders at least this large are watched for spoofing.
+	LargeOrderQuantity int64
+	// An order resting at least this far (in basis points) from the opposite
+	// touch counts as far from the market.
+	FarTouchBps int64
+	// A cancel counts as the market approaching if the order's distance from
+	// the touch has shrunk to this fraction of what it was when placed.
+	ApproachRatio float64
+	// Window over which an account's cancels and placements are compared.
+	LayeringWindow time.Duration
+	// Cancels on the opposite side within the window needed before an
+	// execution is flagged as layering.
+	MinLayeringCancels int
+	// Minimum share of the account's orders in the window that were cancelled.
+	MinCancelRatio float64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		LargeOrderQuantity: 100,
+		FarTouchBps:        50,
+		ApproachRatio:      0.5,
+		LayeringWindow:     10 * time.Second,
+		MinLayeringCancels: 3,
+		MinCancelRatio:     0.8,
+	}
+}
+
+// watchedOrder is what the analyzer remembers about a resting order.
+type watchedOrder struct {
+	distanceBps float64 // from the opposite touch when placed, -1 if unknown
+	filled      bool
+}
+
+type cancelEvent struct {
+	orderID string
+	symbol  string
+	side    models.Side
+	at      time.Time
+}
+
+type accountActivity struct {
+	placed  []time.Time
+	cancels []cancelEvent
+	// last layering alert per symbol, to avoid re-alerting on every fill
+	lastLayering map[string]time.Time
+}
+
+// Analyzer watches engine events for spoofing and layering patterns and
+// raises scored alerts. Orders without an account are ignored.
+type Analyzer struct {
+	cfg      Config
+	alerts   *AlertStore
+	mu       sync.Mutex
+	orders   map[string]*watchedOrder
+	accounts map[string]*accountActivity
+	now      func() time.Time
+}
+
+var _ matching.EventListener = (*Analyzer)(nil)
+
+func NewAnalyzer(cfg Config, alerts *AlertStore) *Analyzer {
+	return &Analyzer{
+		cfg:      cfg,
+		alerts:   alerts,
+		orders:   make(map[string]*watchedOrder),
+		accounts: make(map[string]*accountActivity),
+		now:      time.Now,
+	}
+}
+
+// distanceBps is how far price is from the touch an order on side would
+// trade against, in basis points, or -1 if that side of the book is empty.
+func distanceBps(side models.Side, price int64, top matching.BookTop) float64 {
+	if side == models.Buy {
+		if top.BestAsk == 0 {
+			return -1
+		}
+		return float64(top.BestAsk-price) / float64(top.BestAsk) * 10000
+	}
+	if top.BestBid == 0 {
+		return -1
+	}
+	return float64(price-top.BestBid) / float64(top.BestBid) * 10000
+}
+
+func (a *Analyzer) activity(account string) *accountActivity {
+	act, ok := a.accounts[account]
+	if !ok {
+		act = &accountActivity{lastLayering: make(map[string]time.Time)}
+		a.accounts[account] = act
+	}
+	return act
+}
+
+// prune drops activity that has fallen out of the layering window.
+func (a *Analyzer) prune(act *accountActivity, now time.Time) {
+	cutoff := now.Add(-a.cfg.LayeringWindow)
+	i := 0
+	for i < len(act.placed) && act.placed[i].Before(cutoff) {
+		i++
+	}
+	act.placed = act.placed[i:]
+	i = 0
+	for i < len(act.cancels) && act.cancels[i].at.Before(cutoff) {
+		i++
+	}
+	act.cancels = act.cancels[i:]
+}
+
+func (a *Analyzer) OrderAccepted(order *models.Order, top matching.BookTop) {
+	if order.Account == "" || order.Type != models.Limit {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	act := a.activity(order.Account)
+	a.prune(act, now)
+	act.placed = append(act.placed, now)
+
+	if order.RemainingQuantity > 0 && order.Status != models.Cancelled {
+		a.orders[order.ID] = &watchedOrder{
+			distanceBps: distanceBps(order.Side, order.Price, top),
+			filled:      order.FilledQuantity > 0,
+		}
+	}
+}
+
+func (a *Analyzer) OrderCancelled(order *models.Order, top matching.BookTop) {
+	if order.Account == "" {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	act := a.activity(order.Account)
+	a.prune(act, now)
+	act.cancels = append(act.cancels, cancelEvent{orderID: order.ID, symbol: order.Symbol, side: order.Side, at: now})
+
+	watched, ok := a.orders[order.ID]
+	delete(a.orders, order.ID)
+	if !ok || watched.filled || order.OriginalQuantity < a.cfg.LargeOrderQuantity {
+		return
+	}
+	if watched.distanceBps < float64(a.cfg.FarTouchBps) {
+		return
+	}
+	current := distanceBps(order.Side, order.Price, top)
+	if current < 0 || current > watched.distanceBps*a.cfg.ApproachRatio {
+		return
+	}
+
+	// Bigger orders and closer approaches score higher
+	sizeFactor := math.Min(1, float64(order.OriginalQuantity)/float64(4*a.cfg.LargeOrderQuantity))
+	approach := 1 - math.Max(0, current)/watched.distanceBps
+	a.alerts.Add(&Alert{
+		Type:     Spoofing,
+		Score:    roundScore(0.5*sizeFactor + 0.5*approach),
+		Account:  order.Account,
+		Symbol:   order.Symbol,
+		OrderIDs: []string{order.ID},
+		Description: fmt.Sprintf("%s order for %d placed %.0f bps from the touch was cancelled unfilled at %.0f bps as the market approached",
+			order.Side, order.OriginalQuantity, watched.distanceBps, current),
+	})
+}
+
+func (a *Analyzer) TradeExecuted(trade *models.Trade, taker, maker *models.Order) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	for _, order := range []*models.Order{taker, maker} {
+		if watched, ok := a.orders[order.ID]; ok {
+			watched.filled = true
+			if order.RemainingQuantity == 0 {
+				delete(a.orders, order.ID)
+			}
+		}
+		if order.Account != "" {
+			a.checkLayering(order, trade, now)
+		}
+	}
+}
+
+// checkLayering flags an execution preceded by a burst of cancels on the
+// other side of the same book by the same account.
+func (a *Analyzer) checkLayering(order *models.Order, trade *models.Trade, now time.Time) {
+	act := a.activity(order.Account)
+	a.prune(act, now)
+	if last, ok := act.lastLayering[order.Symbol]; ok && now.Sub(last) < a.cfg.LayeringWindow {
+		return
+	}
+
+	var orderIDs []string
+	for _, c := range act.cancels {
+		if c.symbol == order.Symbol && c.side != order.Side {
+			orderIDs = append(orderIDs, c.orderID)
+		}
+	}
+	if len(orderIDs) < a.cfg.MinLayeringCancels || len(act.placed) == 0 {
+		return
+	}
+	cancelRatio := math.Min(1, float64(len(act.cancels))/float64(len(act.placed)))
+	if cancelRatio < a.cfg.MinCancelRatio {
+		return
+	}
+
+	act.lastLayering[order.Symbol] = now
+	burst := math.Min(1, float64(len(orderIDs))/float64(2*a.cfg.MinLayeringCancels))
+	a.alerts.Add(&Alert{
+		Type:     Layering,
+		Score:    roundScore(cancelRatio * burst),
+		Account:  order.Account,
+		Symbol:   order.Symbol,
+		OrderIDs: append(orderIDs, order.ID),
+		TradeIDs: []string{trade.ID},
+		Description: fmt.Sprintf("%s execution followed %d cancelled orders on the other side within %s (cancel ratio %.2f)",
+			order.Side, len(orderIDs), a.cfg.LayeringWindow, cancelRatio),
+	})
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*100) / 100
+}
@@ -0,0 +1,101 @@
+package surveillance
+
+import (
+	"fmt"
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newOrder(id, account string, side models.Side, price, quantity int64) *models.Order {
+	order := models.NewOrder(id, "BTCUSD", side, models.Limit, price, quantity)
+	order.Account = account
+	return order
+}
+
+func TestAnalyzer_SpoofingOnApproach(t *testing.T) {
+	engine := matching.NewEngine(metrics.NewMetrics())
+	alerts := NewAlertStore()
+	engine.AddEventListener(NewAnalyzer(DefaultConfig(), alerts))
+
+	engine.ProcessOrder(newOrder("ask1", "mm", models.Sell, 1000, 10))
+	// Large bid 2% below the ask
+	engine.ProcessOrder(newOrder("spoof", "acct1", models.Buy, 980, 500))
+
+	// The market comes down towards the bid, then it is pulled
+	engine.ProcessOrder(newOrder("ask2", "mm", models.Sell, 982, 10))
+	engine.CancelOrder("spoof")
+
+	list := alerts.List()
+	assert.Equal(t, 1, len(list))
+	assert.Equal(t, Spoofing, list[0].Type)
+	assert.Equal(t, "acct1", list[0].Account)
+	assert.Equal(t, []string{"spoof"}, list[0].OrderIDs)
+	assert.Greater(t, list[0].Score, 0.5)
+}
+
+func TestAnalyzer_NoSpoofingWithoutApproach(t *testing.T) {
+	engine := matching.NewEngine(metrics.NewMetrics())
+	alerts := NewAlertStore()
+	engine.AddEventListener(NewAnalyzer(DefaultConfig(), alerts))
+
+	engine.ProcessOrder(newOrder("ask1", "mm", models.Sell, 1000, 10))
+	engine.ProcessOrder(newOrder("big", "acct1", models.Buy, 980, 500))
+	engine.CancelOrder("big")
+
+	assert.Empty(t, alerts.List())
+}
+
+func TestAnalyzer_Layering(t *testing.T) {
+	engine := matching.NewEngine(metrics.NewMetrics())
+	alerts := NewAlertStore()
+	engine.AddEventListener(NewAnalyzer(DefaultConfig(), alerts))
+
+	engine.ProcessOrder(newOrder("bid", "mm", models.Buy, 990, 10))
+
+	// Layer asks, pull them all, then buy
+	for i := 0; i < 4; i++ {
+		id := fmt.Sprintf("layer%d", i)
+		engine.ProcessOrder(newOrder(id, "acct1", models.Sell, int64(1001+i), 50))
+		engine.CancelOrder(id)
+	}
+	engine.ProcessOrder(newOrder("ask", "mm", models.Sell, 1000, 5))
+	engine.ProcessOrder(newOrder("take", "acct1", models.Buy, 1000, 5))
+
+	list := alerts.List()
+	assert.Equal(t, 1, len(list))
+	assert.Equal(t, Layering, list[0].Type)
+	assert.Contains(t, list[0].OrderIDs, "take")
+	assert.Equal(t, 1, len(list[0].TradeIDs))
+}
+
+func TestAlertStore_Lifecycle(t *testing.T) {
+	store := NewAlertStore()
+	store.Add(&Alert{Type: Spoofing, Account: "acct1", Symbol: "BTCUSD"})
+	id := store.List()[0].ID
+	assert.Equal(t, Open, store.List()[0].Status)
+
+	acknowledged, assignee := Acknowledged, "alice"
+	alert, err := store.Update(id, AlertUpdate{Status: &acknowledged, Assignee: &assignee})
+	assert.NoError(t, err)
+	assert.Equal(t, Acknowledged, alert.Status)
+	assert.Equal(t, "alice", alert.Assignee)
+
+	resolved := Resolved
+	_, err = store.Update(id, AlertUpdate{Status: &resolved})
+	assert.NoError(t, err)
+
+	// Resolved alerts go back through OPEN
+	_, err = store.Update(id, AlertUpdate{Status: &acknowledged})
+	assert.Error(t, err)
+	open := Open
+	alert, err = store.Update(id, AlertUpdate{Status: &open})
+	assert.NoError(t, err)
+	assert.Equal(t, Open, alert.Status)
+
+	_, err = store.Update("missing", AlertUpdate{})
+	assert.EqualError(t, err, "alert not found")
+}
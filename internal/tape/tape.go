@@ -0,0 +1,132 @@
+// Package tape keeps each symbol's recent trades for market data consumers,
+// either one entry per fill or compressed so a sweep through several price
+// levels is reported as a single entry.
+package tape
+
+import (
+	"repello/internal/matching"
+	"repello/internal/models"
+	"sync"
+)
+
+// DefaultRetain is how many fills a symbol's tape keeps by default.
+const DefaultRetain = 1000
+
+// Entry is one line of the tape. Unaggregated, it is a single fill. In an
+// aggregated tape it is a run of consecutive fills by the same aggressor
+// order: Quantity is their sum, Price and Timestamp are the last fill's, and
+// Count is how many were combined. Aggregated is set when Count is more than
+// one.
+type Entry struct {
+	TradeID       string      `json:"trade_id"` // last fill's
+	Symbol        string      `json:"symbol"`
+	TakerOrderID  string      `json:"taker_order_id"`
+	AggressorSide models.Side `json:"aggressor_side"`
+	Price         int64       `json:"price"`
+	Quantity      int64       `json:"quantity"`
+	Count         int         `json:"count"`
+	Timestamp     int64       `json:"timestamp"` // UnixNano
+	Aggregated    bool        `json:"aggregated"`
+}
+
+// Tape is a matching.EventListener that records each symbol's last fills.
+type Tape struct {
+	retain int
+	mu     sync.Mutex
+	fills  map[string]*ring
+}
+
+// ring holds a symbol's last fills. Once full, each fill overwrites the
+// oldest, at head.
+type ring struct {
+	entries []Entry
+	head    int
+}
+
+func (r *ring) add(entry Entry, size int) {
+	if len(r.entries) < size {
+		r.entries = append(r.entries, entry)
+		return
+	}
+	r.entries[r.head] = entry
+	r.head = (r.head + 1) % size
+}
+
+// appendTo appends the fills to out, oldest first.
+func (r *ring) appendTo(out []Entry) []Entry {
+	out = append(out, r.entries[r.head:]...)
+	return append(out, r.entries[:r.head]...)
+}
+
+var _ matching.EventListener = (*Tape)(nil)
+
+// New creates a tape keeping the last retain fills of each symbol.
+func New(retain int) *Tape {
+	return &Tape{
+		retain: retain,
+		fills:  make(map[string]*ring),
+	}
+}
+
+func (t *Tape) OrderAccepted(*models.Order, matching.BookTop) {}
+
+func (t *Tape) OrderCancelled(*models.Order, matching.BookTop) {}
+
+func (t *Tape) TradeExecuted(trade *models.Trade, taker, maker *models.Order) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fills, ok := t.fills[trade.Symbol]
+	if !ok {
+		fills = &ring{}
+		t.fills[trade.Symbol] = fills
+	}
+	fills.add(Entry{
+		TradeID:       trade.ID,
+		Symbol:        trade.Symbol,
+		TakerOrderID:  trade.TakerOrderID,
+		AggressorSide: trade.AggressorSide,
+		Price:         trade.Price,
+		Quantity:      trade.Quantity,
+		Count:         1,
+		Timestamp:     trade.Timestamp,
+	}, t.retain)
+}
+
+// Recent returns a symbol's last limit entries, oldest first; limit 0 returns
+// every one kept. If aggregated, consecutive fills by the same aggressor order
+// are combined first. The engine publishes a taker's fills before anything
+// else happens in its book, so they are always consecutive; a run that
+// started before the oldest fill kept is combined from what is left.
+func (t *Tape) Recent(symbol string, limit int, aggregated bool) []Entry {
+	t.mu.Lock()
+	var fills []Entry
+	if r, ok := t.fills[symbol]; ok {
+		fills = r.appendTo(make([]Entry, 0, len(r.entries)))
+	}
+	t.mu.Unlock()
+
+	if !aggregated {
+		if limit > 0 && len(fills) > limit {
+			fills = fills[len(fills)-limit:]
+		}
+		return fills
+	}
+	entries := make([]Entry, 0, len(fills))
+	for _, fill := range fills {
+		if n := len(entries); n > 0 && entries[n-1].TakerOrderID == fill.TakerOrderID {
+			last := &entries[n-1]
+			last.TradeID = fill.TradeID
+			last.Price = fill.Price
+			last.Quantity += fill.Quantity
+			last.Count++
+			last.Timestamp = fill.Timestamp
+			last.Aggregated = true
+			continue
+		}
+		entries = append(entries, fill)
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries
+}
@@ -0,0 +1,62 @@
+package tape
+
+import (
+	"repello/internal/matching"
+	"repello/internal/metrics"
+	"repello/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTape(t *testing.T) {
+	engine := matching.NewEngine(metrics.NewMetrics())
+	tape := New(10)
+	engine.AddEventListener(tape)
+
+	engine.ProcessOrder(models.NewOrder("s1", "BTCUSD", models.Sell, models.Limit, 100, 2))
+	engine.ProcessOrder(models.NewOrder("s2", "BTCUSD", models.Sell, models.Limit, 101, 3))
+	engine.ProcessOrder(models.NewOrder("s3", "BTCUSD", models.Sell, models.Limit, 102, 5))
+	engine.ProcessOrder(models.NewOrder("b1", "BTCUSD", models.Buy, models.Limit, 102, 7)) // sweeps three levels
+	engine.ProcessOrder(models.NewOrder("b2", "BTCUSD", models.Buy, models.Limit, 102, 1))
+
+	fills := tape.Recent("BTCUSD", 0, false)
+	if assert.Len(t, fills, 4) {
+		assert.Equal(t, int64(100), fills[0].Price)
+		assert.Equal(t, 1, fills[0].Count)
+		assert.False(t, fills[0].Aggregated)
+	}
+
+	entries := tape.Recent("BTCUSD", 0, true)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "b1", entries[0].TakerOrderID)
+		assert.Equal(t, models.Buy, entries[0].AggressorSide)
+		assert.Equal(t, int64(102), entries[0].Price)
+		assert.Equal(t, int64(7), entries[0].Quantity)
+		assert.Equal(t, 3, entries[0].Count)
+		assert.Equal(t, fills[2].TradeID, entries[0].TradeID)
+		assert.True(t, entries[0].Aggregated)
+
+		assert.Equal(t, "b2", entries[1].TakerOrderID)
+		assert.Equal(t, int64(1), entries[1].Quantity)
+		assert.False(t, entries[1].Aggregated)
+	}
+
+	latest := tape.Recent("BTCUSD", 1, true)
+	if assert.Len(t, latest, 1) {
+		assert.Equal(t, "b2", latest[0].TakerOrderID)
+	}
+	assert.Empty(t, tape.Recent("ETHUSD", 0, true))
+
+	// Only the last fills are kept
+	tape = New(2)
+	engine.AddEventListener(tape)
+	engine.ProcessOrder(models.NewOrder("s4", "BTCUSD", models.Sell, models.Limit, 103, 3))
+	engine.ProcessOrder(models.NewOrder("b3", "BTCUSD", models.Buy, models.Limit, 103, 4))
+	engine.ProcessOrder(models.NewOrder("b4", "BTCUSD", models.Buy, models.Limit, 103, 1))
+	fills = tape.Recent("BTCUSD", 0, false)
+	if assert.Len(t, fills, 2) {
+		assert.Equal(t, "b3", fills[0].TakerOrderID)
+		assert.Equal(t, "b4", fills[1].TakerOrderID)
+	}
+}